@@ -0,0 +1,115 @@
+package chunker
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// ConcurrentAssembler writes chunks to an output file from many goroutines at once.
+// Each chunk is verified and written at its own offset with WriteAt, which is safe
+// for concurrent use at distinct offsets, so parallel reconstruction workers don't
+// funnel through a single consumer. Call Close to check completeness.
+type ConcurrentAssembler struct {
+	output      *os.File
+	totalChunks int
+	chunkSize   int
+	hashes      []string // optional expected plaintext hashes, one per chunk
+
+	mu       sync.Mutex // guards received, count and closed
+	received []bool
+	count    int
+	closed   bool
+}
+
+// NewConcurrentAssembler creates (or truncates) outputPath for totalChunks chunks.
+// If hashes is non-nil it must hold one expected hash per chunk and takes precedence
+// over Chunk.Hash when verifying. Chunks are placed at the default ChunkSize; see
+// NewConcurrentAssemblerWithConfig.
+func NewConcurrentAssembler(outputPath string, totalChunks int, hashes []string) (*ConcurrentAssembler, error) {
+	return NewConcurrentAssemblerWithConfig(outputPath, totalChunks, hashes, DefaultConfig())
+}
+
+// NewConcurrentAssemblerWithConfig is NewConcurrentAssembler for chunks of
+// cfg.ChunkSize, the size the file was chunked at
+func NewConcurrentAssemblerWithConfig(outputPath string, totalChunks int, hashes []string, cfg Config) (*ConcurrentAssembler, error) {
+	if err := checkChunkCount(totalChunks); err != nil {
+		return nil, err
+	}
+	if hashes != nil && len(hashes) != totalChunks {
+		return nil, fmt.Errorf("expected %d chunk hashes, got %d", totalChunks, len(hashes))
+	}
+
+	output, err := os.Create(outputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output file: %w", err)
+	}
+
+	return &ConcurrentAssembler{
+		output:      output,
+		totalChunks: totalChunks,
+		chunkSize:   cfg.chunkSize(),
+		hashes:      hashes,
+		received:    make([]bool, totalChunks),
+	}, nil
+}
+
+// WriteChunk verifies a chunk and writes it at its offset. Safe for concurrent use.
+// Duplicate chunks are ignored.
+func (a *ConcurrentAssembler) WriteChunk(chunk Chunk) error {
+	if chunk.Index < 0 || chunk.Index >= a.totalChunks {
+		return fmt.Errorf("chunk index %d out of bounds (max %d)", chunk.Index, a.totalChunks-1)
+	}
+
+	expected := chunk.Hash
+	if a.hashes != nil {
+		expected = a.hashes[chunk.Index]
+	}
+	if !VerifyChunk(chunk.Data, expected) {
+		return fmt.Errorf("chunk %d failed hash verification", chunk.Index)
+	}
+
+	// Claim the index so concurrent duplicates don't both write
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return fmt.Errorf("assembler is closed")
+	}
+	if a.received[chunk.Index] {
+		a.mu.Unlock()
+		return nil
+	}
+	a.received[chunk.Index] = true
+	a.mu.Unlock()
+
+	offset := int64(chunk.Index) * int64(a.chunkSize)
+	if _, err := a.output.WriteAt(chunk.Data, offset); err != nil {
+		// Release the claim so a retry can write it
+		a.mu.Lock()
+		a.received[chunk.Index] = false
+		a.mu.Unlock()
+		return fmt.Errorf("failed to write chunk %d: %w", chunk.Index, err)
+	}
+
+	a.mu.Lock()
+	a.count++
+	a.mu.Unlock()
+	return nil
+}
+
+// Close closes the output file and reports an error if any chunk is missing.
+// Callers must not call Close until all WriteChunk calls have returned.
+func (a *ConcurrentAssembler) Close() error {
+	a.mu.Lock()
+	a.closed = true
+	count := a.count
+	a.mu.Unlock()
+
+	if err := a.output.Close(); err != nil {
+		return fmt.Errorf("failed to close output file: %w", err)
+	}
+	if count != a.totalChunks {
+		return fmt.Errorf("incomplete file: expected %d chunks, got %d", a.totalChunks, count)
+	}
+	return nil
+}
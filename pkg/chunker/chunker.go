@@ -2,6 +2,7 @@ package chunker
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
@@ -16,6 +17,44 @@ const DataShards = 4          					// 4 data shards per chunk
 const ParityShards = 2        					// 2 parity shards per chunk
 const TotalShards = DataShards + ParityShards 	// 6 total shards
 
+// MinChunkSize is the smallest chunk size RecommendChunkSize returns
+const MinChunkSize = 4 * 1024
+
+// RecommendChunkSize picks a chunk size for a file of fileSize bytes.
+//
+// Every chunk costs a fixed amount besides its data: the AEAD nonce and tag,
+// TotalShards shard entries in the manifest and as many farmer requests. When
+// encrypted chunks are padded to a uniform size, a chunk larger than the file
+// also stores padding. Files of ChunkSize or more use ChunkSize: they span
+// several chunks either way, the fixed cost is far below 1% of a 1MB chunk, and
+// fixed boundaries keep chunk hashes stable across versions of a file, which
+// dedup relies on. A smaller file gets one chunk, sized as the smallest power
+// of two from MinChunkSize up that holds it, so padding at most doubles it and
+// reveals only its size class.
+func RecommendChunkSize(fileSize int64) int {
+	size := MinChunkSize
+	for size < ChunkSize && int64(size) < fileSize {
+		size *= 2
+	}
+	return size
+}
+
+// MaxChunks caps the chunk count the assemblers allocate tracking state for
+// (4TB at the default chunk size). Counts usually come from a manifest, which
+// may be hostile, so larger ones are refused instead of allocated.
+const MaxChunks = 1 << 22
+
+// checkChunkCount rejects a chunk count an assembler shouldn't allocate for
+func checkChunkCount(totalChunks int) error {
+	if totalChunks < 0 {
+		return fmt.Errorf("invalid chunk count %d", totalChunks)
+	}
+	if totalChunks > MaxChunks {
+		return fmt.Errorf("chunk count %d exceeds MaxChunks (%d)", totalChunks, MaxChunks)
+	}
+	return nil
+}
+
 // Chunk represents a file chunk struct with its metadata
 type Chunk struct {
 	Index int    `json:"index"` // chunk index
@@ -39,12 +78,63 @@ type Shard struct {
     Size       int    `json:"size"`        // shard size in bytes
 }
 
+// Config controls how a file is split into chunks
+type Config struct {
+	ChunkSize int // bytes per chunk (0 = default ChunkSize)
+
+	// Buffer is how many chunks the producer may have read and hashed ahead of
+	// the consumer before it blocks (0 = DefaultStreamBuffer). A deeper buffer
+	// absorbs a bursty consumer at the cost of memory.
+	Buffer int
+
+	// PrefetchBytes sizes the buffer by memory instead: the producer keeps
+	// reading and hashing while up to this many bytes of chunks wait for a slow
+	// consumer. Overrides a smaller Buffer (0 = off). Chunks are always emitted
+	// in index order.
+	PrefetchBytes int64
+}
+
+// DefaultStreamBuffer is the number of chunks streamed ahead of the consumer by default
+const DefaultStreamBuffer = 4
+
+// maxStreamBuffer caps the channel depth; its slots are allocated up front
+const maxStreamBuffer = 4096
+
+// DefaultConfig returns the standard 1MB chunking configuration
+func DefaultConfig() Config {
+	return Config{ChunkSize: ChunkSize}
+}
+
+// chunkSize returns the configured chunk size, falling back to the default
+func (c Config) chunkSize() int {
+	if c.ChunkSize <= 0 {
+		return ChunkSize
+	}
+	return c.ChunkSize
+}
+
+// bufferDepth returns the channel depth for Buffer and PrefetchBytes
+func (c Config) bufferDepth() int {
+	depth := c.Buffer
+	if depth <= 0 {
+		depth = DefaultStreamBuffer
+	}
+	if c.PrefetchBytes > 0 {
+		depth = max(depth, int(min(c.PrefetchBytes/int64(c.chunkSize()), maxStreamBuffer)))
+	}
+	return min(depth, maxStreamBuffer)
+}
+
 // StreamChunkFile reads a file and streams chunks to a returned channel.
 // This allows processing huge files without loading them entirely into memory.
 func StreamChunkFile(filePath string) <-chan ChunkResult {
+	return StreamChunkFileWithConfig(filePath, DefaultConfig())
+}
 
+// StreamChunkFileWithConfig is StreamChunkFile with an explicit chunking config
+func StreamChunkFileWithConfig(filePath string, cfg Config) <-chan ChunkResult {
 	// Create a buffered channel to keep the pipeline busy
-	out := make(chan ChunkResult, 4) // buffer of 4 chunks
+	out := make(chan ChunkResult, cfg.bufferDepth())
 
 	go func() {
 		defer close(out)
@@ -57,72 +147,125 @@ func StreamChunkFile(filePath string) <-chan ChunkResult {
 		}
 		defer file.Close()
 
-		index := 0                        // index to track chunk number
-		buffer := make([]byte, ChunkSize) // a reusable buffer allocation of 1MB
+		streamChunks(context.Background(), file, cfg.chunkSize(), out)
+	}()
+	// return all chunks
+	return out
+}
+
+// StreamChunkReader is StreamChunkFile over an arbitrary reader, e.g. several files
+// concatenated. Once ctx is done the producer stops and closes the channel without
+// sending an error, so callers should check ctx.Err() after the channel closes;
+// cancelling ctx is also how a consumer that stops early releases the producer.
+func StreamChunkReader(ctx context.Context, r io.Reader, cfg Config) <-chan ChunkResult {
+	out := make(chan ChunkResult, cfg.bufferDepth())
+
+	go func() {
+		defer close(out)
+		streamChunks(ctx, r, cfg.chunkSize(), out)
+	}()
+	return out
+}
+
+// streamChunks reads r in chunkSize pieces and sends them to out until EOF,
+// a read error, or ctx being done
+func streamChunks(ctx context.Context, r io.Reader, chunkSize int, out chan<- ChunkResult) {
+	send := func(res ChunkResult) bool {
+		select {
+		case out <- res:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	index := 0                        // index to track chunk number
+	buffer := make([]byte, chunkSize) // a reusable buffer allocation of one chunk
 
 	// read file in a loop
-		for {
-			n, err := io.ReadFull(file, buffer)
+	for {
+		n, err := io.ReadFull(r, buffer)
 
-			if err == io.EOF {
-				break // Exact EOF, we are done
-			}
-			if err == io.ErrUnexpectedEOF {
-				// This is the last chunk (partial size). 
-				// It's not a real error for us, just the end of file.
-				err = nil
-			}
-			if err != nil {
-				out <- ChunkResult{Err: fmt.Errorf("failed to read chunk %d: %w", index, err)}
-				return
-			}
+		if err == io.EOF {
+			break // Exact EOF, we are done
+		}
+		if err == io.ErrUnexpectedEOF {
+			// This is the last chunk (partial size). 
+			// It's not a real error for us, just the end of file.
+			err = nil
+		}
+		if err != nil {
+			send(ChunkResult{Err: fmt.Errorf("failed to read chunk %d: %w", index, err)})
+			return
+		}
 
-			// Copy data to new slice (don't reuse buffer)
-			chunkData := make([]byte, n)
-			copy(chunkData, buffer[:n])
+		// Copy data to new slice (don't reuse buffer)
+		chunkData := make([]byte, n)
+		copy(chunkData, buffer[:n])
 
-			hash := sha256.Sum256(chunkData) // Calculate SHA256 hash of plaintext
+		hash := sha256.Sum256(chunkData) // Calculate SHA256 hash of plaintext
 
-			// create chunk metadata
-			chunk := Chunk{
-				Index: index,
-				Data:  chunkData,
-				Hash:  hex.EncodeToString(hash[:]),
-				Size:  n,
-			}
+		// create chunk metadata
+		chunk := Chunk{
+			Index: index,
+			Data:  chunkData,
+			Hash:  hex.EncodeToString(hash[:]),
+			Size:  n,
+		}
 
-			// Send to channel
-			out <- ChunkResult{Chunk: chunk, Err: nil}
-			index++
+		// Send to channel
+		if !send(ChunkResult{Chunk: chunk, Err: nil}) {
+			return
+		}
+		index++
 
-			// If we hit the partial chunk case (ErrUnexpectedEOF previously), we break now.
-			if n < ChunkSize {
-				break
-			}
+		// If we hit the partial chunk case (ErrUnexpectedEOF previously), we break now.
+		if n < chunkSize {
+			break
 		}
-	}()
-	// return all chunks
-	return out
+	}
+}
+
+// ChunkHashesForFile re-chunks a local file and returns the ordered chunk hashes.
+// Hashing is identical to StreamChunkFile, so the result can be cross-checked
+// against a manifest's Chunks without sharding or uploading anything.
+func ChunkHashesForFile(path string, cfg Config) ([]string, error) {
+	var hashes []string
+	for result := range StreamChunkFileWithConfig(path, cfg) {
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		hashes = append(hashes, result.Chunk.Hash)
+	}
+	return hashes, nil
 }
 
 // ShardChunk applies erasure coding to a single encrypted chunk
 // Returns 6 shards: 4 data + 2 parity (any 4 can reconstruct)
 // takes Chunk metadata and encrypted chunk data as input and returns slice of Shard structs
 func ShardChunk(chunk Chunk, encryptedData []byte) ([]Shard, error) {
+	return ShardChunkWithConfig(chunk, encryptedData, DataShards, ParityShards)
+}
+
+// ShardChunkWithConfig is ShardChunk with an explicit erasure config,
+// yielding dataShards+parityShards shards indexed from 0
+func ShardChunkWithConfig(chunk Chunk, encryptedData []byte, dataShards, parityShards int) ([]Shard, error) {
 	
 	// SAFETY CHECK: Ensure data matches metadata
 	if len(encryptedData) != chunk.Size {
 		return nil, fmt.Errorf("data size mismatch: expected %d, got %d", chunk.Size, len(encryptedData))
 	}
 
-    // Create Reed-Solomon encoder (4 data shards, 2 parity shards)
-    enc, err := reedsolomon.New(DataShards, ParityShards)
+    // Create Reed-Solomon encoder
+    enc, err := reedsolomon.New(dataShards, parityShards)
     if err != nil {
         return nil, fmt.Errorf("failed to create encoder: %w", err)
     }
 
-    // Split encrypted data into 4 equal parts
-    shards, err := enc.Split(encryptedData) // returns [][]byte with length TotalShards
+    // Split encrypted data into dataShards equal parts. Split would lay shards out
+    // in any spare capacity of the slice, scribbling over the caller's buffer, so
+    // cap it at its length.
+    shards, err := enc.Split(encryptedData[:len(encryptedData):len(encryptedData)]) // returns [][]byte with length dataShards+parityShards
     if err != nil {
         return nil, fmt.Errorf("failed to split data: %w", err)
     }
@@ -136,7 +279,7 @@ func ShardChunk(chunk Chunk, encryptedData []byte) ([]Shard, error) {
     // Create shard metadata
     var shardList []Shard
 	// Calculate hash for each shard and create Shard struct
-    for i := 0; i < TotalShards; i++ {
+    for i := range shards {
         shardHash := sha256.Sum256(shards[i]) // returns [32]byte
         
         shard := Shard{
@@ -152,79 +295,489 @@ func ShardChunk(chunk Chunk, encryptedData []byte) ([]Shard, error) {
     return shardList, nil
 }
 
-// ReconstructChunk rebuilds original encrypted chunk from any 4+ shards
+// ShardChunkPadded zero-pads the encrypted chunk to paddedSize before sharding,
+// so every chunk of a blob yields identically sized shards. Reconstruct with
+// ReconstructOptions.PaddedSize set and the real size as dataSize to strip the padding.
+func ShardChunkPadded(chunk Chunk, encryptedData []byte, paddedSize int) ([]Shard, error) {
+	if len(encryptedData) != chunk.Size {
+		return nil, fmt.Errorf("data size mismatch: expected %d, got %d", chunk.Size, len(encryptedData))
+	}
+	if paddedSize < chunk.Size {
+		return nil, fmt.Errorf("padded size %d smaller than chunk size %d", paddedSize, chunk.Size)
+	}
+
+	padded := make([]byte, paddedSize) // zero-filled tail is the padding
+	copy(padded, encryptedData)
+
+	paddedChunk := chunk
+	paddedChunk.Size = paddedSize
+	return ShardChunk(paddedChunk, padded)
+}
+
+// ShardSizeError reports a shard whose length doesn't match the padded shard
+// size expected for its chunk (e.g. a farmer returned a truncated shard)
+type ShardSizeError struct {
+	ChunkIndex int // chunk the shard belongs to
+	ShardIndex int // offending shard
+	Size       int // actual shard length
+	Expected   int // expected padded shard length
+}
+
+func (e *ShardSizeError) Error() string {
+	return fmt.Sprintf("chunk %d shard %d has size %d, expected %d", e.ChunkIndex, e.ShardIndex, e.Size, e.Expected)
+}
+
+// ErasureConfigError reports shards whose shape doesn't fit the erasure config used to
+// reconstruct them, e.g. a 4+2 shard set reconstructed with 6+3 parameters.
+// Unwraps to the ShardSizeError of the first shard.
+type ErasureConfigError struct {
+	DataShards   int // data shards the caller reconstructs with
+	ParityShards int // parity shards the caller reconstructs with
+	ShardSize    int // common length of the supplied shards
+	Expected     int // shard length the config implies
+	sizeErr      *ShardSizeError
+}
+
+func (e *ErasureConfigError) Error() string {
+	return fmt.Sprintf("erasure config mismatch: shards are %d bytes but %d+%d implies %d (wrong data/parity counts or data size)",
+		e.ShardSize, e.DataShards, e.ParityShards, e.Expected)
+}
+
+func (e *ErasureConfigError) Unwrap() error {
+	return e.sizeErr
+}
+
+// ExpectedShardSize returns the padded shard length produced by ShardChunk
+// for dataSize bytes split across dataShards shards
+func ExpectedShardSize(dataSize, dataShards int) int {
+	return (dataSize + dataShards - 1) / dataShards
+}
+
+// MaxTotalShards is the most shards per chunk a Reed-Solomon code over GF(2^8) can produce
+const MaxTotalShards = 256
+
+// ValidateErasureConfig checks that data+parity is a usable erasure config: at
+// least one data and one parity shard (without parity any lost shard loses the
+// chunk) and no more than MaxTotalShards in all
+func ValidateErasureConfig(data, parity int) error {
+	if data < 1 {
+		return fmt.Errorf("need at least 1 data shard, got %d", data)
+	}
+	if parity < 1 {
+		return fmt.Errorf("need at least 1 parity shard, got %d: a single lost shard would lose the chunk", parity)
+	}
+	if data+parity > MaxTotalShards {
+		return fmt.Errorf("%d+%d erasure config exceeds %d shards per chunk", data, parity, MaxTotalShards)
+	}
+	return nil
+}
+
+// ErasureProperties describes the trade-off of a data+parity config: each byte
+// stored costs storageOverhead bytes across farmers ((data+parity)/data, 1.5 for
+// 4+2), and any failuresTolerated shards of a chunk (parity) can be lost without
+// losing the chunk. Returns zeros for a config ValidateErasureConfig rejects.
+func ErasureProperties(data, parity int) (storageOverhead float64, failuresTolerated int) {
+	if ValidateErasureConfig(data, parity) != nil {
+		return 0, 0
+	}
+	return float64(data+parity) / float64(data), parity
+}
+
+// ReconstructOptions tunes chunk reconstruction
+type ReconstructOptions struct {
+	// SkipParityVerify skips reconstruction and the parity re-check when every
+	// data shard is present (they are already hash-verified individually).
+	// This trades a belt-and-suspenders check for throughput: integrity then
+	// rests on the per-shard hashes plus the final whole-file hash.
+	// Off by default; ignored when any data shard is missing.
+	SkipParityVerify bool
+
+	// PaddedSize is the length the data was padded to before sharding
+	// (see ShardChunkPadded). Shards are sized from it and the output is
+	// trimmed back to dataSize. 0 means no padding.
+	PaddedSize int
+
+	// DataShards and ParityShards describe the erasure config the shards were
+	// produced with, normally taken from the manifest. 0 means package defaults.
+	DataShards   int
+	ParityShards int
+
+	// TotalShards is the shard index space declared by the manifest. When set,
+	// parity defaults to TotalShards-DataShards and shard indices are validated
+	// against it instead of the compile-time TotalShards. 0 means unset.
+	TotalShards int
+}
+
+// erasure returns the configured data/parity counts, falling back to the package defaults
+func (o ReconstructOptions) erasure() (int, int, error) {
+	data, parity := o.DataShards, o.ParityShards
+	if data <= 0 {
+		data = DataShards
+	}
+	if o.TotalShards > 0 {
+		if parity <= 0 {
+			parity = o.TotalShards - data
+		}
+		if data+parity != o.TotalShards {
+			return 0, 0, fmt.Errorf("inconsistent erasure config: %d data + %d parity shards != %d total", data, parity, o.TotalShards)
+		}
+	}
+	if parity <= 0 {
+		parity = ParityShards
+	}
+	return data, parity, nil
+}
+
+// ReconstructChunk rebuilds original encrypted chunk from any 4+ shards.
+// Redundant copies of a shard index are tolerated: identical copies count once.
+// Shards failing hash verification are ignored as long as 4 others verify.
 func ReconstructChunk(shards []Shard, dataSize int) ([]byte, error) {
+	return ReconstructChunkWithOptions(shards, dataSize, ReconstructOptions{})
+}
 
-	if len(shards) < DataShards {
-		return nil, fmt.Errorf("need at least %d shards, got %d", DataShards, len(shards))
+// ReconstructChunkWithOptions is ReconstructChunk with tunable options
+func ReconstructChunkWithOptions(shards []Shard, dataSize int, opts ReconstructOptions) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(dataSize)
+	if err := reconstructChunkTo(&buf, shards, dataSize, opts); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ReconstructChunkWithAvailable rebuilds a chunk from only the shards whose
+// indices are listed in available, ignoring the rest of allShards, to simulate a
+// given set of farmers being reachable. data and parity give the erasure config
+// (0 means package defaults). Fails if fewer than data distinct indices are
+// listed, or a listed index has no shard in allShards.
+func ReconstructChunkWithAvailable(allShards []Shard, available []int, dataSize, data, parity int) ([]byte, error) {
+	opts := ReconstructOptions{DataShards: data, ParityShards: parity}
+	dataShards, parityShards, err := opts.erasure()
+	if err != nil {
+		return nil, err
+	}
+
+	listed := make(map[int]bool, len(available))
+	for _, index := range available {
+		if index < 0 || index >= dataShards+parityShards {
+			return nil, fmt.Errorf("available shard index %d out of range [0, %d)", index, dataShards+parityShards)
+		}
+		listed[index] = true
+	}
+	if len(listed) < dataShards {
+		return nil, fmt.Errorf("need at least %d available shard indices, got %d", dataShards, len(listed))
+	}
+
+	shards := make([]Shard, 0, len(listed))
+	supplied := make(map[int]bool, len(listed))
+	for _, shard := range allShards {
+		if listed[shard.ShardIndex] {
+			shards = append(shards, shard)
+			supplied[shard.ShardIndex] = true
+		}
+	}
+	for index := range listed {
+		if !supplied[index] {
+			return nil, fmt.Errorf("shard %d listed as available but not supplied", index)
+		}
+	}
+	return ReconstructChunkWithOptions(shards, dataSize, opts)
+}
+
+// ReconstructChunkTo rebuilds a chunk like ReconstructChunk but streams the joined
+// data straight to w instead of buffering the whole chunk, for large chunks or when
+// the output goes directly into a decrypt stream or file. data and parity give the
+// erasure config (0 means package defaults). All checks happen before the first
+// write; only an error from w itself can leave w with a partial chunk.
+func ReconstructChunkTo(w io.Writer, shards []Shard, dataSize, data, parity int) error {
+	return reconstructChunkTo(w, shards, dataSize, ReconstructOptions{DataShards: data, ParityShards: parity})
+}
+
+// reconstructChunkTo verifies, reconstructs and joins shards into w
+func reconstructChunkTo(w io.Writer, shards []Shard, dataSize int, opts ReconstructOptions) error {
+	dataShards, parityShards, err := opts.erasure()
+	if err != nil {
+		return err
+	}
+	totalShards := dataShards + parityShards
+
+	if len(shards) < dataShards {
+		return fmt.Errorf("need at least %d shards, got %d", dataShards, len(shards))
 	}
 
 	if dataSize <= 0 {
-		return nil, fmt.Errorf("invalid data size")
+		return fmt.Errorf("invalid data size")
 	}
 
+	// Replicas or over-fetching can hand in several copies of one shard index.
+	// Copies failing their hash are set aside and only reported if too few
+	// verified shards remain to rebuild the chunk.
 	expectedChunk := shards[0].ChunkIndex
-	for _, s := range shards {
+	valid := make([]bool, len(shards))
+	hasValid := make(map[int]bool)
+	for i, s := range shards {
 		if s.ChunkIndex != expectedChunk {
-			return nil, fmt.Errorf("shards belong to different chunks")
+			return fmt.Errorf("shards belong to different chunks")
+		}
+		valid[i] = VerifyShard(s.Data, s.Hash)
+		if valid[i] {
+			hasValid[s.ShardIndex] = true
+		}
+	}
+	failed := -1 // a shard index no copy of which verifies
+	for i, s := range shards {
+		if !valid[i] && !hasValid[s.ShardIndex] {
+			failed = s.ShardIndex
+			break
 		}
-		if !VerifyShard(s.Data, s.Hash) {
-            return nil, fmt.Errorf("shard %d failed hash verification", s.ShardIndex)
-        }
 	}
 
     // Create encoder
-    enc, err := reedsolomon.New(DataShards, ParityShards)
+    enc, err := reedsolomon.New(dataShards, parityShards)
     if err != nil {
-        return nil, fmt.Errorf("failed to create encoder: %w", err)
+        return fmt.Errorf("failed to create encoder: %w", err)
     }
 
     // Prepare nil shard array 
-    shardData := make([][]byte, TotalShards)
+    shardData := make([][]byte, totalShards)
 
-    // Fill in available shards
-    for _, shard := range shards {
-        if shard.ShardIndex < 0 || shard.ShardIndex >= TotalShards {
-            return nil, fmt.Errorf("invalid shard index %d", shard.ShardIndex)
+    // Shards were cut from the padded data when uniform shard sizes are used
+    shardedSize := dataSize
+    if opts.PaddedSize > 0 {
+        if opts.PaddedSize < dataSize {
+            return fmt.Errorf("padded size %d smaller than data size %d", opts.PaddedSize, dataSize)
         }
-        if shardData[shard.ShardIndex] != nil {
-            return nil, fmt.Errorf("duplicate shard index %d", shard.ShardIndex)
-        }
-        shardData[shard.ShardIndex] = shard.Data	
+        shardedSize = opts.PaddedSize
     }
 
-    // Reconstruct missing shards
-    err = enc.Reconstruct(shardData)
-    if err != nil {
-        return nil, fmt.Errorf("failed to reconstruct: %w", err)
+    // reedsolomon needs equal-length shards; check against the padded size up front
+    expectedSize := ExpectedShardSize(shardedSize, dataShards)
+
+    // Shards all agreeing on a different size means the erasure config is wrong,
+    // not that one farmer returned a bad shard
+    if len(shards[0].Data) != expectedSize && allSameSize(shards) {
+        return &ErasureConfigError{
+            DataShards:   dataShards,
+            ParityShards: parityShards,
+            ShardSize:    len(shards[0].Data),
+            Expected:     expectedSize,
+            sizeErr: &ShardSizeError{
+                ChunkIndex: shards[0].ChunkIndex,
+                ShardIndex: shards[0].ShardIndex,
+                Size:       len(shards[0].Data),
+                Expected:   expectedSize,
+            },
+        }
     }
 
-    // Verify reconstruction
-    ok, err := enc.Verify(shardData)
-    if err != nil {
-        return nil, fmt.Errorf("verification failed: %w", err)
+    // Fill in available shards; identical copies of one index collapse to the first
+    distinct := 0
+    for i, shard := range shards {
+        if shard.ShardIndex < 0 || shard.ShardIndex >= totalShards {
+            return fmt.Errorf("invalid shard index %d for %d+%d erasure config", shard.ShardIndex, dataShards, parityShards)
+        }
+        if !valid[i] {
+            continue
+        }
+        if shardData[shard.ShardIndex] != nil {
+            if bytes.Equal(shardData[shard.ShardIndex], shard.Data) {
+                continue
+            }
+            return fmt.Errorf("conflicting copies of shard index %d", shard.ShardIndex)
+        }
+        if len(shard.Data) != expectedSize {
+            return &ShardSizeError{
+                ChunkIndex: shard.ChunkIndex,
+                ShardIndex: shard.ShardIndex,
+                Size:       len(shard.Data),
+                Expected:   expectedSize,
+            }
+        }
+        shardData[shard.ShardIndex] = shard.Data	
+        distinct++
     }
-    if !ok {
-        return nil, fmt.Errorf("reconstructed data failed verification")
+    if distinct < dataShards {
+        if failed >= 0 {
+            return fmt.Errorf("shard %d failed hash verification; %d of %d needed shards verified", failed, distinct, dataShards)
+        }
+        return fmt.Errorf("need at least %d distinct shards, got %d", dataShards, distinct)
     }
 
-    // Create a buffer to act as the io.Writer
-    var buf bytes.Buffer
+    // Fast path: all data shards present, nothing to rebuild and parity check skipped
+    fastPath := opts.SkipParityVerify && hasAllDataShards(shardData, dataShards)
+
+    if !fastPath {
+        // Reconstruct missing shards
+        err = enc.Reconstruct(shardData)
+        if err != nil {
+            return fmt.Errorf("failed to reconstruct: %w", err)
+        }
+
+        // Verify reconstruction
+        ok, err := enc.Verify(shardData)
+        if err != nil {
+            return fmt.Errorf("verification failed: %w", err)
+        }
+        if !ok {
+            return fmt.Errorf("reconstructed data failed verification")
+        }
+    }
 
-    // Join combines the shards and writes them to buf.
+    // Join combines the shards and writes them to w.
     // Ideally, pass the original data size. If dataSize is passed, 
     // Join will automatically strip the zero-padding bytes.
-    err = enc.Join(&buf, shardData, dataSize)
+    err = enc.Join(w, shardData, dataSize)
     if err != nil {
-        return nil, fmt.Errorf("failed to join shards: %w", err)
+        return fmt.Errorf("failed to join shards: %w", err)
     }
 
-    return buf.Bytes(), nil
+    return nil
+}
+
+// VerifyShardSet checks that a complete set of a chunk's shards is internally
+// consistent (parity matches data) without reconstructing or joining anything.
+// Useful for audits and to confirm a repaired shard set before trusting it.
+// All data+parity shards must be present; data and parity of 0 mean package defaults.
+// Shard hashes are not checked: (false, nil) means the shards disagree with each other.
+func VerifyShardSet(shards []Shard, data, parity int) (bool, error) {
+	dataShards, parityShards, err := ReconstructOptions{DataShards: data, ParityShards: parity}.erasure()
+	if err != nil {
+		return false, err
+	}
+	totalShards := dataShards + parityShards
+	if len(shards) != totalShards {
+		return false, fmt.Errorf("need all %d shards to verify parity, got %d", totalShards, len(shards))
+	}
+
+	shardData := make([][]byte, totalShards)
+	for _, shard := range shards {
+		if shard.ChunkIndex != shards[0].ChunkIndex {
+			return false, fmt.Errorf("shards belong to different chunks")
+		}
+		if shard.ShardIndex < 0 || shard.ShardIndex >= totalShards {
+			return false, fmt.Errorf("invalid shard index %d for %d+%d erasure config", shard.ShardIndex, dataShards, parityShards)
+		}
+		if shardData[shard.ShardIndex] != nil {
+			return false, fmt.Errorf("duplicate shard index %d", shard.ShardIndex)
+		}
+		if len(shard.Data) != len(shards[0].Data) {
+			return false, &ShardSizeError{
+				ChunkIndex: shard.ChunkIndex,
+				ShardIndex: shard.ShardIndex,
+				Size:       len(shard.Data),
+				Expected:   len(shards[0].Data),
+			}
+		}
+		shardData[shard.ShardIndex] = shard.Data
+	}
+
+	enc, err := reedsolomon.New(dataShards, parityShards)
+	if err != nil {
+		return false, fmt.Errorf("failed to create encoder: %w", err)
+	}
+	ok, err := enc.Verify(shardData)
+	if err != nil {
+		return false, fmt.Errorf("verification failed: %w", err)
+	}
+	return ok, nil
+}
+
+// RegenerateShards recomputes the shards at indices from at least data verified
+// shards of one chunk, e.g. to re-upload shards found missing or corrupt. The
+// regenerated shards carry fresh hashes; callers compare them with the recorded
+// ones before storing anything. data and parity of 0 mean package defaults.
+func RegenerateShards(shards []Shard, indices []int, data, parity int) ([]Shard, error) {
+	dataShards, parityShards, err := ReconstructOptions{DataShards: data, ParityShards: parity}.erasure()
+	if err != nil {
+		return nil, err
+	}
+	totalShards := dataShards + parityShards
+	if len(shards) == 0 {
+		return nil, fmt.Errorf("no shards to regenerate from")
+	}
+
+	shardData := make([][]byte, totalShards)
+	for _, shard := range shards {
+		if shard.ChunkIndex != shards[0].ChunkIndex {
+			return nil, fmt.Errorf("shards belong to different chunks")
+		}
+		if shard.ShardIndex < 0 || shard.ShardIndex >= totalShards {
+			return nil, fmt.Errorf("invalid shard index %d for %d+%d erasure config", shard.ShardIndex, dataShards, parityShards)
+		}
+		if len(shard.Data) != len(shards[0].Data) {
+			return nil, &ShardSizeError{
+				ChunkIndex: shard.ChunkIndex,
+				ShardIndex: shard.ShardIndex,
+				Size:       len(shard.Data),
+				Expected:   len(shards[0].Data),
+			}
+		}
+		shardData[shard.ShardIndex] = shard.Data
+	}
+	for _, index := range indices {
+		if index < 0 || index >= totalShards {
+			return nil, fmt.Errorf("invalid shard index %d for %d+%d erasure config", index, dataShards, parityShards)
+		}
+	}
+
+	enc, err := reedsolomon.New(dataShards, parityShards)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create encoder: %w", err)
+	}
+	if err := enc.Reconstruct(shardData); err != nil {
+		return nil, fmt.Errorf("failed to regenerate shards: %w", err)
+	}
+
+	regenerated := make([]Shard, 0, len(indices))
+	for _, index := range indices {
+		sum := sha256.Sum256(shardData[index])
+		regenerated = append(regenerated, Shard{
+			ChunkIndex: shards[0].ChunkIndex,
+			ShardIndex: index,
+			Data:       shardData[index],
+			Hash:       hex.EncodeToString(sum[:]),
+			Size:       len(shardData[index]),
+		})
+	}
+	return regenerated, nil
+}
+
+// hasAllDataShards reports whether every data shard slot is filled
+func hasAllDataShards(shardData [][]byte, dataShards int) bool {
+	for i := 0; i < dataShards; i++ {
+		if shardData[i] == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// allSameSize reports whether every shard has the same data length
+func allSameSize(shards []Shard) bool {
+	for _, s := range shards[1:] {
+		if len(s.Data) != len(shards[0].Data) {
+			return false
+		}
+	}
+	return true
 }
 
 // AssembleChunks consumes a stream of chunks and writes them to the output file.
 // Uses WriteAt, so chunks can arrive out of order (good for parallel downloads).
+// Chunks are placed at the default ChunkSize; see AssembleChunksWithConfig.
 func AssembleChunks(chunkStream <-chan Chunk, outputPath string, totalChunks int) error {
+	return AssembleChunksWithConfig(chunkStream, outputPath, totalChunks, DefaultConfig())
+}
+
+// AssembleChunksWithConfig is AssembleChunks for chunks of cfg.ChunkSize, which
+// must be the size the file was chunked at (the manifest's ChunkSize): chunk i
+// is written at i * cfg.ChunkSize.
+func AssembleChunksWithConfig(chunkStream <-chan Chunk, outputPath string, totalChunks int, cfg Config) error {
+	if err := checkChunkCount(totalChunks); err != nil {
+		return err
+	}
+	chunkSize := cfg.chunkSize()
+
 	// create output file / overwrite to 0 byte if exists
 	output, err := os.Create(outputPath)
 	if err != nil {
@@ -248,8 +801,8 @@ func AssembleChunks(chunkStream <-chan Chunk, outputPath string, totalChunks int
             continue 
         }
 
-		// Calculate offset based on index (Index * 1MB)
-		offset := int64(chunk.Index) * int64(ChunkSize)
+		// Calculate offset based on index (Index * chunk size)
+		offset := int64(chunk.Index) * int64(chunkSize)
 
 		// WriteAt allows random access writing
 		_, err := output.WriteAt(chunk.Data, offset)
@@ -278,4 +831,16 @@ func VerifyChunk(data []byte, expectedHash string) bool {
 func VerifyShard(data []byte, expectedHash string) bool {
     actualHash := sha256.Sum256(data)
     return hex.EncodeToString(actualHash[:]) == expectedHash
+}
+
+// VerifyShardReader is VerifyShard for a shard streamed from r, e.g. an HTTP
+// body, hashing it as it is read instead of buffering it. r is always read to
+// EOF, so a shard with trailing bytes fails rather than passing on its prefix.
+// Read failures are returned as errors, distinct from a hash mismatch.
+func VerifyShardReader(r io.Reader, expectedHash string) (bool, error) {
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, r); err != nil {
+		return false, fmt.Errorf("failed to read shard: %w", err)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)) == expectedHash, nil
 }
\ No newline at end of file
@@ -2,11 +2,22 @@ package chunker
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
+	"io"
 	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/crypto"
 )
 
 // ============================================================================
@@ -181,6 +192,171 @@ func TestStreamChunkFile_NonExistent(t *testing.T) {
 	}
 }
 
+func TestChunkHashesForFile_MatchesStream(t *testing.T) {
+	testFile := "test-hashes.bin"
+	testData := make([]byte, 2*ChunkSize+1234)
+	rand.Read(testData)
+	if err := os.WriteFile(testFile, testData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+
+	hashes, err := ChunkHashesForFile(testFile, DefaultConfig())
+	if err != nil {
+		t.Fatalf("ChunkHashesForFile failed: %v", err)
+	}
+
+	// Must match StreamChunkFile exactly, in order
+	var streamed []string
+	for result := range StreamChunkFile(testFile) {
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+		streamed = append(streamed, result.Chunk.Hash)
+	}
+
+	if len(hashes) != len(streamed) {
+		t.Fatalf("Expected %d hashes, got %d", len(streamed), len(hashes))
+	}
+	for i := range hashes {
+		if hashes[i] != streamed[i] {
+			t.Errorf("Chunk %d hash mismatch", i)
+		}
+	}
+
+	// Custom chunk size re-derives different boundaries
+	small, err := ChunkHashesForFile(testFile, Config{ChunkSize: ChunkSize / 2})
+	if err != nil {
+		t.Fatalf("ChunkHashesForFile failed: %v", err)
+	}
+	if len(small) != 5 {
+		t.Errorf("Expected 5 chunks at 512KB, got %d", len(small))
+	}
+	firstHalf := sha256.Sum256(testData[:ChunkSize/2])
+	if small[0] != hex.EncodeToString(firstHalf[:]) {
+		t.Error("First 512KB chunk hash mismatch")
+	}
+}
+
+// countingReader counts bytes read and optionally stalls each read
+type countingReader struct {
+	r     io.Reader
+	delay time.Duration
+	n     atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	if c.delay > 0 {
+		time.Sleep(c.delay)
+	}
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+func TestStreamChunkReader_PrefetchBytes(t *testing.T) {
+	const chunkSize = 1024
+	data := make([]byte, 40*chunkSize)
+	rand.Read(data)
+
+	// Nothing is consumed yet: the producer reads ahead until the buffer is full
+	readAhead := func(cfg Config) int64 {
+		src := &countingReader{r: bytes.NewReader(data)}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		StreamChunkReader(ctx, src, cfg)
+		time.Sleep(50 * time.Millisecond)
+		return src.n.Load() / chunkSize
+	}
+	// Buffered chunks plus the one blocked on send
+	if got := readAhead(Config{ChunkSize: chunkSize}); got != DefaultStreamBuffer+1 {
+		t.Errorf("Expected %d chunks read ahead by default, got %d", DefaultStreamBuffer+1, got)
+	}
+	if got := readAhead(Config{ChunkSize: chunkSize, Buffer: 1}); got != 2 {
+		t.Errorf("Expected 2 chunks read ahead with Buffer 1, got %d", got)
+	}
+	if got := readAhead(Config{ChunkSize: chunkSize, PrefetchBytes: 16 * chunkSize}); got != 17 {
+		t.Errorf("Expected 17 chunks read ahead with a 16-chunk budget, got %d", got)
+	}
+
+	// Order is preserved however deep the buffer
+	var got []byte
+	index := 0
+	for result := range StreamChunkReader(context.Background(), bytes.NewReader(data), Config{ChunkSize: chunkSize, PrefetchBytes: 1 << 20}) {
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+		if result.Chunk.Index != index {
+			t.Fatalf("Expected chunk %d, got %d", index, result.Chunk.Index)
+		}
+		index++
+		got = append(got, result.Chunk.Data...)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("Streamed chunks don't reassemble the input")
+	}
+}
+
+// benchmarkSlowConsumer streams from a reader that stalls 1ms per chunk into a
+// bursty consumer that stalls 8ms on every 8th chunk: about 1ms per chunk each
+// on average, so the stream keeps up only if the reader can run ahead of a burst
+func benchmarkSlowConsumer(b *testing.B, cfg Config) {
+	const chunkSize = 64 * 1024
+	cfg.ChunkSize = chunkSize
+	data := make([]byte, 64*chunkSize)
+	rand.Read(data)
+
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		src := &countingReader{r: bytes.NewReader(data), delay: time.Millisecond}
+		for result := range StreamChunkReader(context.Background(), src, cfg) {
+			if result.Err != nil {
+				b.Fatal(result.Err)
+			}
+			if result.Chunk.Index%8 == 7 {
+				time.Sleep(8 * time.Millisecond)
+			}
+		}
+	}
+}
+
+func BenchmarkStreamChunkReader_SlowConsumer_Buffer1(b *testing.B) {
+	benchmarkSlowConsumer(b, Config{Buffer: 1})
+}
+
+func BenchmarkStreamChunkReader_SlowConsumer_Prefetch1MB(b *testing.B) {
+	benchmarkSlowConsumer(b, Config{Buffer: 1, PrefetchBytes: 1 << 20})
+}
+
+func TestRecommendChunkSize(t *testing.T) {
+	tests := []struct {
+		fileSize int64
+		want     int
+	}{
+		{0, MinChunkSize},
+		{1, MinChunkSize},
+		{MinChunkSize, MinChunkSize},
+		{MinChunkSize + 1, 2 * MinChunkSize},
+		{300 * 1024, 512 * 1024},
+		{ChunkSize - 1, ChunkSize},
+		{ChunkSize, ChunkSize},
+		{100 * ChunkSize, ChunkSize},
+	}
+	for _, tt := range tests {
+		if got := RecommendChunkSize(tt.fileSize); got != tt.want {
+			t.Errorf("RecommendChunkSize(%d) = %d, want %d", tt.fileSize, got, tt.want)
+		}
+	}
+}
+
+func TestChunkHashesForFile_NonExistent(t *testing.T) {
+	_, err := ChunkHashesForFile("nonexistent-file.bin", DefaultConfig())
+	if err == nil {
+		t.Error("Expected error for non-existent file")
+	}
+}
+
 // ============================================================================
 // ERASURE CODING TESTS
 // ============================================================================
@@ -356,6 +532,37 @@ func TestReconstructChunk_InsufficientShards(t *testing.T) {
 	}
 }
 
+func TestReconstructChunkWithAvailable_Outages(t *testing.T) {
+	testData := make([]byte, 50000)
+	rand.Read(testData)
+	allShards, err := ShardChunkWithConfig(Chunk{Index: 0, Data: testData, Size: len(testData)}, testData, 6, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Farmers 0, 1 and 7 down
+	got, err := ReconstructChunkWithAvailable(allShards, []int{2, 3, 4, 5, 6, 8}, len(testData), 6, 3)
+	if err != nil {
+		t.Fatalf("ReconstructChunkWithAvailable failed: %v", err)
+	}
+	if !bytes.Equal(got, testData) {
+		t.Error("Reconstructed data doesn't match original")
+	}
+
+	// Shards outside the list are never used, even if they'd make up the difference
+	if _, err := ReconstructChunkWithAvailable(allShards, []int{0, 1, 2, 3, 4, 4}, len(testData), 6, 3); err == nil ||
+		!strings.Contains(err.Error(), "need at least 6") {
+		t.Errorf("Expected error for 5 distinct indices, got %v", err)
+	}
+	if _, err := ReconstructChunkWithAvailable(allShards, []int{0, 1, 2, 3, 4, 9}, len(testData), 6, 3); err == nil {
+		t.Error("Expected error for an out-of-range index")
+	}
+	if _, err := ReconstructChunkWithAvailable(allShards[1:], []int{0, 1, 2, 3, 4, 5}, len(testData), 6, 3); err == nil ||
+		!strings.Contains(err.Error(), "not supplied") {
+		t.Errorf("Expected error for a listed shard that wasn't supplied, got %v", err)
+	}
+}
+
 func TestReconstructChunk_CorruptedShard(t *testing.T) {
 	// Create test data
 	testData := make([]byte, ChunkSize)
@@ -385,6 +592,83 @@ func TestReconstructChunk_CorruptedShard(t *testing.T) {
 	}
 }
 
+func TestReconstructChunk_RedundantCopies(t *testing.T) {
+	testData := make([]byte, ChunkSize)
+	rand.Read(testData)
+	shards, err := ShardChunk(Chunk{Index: 0, Size: len(testData)}, testData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two valid copies of shard 0, e.g. from replicas, alongside three others
+	replica := shards[0]
+	replica.Data = append([]byte(nil), shards[0].Data...)
+	got, err := ReconstructChunk([]Shard{shards[0], replica, shards[1], shards[2], shards[3]}, len(testData))
+	if err != nil {
+		t.Fatalf("Expected redundant copies to be tolerated, got %v", err)
+	}
+	if !bytes.Equal(got, testData) {
+		t.Error("Reconstructed data mismatch with redundant copies")
+	}
+
+	// A corrupted copy is skipped when another copy of the index verifies
+	corrupted := replica
+	corrupted.Data = append([]byte(nil), shards[0].Data...)
+	corrupted.Data[0] ^= 0xFF
+	if _, err := ReconstructChunk([]Shard{corrupted, shards[0], shards[1], shards[2], shards[3]}, len(testData)); err != nil {
+		t.Errorf("Expected corrupted copy to be skipped, got %v", err)
+	}
+
+	// A corrupted shard is skipped when enough other shard indices verify
+	corrupted.ShardIndex = 4
+	corrupted.Hash = shards[4].Hash
+	if _, err := ReconstructChunk([]Shard{corrupted, shards[0], shards[1], shards[2], shards[3]}, len(testData)); err != nil {
+		t.Errorf("Expected corrupted shard to be skipped, got %v", err)
+	}
+
+	// Copies don't count twice towards DataShards
+	if _, err := ReconstructChunk([]Shard{shards[0], replica, shards[1], shards[2]}, len(testData)); err == nil {
+		t.Error("Expected error with only 3 distinct shards")
+	}
+
+	// Two verified copies of one index with different data are inconsistent
+	conflicting := shards[1]
+	conflicting.ShardIndex = 0
+	if _, err := ReconstructChunk([]Shard{shards[0], conflicting, shards[1], shards[2], shards[3]}, len(testData)); err == nil {
+		t.Error("Expected error for conflicting copies of one shard index")
+	}
+}
+
+func TestErasureProperties(t *testing.T) {
+	tests := []struct {
+		data, parity int
+		overhead     float64
+		tolerated    int
+	}{
+		{4, 2, 1.5, 2},
+		{8, 2, 1.25, 2},
+		{10, 4, 1.4, 4},
+	}
+	for _, tt := range tests {
+		if err := ValidateErasureConfig(tt.data, tt.parity); err != nil {
+			t.Errorf("%d+%d: unexpected error: %v", tt.data, tt.parity, err)
+		}
+		overhead, tolerated := ErasureProperties(tt.data, tt.parity)
+		if overhead != tt.overhead || tolerated != tt.tolerated {
+			t.Errorf("%d+%d: expected (%v, %d), got (%v, %d)", tt.data, tt.parity, tt.overhead, tt.tolerated, overhead, tolerated)
+		}
+	}
+
+	for _, bad := range [][2]int{{0, 2}, {4, 0}, {-1, 2}, {200, 57}} {
+		if err := ValidateErasureConfig(bad[0], bad[1]); err == nil {
+			t.Errorf("%d+%d: expected error", bad[0], bad[1])
+		}
+		if overhead, tolerated := ErasureProperties(bad[0], bad[1]); overhead != 0 || tolerated != 0 {
+			t.Errorf("%d+%d: expected zeros, got (%v, %d)", bad[0], bad[1], overhead, tolerated)
+		}
+	}
+}
+
 func TestReconstructChunk_MixedChunks(t *testing.T) {
 	// Create two different chunks
 	testData1 := make([]byte, ChunkSize)
@@ -417,161 +701,891 @@ func TestReconstructChunk_MixedChunks(t *testing.T) {
 	}
 }
 
-// ============================================================================
-// ASSEMBLE CHUNKS TESTS (with channels)
-// ============================================================================
+func TestReconstructChunk_TruncatedShard(t *testing.T) {
+	testData := make([]byte, ChunkSize)
+	rand.Read(testData)
 
-func TestAssembleChunks_InOrder(t *testing.T) {
-	// Create original file
-	original := "test-original.bin"
-	testData := make([]byte, 3*ChunkSize+500)
-	for i := range testData {
-		testData[i] = byte(i % 256)
-	}
-	if err := os.WriteFile(original, testData, 0644); err != nil {
+	chunk := Chunk{Index: 7, Data: testData, Size: len(testData)}
+	shards, err := ShardChunk(chunk, testData)
+	if err != nil {
 		t.Fatal(err)
 	}
-	defer os.Remove(original)
 
-	// Stream chunks
-	chunkStream := StreamChunkFile(original)
-	var chunks []Chunk
+	// Farmer returns a truncated shard 2 with a hash matching the truncated bytes
+	truncated := shards[2].Data[:len(shards[2].Data)-10]
+	truncHash := sha256.Sum256(truncated)
+	shards[2].Data = truncated
+	shards[2].Hash = hex.EncodeToString(truncHash[:])
+	shards[2].Size = len(truncated)
 
-	for result := range chunkStream {
-		if result.Err != nil {
-			t.Fatal(result.Err)
-		}
-		chunks = append(chunks, result.Chunk)
+	_, err = ReconstructChunk(shards[:4], len(testData))
+	if err == nil {
+		t.Fatal("Expected error for truncated shard")
 	}
 
-	// Create channel and send chunks in order
-	outStream := make(chan Chunk, len(chunks))
-	for _, chunk := range chunks {
-		outStream <- chunk
+	var sizeErr *ShardSizeError
+	if !errors.As(err, &sizeErr) {
+		t.Fatalf("Expected ShardSizeError, got %T: %v", err, err)
 	}
-	close(outStream)
-
-	// Reassemble
-	assembled := "test-assembled.bin"
-	defer os.Remove(assembled)
-
-	err := AssembleChunks(outStream, assembled, len(chunks))
-	if err != nil {
-		t.Fatalf("AssembleChunks failed: %v", err)
+	if sizeErr.ShardIndex != 2 || sizeErr.ChunkIndex != 7 {
+		t.Errorf("Wrong offending shard: chunk %d shard %d", sizeErr.ChunkIndex, sizeErr.ShardIndex)
+	}
+	if sizeErr.Expected != ExpectedShardSize(len(testData), DataShards) {
+		t.Errorf("Wrong expected size: %d", sizeErr.Expected)
 	}
+}
 
-	// Verify
-	assembledData, err := os.ReadFile(assembled)
+func TestReconstructChunk_WrongDataSize(t *testing.T) {
+	testData := make([]byte, ChunkSize)
+	rand.Read(testData)
+
+	chunk := Chunk{Index: 0, Data: testData, Size: len(testData)}
+	shards, err := ShardChunk(chunk, testData)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if !bytes.Equal(assembledData, testData) {
-		t.Error("Assembled data doesn't match original")
+	// Shards are consistent with each other but not with the claimed data size
+	_, err = ReconstructChunk(shards, len(testData)/2)
+	var sizeErr *ShardSizeError
+	if !errors.As(err, &sizeErr) {
+		t.Fatalf("Expected ShardSizeError, got %v", err)
 	}
 }
 
-func TestAssembleChunks_OutOfOrder(t *testing.T) {
-	// Create test data
-	testData := make([]byte, 5*ChunkSize)
+func TestReconstructChunk_SkipParityVerify(t *testing.T) {
+	testData := make([]byte, ChunkSize)
 	rand.Read(testData)
 
-	original := "test-random.bin"
-	if err := os.WriteFile(original, testData, 0644); err != nil {
+	chunk := Chunk{Index: 0, Data: testData, Size: len(testData)}
+	shards, err := ShardChunk(chunk, testData)
+	if err != nil {
 		t.Fatal(err)
 	}
-	defer os.Remove(original)
 
-	// Stream chunks
-	chunkStream := StreamChunkFile(original)
-	var chunks []Chunk
+	opts := ReconstructOptions{SkipParityVerify: true}
 
-	for result := range chunkStream {
-		if result.Err != nil {
-			t.Fatal(result.Err)
-		}
-		chunks = append(chunks, result.Chunk)
+	// All data shards present: fast path
+	reconstructed, err := ReconstructChunkWithOptions(shards[:DataShards], len(testData), opts)
+	if err != nil {
+		t.Fatalf("Fast path reconstruction failed: %v", err)
+	}
+	if !bytes.Equal(reconstructed, testData) {
+		t.Error("Fast path data doesn't match original")
 	}
 
-	// Send chunks in reverse order
-	outStream := make(chan Chunk, len(chunks))
-	for i := len(chunks) - 1; i >= 0; i-- {
-		outStream <- chunks[i]
+	// Missing a data shard: option ignored, parity reconstruction still works
+	mixed := []Shard{shards[0], shards[2], shards[4], shards[5]}
+	reconstructed, err = ReconstructChunkWithOptions(mixed, len(testData), opts)
+	if err != nil {
+		t.Fatalf("Parity path reconstruction failed: %v", err)
 	}
-	close(outStream)
+	if !bytes.Equal(reconstructed, testData) {
+		t.Error("Parity path data doesn't match original")
+	}
+}
 
-	// Reassemble
-	assembled := "test-assembled-random.bin"
-	defer os.Remove(assembled)
+func TestShardChunkPadded_UniformSizes(t *testing.T) {
+	paddedSize := ChunkSize + 40
 
-	err := AssembleChunks(outStream, assembled, len(chunks))
+	full := make([]byte, ChunkSize+40)
+	small := make([]byte, 1000)
+	rand.Read(full)
+	rand.Read(small)
+
+	fullShards, err := ShardChunkPadded(Chunk{Index: 0, Size: len(full)}, full, paddedSize)
 	if err != nil {
-		t.Fatalf("AssembleChunks failed: %v", err)
+		t.Fatal(err)
 	}
-
-	// Verify
-	assembledData, err := os.ReadFile(assembled)
+	smallShards, err := ShardChunkPadded(Chunk{Index: 1, Size: len(small)}, small, paddedSize)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if !bytes.Equal(assembledData, testData) {
-		t.Error("Assembled data doesn't match original (out of order)")
+	// Every shard in the blob has the same length
+	expected := ExpectedShardSize(paddedSize, DataShards)
+	for _, s := range append(fullShards, smallShards...) {
+		if s.Size != expected || len(s.Data) != expected {
+			t.Errorf("Chunk %d shard %d has size %d, expected %d", s.ChunkIndex, s.ShardIndex, s.Size, expected)
+		}
 	}
-}
 
-func TestAssembleChunks_MissingChunk(t *testing.T) {
-	// Create 3 chunks
-	chunks := make([]Chunk, 3)
-	for i := 0; i < 3; i++ {
-		data := make([]byte, ChunkSize)
-		rand.Read(data)
-		chunks[i] = Chunk{Index: i, Data: data, Size: ChunkSize}
+	// Reconstruction strips the padding, including from parity
+	opts := ReconstructOptions{PaddedSize: paddedSize}
+	subsets := [][]Shard{
+		smallShards[:DataShards],
+		{smallShards[1], smallShards[3], smallShards[4], smallShards[5]},
+	}
+	for _, subset := range subsets {
+		reconstructed, err := ReconstructChunkWithOptions(subset, len(small), opts)
+		if err != nil {
+			t.Fatalf("ReconstructChunkWithOptions failed: %v", err)
+		}
+		if !bytes.Equal(reconstructed, small) {
+			t.Errorf("Reconstructed %d bytes, expected original %d bytes without padding", len(reconstructed), len(small))
+		}
 	}
 
-	// Send only 2 chunks (missing chunk 1)
-	outStream := make(chan Chunk, 2)
-	outStream <- chunks[0]
-	outStream <- chunks[2] // Skip chunk 1
-	close(outStream)
-
-	// Should fail
-	assembled := "test-missing.bin"
-	defer os.Remove(assembled)
-
-	err := AssembleChunks(outStream, assembled, 3)
-	if err == nil {
-		t.Error("Expected error for missing chunk")
+	// Without PaddedSize the shard sizes don't match the real data size
+	if _, err := ReconstructChunk(smallShards, len(small)); err == nil {
+		t.Error("Expected error reconstructing padded shards without PaddedSize")
 	}
 }
 
-// ============================================================================
-// VERIFY FUNCTIONS TESTS
-// ============================================================================
-
-func TestVerifyChunk(t *testing.T) {
-	data := []byte("test data for verification")
+func TestShardChunkPadded_TooSmall(t *testing.T) {
+	data := make([]byte, 100)
+	if _, err := ShardChunkPadded(Chunk{Index: 0, Size: 100}, data, 50); err == nil {
+		t.Error("Expected error when padded size is smaller than data")
+	}
+}
 
-	hash := sha256.Sum256(data)
-	correctHash := hex.EncodeToString(hash[:])
+func TestReconstructChunk_ErasureConfigMismatch(t *testing.T) {
+	testData := make([]byte, ChunkSize)
+	rand.Read(testData)
 
-	if !VerifyChunk(data, correctHash) {
-		t.Error("Verification failed for correct hash")
+	// Shards produced with the default 4+2 config
+	chunk := Chunk{Index: 0, Data: testData, Size: len(testData)}
+	shards, err := ShardChunk(chunk, testData)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	if VerifyChunk(data, "wronghash123456") {
-		t.Error("Verification passed for wrong hash")
+	// Reconstructing under 6+3 must fail with a clear mismatch error
+	opts := ReconstructOptions{DataShards: 6, ParityShards: 3}
+	_, err = ReconstructChunkWithOptions(shards, len(testData), opts)
+	if err == nil {
+		t.Fatal("Expected error reconstructing 4+2 shards as 6+3")
 	}
-}
-
-func TestVerifyShard(t *testing.T) {
-	data := []byte("shard data for verification")
 
-	hash := sha256.Sum256(data)
-	correctHash := hex.EncodeToString(hash[:])
+	var cfgErr *ErasureConfigError
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("Expected ErasureConfigError, got %T: %v", err, err)
+	}
+	if cfgErr.DataShards != 6 || cfgErr.ParityShards != 3 {
+		t.Errorf("Wrong config in error: %d+%d", cfgErr.DataShards, cfgErr.ParityShards)
+	}
+	if cfgErr.ShardSize != ExpectedShardSize(len(testData), 4) || cfgErr.Expected != ExpectedShardSize(len(testData), 6) {
+		t.Errorf("Wrong sizes in error: %d vs %d", cfgErr.ShardSize, cfgErr.Expected)
+	}
 
-	if !VerifyShard(data, correctHash) {
-		t.Error("Shard verification failed for correct hash")
+	// Too few shards for the claimed data count
+	_, err = ReconstructChunkWithOptions(shards[:5], len(testData), opts)
+	if err == nil {
+		t.Error("Expected error with 5 shards under a 6-data-shard config")
+	}
+
+	// Explicitly passing the right config works
+	reconstructed, err := ReconstructChunkWithOptions(shards[2:], len(testData), ReconstructOptions{DataShards: 4, ParityShards: 2})
+	if err != nil {
+		t.Fatalf("Reconstruction with matching config failed: %v", err)
+	}
+	if !bytes.Equal(reconstructed, testData) {
+		t.Error("Reconstructed data doesn't match original")
+	}
+}
+
+func TestReconstructChunk_ManifestTotalShards(t *testing.T) {
+	testData := make([]byte, ChunkSize)
+	rand.Read(testData)
+
+	// Blob whose manifest declares 6 data + 3 parity = 9 shards
+	chunk := Chunk{Index: 0, Data: testData, Size: len(testData)}
+	shards, err := ShardChunkWithConfig(chunk, testData, 6, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shards) != 9 {
+		t.Fatalf("Expected 9 shards, got %d", len(shards))
+	}
+
+	opts := ReconstructOptions{DataShards: 6, TotalShards: 9}
+
+	// Non-contiguous set beyond the default index space: drop 0, 2 and 4
+	subset := []Shard{shards[1], shards[3], shards[5], shards[6], shards[7], shards[8]}
+	reconstructed, err := ReconstructChunkWithOptions(subset, len(testData), opts)
+	if err != nil {
+		t.Fatalf("Reconstruction with 9-shard index space failed: %v", err)
+	}
+	if !bytes.Equal(reconstructed, testData) {
+		t.Error("Reconstructed data doesn't match original")
+	}
+
+	// Index outside the declared space is rejected, not a panic
+	bad := append([]Shard(nil), subset...)
+	bad[5].ShardIndex = 9
+	if _, err := ReconstructChunkWithOptions(bad, len(testData), opts); err == nil {
+		t.Error("Expected error for shard index outside declared total")
+	}
+
+	// Totals that don't add up are rejected
+	inconsistent := ReconstructOptions{DataShards: 6, ParityShards: 2, TotalShards: 9}
+	if _, err := ReconstructChunkWithOptions(subset, len(testData), inconsistent); err == nil {
+		t.Error("Expected error for inconsistent erasure config")
+	}
+}
+
+func TestReconstructChunkTo_StreamsToWriter(t *testing.T) {
+	testData := make([]byte, ChunkSize)
+	rand.Read(testData)
+
+	chunk := Chunk{Index: 0, Data: testData, Size: len(testData)}
+	shards, err := ShardChunkWithConfig(chunk, testData, 6, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Missing data shards force a rebuild before the join
+	subset := []Shard{shards[1], shards[3], shards[5], shards[6], shards[7], shards[8]}
+	var out bytes.Buffer
+	if err := ReconstructChunkTo(&out, subset, len(testData), 6, 3); err != nil {
+		t.Fatalf("ReconstructChunkTo failed: %v", err)
+	}
+	if !bytes.Equal(out.Bytes(), testData) {
+		t.Error("Streamed data doesn't match original")
+	}
+
+	// Failed checks must not leave partial output behind
+	out.Reset()
+	if err := ReconstructChunkTo(&out, subset[:5], len(testData), 6, 3); err == nil {
+		t.Error("Expected error with too few shards")
+	}
+	corrupted := append([]Shard(nil), subset...)
+	corrupted[0].Data = append([]byte(nil), corrupted[0].Data...)
+	corrupted[0].Data[0] ^= 0xFF
+	if err := ReconstructChunkTo(&out, corrupted, len(testData), 6, 3); err == nil {
+		t.Error("Expected error for corrupted shard")
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected no output after failures, got %d bytes", out.Len())
+	}
+}
+
+func TestVerifyShardSet(t *testing.T) {
+	testData := make([]byte, ChunkSize)
+	rand.Read(testData)
+
+	shards, err := ShardChunk(Chunk{Index: 0, Data: testData, Size: len(testData)}, testData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err := VerifyShardSet(shards, DataShards, ParityShards)
+	if err != nil || !ok {
+		t.Fatalf("Expected consistent shard set, got ok=%v err=%v", ok, err)
+	}
+
+	// A flipped byte is caught by parity even if the shard hash were forged to match
+	corrupted := append([]Shard(nil), shards...)
+	corrupted[2].Data = append([]byte(nil), shards[2].Data...)
+	corrupted[2].Data[10] ^= 0x01
+	ok, err = VerifyShardSet(corrupted, DataShards, ParityShards)
+	if err != nil || ok {
+		t.Errorf("Expected inconsistent shard set, got ok=%v err=%v", ok, err)
+	}
+
+	if _, err := VerifyShardSet(shards[:TotalShards-1], 0, 0); err == nil {
+		t.Error("Expected error with a shard missing")
+	}
+	dup := append([]Shard(nil), shards...)
+	dup[1] = shards[0]
+	if _, err := VerifyShardSet(dup, 0, 0); err == nil {
+		t.Error("Expected error for duplicate shard index")
+	}
+}
+
+// FuzzReconstructChunk shards random data under a random erasure config, then
+// hands ReconstructChunk a random sequence of intact, duplicated, bit-flipped,
+// truncated and extended shards. Reconstruction must succeed and return the
+// original data exactly when at least DataShards distinct shard indices have an
+// intact copy, and fail otherwise.
+func FuzzReconstructChunk(f *testing.F) {
+	f.Add([]byte("hello, erasure coding"), []byte{0x00, 0x08, 0x10, 0x18}, byte(0x0b))
+	f.Add([]byte("minimum set plus damage"), []byte{0x02, 0x08, 0x10, 0x18, 0x20, 0x23}, byte(0x0b))
+	f.Add([]byte("too few intact shards"), []byte{0x00, 0x0a, 0x13, 0x1c, 0x21}, byte(0x0b))
+	f.Add(bytes.Repeat([]byte{0xab}, 4097), []byte{0x01, 0x01, 0x09, 0x12, 0x1b, 0x24, 0x2d, 0x30, 0x38}, byte(0x3d))
+	f.Add([]byte{1}, []byte{0x00}, byte(0x20))
+
+	f.Fuzz(func(t *testing.T, payload, ops []byte, cfg byte) {
+		if len(payload) == 0 || len(payload) > 1<<16 || len(ops) > 64 {
+			t.Skip()
+		}
+		data := 1 + int(cfg&0x07)
+		parity := 1 + int(cfg>>3&0x03)
+		total := data + parity
+		opts := ReconstructOptions{DataShards: data, ParityShards: parity, SkipParityVerify: cfg&0x20 != 0}
+
+		shards, err := ShardChunkWithConfig(Chunk{Index: 0, Size: len(payload)}, payload, data, parity)
+		if err != nil {
+			t.Fatalf("ShardChunkWithConfig(%d+%d) failed: %v", data, parity, err)
+		}
+
+		// The low 3 bits of each op pick the damage, the rest the shard index
+		var input []Shard
+		intact := make(map[int]bool)
+		for i, op := range ops {
+			s := shards[int(op>>3)%total]
+			s.Data = append([]byte(nil), s.Data...)
+			switch op & 0x07 {
+			case 0, 1:
+				intact[s.ShardIndex] = true
+			case 2:
+				s.Data[i%len(s.Data)] ^= 1 << (i % 8)
+			case 3:
+				s.Data = s.Data[:len(s.Data)-1]
+			case 4:
+				s.Data = append(s.Data, 0)
+			default:
+				continue
+			}
+			input = append(input, s)
+		}
+
+		got, err := ReconstructChunkWithOptions(input, len(payload), opts)
+		if len(intact) >= data {
+			if err != nil {
+				t.Fatalf("%d+%d with %d intact indices: %v", data, parity, len(intact), err)
+			}
+			if !bytes.Equal(got, payload) {
+				t.Fatalf("%d+%d: reconstructed data doesn't match original", data, parity)
+			}
+		} else if err == nil {
+			t.Fatalf("%d+%d: expected error with only %d intact indices", data, parity, len(intact))
+		}
+	})
+}
+
+// ============================================================================
+// ASSEMBLE CHUNKS TESTS (with channels)
+// ============================================================================
+
+func TestAssembleChunks_InOrder(t *testing.T) {
+	// Create original file
+	original := "test-original.bin"
+	testData := make([]byte, 3*ChunkSize+500)
+	for i := range testData {
+		testData[i] = byte(i % 256)
+	}
+	if err := os.WriteFile(original, testData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(original)
+
+	// Stream chunks
+	chunkStream := StreamChunkFile(original)
+	var chunks []Chunk
+
+	for result := range chunkStream {
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+		chunks = append(chunks, result.Chunk)
+	}
+
+	// Create channel and send chunks in order
+	outStream := make(chan Chunk, len(chunks))
+	for _, chunk := range chunks {
+		outStream <- chunk
+	}
+	close(outStream)
+
+	// Reassemble
+	assembled := "test-assembled.bin"
+	defer os.Remove(assembled)
+
+	err := AssembleChunks(outStream, assembled, len(chunks))
+	if err != nil {
+		t.Fatalf("AssembleChunks failed: %v", err)
+	}
+
+	// Verify
+	assembledData, err := os.ReadFile(assembled)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(assembledData, testData) {
+		t.Error("Assembled data doesn't match original")
+	}
+}
+
+func TestAssembleChunks_OutOfOrder(t *testing.T) {
+	// Create test data
+	testData := make([]byte, 5*ChunkSize)
+	rand.Read(testData)
+
+	original := "test-random.bin"
+	if err := os.WriteFile(original, testData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(original)
+
+	// Stream chunks
+	chunkStream := StreamChunkFile(original)
+	var chunks []Chunk
+
+	for result := range chunkStream {
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+		chunks = append(chunks, result.Chunk)
+	}
+
+	// Send chunks in reverse order
+	outStream := make(chan Chunk, len(chunks))
+	for i := len(chunks) - 1; i >= 0; i-- {
+		outStream <- chunks[i]
+	}
+	close(outStream)
+
+	// Reassemble
+	assembled := "test-assembled-random.bin"
+	defer os.Remove(assembled)
+
+	err := AssembleChunks(outStream, assembled, len(chunks))
+	if err != nil {
+		t.Fatalf("AssembleChunks failed: %v", err)
+	}
+
+	// Verify
+	assembledData, err := os.ReadFile(assembled)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(assembledData, testData) {
+		t.Error("Assembled data doesn't match original (out of order)")
+	}
+}
+
+func TestAssembleChunks_MissingChunk(t *testing.T) {
+	// Create 3 chunks
+	chunks := make([]Chunk, 3)
+	for i := 0; i < 3; i++ {
+		data := make([]byte, ChunkSize)
+		rand.Read(data)
+		chunks[i] = Chunk{Index: i, Data: data, Size: ChunkSize}
+	}
+
+	// Send only 2 chunks (missing chunk 1)
+	outStream := make(chan Chunk, 2)
+	outStream <- chunks[0]
+	outStream <- chunks[2] // Skip chunk 1
+	close(outStream)
+
+	// Should fail
+	assembled := "test-missing.bin"
+	defer os.Remove(assembled)
+
+	err := AssembleChunks(outStream, assembled, 3)
+	if err == nil {
+		t.Error("Expected error for missing chunk")
+	}
+}
+
+func TestAssembleChunks_RejectsImplausibleCount(t *testing.T) {
+	assembled := "test-implausible.bin"
+	defer os.Remove(assembled)
+
+	// A hostile count must be refused before anything is allocated or created
+	huge := 1 << 40
+	if err := AssembleChunks(make(chan Chunk), assembled, huge); err == nil || !strings.Contains(err.Error(), "MaxChunks") {
+		t.Errorf("Expected MaxChunks error, got %v", err)
+	}
+	if _, err := os.Stat(assembled); !os.IsNotExist(err) {
+		t.Error("Output file should not be created")
+	}
+	if err := AssembleChunksResumable(make(chan Chunk), assembled, huge, nil); err == nil {
+		t.Error("Expected error from AssembleChunksResumable")
+	}
+	if _, err := PendingChunks(assembled, MaxChunks+1, nil); err == nil {
+		t.Error("Expected error from PendingChunks")
+	}
+	if _, err := NewConcurrentAssembler(assembled, huge, nil); err == nil {
+		t.Error("Expected error from NewConcurrentAssembler")
+	}
+	if err := AssembleChunks(make(chan Chunk), assembled, -1); err == nil {
+		t.Error("Expected error for negative count")
+	}
+}
+
+func TestAssemble_SmallChunkSize(t *testing.T) {
+	// 64KB chunks, as an upload with UploadConfig.ChunkSize or RecommendChunkSize picks
+	cfg := Config{ChunkSize: 64 * 1024}
+	testData := make([]byte, 5*cfg.ChunkSize+321)
+	rand.Read(testData)
+	original := "test-small-chunks.bin"
+	if err := os.WriteFile(original, testData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(original)
+
+	var chunks []Chunk
+	var hashes []string
+	for result := range StreamChunkFileWithConfig(original, cfg) {
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+		chunks = append(chunks, result.Chunk)
+		hashes = append(hashes, result.Chunk.Hash)
+	}
+	if len(chunks) != 6 {
+		t.Fatalf("Expected 6 chunks, got %d", len(chunks))
+	}
+	stream := func(indices ...int) <-chan Chunk {
+		out := make(chan Chunk, len(indices))
+		for _, i := range indices {
+			out <- chunks[i]
+		}
+		close(out)
+		return out
+	}
+	check := func(name, path string) {
+		t.Helper()
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, testData) {
+			t.Errorf("%s: assembled data doesn't match original", name)
+		}
+	}
+
+	assembled := "test-small-assembled.bin"
+	defer os.Remove(assembled)
+	if err := AssembleChunksWithConfig(stream(5, 2, 0, 4, 1, 3), assembled, len(chunks), cfg); err != nil {
+		t.Fatalf("AssembleChunksWithConfig failed: %v", err)
+	}
+	check("AssembleChunksWithConfig", assembled)
+
+	asm, err := NewConcurrentAssemblerWithConfig(assembled, len(chunks), hashes, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []int{3, 0, 5, 1, 4, 2} {
+		if err := asm.WriteChunk(chunks[i]); err != nil {
+			t.Fatalf("WriteChunk failed: %v", err)
+		}
+	}
+	if err := asm.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	check("ConcurrentAssembler", assembled)
+
+	resumable := "test-small-resumable.bin"
+	defer os.Remove(resumable)
+	defer os.Remove(resumable + AssemblyStateSuffix)
+	if err := AssembleChunksResumableWithConfig(stream(0, 4), resumable, len(chunks), hashes, cfg); err == nil {
+		t.Fatal("Expected incomplete error on first run")
+	}
+	sidecar, err := os.ReadFile(resumable + AssemblyStateSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(sidecar), `"chunk_size":65536`) {
+		t.Errorf("Sidecar doesn't record the chunk size: %s", sidecar)
+	}
+	pending, err := PendingChunksWithConfig(resumable, len(chunks), hashes, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(pending, []int{1, 2, 3, 5}) {
+		t.Fatalf("Expected pending [1 2 3 5], got %v", pending)
+	}
+	if err := AssembleChunksResumableWithConfig(stream(pending...), resumable, len(chunks), hashes, cfg); err != nil {
+		t.Fatalf("Resumed assembly failed: %v", err)
+	}
+	check("AssembleChunksResumableWithConfig", resumable)
+}
+
+func TestAssembleChunksResumable_ResumesAfterFailure(t *testing.T) {
+	testData := make([]byte, 3*ChunkSize+500)
+	rand.Read(testData)
+
+	var chunks []Chunk
+	var hashes []string
+	for i := 0; i*ChunkSize < len(testData); i++ {
+		data := testData[i*ChunkSize : min((i+1)*ChunkSize, len(testData))]
+		hash := sha256.Sum256(data)
+		chunks = append(chunks, Chunk{Index: i, Data: data, Size: len(data), Hash: hex.EncodeToString(hash[:])})
+		hashes = append(hashes, chunks[i].Hash)
+	}
+
+	assembled := "test-resumable.bin"
+	defer os.Remove(assembled)
+	defer os.Remove(assembled + AssemblyStateSuffix)
+
+	// First run dies after chunks 0 and 3
+	first := make(chan Chunk, 2)
+	first <- chunks[0]
+	first <- chunks[3]
+	close(first)
+	if err := AssembleChunksResumable(first, assembled, len(chunks), hashes); err == nil {
+		t.Fatal("Expected incomplete error on first run")
+	}
+	if _, err := os.Stat(assembled + AssemblyStateSuffix); err != nil {
+		t.Fatalf("Expected sidecar after interrupted run: %v", err)
+	}
+
+	pending, err := PendingChunks(assembled, len(chunks), hashes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 2 || pending[0] != 1 || pending[1] != 2 {
+		t.Fatalf("Expected pending [1 2], got %v", pending)
+	}
+
+	// Second run supplies only what's pending
+	second := make(chan Chunk, len(pending))
+	for _, i := range pending {
+		second <- chunks[i]
+	}
+	close(second)
+	if err := AssembleChunksResumable(second, assembled, len(chunks), hashes); err != nil {
+		t.Fatalf("Resumed assembly failed: %v", err)
+	}
+
+	assembledData, err := os.ReadFile(assembled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(assembledData, testData) {
+		t.Error("Resumed file doesn't match original")
+	}
+	if _, err := os.Stat(assembled + AssemblyStateSuffix); !os.IsNotExist(err) {
+		t.Error("Expected sidecar removed after completion")
+	}
+}
+
+func TestPendingChunks_ReconcilesWithFile(t *testing.T) {
+	chunks := make([]Chunk, 2)
+	hashes := make([]string, 2)
+	for i := range chunks {
+		data := make([]byte, ChunkSize)
+		rand.Read(data)
+		hash := sha256.Sum256(data)
+		chunks[i] = Chunk{Index: i, Data: data, Size: ChunkSize}
+		hashes[i] = hex.EncodeToString(hash[:])
+	}
+
+	assembled := "test-reconcile.bin"
+	defer os.Remove(assembled)
+	defer os.Remove(assembled + AssemblyStateSuffix)
+
+	// Write chunk 0 only, leaving the sidecar behind
+	stream := make(chan Chunk, 1)
+	stream <- chunks[0]
+	close(stream)
+	AssembleChunksResumable(stream, assembled, 2, hashes)
+
+	// Corrupt the written chunk: bitmap says done, contents say otherwise
+	f, err := os.OpenFile(assembled, os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteAt([]byte("garbage"), 100)
+	f.Close()
+
+	pending, err := PendingChunks(assembled, 2, hashes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 2 {
+		t.Errorf("Expected corrupted chunk to be pending again, got %v", pending)
+	}
+
+	// Without hashes only the file length is checked
+	pending, err = PendingChunks(assembled, 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0] != 1 {
+		t.Errorf("Expected pending [1] without hashes, got %v", pending)
+	}
+}
+
+func TestAssembleChunksResumable_VerifiesPartialOutput(t *testing.T) {
+	testData := make([]byte, 4*ChunkSize+700)
+	rand.Read(testData)
+
+	var chunks []Chunk
+	var hashes []string
+	for i := 0; i*ChunkSize < len(testData); i++ {
+		data := testData[i*ChunkSize : min((i+1)*ChunkSize, len(testData))]
+		hash := sha256.Sum256(data)
+		chunks = append(chunks, Chunk{Index: i, Data: data, Size: len(data), Hash: hex.EncodeToString(hash[:])})
+		hashes = append(hashes, chunks[i].Hash)
+	}
+
+	assembled := "test-partial-output.bin"
+	defer os.Remove(assembled)
+	defer os.Remove(assembled + AssemblyStateSuffix)
+
+	// A crashed run: every chunk but 3 reached the file, chunk 1 only half way
+	// (a torn write over a zero-filled hole) and the sidecar never got saved
+	partial := append([]byte(nil), testData[:3*ChunkSize]...)
+	clear(partial[ChunkSize+ChunkSize/2 : 2*ChunkSize])
+	partial = append(partial, make([]byte, ChunkSize)...) // hole for chunk 3
+	partial = append(partial, testData[4*ChunkSize:]...)
+	if err := os.WriteFile(assembled, partial, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := PendingChunks(assembled, len(chunks), hashes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 2 || pending[0] != 1 || pending[1] != 3 {
+		t.Fatalf("Expected pending [1 3], got %v", pending)
+	}
+
+	// Garbage past the end invalidates the last chunk too
+	f, err := os.OpenFile(assembled, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("trailing garbage"))
+	f.Close()
+	pending, err = PendingChunks(assembled, len(chunks), hashes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 3 || pending[2] != 4 {
+		t.Fatalf("Expected pending [1 3 4], got %v", pending)
+	}
+
+	// Fetching just the pending chunks completes the file
+	resume := make(chan Chunk, len(pending))
+	for _, i := range pending {
+		resume <- chunks[i]
+	}
+	close(resume)
+	if err := AssembleChunksResumable(resume, assembled, len(chunks), hashes); err != nil {
+		t.Fatalf("Resumed assembly failed: %v", err)
+	}
+	assembledData, err := os.ReadFile(assembled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(assembledData, testData) {
+		t.Error("Resumed file doesn't match original")
+	}
+}
+
+func TestConcurrentAssembler_ParallelWriters(t *testing.T) {
+	testData := make([]byte, 8*ChunkSize+321)
+	rand.Read(testData)
+
+	original := "test-concurrent-original.bin"
+	if err := os.WriteFile(original, testData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(original)
+
+	var chunks []Chunk
+	for result := range StreamChunkFile(original) {
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+		chunks = append(chunks, result.Chunk)
+	}
+
+	assembled := "test-concurrent.bin"
+	defer os.Remove(assembled)
+
+	asm, err := NewConcurrentAssembler(assembled, len(chunks), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One goroutine per chunk, plus a duplicate writer for chunk 0
+	var wg sync.WaitGroup
+	errs := make(chan error, len(chunks)+1)
+	for _, chunk := range append(chunks, chunks[0]) {
+		wg.Add(1)
+		go func(c Chunk) {
+			defer wg.Done()
+			errs <- asm.WriteChunk(c)
+		}(chunk)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("WriteChunk failed: %v", err)
+		}
+	}
+
+	if err := asm.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	assembledData, err := os.ReadFile(assembled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(assembledData, testData) {
+		t.Error("Concurrently assembled data doesn't match original")
+	}
+}
+
+func TestConcurrentAssembler_RejectsBadChunk(t *testing.T) {
+	data := []byte("chunk data")
+	hash := sha256.Sum256(data)
+	good := Chunk{Index: 0, Data: data, Size: len(data), Hash: hex.EncodeToString(hash[:])}
+
+	assembled := "test-concurrent-bad.bin"
+	defer os.Remove(assembled)
+
+	asm, err := NewConcurrentAssembler(assembled, 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bad := good
+	bad.Data = []byte("tampered!!")
+	if err := asm.WriteChunk(bad); err == nil {
+		t.Error("Expected hash verification failure")
+	}
+	if err := asm.WriteChunk(Chunk{Index: 5, Data: data, Hash: good.Hash}); err == nil {
+		t.Error("Expected out-of-bounds error")
+	}
+	if err := asm.WriteChunk(good); err != nil {
+		t.Fatalf("WriteChunk failed: %v", err)
+	}
+
+	// Chunk 1 never arrived
+	if err := asm.Close(); err == nil {
+		t.Error("Expected incomplete file error on Close")
+	}
+}
+
+// ============================================================================
+// VERIFY FUNCTIONS TESTS
+// ============================================================================
+
+func TestVerifyChunk(t *testing.T) {
+	data := []byte("test data for verification")
+
+	hash := sha256.Sum256(data)
+	correctHash := hex.EncodeToString(hash[:])
+
+	if !VerifyChunk(data, correctHash) {
+		t.Error("Verification failed for correct hash")
+	}
+
+	if VerifyChunk(data, "wronghash123456") {
+		t.Error("Verification passed for wrong hash")
+	}
+}
+
+func TestVerifyShard(t *testing.T) {
+	data := []byte("shard data for verification")
+
+	hash := sha256.Sum256(data)
+	correctHash := hex.EncodeToString(hash[:])
+
+	if !VerifyShard(data, correctHash) {
+		t.Error("Shard verification failed for correct hash")
 	}
 
 	if VerifyShard(data, "wronghash789abc") {
@@ -579,6 +1593,120 @@ func TestVerifyShard(t *testing.T) {
 	}
 }
 
+// failingReader returns its data, then err instead of EOF
+type failingReader struct {
+	data []byte
+	err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestVerifyShardReader(t *testing.T) {
+	data := make([]byte, 3*ChunkSize/DataShards+17)
+	rand.Read(data)
+	hash := sha256.Sum256(data)
+	correctHash := hex.EncodeToString(hash[:])
+
+	// One byte at a time, so every read is hashed incrementally
+	ok, err := VerifyShardReader(iotest.OneByteReader(bytes.NewReader(data)), correctHash)
+	if err != nil || !ok {
+		t.Errorf("Streamed shard failed verification: %v, %v", ok, err)
+	}
+
+	// Trailing bytes after a valid prefix must not pass
+	withTrailer := append(append([]byte(nil), data...), 0xFF)
+	if ok, err := VerifyShardReader(bytes.NewReader(withTrailer), correctHash); err != nil || ok {
+		t.Errorf("Shard with trailing bytes passed verification: %v, %v", ok, err)
+	}
+
+	if ok, err := VerifyShardReader(bytes.NewReader(data), "wronghash789abc"); err != nil || ok {
+		t.Errorf("Shard verification passed for wrong hash: %v, %v", ok, err)
+	}
+
+	readErr := errors.New("connection reset")
+	ok, err = VerifyShardReader(&failingReader{data: data, err: readErr}, correctHash)
+	if !errors.Is(err, readErr) || ok {
+		t.Errorf("Expected the read error, got %v, %v", ok, err)
+	}
+}
+
+// ============================================================================
+// SHARD FEC TESTS
+// ============================================================================
+
+func TestShardFEC_Corrects(t *testing.T) {
+	fec := ShardFEC{Blocks: 16, ParityBlocks: 2}
+	data := make([]byte, 10000)
+	rand.Read(data)
+
+	stored, err := fec.Encode(data)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if len(stored) != fec.EncodedSize(len(data)) {
+		t.Fatalf("Expected %d stored bytes, got %d", fec.EncodedSize(len(data)), len(stored))
+	}
+	if !bytes.Equal(stored[:len(data)], data) {
+		t.Error("Stored shard should start with the shard itself")
+	}
+
+	got, corrected, err := fec.Decode(stored)
+	if err != nil || corrected != 0 || !bytes.Equal(got, data) {
+		t.Fatalf("Clean decode: %d corrected, err %v", corrected, err)
+	}
+
+	// Bit flips in two blocks, one of them the checksum table: correctable
+	damaged := bytes.Clone(stored)
+	damaged[10] ^= 0x01
+	damaged[11] ^= 0x80
+	damaged[len(damaged)-1] ^= 0x04
+	got, corrected, err = fec.Decode(damaged)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if corrected != 2 || !bytes.Equal(got, data) {
+		t.Errorf("Expected 2 blocks corrected to the original, got %d", corrected)
+	}
+
+	// A third damaged block is one too many
+	damaged[5000] ^= 0x10
+	if _, _, err := fec.Decode(damaged); err == nil {
+		t.Error("Expected an error with more damaged blocks than parity blocks")
+	}
+}
+
+func TestShardFEC_Sizes(t *testing.T) {
+	for _, fec := range []ShardFEC{DefaultShardFEC, {Blocks: 3, ParityBlocks: 1}, {Blocks: 200, ParityBlocks: 56}} {
+		for size := 1; size <= 1000; size++ {
+			got, ok := fec.DecodedSize(fec.EncodedSize(size))
+			if !ok || got != size {
+				t.Fatalf("%+v: DecodedSize(EncodedSize(%d)) = %d, %v", fec, size, got, ok)
+			}
+		}
+		data := make([]byte, 7)
+		stored, err := fec.Encode(data)
+		if err != nil {
+			t.Fatalf("%+v: Encode of a shard smaller than Blocks failed: %v", fec, err)
+		}
+		if got, _, err := fec.Decode(stored); err != nil || !bytes.Equal(got, data) {
+			t.Errorf("%+v: small shard didn't round-trip: %v", fec, err)
+		}
+	}
+
+	for _, fec := range []ShardFEC{{Blocks: 0, ParityBlocks: 1}, {Blocks: 4, ParityBlocks: 0}, {Blocks: 250, ParityBlocks: 10}} {
+		if err := fec.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", fec)
+		}
+	}
+}
+
 // ============================================================================
 // FULL ROUND-TRIP TEST (Most Important!)
 // ============================================================================
@@ -670,3 +1798,137 @@ func TestFullRoundTrip_ChunkShardReconstruct(t *testing.T) {
 
 	t.Log("✅ Full round-trip successful: chunk → shard → reconstruct → assemble")
 }
+
+// ============================================================================
+// BENCHMARKS
+// ============================================================================
+
+func benchmarkReconstruct(b *testing.B, opts ReconstructOptions) {
+	testData := make([]byte, ChunkSize)
+	rand.Read(testData)
+
+	chunk := Chunk{Index: 0, Data: testData, Size: len(testData)}
+	shards, err := ShardChunk(chunk, testData)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	// Healthy download: exactly the data shards
+	dataShards := shards[:DataShards]
+
+	b.SetBytes(int64(len(testData)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ReconstructChunkWithOptions(dataShards, len(testData), opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReconstructChunk_Verify(b *testing.B) {
+	benchmarkReconstruct(b, ReconstructOptions{})
+}
+
+func BenchmarkReconstructChunk_SkipParityVerify(b *testing.B) {
+	benchmarkReconstruct(b, ReconstructOptions{SkipParityVerify: true})
+}
+
+// benchFileSize is the blob size for the file-based benchmarks: several full
+// chunks plus a partial one
+const benchFileSize = 8*ChunkSize + 12345
+
+// writeBenchFile writes benchFileSize random bytes to a file removed when b ends
+func writeBenchFile(b *testing.B, path string) []byte {
+	data := make([]byte, benchFileSize)
+	rand.Read(data)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { os.Remove(path) })
+	return data
+}
+
+func BenchmarkStreamChunkFile(b *testing.B) {
+	path := "test-bench-stream.bin"
+	writeBenchFile(b, path)
+
+	b.SetBytes(benchFileSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for result := range StreamChunkFile(path) {
+			if result.Err != nil {
+				b.Fatal(result.Err)
+			}
+		}
+	}
+}
+
+func BenchmarkShardChunk(b *testing.B) {
+	testData := make([]byte, ChunkSize)
+	rand.Read(testData)
+	chunk := Chunk{Index: 0, Data: testData, Size: len(testData)}
+
+	b.SetBytes(int64(len(testData)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ShardChunk(chunk, testData); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkFullPipeline pushes a fixed-size blob through every stage of an
+// upload and download without the network: chunk, encrypt, shard, reconstruct
+// from the data shards, decrypt and assemble. It is the baseline for comparing
+// performance changes end to end.
+func BenchmarkFullPipeline(b *testing.B) {
+	inputPath := "test-bench-pipeline.bin"
+	outputPath := "test-bench-pipeline.out"
+	writeBenchFile(b, inputPath)
+	b.Cleanup(func() { os.Remove(outputPath) })
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		b.Fatal(err)
+	}
+	totalChunks := (benchFileSize + ChunkSize - 1) / ChunkSize
+
+	b.SetBytes(benchFileSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		assembled := make(chan Chunk)
+		done := make(chan error, 1)
+		go func() { done <- AssembleChunks(assembled, outputPath, totalChunks) }()
+
+		for result := range StreamChunkFile(inputPath) {
+			if result.Err != nil {
+				b.Fatal(result.Err)
+			}
+			chunk := result.Chunk
+			encrypted, err := crypto.EncryptChunk(chunk.Data, key)
+			if err != nil {
+				b.Fatal(err)
+			}
+			shards, err := ShardChunk(Chunk{Index: chunk.Index, Data: encrypted, Size: len(encrypted)}, encrypted)
+			if err != nil {
+				b.Fatal(err)
+			}
+			reconstructed, err := ReconstructChunk(shards[:DataShards], len(encrypted))
+			if err != nil {
+				b.Fatal(err)
+			}
+			plaintext, err := crypto.DecryptChunk(reconstructed, key)
+			if err != nil {
+				b.Fatal(err)
+			}
+			assembled <- Chunk{Index: chunk.Index, Data: plaintext, Size: len(plaintext)}
+		}
+		close(assembled)
+		if err := <-done; err != nil {
+			b.Fatal(err)
+		}
+	}
+}
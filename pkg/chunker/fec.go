@@ -0,0 +1,194 @@
+package chunker
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/klauspost/reedsolomon"
+)
+
+// ShardFEC is an optional inner forward error correction layer applied to each
+// stored shard. The chunk-level erasure code survives losing whole shards, but
+// a single flipped bit fails VerifyShard and loses the shard just the same;
+// ShardFEC lets a reader correct small errors inside a shard before falling
+// back to reconstructing the chunk from other shards.
+//
+// A shard is split into Blocks equal blocks (the last zero-padded), Reed-Solomon
+// adds ParityBlocks parity blocks, and every block gets a CRC-32C. The stored
+// shard is the shard itself, then the parity blocks, then the checksums. A block
+// whose checksum fails is treated as erased, so damage confined to at most
+// ParityBlocks blocks is corrected however many bits it flips, including within
+// the checksum table itself. Damage spread over more blocks than that can't be
+// corrected and the shard is lost as before.
+//
+// Storage cost per shard is ParityBlocks/Blocks of the shard plus 4 bytes per
+// block. DefaultShardFEC (64+2) adds about 3.1% and 264 bytes: on a 256KB shard
+// it corrects up to two damaged 4KB blocks.
+type ShardFEC struct {
+	Blocks       int `json:"blocks"`        // data blocks each shard is split into
+	ParityBlocks int `json:"parity_blocks"` // parity blocks stored with them
+}
+
+// DefaultShardFEC is 64 data blocks and 2 parity blocks per shard
+var DefaultShardFEC = ShardFEC{Blocks: 64, ParityBlocks: 2}
+
+// fecChecksumSize is the size of each block's CRC-32C in the checksum table
+const fecChecksumSize = 4
+
+var fecTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Validate checks the block counts are usable: at least one of each, and at
+// most 256 blocks in all, the Reed-Solomon limit over GF(2^8)
+func (f ShardFEC) Validate() error {
+	if f.Blocks <= 0 || f.ParityBlocks <= 0 {
+		return fmt.Errorf("invalid shard FEC %d+%d: need at least one data and one parity block", f.Blocks, f.ParityBlocks)
+	}
+	if f.Blocks+f.ParityBlocks > 256 {
+		return fmt.Errorf("invalid shard FEC %d+%d: at most 256 blocks in total", f.Blocks, f.ParityBlocks)
+	}
+	return nil
+}
+
+// blockSize is the size of each block of a size-byte shard
+func (f ShardFEC) blockSize(size int) int {
+	return (size + f.Blocks - 1) / f.Blocks
+}
+
+// EncodedSize returns the stored size of a size-byte shard
+func (f ShardFEC) EncodedSize(size int) int {
+	return size + f.ParityBlocks*f.blockSize(size) + fecChecksumSize*(f.Blocks+f.ParityBlocks)
+}
+
+// DecodedSize inverts EncodedSize: the size of the shard a stored shard of
+// encoded bytes holds. Returns false if no shard size encodes to that.
+func (f ShardFEC) DecodedSize(encoded int) (int, bool) {
+	total := f.Blocks + f.ParityBlocks
+	rest := encoded - fecChecksumSize*total
+	if f.Blocks <= 0 || f.ParityBlocks < 0 || rest <= 0 {
+		return 0, false
+	}
+	// rest = size + ParityBlocks*ceil(size/Blocks); with size = Blocks*q - t
+	// (0 <= t < Blocks), that is total*q - t
+	q := (rest + total - 1) / total
+	t := total*q - rest
+	if t >= f.Blocks {
+		return 0, false
+	}
+	return f.Blocks*q - t, true
+}
+
+// Encode returns the stored form of a shard: data, parity blocks, checksums
+func (f ShardFEC) Encode(data []byte) ([]byte, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("can't encode an empty shard")
+	}
+	blocks := f.split(data)
+	enc, err := reedsolomon.New(f.Blocks, f.ParityBlocks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create encoder: %w", err)
+	}
+	if err := enc.Encode(blocks); err != nil {
+		return nil, fmt.Errorf("failed to encode shard FEC: %w", err)
+	}
+
+	out := make([]byte, 0, f.EncodedSize(len(data)))
+	out = append(out, data...)
+	for _, parity := range blocks[f.Blocks:] {
+		out = append(out, parity...)
+	}
+	for _, block := range blocks {
+		out = binary.BigEndian.AppendUint32(out, crc32.Checksum(block, fecTable))
+	}
+	return out, nil
+}
+
+// Decode returns the shard a stored shard holds, correcting damaged blocks,
+// and how many blocks were corrected. It fails if more than ParityBlocks blocks
+// are damaged. A corrected shard should still be checked against its recorded
+// hash (see EncodeShard): a checksum can't rule out every error.
+func (f ShardFEC) Decode(stored []byte) ([]byte, int, error) {
+	if err := f.Validate(); err != nil {
+		return nil, 0, err
+	}
+	size, ok := f.DecodedSize(len(stored))
+	if !ok {
+		return nil, 0, fmt.Errorf("%d bytes is not a valid %d+%d shard FEC size", len(stored), f.Blocks, f.ParityBlocks)
+	}
+	bs := f.blockSize(size)
+	total := f.Blocks + f.ParityBlocks
+
+	blocks := f.split(stored[:size])
+	parity := stored[size : size+f.ParityBlocks*bs]
+	for i := range f.ParityBlocks {
+		blocks[f.Blocks+i] = parity[i*bs : (i+1)*bs]
+	}
+	checksums := stored[size+f.ParityBlocks*bs:]
+
+	damaged := 0
+	for i := range total {
+		if crc32.Checksum(blocks[i], fecTable) != binary.BigEndian.Uint32(checksums[i*fecChecksumSize:]) {
+			blocks[i] = nil
+			damaged++
+		}
+	}
+	if damaged == 0 {
+		return stored[:size], 0, nil
+	}
+	if damaged > f.ParityBlocks {
+		return nil, 0, fmt.Errorf("%d damaged blocks, at most %d can be corrected", damaged, f.ParityBlocks)
+	}
+
+	enc, err := reedsolomon.New(f.Blocks, f.ParityBlocks)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create encoder: %w", err)
+	}
+	if err := enc.ReconstructData(blocks); err != nil {
+		return nil, 0, fmt.Errorf("failed to correct shard: %w", err)
+	}
+	data := make([]byte, 0, f.Blocks*bs)
+	for _, block := range blocks[:f.Blocks] {
+		data = append(data, block...)
+	}
+	return data[:size], damaged, nil
+}
+
+// EncodeShard returns shard in its stored form, with Hash and Size describing
+// the encoded bytes
+func (f ShardFEC) EncodeShard(shard Shard) (Shard, error) {
+	encoded, err := f.Encode(shard.Data)
+	if err != nil {
+		return Shard{}, fmt.Errorf("chunk %d shard %d: %w", shard.ChunkIndex, shard.ShardIndex, err)
+	}
+	hash := sha256.Sum256(encoded)
+	shard.Data = encoded
+	shard.Hash = hex.EncodeToString(hash[:])
+	shard.Size = len(encoded)
+	return shard, nil
+}
+
+// split cuts data into Blocks blocks of equal size, zero-padding the last ones,
+// with room for the parity blocks after them. Full blocks share data's memory.
+func (f ShardFEC) split(data []byte) [][]byte {
+	bs := f.blockSize(len(data))
+	blocks := make([][]byte, f.Blocks+f.ParityBlocks)
+	for i := range f.Blocks {
+		start := min(i*bs, len(data))
+		end := min(start+bs, len(data))
+		if end-start == bs {
+			blocks[i] = data[start:end]
+			continue
+		}
+		blocks[i] = make([]byte, bs)
+		copy(blocks[i], data[start:end])
+	}
+	for i := f.Blocks; i < len(blocks); i++ {
+		blocks[i] = make([]byte, bs)
+	}
+	return blocks
+}
@@ -0,0 +1,264 @@
+package chunker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/scratch"
+)
+
+// AssemblyStateSuffix is appended to the output path to name the sidecar file
+// recording which chunks have been written
+const AssemblyStateSuffix = ".assembly"
+
+// assemblyState is the sidecar persisted next to a partially assembled file
+type assemblyState struct {
+	TotalChunks int    `json:"total_chunks"`
+	ChunkSize   int    `json:"chunk_size"`
+	Received    []byte `json:"received"` // bitmap, bit i set = chunk i written
+}
+
+func newAssemblyState(totalChunks, chunkSize int) *assemblyState {
+	return &assemblyState{
+		TotalChunks: totalChunks,
+		ChunkSize:   chunkSize,
+		Received:    make([]byte, (totalChunks+7)/8),
+	}
+}
+
+func (s *assemblyState) has(i int) bool {
+	return s.Received[i/8]&(1<<(i%8)) != 0
+}
+
+func (s *assemblyState) set(i int) {
+	s.Received[i/8] |= 1 << (i % 8)
+}
+
+func (s *assemblyState) clear(i int) {
+	s.Received[i/8] &^= 1 << (i % 8)
+}
+
+func (s *assemblyState) count() int {
+	n := 0
+	for i := 0; i < s.TotalChunks; i++ {
+		if s.has(i) {
+			n++
+		}
+	}
+	return n
+}
+
+// save writes the sidecar atomically (temp file + rename)
+func (s *assemblyState) save(path string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to marshal assembly state: %w", err)
+	}
+
+	// Temp file must share the sidecar's directory for the rename to be atomic;
+	// the scratch prefix lets scratch.CleanupStale find it after a crash
+	tmp, err := scratch.Create(filepath.Dir(path), "assembly-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write assembly state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write assembly state: %w", err)
+	}
+	return nil
+}
+
+// loadAssemblyState reads the sidecar for outputPath and reconciles it with the
+// file on disk. Returns a fresh state if there is nothing usable to resume from.
+// hashes (optional, one per chunk) lets reconciliation verify written chunk contents.
+//
+// With hashes the sidecar is only a hint: a crash can leave torn writes or
+// zero-filled holes behind chunks it marks, and valid chunks it never got to
+// mark, so every chunk region in the file is verified and exactly the ones
+// matching their hash count as received.
+func loadAssemblyState(outputPath string, totalChunks, chunkSize int, hashes []string) (*assemblyState, error) {
+	if err := checkChunkCount(totalChunks); err != nil {
+		return nil, err
+	}
+	verify := len(hashes) == totalChunks
+	data, err := os.ReadFile(outputPath + AssemblyStateSuffix)
+	if os.IsNotExist(err) && !verify {
+		return newAssemblyState(totalChunks, chunkSize), nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read assembly state: %w", err)
+	}
+
+	var state assemblyState
+	if err != nil || json.Unmarshal(data, &state) != nil ||
+		state.TotalChunks != totalChunks ||
+		state.ChunkSize != chunkSize ||
+		len(state.Received) != (totalChunks+7)/8 {
+		// No sidecar, or it belongs to a different assembly: start over unless
+		// the file's contents can be verified
+		if !verify {
+			return newAssemblyState(totalChunks, chunkSize), nil
+		}
+		state = *newAssemblyState(totalChunks, chunkSize)
+	}
+
+	output, err := os.Open(outputPath)
+	if os.IsNotExist(err) {
+		return newAssemblyState(totalChunks, chunkSize), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open output file: %w", err)
+	}
+	defer output.Close()
+
+	info, err := output.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat output file: %w", err)
+	}
+
+	// Drop any chunk the file doesn't actually hold
+	for i := 0; i < totalChunks; i++ {
+		if !state.has(i) && !verify {
+			continue
+		}
+		if chunkOnDisk(output, info.Size(), i, totalChunks, chunkSize, hashes) {
+			state.set(i)
+		} else {
+			state.clear(i)
+		}
+	}
+	return &state, nil
+}
+
+// chunkOnDisk reports whether chunk i appears to be fully written to output.
+// Without a hash only the file length can be checked.
+func chunkOnDisk(output *os.File, fileSize int64, i, totalChunks, chunkSize int, hashes []string) bool {
+	offset := int64(i) * int64(chunkSize)
+	end := offset + int64(chunkSize)
+	if i == totalChunks-1 {
+		end = fileSize // final chunk may be short
+	}
+	if end > fileSize || end <= offset {
+		return false
+	}
+	if len(hashes) != totalChunks {
+		return true
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, io.NewSectionReader(output, offset, end-offset)); err != nil {
+		return false
+	}
+	return hex.EncodeToString(h.Sum(nil)) == hashes[i]
+}
+
+// PendingChunks returns the chunk indices a resumed AssembleChunksResumable still
+// needs, so callers can skip downloading chunks already on disk. Chunks are
+// taken to be the default ChunkSize; see PendingChunksWithConfig.
+func PendingChunks(outputPath string, totalChunks int, hashes []string) ([]int, error) {
+	return PendingChunksWithConfig(outputPath, totalChunks, hashes, DefaultConfig())
+}
+
+// PendingChunksWithConfig is PendingChunks for chunks of cfg.ChunkSize
+func PendingChunksWithConfig(outputPath string, totalChunks int, hashes []string, cfg Config) ([]int, error) {
+	state, err := loadAssemblyState(outputPath, totalChunks, cfg.chunkSize(), hashes)
+	if err != nil {
+		return nil, err
+	}
+	var pending []int
+	for i := 0; i < totalChunks; i++ {
+		if !state.has(i) {
+			pending = append(pending, i)
+		}
+	}
+	return pending, nil
+}
+
+// AssembleChunksResumable is AssembleChunks that survives being interrupted.
+// Written chunks are recorded in a sidecar bitmap (outputPath + AssemblyStateSuffix);
+// a later run reconciles the bitmap with the file, keeps what's there and skips
+// chunks already written. The sidecar is removed once the file is complete.
+// hashes is optional; when given (one per chunk) reconciliation verifies every
+// chunk region in the file, so torn or missing writes are fetched again.
+// Chunks are placed at the default ChunkSize; see AssembleChunksResumableWithConfig.
+func AssembleChunksResumable(chunkStream <-chan Chunk, outputPath string, totalChunks int, hashes []string) error {
+	return AssembleChunksResumableWithConfig(chunkStream, outputPath, totalChunks, hashes, DefaultConfig())
+}
+
+// AssembleChunksResumableWithConfig is AssembleChunksResumable for chunks of
+// cfg.ChunkSize, the size the file was chunked at. The size is recorded in the
+// sidecar, and a sidecar for a different size is not resumed from.
+func AssembleChunksResumableWithConfig(chunkStream <-chan Chunk, outputPath string, totalChunks int, hashes []string, cfg Config) error {
+	chunkSize := cfg.chunkSize()
+	state, err := loadAssemblyState(outputPath, totalChunks, chunkSize, hashes)
+	if err != nil {
+		return err
+	}
+	statePath := outputPath + AssemblyStateSuffix
+
+	// Keep existing contents only when resuming
+	flags := os.O_RDWR | os.O_CREATE
+	if state.count() == 0 {
+		flags |= os.O_TRUNC
+	}
+	output, err := os.OpenFile(outputPath, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open output file: %w", err)
+	}
+	defer output.Close()
+
+	if err := state.save(statePath); err != nil {
+		return err
+	}
+
+	for chunk := range chunkStream {
+		if chunk.Index < 0 || chunk.Index >= totalChunks {
+			return fmt.Errorf("chunk index %d out of bounds (max %d)", chunk.Index, totalChunks-1)
+		}
+		if state.has(chunk.Index) {
+			continue
+		}
+
+		offset := int64(chunk.Index) * int64(chunkSize)
+		if _, err := output.WriteAt(chunk.Data, offset); err != nil {
+			return fmt.Errorf("failed to write chunk %d: %w", chunk.Index, err)
+		}
+		// A rewritten final chunk may be shorter than the garbage it replaced
+		if chunk.Index == totalChunks-1 {
+			if err := output.Truncate(offset + int64(len(chunk.Data))); err != nil {
+				return fmt.Errorf("failed to write chunk %d: %w", chunk.Index, err)
+			}
+		}
+
+		// Record progress only after the data write succeeded
+		state.set(chunk.Index)
+		if err := state.save(statePath); err != nil {
+			return err
+		}
+	}
+
+	if got := state.count(); got != totalChunks {
+		return fmt.Errorf("incomplete file: expected %d chunks, got %d", totalChunks, got)
+	}
+
+	if err := output.Sync(); err != nil {
+		return fmt.Errorf("failed to sync output file: %w", err)
+	}
+	if err := os.Remove(statePath); err != nil {
+		return fmt.Errorf("failed to remove assembly state: %w", err)
+	}
+	return nil
+}
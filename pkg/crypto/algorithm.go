@@ -0,0 +1,175 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+	"io"
+	"slices"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Algorithm names a chunk cipher. The zero value means XChaCha20-Poly1305.
+type Algorithm string
+
+const (
+	AlgXChaCha20Poly1305 Algorithm = "xchacha20-poly1305" // default, 32-byte keys, 24-byte nonces
+	AlgAESGCM            Algorithm = "aes-gcm"            // AES-128/192/256 by key length, 12-byte nonces
+)
+
+// normalize maps the zero value to the default algorithm
+func (a Algorithm) normalize() Algorithm {
+	if a == "" {
+		return AlgXChaCha20Poly1305
+	}
+	return a
+}
+
+// KeySizes returns the key lengths in bytes the algorithm accepts
+func (a Algorithm) KeySizes() []int {
+	switch a.normalize() {
+	case AlgXChaCha20Poly1305:
+		return []int{chacha20poly1305.KeySize}
+	case AlgAESGCM:
+		return []int{16, 24, 32}
+	default:
+		return nil
+	}
+}
+
+// NonceSize returns the nonce length prepended to ciphertexts (0 if unsupported)
+func (a Algorithm) NonceSize() int {
+	switch a.normalize() {
+	case AlgXChaCha20Poly1305:
+		return chacha20poly1305.NonceSizeX
+	case AlgAESGCM:
+		return 12
+	default:
+		return 0
+	}
+}
+
+// Overhead returns the bytes a ciphertext adds to its plaintext: nonce + 16-byte tag
+func (a Algorithm) Overhead() int {
+	if a.NonceSize() == 0 {
+		return 0
+	}
+	return a.NonceSize() + chacha20poly1305.Overhead // both ciphers use 16-byte tags
+}
+
+// ValidateKey checks key length against the algorithm
+func (a Algorithm) ValidateKey(key []byte) error {
+	sizes := a.KeySizes()
+	if sizes == nil {
+		return fmt.Errorf("unsupported algorithm %q", a)
+	}
+	if !slices.Contains(sizes, len(key)) {
+		return fmt.Errorf("invalid key size for %s: expected one of %v, got %d", a.normalize(), sizes, len(key))
+	}
+	return nil
+}
+
+// newAEAD builds the cipher for an algorithm after validating the key
+func newAEAD(alg Algorithm, key []byte) (cipher.AEAD, error) {
+	if err := alg.ValidateKey(key); err != nil {
+		return nil, err
+	}
+
+	var aead cipher.AEAD
+	var err error
+	switch alg.normalize() {
+	case AlgXChaCha20Poly1305:
+		aead, err = chacha20poly1305.NewX(key)
+	case AlgAESGCM:
+		var block cipher.Block
+		if block, err = aes.NewCipher(key); err == nil {
+			aead, err = cipher.NewGCM(block)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return aead, nil
+}
+
+// GenerateKeySize creates a random key of n bytes.
+// n must be a key size of a supported algorithm: 16 or 24 (AES-GCM only) or 32.
+// EncryptChunkWith checks the length against the algorithm actually used.
+func GenerateKeySize(n int) ([]byte, error) {
+	if !slices.Contains(AlgXChaCha20Poly1305.KeySizes(), n) && !slices.Contains(AlgAESGCM.KeySizes(), n) {
+		return nil, fmt.Errorf("unsupported key size %d", n)
+	}
+
+	key := make([]byte, n)
+	if _, err := io.ReadFull(randReader, key); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	return key, nil
+}
+
+// EncryptChunkWith encrypts a chunk with the given algorithm
+// Returns: [nonce|ciphertext|authentication_tag]
+func EncryptChunkWith(alg Algorithm, plaintext []byte, key []byte) ([]byte, error) {
+	return encryptChunk(alg, plaintext, key, randReader, nil)
+}
+
+// DecryptChunkWith decrypts a chunk encrypted with EncryptChunkWith
+func DecryptChunkWith(alg Algorithm, ciphertext []byte, key []byte) ([]byte, error) {
+	return decryptChunk(alg, ciphertext, key, nil)
+}
+
+// decryptChunk opens [nonce|ciphertext|tag], authenticating aad along with it
+func decryptChunk(alg Algorithm, ciphertext []byte, key []byte, aad []byte) ([]byte, error) {
+	aead, err := newAEAD(alg, key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Nonce length comes from the algorithm; anything shorter than nonce + tag
+	// can't be its output
+	if minLen := aead.NonceSize() + aead.Overhead(); len(ciphertext) < minLen {
+		return nil, fmt.Errorf("ciphertext too short for %s: expected at least %d bytes (%d-byte nonce + %d-byte tag), got %d",
+			alg.normalize(), minLen, aead.NonceSize(), aead.Overhead(), len(ciphertext))
+	}
+
+	// Split nonce and actual ciphertext
+	nonce := ciphertext[:aead.NonceSize()]
+	ciphertext = ciphertext[aead.NonceSize():]
+
+	// Decrypt and verify authentication tag
+	plaintext, err := aead.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed with %s (wrong key, wrong algorithm or tampered data): %w", alg.normalize(), err)
+	}
+
+	return plaintext, nil
+}
+
+// encryptChunk seals plaintext under a fresh nonce read from nonceSource,
+// authenticating aad (which isn't stored) along with it
+func encryptChunk(alg Algorithm, plaintext []byte, key []byte, nonceSource io.Reader, aad []byte) ([]byte, error) {
+	aead, err := newAEAD(alg, key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Default to the system CSPRNG
+	if nonceSource == nil {
+		nonceSource = randReader
+	}
+
+	// ReadFull guards against a source returning fewer bytes than requested
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(nonceSource, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	// Debug builds (-tags noncedebug) refuse to reuse a nonce under the same key
+	if err := checkNonceReuse(key, nonce); err != nil {
+		return nil, err
+	}
+
+	// output = nonce || ciphertext || tag
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
@@ -0,0 +1,84 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// CommitmentSize is the length of the key commitment in committed chunks
+const CommitmentSize = sha256.Size
+
+// CommittedOverhead is Overhead plus the key commitment: 72 bytes
+const CommittedOverhead = Overhead + CommitmentSize
+
+// chunkCommitmentLabel is MACed together with each chunk's nonce
+const chunkCommitmentLabel = "dbxn chunk commitment v1"
+
+// ErrCommitmentMismatch is returned when a committed chunk is opened with a key
+// other than the one it was sealed with
+var ErrCommitmentMismatch = errors.New("key commitment mismatch")
+
+// EncryptChunkCommitted encrypts a chunk like EncryptChunk and binds it to the key.
+// Poly1305 alone isn't key-committing: a ciphertext can be crafted that opens under
+// two keys. The commitment, an HMAC-SHA256 of a label and the nonce under the key,
+// can't be matched by a second key, so DecryptChunkCommitted rejects it.
+// Returns: [nonce|commitment|ciphertext|authentication_tag]
+func EncryptChunkCommitted(plaintext []byte, key []byte) ([]byte, error) {
+	return encryptChunkCommitted(plaintext, key, randReader, nil)
+}
+
+// DecryptChunkCommitted checks the key commitment of a chunk encrypted with
+// EncryptChunkCommitted, then decrypts it
+func DecryptChunkCommitted(ciphertext []byte, key []byte) ([]byte, error) {
+	return decryptChunkCommitted(ciphertext, key, nil)
+}
+
+// decryptChunkCommitted is DecryptChunkCommitted authenticating aad as well
+func decryptChunkCommitted(ciphertext []byte, key []byte, aad []byte) ([]byte, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("invalid key size: expected %d, got %d", KeySize, len(key))
+	}
+	if len(ciphertext) < CommittedOverhead {
+		return nil, fmt.Errorf("committed ciphertext too short: expected at least %d bytes, got %d", CommittedOverhead, len(ciphertext))
+	}
+
+	nonce := ciphertext[:NonceSize]
+	commitment := ciphertext[NonceSize : NonceSize+CommitmentSize]
+	if !hmac.Equal(commitment, chunkCommitment(key, nonce)) {
+		return nil, ErrCommitmentMismatch
+	}
+
+	// Reassemble [nonce|ciphertext|tag] for the plain AEAD
+	sealed := make([]byte, 0, len(ciphertext)-CommitmentSize)
+	sealed = append(sealed, nonce...)
+	sealed = append(sealed, ciphertext[NonceSize+CommitmentSize:]...)
+	return decryptChunk(AlgXChaCha20Poly1305, sealed, key, aad)
+}
+
+// encryptChunkCommitted is EncryptChunkCommitted with an explicit nonce source,
+// authenticating aad as well
+func encryptChunkCommitted(plaintext []byte, key []byte, nonceSource io.Reader, aad []byte) ([]byte, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("invalid key size: expected %d, got %d", KeySize, len(key))
+	}
+	sealed, err := encryptChunk(AlgXChaCha20Poly1305, plaintext, key, nonceSource, aad)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(sealed)+CommitmentSize)
+	out = append(out, sealed[:NonceSize]...)
+	out = append(out, chunkCommitment(key, sealed[:NonceSize])...)
+	return append(out, sealed[NonceSize:]...), nil
+}
+
+// chunkCommitment returns HMAC-SHA256(key, label || nonce)
+func chunkCommitment(key, nonce []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(chunkCommitmentLabel))
+	mac.Write(nonce)
+	return mac.Sum(nil)
+}
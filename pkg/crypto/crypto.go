@@ -3,18 +3,26 @@ package crypto
 import (
 	"crypto/rand"
 	"fmt"
+	"io"
 
 	"golang.org/x/crypto/chacha20poly1305"
 )
 
 const KeySize = 32 // 32 bytes / 256 bits for encryption key
+const NonceSize = chacha20poly1305.NonceSizeX           // 24 bytes prepended to each ciphertext
+const Overhead = NonceSize + chacha20poly1305.Overhead // 40 bytes: nonce + 16-byte auth tag
+
+// randReader is the source of every key and nonce this package generates.
+// Testing only: tests may swap in a deterministic reader to assert exact
+// values; production code must leave it as crypto/rand.Reader.
+var randReader io.Reader = rand.Reader
 
 // GenerateKey creates a new random 256-bit encryption key and returns it
 func GenerateKey() ([]byte, error) {
 	// Allocate byte slice for key
 	key := make([]byte, KeySize)
 	// Fill with cryptographically secure random bytes
-	_, err := rand.Read(key)
+	_, err := io.ReadFull(randReader, key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate key: %w", err)
 	}
@@ -25,28 +33,20 @@ func GenerateKey() ([]byte, error) {
 // EncryptChunk encrypts a chunk with XChaCha20-Poly1305 AEAD
 // Returns: [nonce|ciphertext|authentication_tag]
 func EncryptChunk(plaintext []byte, key []byte) ([]byte, error) {
+	return EncryptChunkWithRand(plaintext, key, randReader)
+}
+
+// EncryptChunkWithRand is EncryptChunk with an explicit nonce source.
+// A nil nonceSource falls back to crypto/rand.Reader (see randReader). Useful for deterministic
+// tests (fixed reader) or routing nonce generation through an HSM.
+func EncryptChunkWithRand(plaintext []byte, key []byte, nonceSource io.Reader) ([]byte, error) {
 	// Validate key size
 	if len(key) != KeySize {
 		return nil, fmt.Errorf("invalid key size: expected %d, got %d", KeySize, len(key))
 	}
 
-	// Create AEAD (Authenticated Encryption with Associated Data) cipher
-	aead, err := chacha20poly1305.NewX(key)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create cipher: %w", err)
-	}
-
-	// Generate random nonce
-	nonce := make([]byte, aead.NonceSize())    // 24 bytes (192 bits) for XChaCha20
-	if _, err := rand.Read(nonce); err != nil {
-		return nil, fmt.Errorf("failed to generate nonce: %w", err)
-	}
-
-	// Encrypt: output = nonce + ciphertext + tag
-	// We pass nonce as dst so output = nonce || ciphertext || tag
-	ciphertext := aead.Seal(nonce, nonce, plaintext, nil) // seal(dst, nonce, plaintext, additionalData) (output = nonce || ciphertext || tag) where nonce is used for encryption/decryption
-
-	return ciphertext, nil
+	// Encrypt: output = nonce + ciphertext + tag (24-byte XChaCha20 nonce)
+	return encryptChunk(AlgXChaCha20Poly1305, plaintext, key, nonceSource, nil)
 }
 
 
@@ -57,26 +57,5 @@ func DecryptChunk(ciphertext []byte, key []byte) ([]byte, error) {
 		return nil, fmt.Errorf("invalid key size: expected %d, got %d", KeySize, len(key))
 	}
 
-	// Create AEAD (Authenticated Encryption with Associated Data) cipher
-	aead, err := chacha20poly1305.NewX(key)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create cipher: %w", err)
-	}
-
-	// Validate ciphertext length
-	if len(ciphertext) < aead.NonceSize() {
-		return nil, fmt.Errorf("ciphertext too short: expected at least %d bytes, got %d", aead.NonceSize(), len(ciphertext))
-	}
-
-	// Split nonce and actual ciphertext
-	nonce := ciphertext[:aead.NonceSize()]
-	ciphertext = ciphertext[aead.NonceSize():]
-
-	// Decrypt and verify authentication tag
-	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
-	if err != nil {
-		return nil, fmt.Errorf("decryption failed (wrong key or tampered data): %w", err)
-	}
-
-	return plaintext, nil
-}
\ No newline at end of file
+	return DecryptChunkWith(AlgXChaCha20Poly1305, ciphertext, key)
+}
@@ -2,6 +2,11 @@ package crypto
 
 import (
 	"bytes"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"errors"
+	mathrand "math/rand"
+	"strings"
 	"testing"
 )
 
@@ -161,3 +166,463 @@ func TestDecrypt_TooShortCiphertext(t *testing.T) {
 		t.Error("Should fail with ciphertext shorter than nonce size")
 	}
 }
+
+func TestEncryptChunkWithRand_Deterministic(t *testing.T) {
+	if nonceReuseCheck {
+		t.Skip("reuses a fixed nonce on purpose; refused by noncedebug builds")
+	}
+
+	key := bytes.Repeat([]byte{0x42}, KeySize)
+	plaintext := []byte("deterministic nonce test")
+
+	// Same fixed nonce source must produce identical ciphertexts
+	c1, err := EncryptChunkWithRand(plaintext, key, bytes.NewReader(bytes.Repeat([]byte{0x01}, 24)))
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+	c2, err := EncryptChunkWithRand(plaintext, key, bytes.NewReader(bytes.Repeat([]byte{0x01}, 24)))
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+
+	if !bytes.Equal(c1, c2) {
+		t.Error("Fixed nonce source should produce identical ciphertexts")
+	}
+
+	// Nonce prefix should be exactly the bytes read from the source
+	if !bytes.Equal(c1[:24], bytes.Repeat([]byte{0x01}, 24)) {
+		t.Error("Ciphertext should be prefixed with the injected nonce")
+	}
+
+	decrypted, err := DecryptChunk(c1, key)
+	if err != nil {
+		t.Fatalf("Decryption failed: %v", err)
+	}
+	if !bytes.Equal(plaintext, decrypted) {
+		t.Error("Decrypted text doesn't match original")
+	}
+}
+
+func TestEncryptChunkWithRand_ShortNonceSource(t *testing.T) {
+	key, _ := GenerateKey()
+
+	// Source runs dry before a full 24-byte nonce is read
+	_, err := EncryptChunkWithRand([]byte("test"), key, bytes.NewReader(make([]byte, 10)))
+	if err == nil {
+		t.Error("Should fail when nonce source returns fewer bytes than requested")
+	}
+}
+
+func TestEncryptChunkWithRand_NilSource(t *testing.T) {
+	key, _ := GenerateKey()
+	plaintext := []byte("nil source falls back to crypto/rand")
+
+	ciphertext, err := EncryptChunkWithRand(plaintext, key, nil)
+	if err != nil {
+		t.Fatalf("Encryption with nil source failed: %v", err)
+	}
+
+	decrypted, err := DecryptChunk(ciphertext, key)
+	if err != nil {
+		t.Fatalf("Decryption failed: %v", err)
+	}
+	if !bytes.Equal(plaintext, decrypted) {
+		t.Error("Decrypted text doesn't match original")
+	}
+}
+
+func TestEncryptChunkCommitted_Vectors(t *testing.T) {
+	if nonceReuseCheck {
+		t.Skip("reuses a fixed nonce on purpose; refused by noncedebug builds")
+	}
+
+	keyA := bytes.Repeat([]byte{0x42}, KeySize)
+	keyB := bytes.Repeat([]byte{0x43}, KeySize)
+	nonce := bytes.Repeat([]byte{0x01}, NonceSize)
+	plaintext := []byte("committed chunk")
+
+	// HMAC-SHA256(key, "dbxn chunk commitment v1" || nonce)
+	vectors := map[string][]byte{
+		"53c39cfcb34e3597f476f595777ef15c994f2f1c498e743736bef0df843ca582": keyA,
+		"b030fa74bd62735093726299ee838f977966a0d206d4d93a6cd95b7948ff737a": keyB,
+	}
+	for want, key := range vectors {
+		if got := KeyToHex(chunkCommitment(key, nonce)); got != want {
+			t.Errorf("Commitment = %s, want %s", got, want)
+		}
+	}
+
+	sealed, err := encryptChunkCommitted(plaintext, keyA, bytes.NewReader(nonce), nil)
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+	if len(sealed) != len(plaintext)+CommittedOverhead {
+		t.Errorf("Expected %d bytes, got %d", len(plaintext)+CommittedOverhead, len(sealed))
+	}
+	if KeyToHex(sealed[NonceSize:NonceSize+CommitmentSize]) != "53c39cfcb34e3597f476f595777ef15c994f2f1c498e743736bef0df843ca582" {
+		t.Error("Commitment should follow the nonce")
+	}
+
+	got, err := DecryptChunkCommitted(sealed, keyA)
+	if err != nil {
+		t.Fatalf("Decryption failed: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Error("Decrypted text doesn't match original")
+	}
+
+	// A substituted key is rejected by the commitment, before the AEAD is tried
+	if _, err := DecryptChunkCommitted(sealed, keyB); !errors.Is(err, ErrCommitmentMismatch) {
+		t.Errorf("Expected ErrCommitmentMismatch for substituted key, got %v", err)
+	}
+
+	// Swapping in a commitment for the other key doesn't help either way
+	forged := bytes.Clone(sealed)
+	copy(forged[NonceSize:], chunkCommitment(keyB, nonce))
+	if _, err := DecryptChunkCommitted(forged, keyA); !errors.Is(err, ErrCommitmentMismatch) {
+		t.Errorf("Expected ErrCommitmentMismatch for forged commitment, got %v", err)
+	}
+	if _, err := DecryptChunkCommitted(forged, keyB); err == nil {
+		t.Error("Forged commitment should not make the chunk open under another key")
+	}
+
+	// Uncommitted and committed formats don't mix
+	if _, err := DecryptChunk(sealed, keyA); err == nil {
+		t.Error("DecryptChunk should not open a committed chunk")
+	}
+	if _, err := DecryptChunkCommitted(sealed[:CommittedOverhead-1], keyA); err == nil {
+		t.Error("Expected error for short committed ciphertext")
+	}
+}
+
+func TestEncryptChunkWithOptions_AAD(t *testing.T) {
+	key, _ := GenerateKey()
+	plaintext := []byte("bound to its place")
+	aad := []byte("blob-a/1.0/0")
+
+	for _, committed := range []bool{false, true} {
+		opts := ChunkOptions{Committed: committed, AAD: aad}
+		sealed, err := EncryptChunkWithOptions(plaintext, key, opts)
+		if err != nil {
+			t.Fatalf("committed=%v: encrypt failed: %v", committed, err)
+		}
+		if len(sealed) != len(plaintext)+opts.Overhead() {
+			t.Errorf("committed=%v: expected %d bytes, got %d", committed, len(plaintext)+opts.Overhead(), len(sealed))
+		}
+
+		got, err := DecryptChunkWithOptions(sealed, key, opts)
+		if err != nil || !bytes.Equal(got, plaintext) {
+			t.Errorf("committed=%v: round trip failed: %v", committed, err)
+		}
+		if _, err := DecryptChunkWithOptions(sealed, key, ChunkOptions{Committed: committed, AAD: []byte("blob-b/1.0/0")}); err == nil {
+			t.Errorf("committed=%v: chunk opened with different associated data", committed)
+		}
+		if _, err := DecryptChunkWithOptions(sealed, key, ChunkOptions{Committed: committed}); err == nil {
+			t.Errorf("committed=%v: chunk opened without its associated data", committed)
+		}
+	}
+}
+
+// useSeededRand makes key and nonce generation deterministic for the rest of the test
+func useSeededRand(t *testing.T, seed int64) {
+	t.Helper()
+	saved := randReader
+	randReader = mathrand.New(mathrand.NewSource(seed))
+	t.Cleanup(func() { randReader = saved })
+}
+
+func TestRandReader_Deterministic(t *testing.T) {
+	if nonceReuseCheck {
+		t.Skip("replays the same nonces on purpose; refused by noncedebug builds")
+	}
+
+	generate := func() (key, ciphertext []byte) {
+		useSeededRand(t, 42)
+		key, err := GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ciphertext, err = EncryptChunk([]byte("golden"), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key, ciphertext
+	}
+
+	key1, c1 := generate()
+	key2, c2 := generate()
+	if !bytes.Equal(key1, key2) || !bytes.Equal(c1, c2) {
+		t.Error("Same seed should reproduce keys and ciphertexts exactly")
+	}
+	// Nonces keep advancing within one run
+	if c3, _ := EncryptChunk([]byte("golden"), key1); bytes.Equal(c1[:NonceSize], c3[:NonceSize]) {
+		t.Error("Consecutive nonces from the seeded reader should differ")
+	}
+}
+
+func TestGenerateKeySize(t *testing.T) {
+	for _, n := range []int{16, 24, 32} {
+		key, err := GenerateKeySize(n)
+		if err != nil {
+			t.Errorf("GenerateKeySize(%d) failed: %v", n, err)
+			continue
+		}
+		if len(key) != n {
+			t.Errorf("Expected %d-byte key, got %d", n, len(key))
+		}
+	}
+
+	for _, n := range []int{0, 8, 31, 64} {
+		if _, err := GenerateKeySize(n); err == nil {
+			t.Errorf("Expected error for key size %d", n)
+		}
+	}
+}
+
+func TestEncryptChunkWith_AESKeySizes(t *testing.T) {
+	plaintext := []byte("AES-GCM chunk payload")
+
+	for _, n := range []int{16, 24, 32} {
+		key, _ := GenerateKeySize(n)
+		ciphertext, err := EncryptChunkWith(AlgAESGCM, plaintext, key)
+		if err != nil {
+			t.Fatalf("AES-%d encrypt failed: %v", n*8, err)
+		}
+		decrypted, err := DecryptChunkWith(AlgAESGCM, ciphertext, key)
+		if err != nil {
+			t.Fatalf("AES-%d decrypt failed: %v", n*8, err)
+		}
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("AES-%d round trip mismatch", n*8)
+		}
+	}
+}
+
+func TestEncryptChunkWith_KeyValidatedPerCipher(t *testing.T) {
+	key16, _ := GenerateKeySize(16)
+
+	// 16-byte keys are AES-only
+	if _, err := EncryptChunkWith(AlgXChaCha20Poly1305, []byte("x"), key16); err == nil {
+		t.Error("Expected XChaCha20 to reject a 16-byte key")
+	}
+	if _, err := EncryptChunkWith("", []byte("x"), key16); err == nil {
+		t.Error("Expected default algorithm to reject a 16-byte key")
+	}
+	if _, err := EncryptChunkWith("rot13", []byte("x"), key16); err == nil {
+		t.Error("Expected unknown algorithm to be rejected")
+	}
+
+	// GenerateKey stays 32 bytes and works with the default cipher
+	key, _ := GenerateKey()
+	ciphertext, err := EncryptChunkWith("", []byte("x"), key)
+	if err != nil {
+		t.Fatalf("Default encrypt failed: %v", err)
+	}
+	if _, err := DecryptChunk(ciphertext, key); err != nil {
+		t.Errorf("DecryptChunk can't read default EncryptChunkWith output: %v", err)
+	}
+}
+
+func TestDecryptChunkWith_AlgorithmMismatch(t *testing.T) {
+	key, _ := GenerateKey()
+
+	// AES-GCM output of an empty chunk (28 bytes) is shorter than any XChaCha20 ciphertext
+	aesCiphertext, err := EncryptChunkWith(AlgAESGCM, nil, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aesCiphertext) != AlgAESGCM.Overhead() {
+		t.Errorf("Expected %d bytes, got %d", AlgAESGCM.Overhead(), len(aesCiphertext))
+	}
+	_, err = DecryptChunkWith(AlgXChaCha20Poly1305, aesCiphertext, key)
+	if err == nil || !strings.Contains(err.Error(), "too short for xchacha20-poly1305") {
+		t.Errorf("Expected explicit too-short error naming the algorithm, got %v", err)
+	}
+
+	// Longer ciphertexts can only fail authentication, but the error still names the algorithm
+	aesCiphertext, _ = EncryptChunkWith(AlgAESGCM, make([]byte, 100), key)
+	_, err = DecryptChunkWith(AlgXChaCha20Poly1305, aesCiphertext, key)
+	if err == nil || !strings.Contains(err.Error(), "xchacha20-poly1305") {
+		t.Errorf("Expected error naming the algorithm, got %v", err)
+	}
+
+	if _, err := DecryptChunkWith(AlgAESGCM, make([]byte, 20), key); err == nil || !strings.Contains(err.Error(), "aes-gcm") {
+		t.Errorf("Expected too-short error naming aes-gcm, got %v", err)
+	}
+}
+
+func TestGenerateEd25519KeyPair_HexRoundTrip(t *testing.T) {
+	pub, priv, err := GenerateEd25519KeyPair()
+	if err != nil {
+		t.Fatalf("GenerateEd25519KeyPair failed: %v", err)
+	}
+
+	decodedPub, err := Ed25519PublicKeyFromHex(KeyToHex(pub))
+	if err != nil {
+		t.Fatalf("Ed25519PublicKeyFromHex failed: %v", err)
+	}
+	decodedPriv, err := Ed25519PrivateKeyFromHex(KeyToHex(priv))
+	if err != nil {
+		t.Fatalf("Ed25519PrivateKeyFromHex failed: %v", err)
+	}
+
+	// Keys survive serialization well enough to sign and verify
+	msg := []byte("manifest bytes")
+	if !ed25519.Verify(decodedPub, msg, ed25519.Sign(decodedPriv, msg)) {
+		t.Error("Signature from decoded keys failed to verify")
+	}
+
+	if _, err := Ed25519PublicKeyFromHex(KeyToHex(pub[:16])); err == nil {
+		t.Error("Expected error for short public key")
+	}
+	if _, err := Ed25519PublicKeyFromHex("not-hex"); err == nil {
+		t.Error("Expected error for invalid hex")
+	}
+
+	// A private key whose public half belongs to another seed is rejected
+	otherPub, _, _ := GenerateEd25519KeyPair()
+	mismatched := append(append([]byte(nil), priv.Seed()...), otherPub...)
+	if _, err := Ed25519PrivateKeyFromHex(KeyToHex(mismatched)); err == nil {
+		t.Error("Expected error for private key with mismatched public half")
+	}
+
+	addr := AddressFromPublicKey(pub)
+	if len(addr) != 42 || !strings.HasPrefix(addr, "0x") {
+		t.Errorf("Unexpected address format: %s", addr)
+	}
+	if AddressFromPublicKey(otherPub) == addr {
+		t.Error("Different keys should derive different addresses")
+	}
+}
+
+func TestGenerateX25519KeyPair_Agreement(t *testing.T) {
+	alicePub, alicePriv, err := GenerateX25519KeyPair()
+	if err != nil {
+		t.Fatalf("GenerateX25519KeyPair failed: %v", err)
+	}
+	bobPub, bobPriv, _ := GenerateX25519KeyPair()
+	if len(alicePub) != X25519KeySize || len(alicePriv) != X25519KeySize {
+		t.Fatalf("Expected %d-byte keys, got %d/%d", X25519KeySize, len(alicePub), len(alicePriv))
+	}
+
+	// Both sides derive the same shared secret from hex-serialized keys
+	shared := func(privHex, pubHex string) []byte {
+		privBytes, err := KeyFromHex(privHex, X25519KeySize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubBytes, err := KeyFromHex(pubHex, X25519KeySize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		priv, _ := ecdh.X25519().NewPrivateKey(privBytes)
+		pub, _ := ecdh.X25519().NewPublicKey(pubBytes)
+		secret, err := priv.ECDH(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return secret
+	}
+	if !bytes.Equal(shared(KeyToHex(alicePriv), KeyToHex(bobPub)), shared(KeyToHex(bobPriv), KeyToHex(alicePub))) {
+		t.Error("Shared secrets differ")
+	}
+}
+
+func TestDeriveKey_Vectors(t *testing.T) {
+	// Fixed outputs: a change here means existing passphrase-protected blobs no
+	// longer open. The scrypt and PBKDF2 vectors match Python's hashlib.
+	salt := "00112233445566778899aabbccddeeff"
+	passphrase := []byte("correct horse battery staple")
+	tests := []struct {
+		params KDFParams
+		want   string
+	}{
+		{KDFParams{Algorithm: KDFArgon2id, Salt: salt, Iterations: 2, Memory: 19 * 1024, Parallelism: 1},
+			"ccd958f3ead5af13ebc914232f7d0627baf8b4e8192ec2ba815443316cbf703d"},
+		{KDFParams{Algorithm: KDFScrypt, Salt: salt, Memory: 32 * 1024, Parallelism: 1},
+			"ecf058348a9bfd4febce50a1ae9205da2720790fccdae3644bf0ed98c9740302"},
+		{KDFParams{Algorithm: KDFPBKDF2, Salt: salt, Iterations: 600000},
+			"7c0123695eb46911838d4c16fa259d7280c59060c6031130b8269b624faacd02"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.params.Algorithm), func(t *testing.T) {
+			key, err := DeriveKey(passphrase, tt.params)
+			if err != nil {
+				t.Fatalf("DeriveKey failed: %v", err)
+			}
+			if got := KeyToHex(key); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestKDFParams_Validate(t *testing.T) {
+	for _, alg := range []KDF{KDFArgon2id, KDFScrypt, KDFPBKDF2} {
+		p, err := DefaultKDFParams(alg)
+		if err != nil {
+			t.Fatalf("DefaultKDFParams(%s) failed: %v", alg, err)
+		}
+		if err := p.Validate(); err != nil {
+			t.Errorf("Default %s params rejected: %v", alg, err)
+		}
+		other, _ := DefaultKDFParams(alg)
+		if other.Salt == p.Salt {
+			t.Errorf("Expected a fresh salt for every %s default", alg)
+		}
+	}
+
+	salt := "00112233445566778899aabbccddeeff"
+	weak := []KDFParams{
+		{Algorithm: KDFArgon2id, Salt: salt, Iterations: 1, Memory: 19 * 1024, Parallelism: 1},
+		{Algorithm: KDFArgon2id, Salt: salt, Iterations: 2, Memory: 1024, Parallelism: 1},
+		{Algorithm: KDFArgon2id, Salt: salt, Iterations: 2, Memory: 19 * 1024},
+		{Algorithm: KDFScrypt, Salt: salt, Memory: 16 * 1024, Parallelism: 1},
+		{Algorithm: KDFScrypt, Salt: salt, Memory: 40 * 1024, Parallelism: 1},
+		{Algorithm: KDFScrypt, Salt: salt, Iterations: 5, Memory: 32 * 1024, Parallelism: 1},
+		{Algorithm: KDFPBKDF2, Salt: salt, Iterations: 1000},
+		{Algorithm: KDFPBKDF2, Salt: "0011", Iterations: 600000},
+		{Algorithm: KDFArgon2id, Salt: salt, Iterations: 2, Memory: 8 << 20, Parallelism: 1},
+		{Algorithm: "md5", Salt: salt, Iterations: 600000},
+	}
+	for _, p := range weak {
+		if err := p.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", p)
+		}
+		if _, err := DeriveKey([]byte("pw"), p); err == nil {
+			t.Errorf("Expected DeriveKey to refuse %+v", p)
+		}
+	}
+}
+
+func benchmarkChunkCipher(b *testing.B, decrypt bool) {
+	key, _ := GenerateKey()
+	plaintext := make([]byte, 1024*1024)
+	mathrand.Read(plaintext)
+	ciphertext, err := EncryptChunk(plaintext, key)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetBytes(int64(len(plaintext)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if decrypt {
+			_, err = DecryptChunk(ciphertext, key)
+		} else {
+			_, err = EncryptChunk(plaintext, key)
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncryptChunk(b *testing.B) {
+	benchmarkChunkCipher(b, false)
+}
+
+func BenchmarkDecryptChunk(b *testing.B) {
+	benchmarkChunkCipher(b, true)
+}
@@ -0,0 +1,153 @@
+package crypto
+
+import (
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// KDF names a passphrase key derivation function (KDFParams.Algorithm)
+type KDF string
+
+const (
+	// KDFArgon2id is the preferred KDF: memory-hard, so guessing is costly on GPUs,
+	// but needs that memory on every device deriving the key
+	KDFArgon2id KDF = "argon2id"
+	// KDFScrypt is memory-hard too, for platforms without Argon2id
+	KDFScrypt KDF = "scrypt"
+	// KDFPBKDF2 is PBKDF2-HMAC-SHA256: not memory-hard, for compliance regimes that mandate it
+	KDFPBKDF2 KDF = "pbkdf2-sha256"
+)
+
+// Minimums KDFParams.Validate enforces, following current OWASP guidance, and
+// maximums that keep a hostile manifest from making derivation exhaust the host
+const (
+	MinKDFSaltSize       = 16
+	MinArgon2idMemory    = 19 * 1024 // KiB
+	MinArgon2idTime      = 2
+	MinScryptMemory      = 32 * 1024 // KiB
+	MinPBKDF2Iterations  = 600000
+	maxKDFMemory         = 4 << 20 // KiB (4GiB)
+	maxKDFIterations     = 100000000
+	maxArgon2idTime      = 100
+	maxScryptParallelism = 16
+)
+
+// scryptBlockSize is scrypt's r, fixed so that N equals the memory cost in KiB
+// (128 * r bytes per unit of N)
+const scryptBlockSize = 8
+
+// KDFParams records how an encryption key was derived from a passphrase, so
+// anyone holding the passphrase derives the same key. Which fields apply depends
+// on Algorithm; the others must be 0:
+//   - argon2id: Iterations (time cost), Memory (KiB) and Parallelism (lanes)
+//   - scrypt: Memory (KiB, a power of two: N with r = 8) and Parallelism (p)
+//   - pbkdf2-sha256: Iterations
+type KDFParams struct {
+	Algorithm   KDF    `json:"algorithm"`
+	Salt        string `json:"salt"` // hex, at least MinKDFSaltSize bytes
+	Iterations  int    `json:"iterations,omitempty"`
+	Memory      int    `json:"memory,omitempty"`
+	Parallelism int    `json:"parallelism,omitempty"`
+}
+
+// DefaultKDFParams returns recommended parameters for alg with a fresh random salt
+func DefaultKDFParams(alg KDF) (KDFParams, error) {
+	var p KDFParams
+	switch alg {
+	case KDFArgon2id:
+		p = KDFParams{Iterations: 3, Memory: 64 * 1024, Parallelism: 4} // RFC 9106 second recommended option
+	case KDFScrypt:
+		p = KDFParams{Memory: 64 * 1024, Parallelism: 1}
+	case KDFPBKDF2:
+		p = KDFParams{Iterations: MinPBKDF2Iterations}
+	default:
+		return KDFParams{}, fmt.Errorf("unsupported KDF %q", alg)
+	}
+	salt := make([]byte, 32)
+	if _, err := io.ReadFull(randReader, salt); err != nil {
+		return KDFParams{}, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	p.Algorithm = alg
+	p.Salt = hex.EncodeToString(salt)
+	return p, nil
+}
+
+// Validate rejects unknown algorithms, parameters below the minimums (weak keys)
+// or above the maximums, and parameters the algorithm doesn't use
+func (p KDFParams) Validate() error {
+	salt, err := hex.DecodeString(p.Salt)
+	if err != nil {
+		return fmt.Errorf("invalid KDF salt: %w", err)
+	}
+	if len(salt) < MinKDFSaltSize {
+		return fmt.Errorf("KDF salt must be at least %d bytes, got %d", MinKDFSaltSize, len(salt))
+	}
+
+	switch p.Algorithm {
+	case KDFArgon2id:
+		if p.Iterations < MinArgon2idTime || p.Iterations > maxArgon2idTime {
+			return fmt.Errorf("argon2id iterations must be between %d and %d, got %d", MinArgon2idTime, maxArgon2idTime, p.Iterations)
+		}
+		if p.Memory < MinArgon2idMemory || p.Memory > maxKDFMemory {
+			return fmt.Errorf("argon2id memory must be between %d and %d KiB, got %d", MinArgon2idMemory, maxKDFMemory, p.Memory)
+		}
+		if p.Parallelism < 1 || p.Parallelism > 255 {
+			return fmt.Errorf("argon2id parallelism must be between 1 and 255, got %d", p.Parallelism)
+		}
+	case KDFScrypt:
+		if p.Iterations != 0 {
+			return fmt.Errorf("scrypt takes no iterations; its cost is Memory")
+		}
+		if p.Memory < MinScryptMemory || p.Memory > maxKDFMemory || p.Memory&(p.Memory-1) != 0 {
+			return fmt.Errorf("scrypt memory must be a power of two between %d and %d KiB, got %d", MinScryptMemory, maxKDFMemory, p.Memory)
+		}
+		if p.Parallelism < 1 || p.Parallelism > maxScryptParallelism {
+			return fmt.Errorf("scrypt parallelism must be between 1 and %d, got %d", maxScryptParallelism, p.Parallelism)
+		}
+	case KDFPBKDF2:
+		if p.Memory != 0 || p.Parallelism != 0 {
+			return fmt.Errorf("pbkdf2-sha256 takes only iterations")
+		}
+		if p.Iterations < MinPBKDF2Iterations || p.Iterations > maxKDFIterations {
+			return fmt.Errorf("pbkdf2-sha256 iterations must be between %d and %d, got %d", MinPBKDF2Iterations, maxKDFIterations, p.Iterations)
+		}
+	default:
+		return fmt.Errorf("unsupported KDF %q", p.Algorithm)
+	}
+	return nil
+}
+
+// DeriveKey derives a KeySize encryption key from passphrase. The parameters are
+// validated first, so a manifest can't talk a retriever into a weak derivation.
+func DeriveKey(passphrase []byte, p KDFParams) ([]byte, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("empty passphrase")
+	}
+	salt, _ := hex.DecodeString(p.Salt)
+
+	switch p.Algorithm {
+	case KDFArgon2id:
+		return argon2.IDKey(passphrase, salt, uint32(p.Iterations), uint32(p.Memory), uint8(p.Parallelism), KeySize), nil
+	case KDFScrypt:
+		key, err := scrypt.Key(passphrase, salt, p.Memory, scryptBlockSize, p.Parallelism, KeySize)
+		if err != nil {
+			return nil, fmt.Errorf("scrypt: %w", err)
+		}
+		return key, nil
+	default: // KDFPBKDF2
+		key, err := pbkdf2.Key(sha256.New, string(passphrase), salt, p.Iterations, KeySize)
+		if err != nil {
+			return nil, fmt.Errorf("pbkdf2: %w", err)
+		}
+		return key, nil
+	}
+}
@@ -0,0 +1,91 @@
+package crypto
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// X25519KeySize is the length of X25519 public and private keys
+const X25519KeySize = 32
+
+// GenerateEd25519KeyPair creates a signing keypair (publisher identity, manifest signatures)
+func GenerateEd25519KeyPair() (ed25519.PublicKey, ed25519.PrivateKey, error) {
+	pub, priv, err := ed25519.GenerateKey(randReader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate ed25519 key: %w", err)
+	}
+	return pub, priv, nil
+}
+
+// GenerateX25519KeyPair creates a key-agreement keypair (recipient encryption).
+// Returns the raw 32-byte public and private keys.
+func GenerateX25519KeyPair() (public, private []byte, err error) {
+	key, err := ecdh.X25519().GenerateKey(randReader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate x25519 key: %w", err)
+	}
+	return key.PublicKey().Bytes(), key.Bytes(), nil
+}
+
+// KeyToHex encodes any key material for storage in manifests and config files
+func KeyToHex(key []byte) string {
+	return hex.EncodeToString(key)
+}
+
+// KeyFromHex decodes a hex key and checks it has the expected length
+func KeyFromHex(s string, size int) ([]byte, error) {
+	key, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex key: %w", err)
+	}
+	if len(key) != size {
+		return nil, fmt.Errorf("invalid key size: expected %d, got %d", size, len(key))
+	}
+	return key, nil
+}
+
+// Ed25519PublicKeyFromHex decodes a hex-encoded ed25519 public key
+func Ed25519PublicKeyFromHex(s string) (ed25519.PublicKey, error) {
+	key, err := KeyFromHex(s, ed25519.PublicKeySize)
+	if err != nil {
+		return nil, err
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// Ed25519PrivateKeyFromHex decodes a hex-encoded ed25519 private key (seed + public key)
+func Ed25519PrivateKeyFromHex(s string) (ed25519.PrivateKey, error) {
+	key, err := KeyFromHex(s, ed25519.PrivateKeySize)
+	if err != nil {
+		return nil, err
+	}
+	// The second half must be the public key derived from the seed
+	derived := ed25519.NewKeyFromSeed(key[:ed25519.SeedSize])
+	if !derived.Equal(ed25519.PrivateKey(key)) {
+		return nil, fmt.Errorf("invalid ed25519 private key: public half doesn't match seed")
+	}
+	return ed25519.PrivateKey(key), nil
+}
+
+// keyCommitmentLabel is the fixed message MACed by KeyCommitment
+const keyCommitmentLabel = "dbxn key commitment v1"
+
+// KeyCommitment returns a hex HMAC-SHA256 of a fixed label under key. It identifies
+// the key without revealing it, so a supplied key can be checked before any data is
+// fetched. The label keeps it distinct from any other use of the key.
+func KeyCommitment(key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(keyCommitmentLabel))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// AddressFromPublicKey derives a publisher address from an ed25519 public key:
+// "0x" followed by the first 20 bytes of its SHA256, hex-encoded
+func AddressFromPublicKey(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return "0x" + hex.EncodeToString(sum[:20])
+}
@@ -0,0 +1,12 @@
+//go:build !noncedebug
+
+package crypto
+
+// nonceReuseCheck reports whether nonce-reuse detection is compiled in
+const nonceReuseCheck = false
+
+// checkNonceReuse is a no-op in production builds.
+// Build with -tags noncedebug to enable nonce-reuse detection.
+func checkNonceReuse(key, nonce []byte) error {
+	return nil
+}
@@ -0,0 +1,56 @@
+//go:build noncedebug
+
+package crypto
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sync"
+)
+
+// nonceReuseCheck reports whether nonce-reuse detection is compiled in
+const nonceReuseCheck = true
+
+// nonceHistorySize bounds how many recent nonces are remembered per key
+const nonceHistorySize = 1 << 16
+
+// nonceHistory remembers recently used nonces for one key (FIFO eviction)
+type nonceHistory struct {
+	seen  map[string]struct{}
+	order []string
+}
+
+var (
+	nonceMu sync.Mutex
+	nonces  = make(map[[32]byte]*nonceHistory) // keyed by key hash, never the raw key
+)
+
+// checkNonceReuse records the nonce for this key and errors if it was used before.
+// Debug builds only: reusing a nonce under the same key breaks XChaCha20-Poly1305.
+func checkNonceReuse(key, nonce []byte) error {
+	keyID := sha256.Sum256(key)
+
+	nonceMu.Lock()
+	defer nonceMu.Unlock()
+
+	h, ok := nonces[keyID]
+	if !ok {
+		h = &nonceHistory{seen: make(map[string]struct{})}
+		nonces[keyID] = h
+	}
+
+	n := string(nonce)
+	if _, reused := h.seen[n]; reused {
+		return fmt.Errorf("nonce reuse detected under the same key (nonce %x)", nonce)
+	}
+
+	// Evict oldest once the history is full
+	if len(h.order) >= nonceHistorySize {
+		delete(h.seen, h.order[0])
+		h.order = h.order[1:]
+	}
+	h.seen[n] = struct{}{}
+	h.order = append(h.order, n)
+
+	return nil
+}
@@ -0,0 +1,30 @@
+//go:build noncedebug
+
+package crypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Run with: go test -tags noncedebug ./pkg/crypto
+
+func TestNonceReuse_Detected(t *testing.T) {
+	key, _ := GenerateKey()
+	nonce := bytes.Repeat([]byte{0x07}, NonceSize)
+
+	if _, err := EncryptChunkWithRand([]byte("first"), key, bytes.NewReader(nonce)); err != nil {
+		t.Fatalf("First encryption failed: %v", err)
+	}
+
+	// Same nonce, same key: must be refused
+	if _, err := EncryptChunkWithRand([]byte("second"), key, bytes.NewReader(nonce)); err == nil {
+		t.Error("Expected nonce reuse to be detected")
+	}
+
+	// Same nonce under a different key is fine
+	otherKey, _ := GenerateKey()
+	if _, err := EncryptChunkWithRand([]byte("other"), otherKey, bytes.NewReader(nonce)); err != nil {
+		t.Errorf("Nonce under a different key should be allowed: %v", err)
+	}
+}
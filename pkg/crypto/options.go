@@ -0,0 +1,43 @@
+package crypto
+
+import "fmt"
+
+// ChunkOptions selects optional chunk encryption features. The zero value is
+// EncryptChunk/DecryptChunk.
+type ChunkOptions struct {
+	Committed bool   // add a key commitment (see EncryptChunkCommitted)
+	AAD       []byte // associated data: authenticated with the chunk but not stored in it
+}
+
+// Overhead returns the bytes a chunk encrypted with these options adds to its plaintext
+func (o ChunkOptions) Overhead() int {
+	if o.Committed {
+		return CommittedOverhead
+	}
+	return Overhead
+}
+
+// EncryptChunkWithOptions encrypts a chunk with XChaCha20-Poly1305 and the given
+// options. A chunk encrypted with AAD only opens with the same AAD, so binding
+// it to where the chunk belongs stops it being replayed anywhere else.
+func EncryptChunkWithOptions(plaintext []byte, key []byte, opts ChunkOptions) ([]byte, error) {
+	if opts.Committed {
+		return encryptChunkCommitted(plaintext, key, randReader, opts.AAD)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("invalid key size: expected %d, got %d", KeySize, len(key))
+	}
+	return encryptChunk(AlgXChaCha20Poly1305, plaintext, key, randReader, opts.AAD)
+}
+
+// DecryptChunkWithOptions decrypts a chunk encrypted with EncryptChunkWithOptions;
+// opts must match the ones it was encrypted with
+func DecryptChunkWithOptions(ciphertext []byte, key []byte, opts ChunkOptions) ([]byte, error) {
+	if opts.Committed {
+		return decryptChunkCommitted(ciphertext, key, opts.AAD)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("invalid key size: expected %d, got %d", KeySize, len(key))
+	}
+	return decryptChunk(AlgXChaCha20Poly1305, ciphertext, key, opts.AAD)
+}
@@ -0,0 +1,110 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// ============================================================================
+// KNOWN-ANSWER TESTS
+// ============================================================================
+
+// Fixed inputs shared by the vectors: the key, nonce and plaintext of
+// draft-irtf-cfrg-xchacha-03 appendix A.3.1
+var (
+	vectorKey       = mustHex("808182838485868788898a8b8c8d8e8f909192939495969798999a9b9c9d9e9f")
+	vectorNonce     = mustHex("404142434445464748494a4b4c4d4e4f5051525354555657")
+	vectorPlaintext = []byte("Ladies and Gentlemen of the class of '99: If I could offer you only one tip for the future, sunscreen would be it.")
+)
+
+// xchachaVectors pin the default chunk format, nonce || ciphertext || tag under
+// XChaCha20-Poly1305. A change to any of them changes what every farmer stores.
+var xchachaVectors = []struct {
+	name      string
+	plaintext []byte
+	aad       []byte
+	sealed    string // hex
+}{
+	{
+		name:      "draft A.3.1",
+		plaintext: vectorPlaintext,
+		aad:       mustHex("50515253c0c1c2c3c4c5c6c7"),
+		sealed: "404142434445464748494a4b4c4d4e4f5051525354555657" +
+			"bd6d179d3e83d43b9576579493c0e939572a1700252bfaccbed2902c21396cbb" +
+			"731c7f1b0b4aa6440bf3a82f4eda7e39ae64c6708c54c216cb96b72e1213b452" +
+			"2f8c9ba40db5d945b11b69b982c1bb9e3f3fac2bc369488f76b2383565d3fff9" +
+			"21f9664c97637da9768812f615c68b13b52e" +
+			"c0875924c1c7987947deafd8780acf49",
+	},
+	{
+		name:      "no associated data",
+		plaintext: vectorPlaintext,
+		sealed: "404142434445464748494a4b4c4d4e4f5051525354555657" +
+			"bd6d179d3e83d43b9576579493c0e939572a1700252bfaccbed2902c21396cbb" +
+			"731c7f1b0b4aa6440bf3a82f4eda7e39ae64c6708c54c216cb96b72e1213b452" +
+			"2f8c9ba40db5d945b11b69b982c1bb9e3f3fac2bc369488f76b2383565d3fff9" +
+			"21f9664c97637da9768812f615c68b13b52e" +
+			"f7e62efbf45089db18f9c8a3f0e41e5f",
+	},
+	{
+		name:      "empty plaintext",
+		plaintext: []byte{},
+		sealed:    "404142434445464748494a4b4c4d4e4f5051525354555657" + "1dac8f73146d1e9da796cb7f7221a5df",
+	},
+}
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// useFixedNonce makes the next nonce this package generates equal nonce
+func useFixedNonce(t *testing.T, nonce []byte) {
+	t.Helper()
+	saved := randReader
+	randReader = bytes.NewReader(nonce)
+	t.Cleanup(func() { randReader = saved })
+}
+
+func TestXChaCha20Poly1305_Vectors(t *testing.T) {
+	if nonceReuseCheck {
+		t.Skip("reuses a fixed nonce on purpose; refused by noncedebug builds")
+	}
+
+	for _, v := range xchachaVectors {
+		want := mustHex(v.sealed)
+
+		var sealed []byte
+		var err error
+		useFixedNonce(t, vectorNonce)
+		if v.aad == nil {
+			// The default path: EncryptChunk with its nonce from randReader
+			sealed, err = EncryptChunk(v.plaintext, vectorKey)
+		} else {
+			sealed, err = EncryptChunkWithOptions(v.plaintext, vectorKey, ChunkOptions{AAD: v.aad})
+		}
+		if err != nil {
+			t.Fatalf("%s: encryption failed: %v", v.name, err)
+		}
+		if !bytes.Equal(sealed, want) {
+			t.Errorf("%s: ciphertext drifted\n got %x\nwant %x", v.name, sealed, want)
+		}
+
+		var opened []byte
+		if v.aad == nil {
+			opened, err = DecryptChunk(want, vectorKey)
+		} else {
+			opened, err = DecryptChunkWithOptions(want, vectorKey, ChunkOptions{AAD: v.aad})
+		}
+		if err != nil {
+			t.Fatalf("%s: decryption failed: %v", v.name, err)
+		}
+		if !bytes.Equal(opened, v.plaintext) {
+			t.Errorf("%s: decrypted text doesn't match", v.name)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package farmer
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// AuthHeader carries a farmer's credential on every shard request.
+// Clients send the configured value verbatim (typically "Bearer <token>");
+// a farmer protected by RequireAuth compares it to its own configured value.
+const AuthHeader = "Authorization"
+
+// AuthTokens maps farmer endpoint → AuthHeader value.
+// Endpoints without an entry are contacted without authentication.
+// Formatting an AuthTokens with fmt never prints the values.
+type AuthTokens map[string]string
+
+// Apply sets the auth header for endpoint on req, if a token is configured
+func (t AuthTokens) Apply(req *http.Request, endpoint string) {
+	if value := t[endpoint]; value != "" {
+		req.Header.Set(AuthHeader, value)
+	}
+}
+
+// String lists configured endpoints with their tokens redacted
+func (t AuthTokens) String() string {
+	endpoints := make([]string, 0, len(t))
+	for endpoint := range t {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+
+	parts := make([]string, len(endpoints))
+	for i, endpoint := range endpoints {
+		parts[i] = endpoint + ":[REDACTED]"
+	}
+	return "map[" + strings.Join(parts, " ") + "]"
+}
+
+// GoString keeps %#v from printing tokens
+func (t AuthTokens) GoString() string {
+	return fmt.Sprintf("farmer.AuthTokens(%s)", t.String())
+}
+
+// RequireAuth rejects requests whose AuthHeader doesn't exactly match expected
+// with 401 Unauthorized. An empty expected value disables the check.
+func RequireAuth(expected string, next http.Handler) http.Handler {
+	if expected == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := r.Header.Get(AuthHeader)
+		if subtle.ConstantTimeCompare([]byte(got), []byte(expected)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,70 @@
+package farmer
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// ============================================================================
+// AUTH TESTS
+// ============================================================================
+
+func TestRequireAuth(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	secured := httptest.NewServer(RequireAuth("Bearer s3cret", ok))
+	defer secured.Close()
+	open := httptest.NewServer(RequireAuth("", ok))
+	defer open.Close()
+
+	tokens := AuthTokens{secured.URL: "Bearer s3cret"}
+	wrong := AuthTokens{secured.URL: "Bearer nope"}
+
+	cases := []struct {
+		name     string
+		endpoint string
+		tokens   AuthTokens
+		want     int
+	}{
+		{"matching token", secured.URL, tokens, http.StatusOK},
+		{"wrong token", secured.URL, wrong, http.StatusUnauthorized},
+		{"no token configured", secured.URL, nil, http.StatusUnauthorized},
+		{"open farmer", open.URL, tokens, http.StatusOK},
+	}
+
+	for _, tc := range cases {
+		req, _ := http.NewRequest(http.MethodGet, tc.endpoint+"/shards", nil)
+		tc.tokens.Apply(req, tc.endpoint)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", tc.name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s: expected %d, got %d", tc.name, tc.want, resp.StatusCode)
+		}
+	}
+}
+
+func TestAuthTokens_Redacted(t *testing.T) {
+	tokens := AuthTokens{"http://farmer-a": "Bearer s3cret", "http://farmer-b": "Bearer hunter2"}
+	cfg := struct{ Tokens AuthTokens }{tokens}
+
+	for _, out := range []string{
+		fmt.Sprint(tokens),
+		fmt.Sprintf("%v", cfg),
+		fmt.Sprintf("%+v", cfg),
+		fmt.Sprintf("%#v", cfg),
+	} {
+		if strings.Contains(out, "s3cret") || strings.Contains(out, "hunter2") {
+			t.Errorf("Token leaked in %q", out)
+		}
+		if !strings.Contains(out, "http://farmer-a") {
+			t.Errorf("Expected endpoints to remain visible in %q", out)
+		}
+	}
+}
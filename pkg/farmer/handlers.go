@@ -1 +1,123 @@
-package farmer
\ No newline at end of file
+package farmer
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// BlobListPath serves the blob IDs a farmer holds shards for, one page at a time:
+//
+//	GET /blobs?after=<blob_id>&limit=<n>
+//
+// IDs are returned in ascending order, starting after the "after" cursor (from
+// the beginning if empty), at most limit per page. The response's Next field is
+// the cursor for the following page and is empty on the last page.
+const BlobListPath = "/blobs"
+
+const (
+	DefaultBlobListLimit = 1000  // page size when the request sets none
+	MaxBlobListLimit     = 10000 // larger requested page sizes are capped to this
+)
+
+// BlobListResponse is one page of the blob list
+type BlobListResponse struct {
+	BlobIDs []string `json:"blob_ids"`
+	Next    string   `json:"next,omitempty"` // cursor for the next page; empty when done
+}
+
+// BlobLister is the part of a farmer's storage the list endpoint needs
+type BlobLister interface {
+	// ListBlobs returns up to limit blob IDs greater than after, in ascending order
+	ListBlobs(after string, limit int) ([]string, error)
+}
+
+// BlobListHandler serves BlobListPath from store. Wrap it in RequireAuth like
+// the shard handlers: the list reveals what a farmer stores.
+func BlobListHandler(store BlobLister) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		limit := DefaultBlobListLimit
+		if s := r.URL.Query().Get("limit"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n <= 0 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = min(n, MaxBlobListLimit)
+		}
+		after := r.URL.Query().Get("after")
+
+		// One extra ID tells whether another page follows
+		ids, err := store.ListBlobs(after, limit+1)
+		if err != nil {
+			http.Error(w, "failed to list blobs", http.StatusInternalServerError)
+			return
+		}
+		resp := BlobListResponse{BlobIDs: ids}
+		if len(ids) > limit {
+			resp.BlobIDs = ids[:limit]
+			resp.Next = ids[limit-1]
+		}
+		if resp.BlobIDs == nil {
+			resp.BlobIDs = []string{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+}
+
+// StoredShard describes one shard a farmer holds, as reported by BlobShardsHandler
+type StoredShard struct {
+	ChunkIndex int    `json:"chunk_index"`
+	ShardIndex int    `json:"shard_index"`
+	Hash       string `json:"hash"` // SHA256 of the stored bytes
+	Size       int    `json:"size"`
+}
+
+// BlobShardsResponse lists the shards a farmer holds for one blob
+type BlobShardsResponse struct {
+	BlobID string        `json:"blob_id"`
+	Shards []StoredShard `json:"shards"`
+}
+
+// ShardLister is the part of a farmer's storage BlobShardsHandler needs
+type ShardLister interface {
+	// ListShards returns every shard stored for blobID (none if the blob is unknown)
+	ListShards(blobID string) ([]StoredShard, error)
+}
+
+// BlobShardsHandler serves GET BlobListPath/{blob_id}: the shards held for one
+// blob, from which a lost manifest's placement can be rebuilt. Mount it at
+// BlobListPath+"/" next to BlobListHandler, behind RequireAuth.
+func BlobShardsHandler(store ShardLister) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		blobID := strings.TrimPrefix(r.URL.Path, BlobListPath+"/")
+		if blobID == "" || strings.Contains(blobID, "/") {
+			http.Error(w, "invalid blob id", http.StatusBadRequest)
+			return
+		}
+
+		shards, err := store.ListShards(blobID)
+		if err != nil {
+			http.Error(w, "failed to list shards", http.StatusInternalServerError)
+			return
+		}
+		if shards == nil {
+			shards = []StoredShard{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(BlobShardsResponse{BlobID: blobID, Shards: shards})
+	})
+}
@@ -0,0 +1,86 @@
+package farmer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+)
+
+// ============================================================================
+// BLOB LIST TESTS
+// ============================================================================
+
+// memLister serves ListBlobs from a sorted slice
+type memLister []string
+
+func (l memLister) ListBlobs(after string, limit int) ([]string, error) {
+	i := sort.SearchStrings(l, after)
+	if i < len(l) && l[i] == after {
+		i++
+	}
+	return l[i:min(i+limit, len(l))], nil
+}
+
+func TestBlobListHandler_Pages(t *testing.T) {
+	var ids memLister
+	for i := 0; i < 25; i++ {
+		ids = append(ids, fmt.Sprintf("0xblob%02d", i))
+	}
+	server := httptest.NewServer(BlobListHandler(ids))
+	defer server.Close()
+
+	get := func(query string) (*BlobListResponse, int) {
+		resp, err := http.Get(server.URL + BlobListPath + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, resp.StatusCode
+		}
+		var page BlobListResponse
+		if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+			t.Fatal(err)
+		}
+		return &page, resp.StatusCode
+	}
+
+	var all []string
+	query := "?limit=10"
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("Paging didn't terminate")
+		}
+		page, status := get(query)
+		if status != http.StatusOK {
+			t.Fatalf("Unexpected status %d", status)
+		}
+		all = append(all, page.BlobIDs...)
+		if page.Next == "" {
+			break
+		}
+		query = "?limit=10&after=" + page.Next
+	}
+	if fmt.Sprint(all) != fmt.Sprint([]string(ids)) {
+		t.Errorf("Paged listing differs: %v", all)
+	}
+
+	// Exactly one full page: no cursor
+	page, _ := get("?limit=25")
+	if len(page.BlobIDs) != 25 || page.Next != "" {
+		t.Errorf("Expected 25 IDs and no cursor, got %d and %q", len(page.BlobIDs), page.Next)
+	}
+	page, _ = get("?after=0xblob24")
+	if page.BlobIDs == nil || len(page.BlobIDs) != 0 {
+		t.Errorf("Expected empty list past the end, got %v", page.BlobIDs)
+	}
+
+	for _, bad := range []string{"?limit=0", "?limit=-3", "?limit=ten"} {
+		if _, status := get(bad); status != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", bad, status)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package gateway
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/chunker"
+	"github.com/Abhinav-kodes/dbxn/pkg/manifest"
+	"github.com/Abhinav-kodes/dbxn/pkg/publisher"
+	"github.com/Abhinav-kodes/dbxn/pkg/retriever"
+)
+
+// ============================================================================
+// BLOB HANDLER TESTS
+// ============================================================================
+
+// uploadTestBlob stores data in a local shard directory under a .txt name and
+// returns its manifest
+func uploadTestBlob(t *testing.T, data []byte, encryptFields ...string) *manifest.Manifest {
+	t.Helper()
+	testFile := "test-gateway.txt"
+	if err := os.WriteFile(testFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+	manifestPath := "test-gateway.json"
+	defer os.Remove(manifestPath)
+	dir, err := os.MkdirTemp(".", "test-gateway-shards-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	m, _, err := publisher.Upload(publisher.UploadConfig{
+		FilePath:      testFile,
+		OutputPath:    manifestPath,
+		LocalShardDir: dir,
+		EncryptFields: encryptFields,
+	})
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	return m
+}
+
+func TestServeBlob_Ranges(t *testing.T) {
+	data := make([]byte, chunker.ChunkSize+5000)
+	rand.Read(data)
+	m := uploadTestBlob(t, data)
+	handler, err := ServeBlob(m, nil, retriever.DownloadConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	get := func(header map[string]string) (*http.Response, []byte) {
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, body
+	}
+
+	resp, body := get(nil)
+	if resp.StatusCode != http.StatusOK || !bytes.Equal(body, data) {
+		t.Fatalf("Full GET: status %d, %d bytes", resp.StatusCode, len(body))
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Expected text/plain from the file name, got %q", ct)
+	}
+	etag := resp.Header.Get("ETag")
+	if etag != fmt.Sprintf(`"%x"`, sha256.Sum256(m.CanonicalBytes())) {
+		t.Errorf("Expected the manifest's canonical hash as ETag, got %s", etag)
+	}
+
+	// A range spanning the chunk boundary
+	start, end := chunker.ChunkSize-100, chunker.ChunkSize+99
+	resp, body = get(map[string]string{"Range": fmt.Sprintf("bytes=%d-%d", start, end)})
+	if resp.StatusCode != http.StatusPartialContent {
+		t.Fatalf("Expected 206, got %d", resp.StatusCode)
+	}
+	if !bytes.Equal(body, data[start:end+1]) {
+		t.Error("Range body doesn't match the blob")
+	}
+	if cr := resp.Header.Get("Content-Range"); cr != fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)) {
+		t.Errorf("Unexpected Content-Range %q", cr)
+	}
+
+	resp, _ = get(map[string]string{"Range": fmt.Sprintf("bytes=%d-", len(data)+10)})
+	if resp.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("Expected 416 past the end, got %d", resp.StatusCode)
+	}
+
+	resp, _ = get(map[string]string{"If-None-Match": etag})
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("Expected 304 for a matching ETag, got %d", resp.StatusCode)
+	}
+
+	// If-Range with a stale validator serves the whole blob
+	resp, body = get(map[string]string{"Range": "bytes=0-9", "If-Range": `"stale"`})
+	if resp.StatusCode != http.StatusOK || len(body) != len(data) {
+		t.Errorf("Expected full 200 for a stale If-Range, got %d with %d bytes", resp.StatusCode, len(body))
+	}
+
+	post, err := http.Post(server.URL, "text/plain", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	post.Body.Close()
+	if post.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for POST, got %d", post.StatusCode)
+	}
+}
+
+func TestServeBlob_EncryptedFileName(t *testing.T) {
+	// Binary content: sniffing alone would say application/octet-stream
+	data := make([]byte, 1000)
+	rand.Read(data)
+	m := uploadTestBlob(t, data, manifest.FieldFileName)
+	key, err := m.GetEncryptionKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, err := ServeBlob(m, key, retriever.DownloadConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if !bytes.Equal(body, data) {
+		t.Error("Body doesn't match the blob")
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Expected text/plain from the decrypted name, got %q", ct)
+	}
+}
+
+func TestServeBlob_ETagFollowsVersion(t *testing.T) {
+	m := uploadTestBlob(t, []byte("first version"))
+	// A newer version keeps the BlobID but lists other chunks
+	newer := *m
+	newer.Chunks = append([]manifest.ChunkMeta(nil), m.Chunks...)
+	newer.Chunks[0].Hash = strings.Repeat("0", 64)
+
+	etagOf := func(m *manifest.Manifest) string {
+		handler, err := ServeBlob(m, nil, retriever.DownloadConfig{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodHead, "/", nil))
+		return rec.Header().Get("ETag")
+	}
+	if etagOf(m) == etagOf(&newer) {
+		t.Error("Expected a new version of the blob to get a new ETag")
+	}
+}
+
+func TestServeBlob_OpenError(t *testing.T) {
+	m := uploadTestBlob(t, []byte("secret"))
+	wrong := make([]byte, 32)
+	rand.Read(wrong)
+	handler, err := ServeBlob(m, wrong, retriever.DownloadConfig{})
+	if err == nil || handler != nil {
+		t.Error("Expected ServeBlob to return the open error instead of a handler")
+	}
+}
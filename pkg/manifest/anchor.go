@@ -0,0 +1,98 @@
+package manifest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// anchorLabel separates the anchor digest from other hashes over a manifest
+const anchorLabel = "dbxn manifest anchor v1"
+
+// Anchor is a timestamp proof that a manifest existed at some point in time,
+// such as an RFC 3161 token or a blockchain transaction. The manifest package
+// never contacts an authority: the publisher submits AnchorBytes, stores the
+// proof it gets back here, and VerifyAnchor later ties the proof to the manifest.
+type Anchor struct {
+	Scheme string `json:"scheme"` // how Token was obtained, e.g. "rfc3161" (informational)
+	Digest string `json:"digest"` // hex AnchorBytes when the manifest was anchored
+	Token  []byte `json:"token"`  // proof returned by the authority
+}
+
+// CanonicalBytes encodes what a manifest says about its blob, for anchoring: the
+// content (file, chunk list, packed files), its encryption format and who
+// published it, in canonical encoding. Encrypted fields are covered as ciphertext. The key stays out, and so does storage
+// layout (erasure config, shards, farmers, padding), so repairs, imports and
+// resharding keep an anchor valid. The anchor itself is not covered either.
+func (m *Manifest) CanonicalBytes() []byte {
+	b := AppendCanonicalString(nil, m.Version)
+	b = AppendCanonicalString(b, m.BlobID)
+	b = AppendCanonicalString(b, m.FileName)
+	b = AppendCanonicalInt(b, m.FileSize)
+	b = AppendCanonicalString(b, m.OriginalFileHash)
+	b = AppendCanonicalInt(b, int64(m.ChunkSize))
+	b = AppendCanonicalInt(b, int64(m.ChunkCount))
+	b = AppendCanonicalString(b, m.ChunkHashDomain)
+	b = AppendCanonicalInt(b, int64(len(m.Chunks)))
+	for _, c := range m.Chunks {
+		b = AppendCanonicalInt(b, int64(c.Index))
+		b = AppendCanonicalInt(b, int64(c.Size))
+		b = AppendCanonicalString(b, c.Hash)
+	}
+	b = AppendCanonicalInt(b, int64(len(m.Files)))
+	for _, f := range m.Files {
+		b = AppendCanonicalString(b, f.Path)
+		b = AppendCanonicalInt(b, canonicalBool(f.Dir))
+		b = AppendCanonicalInt(b, f.Offset)
+		b = AppendCanonicalInt(b, f.Size)
+		b = AppendCanonicalInt(b, int64(f.Mode))
+	}
+	b = AppendCanonicalString(b, m.KeyCommitment)
+	b = AppendCanonicalInt(b, canonicalBool(m.ChunkCommitment))
+	b = AppendCanonicalInt(b, canonicalBool(m.ChunkAAD))
+	b = AppendCanonicalInt(b, m.CreatedAt.Unix())
+	b = AppendCanonicalInt(b, int64(m.CreatedAt.Nanosecond()))
+	b = AppendCanonicalString(b, m.PublisherAddress)
+	b = AppendCanonicalString(b, m.PublisherPublicKey)
+	b = AppendCanonicalInt(b, int64(len(m.EncryptedFields)))
+	for _, field := range m.EncryptedFields {
+		b = AppendCanonicalString(b, field)
+	}
+	b = AppendCanonicalString(b, m.ContentType)
+	return b
+}
+
+// canonicalBool encodes a flag as the integer 0 or 1
+func canonicalBool(v bool) int64 {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+// AnchorBytes returns the SHA-256 digest to submit to a timestamping authority:
+// a label followed by m.CanonicalBytes(). Only the digest leaves the publisher,
+// so the authority learns nothing about the blob.
+func AnchorBytes(m *Manifest) []byte {
+	h := sha256.New()
+	h.Write(AppendCanonicalString(nil, anchorLabel))
+	h.Write(m.CanonicalBytes())
+	return h.Sum(nil)
+}
+
+// VerifyAnchor checks that token is a proof over m: it must carry AnchorBytes(m)
+// verbatim, as RFC 3161 tokens (the message imprint), OpenTimestamps proofs and
+// OP_RETURN transactions do. If m records an Anchor, its digest must match too,
+// so a manifest edited since anchoring is reported as such. Checking the
+// authority's signature or the transaction's inclusion is left to the caller.
+func VerifyAnchor(m *Manifest, token []byte) error {
+	digest := AnchorBytes(m)
+	if m.Anchor != nil && m.Anchor.Digest != hex.EncodeToString(digest) {
+		return fmt.Errorf("manifest %s changed since it was anchored", m.BlobID)
+	}
+	if !bytes.Contains(token, digest) {
+		return fmt.Errorf("anchor token does not cover manifest %s", m.BlobID)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package manifest
+
+import "encoding/binary"
+
+// Canonical encoding for values fed into hashes and signatures.
+//
+// Integers are always 8 bytes, big-endian (int64 two's complement), whatever
+// their Go type or the platform's int size. Strings are their UTF-8 bytes
+// prefixed with their length encoded as an integer, so adjacent fields can't
+// run into each other. Integrity features must build hashed or signed input
+// with these helpers, never from fmt or JSON renderings of numbers, so every
+// client derives byte-identical commitments.
+
+// AppendCanonicalInt appends the canonical encoding of v to b
+func AppendCanonicalInt(b []byte, v int64) []byte {
+	return binary.BigEndian.AppendUint64(b, uint64(v))
+}
+
+// AppendCanonicalString appends the canonical (length-prefixed) encoding of s to b
+func AppendCanonicalString(b []byte, s string) []byte {
+	b = AppendCanonicalInt(b, int64(len(s)))
+	return append(b, s...)
+}
+
+// CanonicalShardID encodes a (blob, chunk, shard) tuple for hashing or signing:
+// string blobID, then chunkIndex, then shardIndex
+func CanonicalShardID(blobID string, chunkIndex, shardIndex int) []byte {
+	b := make([]byte, 0, 3*8+len(blobID))
+	b = AppendCanonicalString(b, blobID)
+	b = AppendCanonicalInt(b, int64(chunkIndex))
+	return AppendCanonicalInt(b, int64(shardIndex))
+}
+
+// chunkAADLabel separates chunk associated data from other canonical encodings
+const chunkAADLabel = "dbxn chunk aad v1"
+
+// ChunkAssociatedData is the AEAD associated data binding a chunk's ciphertext to
+// its blob, manifest format version and position (Manifest.ChunkAAD): a label,
+// then string blobID, string version and chunkIndex
+func ChunkAssociatedData(blobID, version string, chunkIndex int) []byte {
+	b := make([]byte, 0, 4*8+len(chunkAADLabel)+len(blobID)+len(version))
+	b = AppendCanonicalString(b, chunkAADLabel)
+	b = AppendCanonicalString(b, blobID)
+	b = AppendCanonicalString(b, version)
+	return AppendCanonicalInt(b, int64(chunkIndex))
+}
@@ -0,0 +1,116 @@
+package manifest
+
+import (
+	"encoding/hex"
+	"fmt"
+	"slices"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/crypto"
+)
+
+// Metadata fields EncryptFields can hide (Manifest.EncryptedFields)
+const (
+	FieldFileName         = "file_name"
+	FieldOriginalFileHash = "original_file_hash"
+)
+
+// fieldAADLabel separates encrypted field associated data from other canonical encodings
+const fieldAADLabel = "dbxn manifest field v1"
+
+// fieldAssociatedData binds an encrypted field to its blob and field name, so a
+// ciphertext can't be moved into another field or another manifest
+func fieldAssociatedData(blobID, field string) []byte {
+	b := AppendCanonicalString(nil, fieldAADLabel)
+	b = AppendCanonicalString(b, blobID)
+	return AppendCanonicalString(b, field)
+}
+
+// fieldValue returns a pointer to the named metadata field, or nil if it can't be encrypted
+func (m *Manifest) fieldValue(field string) *string {
+	switch field {
+	case FieldFileName:
+		return &m.FileName
+	case FieldOriginalFileHash:
+		return &m.OriginalFileHash
+	}
+	return nil
+}
+
+// FieldEncrypted reports whether the named field holds ciphertext
+func (m *Manifest) FieldEncrypted(field string) bool {
+	return slices.Contains(m.EncryptedFields, field)
+}
+
+// EncryptFields replaces the named metadata fields (FieldFileName,
+// FieldOriginalFileHash) with their hex ciphertext under the data key, so a
+// manifest shared with someone who lacks the key doesn't reveal them. Fields
+// already encrypted are left alone. BlobID must be final: it is bound into each
+// ciphertext. Anchoring must come after, since the anchor covers the ciphertext.
+func (m *Manifest) EncryptFields(key []byte, fields ...string) error {
+	for _, field := range fields {
+		value := m.fieldValue(field)
+		if value == nil {
+			return fmt.Errorf("field %q can't be encrypted", field)
+		}
+		if m.FieldEncrypted(field) {
+			continue
+		}
+		sealed, err := crypto.EncryptChunkWithOptions([]byte(*value), key,
+			crypto.ChunkOptions{AAD: fieldAssociatedData(m.BlobID, field)})
+		if err != nil {
+			return fmt.Errorf("failed to encrypt %s: %w", field, err)
+		}
+		*value = hex.EncodeToString(sealed)
+		m.EncryptedFields = append(m.EncryptedFields, field)
+	}
+	return nil
+}
+
+// decryptField returns the plaintext of the named field, decrypting it with key
+// if it is encrypted
+func (m *Manifest) decryptField(field string, key []byte) (string, error) {
+	value := m.fieldValue(field)
+	if !m.FieldEncrypted(field) {
+		return *value, nil
+	}
+	sealed, err := hex.DecodeString(*value)
+	if err != nil {
+		return "", fmt.Errorf("encrypted %s: %w", field, err)
+	}
+	plain, err := crypto.DecryptChunkWithOptions(sealed, key,
+		crypto.ChunkOptions{AAD: fieldAssociatedData(m.BlobID, field)})
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt %s: %w", field, err)
+	}
+	return string(plain), nil
+}
+
+// DecryptedFileName returns the original file name, decrypting it with key if
+// the manifest stores it encrypted
+func (m *Manifest) DecryptedFileName(key []byte) (string, error) {
+	return m.decryptField(FieldFileName, key)
+}
+
+// DecryptedOriginalFileHash returns the whole-file hash, decrypting it with key
+// if the manifest stores it encrypted
+func (m *Manifest) DecryptedOriginalFileHash(key []byte) (string, error) {
+	return m.decryptField(FieldOriginalFileHash, key)
+}
+
+// validateEncryptedFields checks EncryptedFields names known fields, once each,
+// and that each holds hex ciphertext. No key is needed.
+func (m *Manifest) validateEncryptedFields() error {
+	for i, field := range m.EncryptedFields {
+		value := m.fieldValue(field)
+		if value == nil {
+			return fmt.Errorf("unknown encrypted field %q", field)
+		}
+		if slices.Contains(m.EncryptedFields[:i], field) {
+			return fmt.Errorf("encrypted field %q listed more than once", field)
+		}
+		if sealed, err := hex.DecodeString(*value); err != nil || len(sealed) < crypto.Overhead {
+			return fmt.Errorf("encrypted field %q is not ciphertext", field)
+		}
+	}
+	return nil
+}
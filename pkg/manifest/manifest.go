@@ -1,19 +1,35 @@
 package manifest
 
 import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/chunker"
+	"github.com/Abhinav-kodes/dbxn/pkg/crypto"
 )
 
 type Manifest struct {
 	Version          string      `json:"version"` 				// manifest version
 	BlobID           string      `json:"blob_id"` 				// unique blob identifier
 	FileName         string      `json:"file_name"` 			// original file name
+	ContentType      string      `json:"content_type,omitempty"`	// MIME type sniffed from the content (empty = unknown)
 	FileSize         int64       `json:"file_size"`				// original file size in bytes
 	OriginalFileHash string      `json:"original_file_hash"`	// SHA256 hash of original file
 	ChunkSize        int         `json:"chunk_size"`			// size of each chunk in bytes
@@ -26,15 +42,76 @@ type Manifest struct {
 	Shards           []ShardMeta  `json:"shards"`				// metadata for each shard
 	Farmers          []FarmerInfo `json:"farmers"`				// list of farmers storing the chunks
 	EncryptionKey    string      `json:"encryption_key"`		// hex-encoded encryption key for chunks
+	KeyCommitment    string      `json:"key_commitment,omitempty"`	// crypto.KeyCommitment of the encryption key (empty = not recorded)
 	CreatedAt        time.Time   `json:"created_at"`			// timestamp of manifest creation
 	PublisherAddress string      `json:"publisher_address"`		// address of the publisher
+	PublisherPublicKey string    `json:"publisher_public_key,omitempty"` // hex-encoded ed25519 public key of the publisher (empty = not recorded)
+	MinRegions       int         `json:"min_regions,omitempty"`	// minimum distinct regions each chunk's shards must span (0 = unconstrained)
+	PaddedSize       int         `json:"padded_size,omitempty"`	// encrypted chunks padded to this size before sharding (0 = no padding)
+	ShardWrap        string      `json:"shard_wrap,omitempty"`	// outer cipher each stored shard is wrapped in (empty = none); key is kept out of the manifest
+	ShardFEC         *chunker.ShardFEC `json:"shard_fec,omitempty"`	// inner error correction each stored shard is encoded with, outside any ShardWrap (nil = none)
+	Files            []DirEntry  `json:"files,omitempty"`		// packed directory tree, in blob order (empty = single-file blob)
+	ChunkHashDomain  string      `json:"chunk_hash_domain,omitempty"` // what ChunkMeta.Hash covers: ChunkHashPlaintext (default) or ChunkHashCiphertext
+	ChunkCommitment  bool        `json:"chunk_commitment,omitempty"`	// chunks carry a key commitment (crypto.EncryptChunkCommitted)
+	ChunkAAD         bool        `json:"chunk_aad,omitempty"`	// chunks are bound to BlobID, Version and index through ChunkAssociatedData
+	Anchor           *Anchor     `json:"anchor,omitempty"`		// timestamp proof over AnchorBytes (nil = not anchored)
+	Segments         []SegmentRef `json:"segments,omitempty"`	// root of a segmented blob: chunks and shards live in these segments (see Segment)
+	EncryptedFields  []string    `json:"encrypted_fields,omitempty"`	// metadata fields stored as ciphertext under the data key (see EncryptFields)
+	Draft            bool        `json:"draft,omitempty"`	// interrupted upload: shards marked Pending aren't stored yet (see publisher.ResumeUpload)
+	KDF              *crypto.KDFParams `json:"kdf,omitempty"`	// key is derived from a passphrase with these parameters and EncryptionKey is empty (nil = random key)
+}
+
+// Chunk hash domains (Manifest.ChunkHashDomain).
+//
+// Plaintext hashes let anyone holding a manifest confirm whether a chunk contains
+// known content, even though the chunk data is encrypted; in exchange they are
+// stable across uploads, which is what makes FindSharedChunks (dedup) and local
+// file checks such as chunker.ChunkHashesForFile work. Ciphertext hashes cover the
+// encrypted chunk (nonce|ciphertext|tag) and reveal nothing about the content, but
+// differ on every upload, so dedup and plaintext comparisons are impossible.
+// Either way OriginalFileHash stays a plaintext hash of the whole file.
+const (
+	ChunkHashPlaintext  = "plaintext"
+	ChunkHashCiphertext = "ciphertext"
+)
+
+// HashesCiphertext reports whether ChunkMeta.Hash covers the encrypted chunk
+func (m *Manifest) HashesCiphertext() bool {
+	return m.ChunkHashDomain == ChunkHashCiphertext
+}
+
+// ChunkOverhead returns the bytes encryption adds to each chunk: nonce and tag,
+// plus the key commitment if ChunkCommitment is set
+func (m *Manifest) ChunkOverhead() int {
+	return crypto.ChunkOptions{Committed: m.ChunkCommitment}.Overhead()
+}
+
+// ChunkOptions returns the encryption options chunk chunkIndex was sealed with
+func (m *Manifest) ChunkOptions(chunkIndex int) crypto.ChunkOptions {
+	opts := crypto.ChunkOptions{Committed: m.ChunkCommitment}
+	if m.ChunkAAD {
+		opts.AAD = ChunkAssociatedData(m.BlobID, m.Version, chunkIndex)
+	}
+	return opts
 }
 
 // ChunkMeta represents metadata for a file chunk
 type ChunkMeta struct {
-	Index int    `json:"index"` // chunk index
-	Hash  string `json:"hash"`  // SHA256 of plaintext chunk
-	Size  int    `json:"size"`  // size of chunk in bytes
+	Index         int    `json:"index"`                    // chunk index
+	Hash          string `json:"hash"`                     // SHA256 of the chunk in the manifest's ChunkHashDomain (plaintext by default)
+	Size          int    `json:"size"`                     // size of chunk in bytes
+	EncryptedSize int    `json:"encrypted_size,omitempty"` // size of the encrypted chunk the shards encode (0 = not recorded: Size + ChunkOverhead)
+	Regions       int    `json:"regions,omitempty"`        // distinct farmer regions achieved at upload
+	PrevHash      string `json:"prev_hash,omitempty"`      // chain link over all preceding chunks (empty = unchained)
+}
+
+// SortShards puts shard metadata in manifest order, by chunk index then shard
+// index, so a manifest doesn't depend on the order shards were produced or
+// uploaded in and two uploads of the same file list shards identically
+func SortShards(shards []ShardMeta) {
+	slices.SortStableFunc(shards, func(a, b ShardMeta) int {
+		return cmp.Or(cmp.Compare(a.ChunkIndex, b.ChunkIndex), cmp.Compare(a.ShardIndex, b.ShardIndex))
+	})
 }
 
 // ShardMeta represents metadata for an erasure-coded shard
@@ -43,16 +120,42 @@ type ShardMeta struct {
     ShardIndex   int    `json:"shard_index"`   // which shard (0-5)
     Hash         string `json:"hash"`          // SHA256 of shard
     Size         int    `json:"size"`          // shard size in bytes
-    FarmerIndex  int    `json:"farmer_index"`  // which farmer stores this
+    FarmerIndex  int    `json:"farmer_index"`  // which farmer stores this (its FarmerInfo.Index)
+    Pending      bool   `json:"pending,omitempty"` // draft manifests only: not stored on its farmer yet
+}
+
+// PendingShards returns the shards a draft manifest records as not yet stored
+func (m *Manifest) PendingShards() []ShardMeta {
+	var pending []ShardMeta
+	for _, shard := range m.Shards {
+		if shard.Pending {
+			pending = append(pending, shard)
+		}
+	}
+	return pending
 }
 
 type FarmerInfo struct {
-    Index    int    `json:"index"`    // farmer index (0-5)
+    Index    int    `json:"index"`    // farmer index (0-5); always its position in Manifest.Farmers (see Validate)
     Address  string `json:"address"`  // farmer wallet address
     Endpoint string `json:"endpoint"` // HTTP endpoint (e.g., "https://farmer1.dbxn.io:4433")
     Region   string `json:"region"`   // geographic region (e.g., "us-east-1")
 }
 
+// DirEntry is one file or directory of a directory blob. File contents are
+// concatenated in Files order, so each file is the byte range [Offset, Offset+Size).
+// Directories are listed so empty ones survive the round trip.
+type DirEntry struct {
+	Path   string `json:"path"`             // slash-separated path relative to the packed root
+	Dir    bool   `json:"dir,omitempty"`    // directory entry (no data)
+	Offset int64  `json:"offset,omitempty"` // start of the file within the blob
+	Size   int64  `json:"size,omitempty"`   // file size in bytes
+	Mode   uint32 `json:"mode"`             // permission bits
+}
+
+// FormatVersion is the Version New records
+const FormatVersion = "1.0"
+
 // New creates a new manifest
 func New(
 	fileName string,
@@ -65,7 +168,7 @@ func New(
 	publisher string,
 ) *Manifest {
 	return &Manifest{
-		Version:          "1.0",
+		Version:          FormatVersion,
 		BlobID:           generateBlobID(),
 		FileName:         fileName,
 		FileSize:         fileSize,
@@ -79,16 +182,30 @@ func New(
 		Shards:           shards,
 		Farmers:          farmers,
 		EncryptionKey:    hex.EncodeToString(encKey),
+		KeyCommitment:    crypto.KeyCommitment(encKey),
 		CreatedAt:        time.Now(),
 		PublisherAddress: publisher,
 	}
 }
 
 
+// randReader is the source of generated blob IDs. Testing only: tests may swap in
+// a deterministic reader; production code must leave it as crypto/rand.Reader.
+var randReader io.Reader = rand.Reader
+
+// NewBlobID returns a fresh random blob ID, for publishers that must know a blob's
+// ID before its manifest exists (e.g. to bind chunks to it with ChunkAAD)
+func NewBlobID() string {
+	return generateBlobID()
+}
+
 // generateBlobID creates a random 32-byte blob ID
 func generateBlobID() string {
 	b := make([]byte, 32)
-	rand.Read(b)
+	if _, err := io.ReadFull(randReader, b); err != nil {
+		// crypto/rand doesn't fail; only a broken test reader gets here
+		panic(fmt.Sprintf("failed to generate blob ID: %v", err))
+	}
 	return "0x" + hex.EncodeToString(b)
 }
 
@@ -110,8 +227,13 @@ func (m *Manifest) Save(path string) error {
 }
 
 
-// Load reads manifest from JSON file
+// Load reads manifest from JSON file, rejecting counts beyond DefaultLimits
 func Load(path string) (*Manifest, error) {
+	return LoadWithLimits(path, DefaultLimits())
+}
+
+// LoadWithLimits is Load with explicit count limits
+func LoadWithLimits(path string, limits Limits) (*Manifest, error) {
 	// Read the JSON manifest from the specified path
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -124,10 +246,133 @@ func Load(path string) (*Manifest, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal manifest: %w", err)
 	}
+	if err := m.CheckLimits(limits); err != nil {
+		return nil, err
+	}
 
 	return &m, nil
 }
 
+// LoadLimited reads a manifest from an untrusted reader, reading at most maxBytes.
+// Input larger than maxBytes or not shaped like a manifest is rejected before parsing.
+// Callers reading from the network should also set a deadline on the underlying connection.
+func LoadLimited(r io.Reader, maxBytes int64) (*Manifest, error) {
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("invalid size limit %d", maxBytes)
+	}
+
+	// Read one byte past the limit so oversized input can be detected
+	data, err := io.ReadAll(io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, fmt.Errorf("manifest exceeds size limit of %d bytes", maxBytes)
+	}
+
+	// Format sniff: a manifest is a JSON object
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return nil, fmt.Errorf("input is not a manifest: expected JSON object")
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(trimmed, &m); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal manifest: %w", err)
+	}
+
+	// Any JSON object parses, so require the fields every manifest carries
+	if m.Version == "" || m.BlobID == "" {
+		return nil, fmt.Errorf("input is not a manifest: missing version or blob_id")
+	}
+	if err := m.CheckLimits(DefaultLimits()); err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// Limits bounds the counts a manifest may declare. Readers size allocations from
+// them (chunk tracking, fetch plans), so a hostile manifest must be refused first.
+type Limits struct {
+	MaxChunks         int // chunk_count and len(chunks)
+	MaxShardsPerChunk int // data_shards + parity_shards
+}
+
+// DefaultLimits allows chunker.MaxChunks chunks and chunker.MaxTotalShards shards per chunk
+func DefaultLimits() Limits {
+	return Limits{MaxChunks: chunker.MaxChunks, MaxShardsPerChunk: chunker.MaxTotalShards}
+}
+
+// CheckLimits rejects a manifest whose counts exceed limits or are implausible for
+// its recorded sizes: no more chunks than FileSize / ChunkSize rounded up (when
+// both are known), and no more shard entries than chunks × shards per chunk
+func (m *Manifest) CheckLimits(limits Limits) error {
+	if m.ChunkCount < 0 || m.ChunkCount > limits.MaxChunks {
+		return fmt.Errorf("chunk_count %d exceeds max chunks limit (%d)", m.ChunkCount, limits.MaxChunks)
+	}
+	if len(m.Chunks) > limits.MaxChunks {
+		return fmt.Errorf("%d chunk entries exceed max chunks limit (%d)", len(m.Chunks), limits.MaxChunks)
+	}
+	if m.FileSize > 0 && m.ChunkSize > 0 {
+		if most := (m.FileSize + int64(m.ChunkSize) - 1) / int64(m.ChunkSize); int64(m.ChunkCount) > most {
+			return fmt.Errorf("chunk_count %d exceeds the %d chunks a %d-byte file has at chunk size %d",
+				m.ChunkCount, most, m.FileSize, m.ChunkSize)
+		}
+	}
+
+	if m.DataShards < 0 || m.ParityShards < 0 || m.TotalShards < 0 {
+		return fmt.Errorf("negative shard counts (%d+%d, total %d)", m.DataShards, m.ParityShards, m.TotalShards)
+	}
+	if perChunk := max(m.DataShards+m.ParityShards, m.TotalShards); perChunk > limits.MaxShardsPerChunk {
+		return fmt.Errorf("%d shards per chunk exceed max shards per chunk limit (%d)", perChunk, limits.MaxShardsPerChunk)
+	}
+	if most := max(m.ChunkCount, len(m.Chunks)) * max(m.DataShards+m.ParityShards, m.TotalShards); len(m.Shards) > most {
+		return fmt.Errorf("%d shard entries exceed the %d that %d chunks can have", len(m.Shards), most, max(m.ChunkCount, len(m.Chunks)))
+	}
+	return nil
+}
+
+// PinPath is the farmer path accepting sealed manifests (POST) and serving them
+// as PinPath/{blob_id} (GET)
+const PinPath = "/manifests"
+
+// PinURL returns the URL under which a farmer serves the sealed manifest of blobID
+func PinURL(endpoint, blobID string) string {
+	return endpoint + PinPath + "/" + blobID
+}
+
+// Seal encrypts the manifest under key for storage on farmers. The manifest holds
+// its own encryption key, so it is never stored anywhere unencrypted; sealing under
+// the blob's key means BlobID, endpoints and key are enough to recover everything.
+func (m *Manifest) Seal(key []byte) ([]byte, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	sealed, err := crypto.EncryptChunk(data, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to seal manifest: %w", err)
+	}
+	return sealed, nil
+}
+
+// OpenSealed decrypts a manifest sealed with Seal and checks it describes blobID
+func OpenSealed(sealed []byte, key []byte, blobID string) (*Manifest, error) {
+	data, err := crypto.DecryptChunk(sealed, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sealed manifest: %w", err)
+	}
+	m, err := LoadLimited(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	if m.BlobID != blobID {
+		return nil, fmt.Errorf("sealed manifest is for blob %s, expected %s", m.BlobID, blobID)
+	}
+	return m, nil
+}
+
 // GetChunkHash returns hash for a given chunk index
 func (m *Manifest) GetChunkHash(index int) string {
 	// Iterate through chunks to find the hash for the specified index
@@ -139,6 +384,46 @@ func (m *Manifest) GetChunkHash(index int) string {
 	return ""
 }
 
+// ChunkRange is the byte range [Start, End) a chunk covers in the original file
+type ChunkRange struct {
+	ChunkIndex int
+	Start      int64
+	End        int64
+}
+
+// ChunkOffsets returns the byte range of every chunk in the original file, in
+// chunk index order, by summing the recorded chunk sizes. That works for fixed
+// and content-defined chunking alike. Chunk indices must run 0 to ChunkCount-1
+// without gaps and the last range must end at FileSize, otherwise the sizes
+// are inconsistent and an error is returned.
+func (m *Manifest) ChunkOffsets() ([]ChunkRange, error) {
+	if m.IsSegmented() {
+		return nil, fmt.Errorf("manifest %s is segmented; its chunks are listed in the segments", m.BlobID)
+	}
+	chunks := slices.Clone(m.Chunks)
+	slices.SortFunc(chunks, func(a, b ChunkMeta) int { return cmp.Compare(a.Index, b.Index) })
+	if len(chunks) != m.ChunkCount {
+		return nil, fmt.Errorf("%d chunks listed, chunk_count is %d", len(chunks), m.ChunkCount)
+	}
+
+	ranges := make([]ChunkRange, len(chunks))
+	var offset int64
+	for i, chunk := range chunks {
+		if chunk.Index != i {
+			return nil, fmt.Errorf("chunk %d missing or listed more than once", i)
+		}
+		if chunk.Size < 0 {
+			return nil, fmt.Errorf("chunk %d: negative size %d", chunk.Index, chunk.Size)
+		}
+		ranges[i] = ChunkRange{ChunkIndex: chunk.Index, Start: offset, End: offset + int64(chunk.Size)}
+		offset += int64(chunk.Size)
+	}
+	if offset != m.FileSize {
+		return nil, fmt.Errorf("chunk sizes sum to %d bytes, file_size is %d", offset, m.FileSize)
+	}
+	return ranges, nil
+}
+
 // GetShardsForChunk returns all shards metadata for a given chunk index
 func (m *Manifest) GetShardsForChunk(chunkIndex int) []ShardMeta {
     var shards []ShardMeta
@@ -150,12 +435,24 @@ func (m *Manifest) GetShardsForChunk(chunkIndex int) []ShardMeta {
     return shards
 }
 
-// GetFarmerForShard returns the FarmerInfo for a given shard
+// GetFarmerForShard returns the FarmerInfo for a given shard: the farmer whose
+// Index is shard.FarmerIndex. Validate requires Farmers[i].Index == i, so that is
+// also its position, but a farmers list that was reordered or filtered still
+// resolves correctly. Returns nil if no farmer, or more than one, has that index.
 func (m *Manifest) GetFarmerForShard(shard ShardMeta) *FarmerInfo {
-    if shard.FarmerIndex >= 0 && shard.FarmerIndex < len(m.Farmers) {
+    if shard.FarmerIndex >= 0 && shard.FarmerIndex < len(m.Farmers) && m.Farmers[shard.FarmerIndex].Index == shard.FarmerIndex {
         return &m.Farmers[shard.FarmerIndex]
     }
-    return nil
+    var found *FarmerInfo
+    for i := range m.Farmers {
+        if m.Farmers[i].Index == shard.FarmerIndex {
+            if found != nil {
+                return nil
+            }
+            found = &m.Farmers[i]
+        }
+    }
+    return found
 }
 
 // GetFarmersForChunk returns unique farmers storing shards for a given chunk index
@@ -176,11 +473,848 @@ func (m *Manifest) GetFarmersForChunk(chunkIndex int) []FarmerInfo {
     return farmers
 }
 
+// VerifyShardData checks that data is the shard the manifest expects at
+// (chunkIndex, shardIndex): same size and same SHA256. Needs no network access.
+func (m *Manifest) VerifyShardData(chunkIndex, shardIndex int, data []byte) error {
+	for _, shard := range m.Shards {
+		if shard.ChunkIndex != chunkIndex || shard.ShardIndex != shardIndex {
+			continue
+		}
+		if len(data) != shard.Size {
+			return fmt.Errorf("chunk %d shard %d: size %d, manifest expects %d", chunkIndex, shardIndex, len(data), shard.Size)
+		}
+		hash := sha256.Sum256(data)
+		if hex.EncodeToString(hash[:]) != shard.Hash {
+			return fmt.Errorf("chunk %d shard %d: hash mismatch", chunkIndex, shardIndex)
+		}
+		return nil
+	}
+	return fmt.Errorf("chunk %d shard %d: no such shard in manifest %s", chunkIndex, shardIndex, m.BlobID)
+}
+
+// shardFetchPath is the farmer path under which shards are served as
+// /shards/{ShardAddress} (mirrors the publisher's upload path)
+const shardFetchPath = "/shards"
+
+// ShardAddress returns the canonical key of a shard, "{blob_id}/{chunk}/{shard}"
+// with decimal indices. Publishers, retrievers and farmers must all address
+// shards through it (and ParseShardAddress) so they agree on storage keys.
+// Blob IDs never contain "/", so distinct shards never share an address.
+func ShardAddress(blobID string, chunkIndex, shardIndex int) string {
+	return blobID + "/" + strconv.Itoa(chunkIndex) + "/" + strconv.Itoa(shardIndex)
+}
+
+// ParseShardAddress splits an address made by ShardAddress. It rejects anything
+// ShardAddress wouldn't produce, such as negative or non-canonical indices.
+func ParseShardAddress(addr string) (blobID string, chunkIndex, shardIndex int, err error) {
+	parts := strings.Split(addr, "/")
+	if len(parts) != 3 || parts[0] == "" {
+		return "", 0, 0, fmt.Errorf("invalid shard address %q", addr)
+	}
+	chunkIndex, err1 := strconv.Atoi(parts[1])
+	shardIndex, err2 := strconv.Atoi(parts[2])
+	if err1 != nil || err2 != nil || ShardAddress(parts[0], chunkIndex, shardIndex) != addr || chunkIndex < 0 || shardIndex < 0 {
+		return "", 0, 0, fmt.Errorf("invalid shard address %q", addr)
+	}
+	return parts[0], chunkIndex, shardIndex, nil
+}
+
+// LocalEndpointScheme marks a farmer endpoint that is a local directory rather
+// than an HTTP farmer, as in "file:///var/shards". Shards are files at
+// LocalShardPath and pinned manifests at LocalPinPath; publishers and retrievers
+// read and write them directly, for tests, offline staging or hybrid fleets.
+const LocalEndpointScheme = "file://"
+
+// LocalEndpoint returns the endpoint naming local directory dir
+func LocalEndpoint(dir string) string {
+	return LocalEndpointScheme + filepath.ToSlash(dir)
+}
+
+// LocalEndpointDir returns the directory a local endpoint names, or false if
+// endpoint isn't one
+func LocalEndpointDir(endpoint string) (string, bool) {
+	dir, ok := strings.CutPrefix(endpoint, LocalEndpointScheme)
+	if !ok || dir == "" {
+		return "", false
+	}
+	return filepath.FromSlash(dir), true
+}
+
+// LocalShardPath returns where a local endpoint's directory holds a shard:
+// dir/{ShardAddress}. Blob IDs that aren't a plain file name are rejected so a
+// manifest can't point outside dir.
+func LocalShardPath(dir, blobID string, chunkIndex, shardIndex int) (string, error) {
+	if err := checkLocalBlobID(blobID); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.FromSlash(ShardAddress(blobID, chunkIndex, shardIndex))), nil
+}
+
+// LocalPinPath returns where a local endpoint's directory holds blobID's pinned
+// manifest, next to its chunk directories
+func LocalPinPath(dir, blobID string) (string, error) {
+	if err := checkLocalBlobID(blobID); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, blobID, "manifest.sealed"), nil
+}
+
+// checkLocalBlobID rejects blob IDs unsafe to use as a directory name
+func checkLocalBlobID(blobID string) error {
+	if !filepath.IsLocal(blobID) || strings.ContainsAny(blobID, `/\`) {
+		return fmt.Errorf("blob id %q can't name a local directory", blobID)
+	}
+	return nil
+}
+
+// ArchiveManifestName is the first entry of a blob archive (retriever.ExportBlob,
+// publisher.ImportBlob): a tar holding the manifest, then every shard's stored
+// bytes at ArchiveShardPath
+const ArchiveManifestName = "manifest.json"
+
+// archiveShardDir is the archive directory shards are stored under
+const archiveShardDir = "shards/"
+
+// ArchiveShardPath returns where a blob archive holds a shard, "shards/{ShardAddress}"
+func ArchiveShardPath(blobID string, chunkIndex, shardIndex int) string {
+	return archiveShardDir + ShardAddress(blobID, chunkIndex, shardIndex)
+}
+
+// ParseArchiveShardPath splits a path made by ArchiveShardPath
+func ParseArchiveShardPath(name string) (blobID string, chunkIndex, shardIndex int, err error) {
+	addr, ok := strings.CutPrefix(name, archiveShardDir)
+	if !ok {
+		return "", 0, 0, fmt.Errorf("invalid archive shard path %q", name)
+	}
+	return ParseShardAddress(addr)
+}
+
+// ShardFetch is one shard request needed to retrieve a chunk
+type ShardFetch struct {
+	Endpoint   string // farmer HTTP endpoint
+	BlobID     string
+	ChunkIndex int
+	ShardIndex int
+	Hash       string // expected SHA256 of the response body
+	Size       int    // expected response size in bytes
+}
+
+// URL returns the GET URL serving the shard
+func (f ShardFetch) URL() string {
+	return ShardURL(f.Endpoint, ShardAddress(f.BlobID, f.ChunkIndex, f.ShardIndex))
+}
+
+// ShardURL returns the URL an HTTP farmer at endpoint serves a shard address at
+func ShardURL(endpoint, addr string) string {
+	return endpoint + shardFetchPath + "/" + addr
+}
+
+// FetchPlanForChunk resolves every shard of a chunk to the farmer request that
+// retrieves it, ordered by shard index so data shards come first.
+// Any DataShards of the returned fetches are enough to reconstruct the chunk.
+func (m *Manifest) FetchPlanForChunk(chunkIndex int) ([]ShardFetch, error) {
+	shards := m.GetShardsForChunk(chunkIndex)
+	if len(shards) == 0 {
+		return nil, fmt.Errorf("chunk %d: no shards in manifest %s", chunkIndex, m.BlobID)
+	}
+	sort.Slice(shards, func(i, j int) bool { return shards[i].ShardIndex < shards[j].ShardIndex })
+
+	plan := make([]ShardFetch, len(shards))
+	for i, shard := range shards {
+		farmer := m.GetFarmerForShard(shard)
+		if farmer == nil {
+			return nil, fmt.Errorf("chunk %d shard %d: farmer index %d not in manifest", chunkIndex, shard.ShardIndex, shard.FarmerIndex)
+		}
+		plan[i] = ShardFetch{
+			Endpoint:   farmer.Endpoint,
+			BlobID:     m.BlobID,
+			ChunkIndex: chunkIndex,
+			ShardIndex: shard.ShardIndex,
+			Hash:       shard.Hash,
+			Size:       shard.Size,
+		}
+	}
+	return plan, nil
+}
+
+// ChainGenesis is the PrevHash of the first chunk in a chained manifest
+const ChainGenesis = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// ChainLink returns the PrevHash of the chunk following one with chunkHash whose
+// own PrevHash is prevHash: SHA256(prevHash || chunkHash) over the hex strings.
+// Each link commits to every earlier chunk hash in order.
+func ChainLink(prevHash, chunkHash string) string {
+	sum := sha256.Sum256([]byte(prevHash + chunkHash))
+	return hex.EncodeToString(sum[:])
+}
+
+// UnrecoverableChunks returns the indices of chunks that cannot be reconstructed
+// when only the given farmers are reachable (fewer than DataShards shards available)
+func (m *Manifest) UnrecoverableChunks(availableFarmers map[int]bool) []int {
+	var unrecoverable []int
+	for _, chunk := range m.Chunks {
+		// Count distinct shard indices so a duplicated entry isn't counted twice
+		available := make(map[int]bool)
+		for _, shard := range m.GetShardsForChunk(chunk.Index) {
+			if availableFarmers[shard.FarmerIndex] {
+				available[shard.ShardIndex] = true
+			}
+		}
+		if len(available) < m.DataShards {
+			unrecoverable = append(unrecoverable, chunk.Index)
+		}
+	}
+	return unrecoverable
+}
+
+// shardHolders maps chunk index → shard index → farmers holding that shard.
+// Shards pointing at farmers missing from the manifest are left out.
+func (m *Manifest) shardHolders() map[int]map[int][]int {
+	holders := make(map[int]map[int][]int, len(m.Chunks))
+	for _, chunk := range m.Chunks {
+		holders[chunk.Index] = make(map[int][]int)
+	}
+	for _, shard := range m.Shards {
+		byShard, ok := holders[shard.ChunkIndex]
+		if !ok || m.GetFarmerForShard(shard) == nil {
+			continue
+		}
+		byShard[shard.ShardIndex] = append(byShard[shard.ShardIndex], shard.FarmerIndex)
+	}
+	return holders
+}
+
+// ChunksByFarmerSet groups chunks by the set of farmers holding their shards, so
+// a scheduler can batch requests to the same farmers or spot chunks sharing a
+// placement. Keys are the set's farmer indices in ascending order, joined by
+// commas ("0,2,5"); values are chunk indices in ascending order. Chunks with
+// identical sets share one key, and a chunk without any placed shard is listed
+// under "". Shards pointing outside Farmers are ignored. Needs no network access.
+func (m *Manifest) ChunksByFarmerSet() map[string][]int {
+	groups := make(map[string][]int)
+	for chunkIndex, byShard := range m.shardHolders() {
+		var set []int
+		for _, farmers := range byShard {
+			set = append(set, farmers...)
+		}
+		sort.Ints(set)
+		set = slices.Compact(set)
+
+		key := make([]string, len(set))
+		for i, f := range set {
+			key[i] = strconv.Itoa(f)
+		}
+		k := strings.Join(key, ",")
+		groups[k] = append(groups[k], chunkIndex)
+	}
+	for _, chunks := range groups {
+		sort.Ints(chunks)
+	}
+	return groups
+}
+
+// MinimalRecoverySet returns a small set of farmer indices whose shards together
+// still supply DataShards distinct shards of every chunk. The set is built greedily,
+// always adding the farmer that fills the most missing shards, so it is sufficient
+// but not necessarily the smallest possible. Errors if even all farmers together
+// cannot recover some chunk.
+func (m *Manifest) MinimalRecoverySet() ([]int, error) {
+	if bad := m.UnrecoverableChunks(m.allFarmers()); len(bad) > 0 {
+		return nil, fmt.Errorf("chunks %v are unrecoverable even with every farmer", bad)
+	}
+
+	holders := m.shardHolders()
+	have := make(map[int]map[int]bool, len(holders)) // chunk → shard indices covered
+	for chunkIndex := range holders {
+		have[chunkIndex] = make(map[int]bool)
+	}
+	chosen := make(map[int]bool)
+
+	for {
+		// Gain of each farmer: shards it would add to chunks still short of DataShards
+		gain := make(map[int]int)
+		for chunkIndex, byShard := range holders {
+			if len(have[chunkIndex]) >= m.DataShards {
+				continue
+			}
+			perFarmer := make(map[int]int)
+			for shardIndex, farmers := range byShard {
+				if have[chunkIndex][shardIndex] {
+					continue
+				}
+				for _, f := range farmers {
+					if !chosen[f] {
+						perFarmer[f]++
+					}
+				}
+			}
+			need := m.DataShards - len(have[chunkIndex])
+			for f, n := range perFarmer {
+				gain[f] += min(n, need)
+			}
+		}
+		if len(gain) == 0 {
+			break
+		}
+
+		best := -1
+		for f, g := range gain {
+			if best == -1 || g > gain[best] || (g == gain[best] && f < best) {
+				best = f
+			}
+		}
+		chosen[best] = true
+		for chunkIndex, byShard := range holders {
+			for shardIndex, farmers := range byShard {
+				for _, f := range farmers {
+					if f == best {
+						have[chunkIndex][shardIndex] = true
+					}
+				}
+			}
+		}
+	}
+
+	set := make([]int, 0, len(chosen))
+	for f := range chosen {
+		set = append(set, f)
+	}
+	sort.Ints(set)
+	return set, nil
+}
+
+// RecoveryMargin returns how many farmers can fail at once with every chunk still
+// recoverable, or -1 if the blob is already unrecoverable. Per chunk it removes
+// farmers greedily, each time the one whose loss makes the most shards unavailable,
+// until the chunk drops below DataShards; the blob's margin is the smallest such
+// count minus one. Exact when each shard lives on a single farmer, as the
+// publisher places them; with duplicated shards it may overestimate.
+func (m *Manifest) RecoveryMargin() int {
+	margin := len(m.Farmers)
+	for _, byShard := range m.shardHolders() {
+		if len(byShard) < m.DataShards {
+			return -1
+		}
+
+		failed := make(map[int]bool)
+		available := func() int {
+			n := 0
+			for _, farmers := range byShard {
+				for _, f := range farmers {
+					if !failed[f] {
+						n++
+						break
+					}
+				}
+			}
+			return n
+		}
+
+		removed := 0
+		for available() >= m.DataShards {
+			// Fail the farmer whose loss leaves the fewest shards available
+			best, bestLeft := -1, 0
+			for _, farmers := range byShard {
+				for _, f := range farmers {
+					if failed[f] {
+						continue
+					}
+					failed[f] = true
+					left := available()
+					failed[f] = false
+					if best == -1 || left < bestLeft || (left == bestLeft && f < best) {
+						best, bestLeft = f, left
+					}
+				}
+			}
+			if best == -1 {
+				break // every holder already failed
+			}
+			failed[best] = true
+			removed++
+		}
+		margin = min(margin, removed-1)
+	}
+	return margin
+}
+
+// allFarmers marks every farmer in the manifest as available
+func (m *Manifest) allFarmers() map[int]bool {
+	all := make(map[int]bool, len(m.Farmers))
+	for i := range m.Farmers {
+		all[i] = true
+	}
+	return all
+}
+
+// RegionSpread returns the number of distinct farmer regions holding shards of a chunk.
+// Farmers without a region don't count towards the spread.
+func (m *Manifest) RegionSpread(chunkIndex int) int {
+	regions := make(map[string]bool)
+	for _, farmer := range m.GetFarmersForChunk(chunkIndex) {
+		if farmer.Region != "" {
+			regions[farmer.Region] = true
+		}
+	}
+	return len(regions)
+}
+
+// RemapFarmerEndpoints rewrites farmer endpoints after a fleet migration.
+// mapping is old endpoint → new endpoint; returns the number of farmers changed.
+// Index, Address and Region are untouched, so ShardMeta.FarmerIndex stays valid.
+func (m *Manifest) RemapFarmerEndpoints(mapping map[string]string) int {
+	changed := 0
+	for i := range m.Farmers {
+		if endpoint, ok := mapping[m.Farmers[i].Endpoint]; ok && endpoint != m.Farmers[i].Endpoint {
+			m.Farmers[i].Endpoint = endpoint
+			changed++
+		}
+	}
+	return changed
+}
+
+// RemapFarmersByAddress sets new endpoints keyed by farmer wallet address,
+// which stays stable when endpoints move. Returns the number of farmers changed.
+func (m *Manifest) RemapFarmersByAddress(mapping map[string]string) int {
+	changed := 0
+	for i := range m.Farmers {
+		if m.Farmers[i].Address == "" {
+			continue
+		}
+		if endpoint, ok := mapping[m.Farmers[i].Address]; ok && endpoint != m.Farmers[i].Endpoint {
+			m.Farmers[i].Endpoint = endpoint
+			changed++
+		}
+	}
+	return changed
+}
+
+// Recompute restores the scalar fields derived from Chunks after they were
+// mutated (repair, append): ChunkCount, FileSize (sum of chunk sizes) and
+// TotalShards. It then checks the shard and farmer lists are consistent with
+// each other, reporting the first problem found. Call it before Validate and Save.
+func (m *Manifest) Recompute() error {
+	m.ChunkCount = len(m.Chunks)
+	m.FileSize = 0
+	chunks := make(map[int]bool, len(m.Chunks))
+	for _, chunk := range m.Chunks {
+		if chunks[chunk.Index] {
+			return fmt.Errorf("chunk %d listed more than once", chunk.Index)
+		}
+		chunks[chunk.Index] = true
+		m.FileSize += int64(chunk.Size)
+	}
+	m.TotalShards = m.DataShards + m.ParityShards
+
+	if err := m.checkFarmerIndices(); err != nil {
+		return err
+	}
+
+	seen := make(map[[2]int]bool, len(m.Shards))
+	for _, shard := range m.Shards {
+		switch {
+		case !chunks[shard.ChunkIndex]:
+			return fmt.Errorf("shard %d references unlisted chunk %d", shard.ShardIndex, shard.ChunkIndex)
+		case shard.ShardIndex < 0 || shard.ShardIndex >= m.TotalShards:
+			return fmt.Errorf("chunk %d: shard index %d outside %d+%d erasure config", shard.ChunkIndex, shard.ShardIndex, m.DataShards, m.ParityShards)
+		case shard.FarmerIndex < 0 || shard.FarmerIndex >= len(m.Farmers):
+			return fmt.Errorf("chunk %d shard %d: farmer index %d not in manifest", shard.ChunkIndex, shard.ShardIndex, shard.FarmerIndex)
+		case seen[[2]int{shard.ChunkIndex, shard.ShardIndex}]:
+			return fmt.Errorf("chunk %d shard %d listed more than once", shard.ChunkIndex, shard.ShardIndex)
+		}
+		seen[[2]int{shard.ChunkIndex, shard.ShardIndex}] = true
+	}
+	return nil
+}
+
+// Validate checks every farmer's Index is its position in Farmers, the manifest's
+// durability constraints still hold and its encrypted fields are well formed
+func (m *Manifest) Validate() error {
+	if err := m.checkFarmerIndices(); err != nil {
+		return err
+	}
+	if err := m.checkDistinctFarmers(); err != nil {
+		return err
+	}
+	if err := m.validateEncryptedFields(); err != nil {
+		return err
+	}
+	if m.MinRegions > 0 {
+		for _, chunk := range m.Chunks {
+			// Recorded spread must have met the constraint at upload time
+			if chunk.Regions < m.MinRegions {
+				return fmt.Errorf("chunk %d: recorded region spread %d below MinRegions %d", chunk.Index, chunk.Regions, m.MinRegions)
+			}
+			// Current placement must still satisfy it
+			if spread := m.RegionSpread(chunk.Index); spread < m.MinRegions {
+				return fmt.Errorf("chunk %d: shards span %d regions, MinRegions requires %d", chunk.Index, spread, m.MinRegions)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateShardSizes checks every chunk's shards against the erasure config: a
+// chunk's shards must all be the padded shard size ceil(encrypted size / DataShards),
+// computed from PaddedSize when set, plus the ShardWrap overhead, then grown by
+// the ShardFEC encoding. Anything else means the manifest is corrupt or was
+// tampered with, and the chunk couldn't be reconstructed. Chunks whose size
+// can't be implied from the metadata (see ChunkEncryptedSize) are skipped.
+func (m *Manifest) ValidateShardSizes() error {
+	dataShards := m.DataShards
+	if dataShards <= 0 {
+		dataShards = chunker.DataShards
+	}
+	wrapOverhead := 0
+	if m.ShardWrap != "" {
+		if wrapOverhead = crypto.Algorithm(m.ShardWrap).Overhead(); wrapOverhead == 0 {
+			return fmt.Errorf("unsupported shard wrap %q", m.ShardWrap)
+		}
+	}
+	if m.ShardFEC != nil {
+		if err := m.ShardFEC.Validate(); err != nil {
+			return err
+		}
+	}
+
+	byChunk := make(map[int][]ShardMeta, len(m.Chunks))
+	for _, shard := range m.Shards {
+		byChunk[shard.ChunkIndex] = append(byChunk[shard.ChunkIndex], shard)
+	}
+	for _, chunk := range m.Chunks {
+		encryptedSize := m.impliedEncryptedSize(chunk)
+		if encryptedSize == 0 {
+			continue
+		}
+		if m.PaddedSize > 0 {
+			if m.PaddedSize < encryptedSize {
+				return fmt.Errorf("chunk %d: encrypted size %d exceeds padded size %d", chunk.Index, encryptedSize, m.PaddedSize)
+			}
+			encryptedSize = m.PaddedSize
+		}
+		expected := m.storedShardSize(chunker.ExpectedShardSize(encryptedSize, dataShards) + wrapOverhead)
+		for _, shard := range byChunk[chunk.Index] {
+			if shard.Size != expected {
+				return fmt.Errorf("chunk %d shard %d: size %d, expected %d for %d bytes over %d data shards",
+					chunk.Index, shard.ShardIndex, shard.Size, expected, encryptedSize, dataShards)
+			}
+		}
+	}
+	return nil
+}
+
+// storedShardSize returns the size a shard of size bytes is stored at, after
+// the ShardFEC encoding if any
+func (m *Manifest) storedShardSize(size int) int {
+	if m.ShardFEC != nil {
+		return m.ShardFEC.EncodedSize(size)
+	}
+	return size
+}
+
+// impliedEncryptedSize returns chunk's encrypted size from the metadata alone:
+// EncryptedSize when recorded, else the plaintext size plus ChunkOverhead. A
+// plaintext size that wasn't recorded either follows from the chunk's position:
+// ChunkSize for all but the last chunk, the rest of FileSize for the last.
+// Returns 0 if none of that is known.
+func (m *Manifest) impliedEncryptedSize(chunk ChunkMeta) int {
+	if chunk.EncryptedSize > 0 {
+		return chunk.EncryptedSize
+	}
+	size := chunk.Size
+	if size == 0 {
+		chunkSize := int64(m.ChunkSize)
+		if chunkSize <= 0 {
+			chunkSize = chunker.ChunkSize
+		}
+		switch rest := m.FileSize - int64(chunk.Index)*chunkSize; {
+		case chunk.Index < 0 || chunk.Index >= m.ChunkCount:
+		case chunk.Index < m.ChunkCount-1:
+			size = int(chunkSize)
+		case rest > 0 && rest <= chunkSize:
+			size = int(rest)
+		}
+	}
+	if size == 0 {
+		return 0
+	}
+	return size + m.ChunkOverhead()
+}
+
+// ChunkEncryptedSize returns the size of the ciphertext chunk's shards encode,
+// which reconstruction needs to join them. Manifests predating
+// ChunkMeta.EncryptedSize don't record it, so it is inferred: from the plaintext
+// size plus the AEAD overhead, checked against the recorded shard sizes, or
+// failing that from the shard sizes alone. Unpadded shards of size s hold
+// anything from (s-1)*DataShards+1 to s*DataShards bytes, so that only settles
+// it with a single data shard; otherwise the size is ambiguous and an error is
+// returned rather than a guess.
+func (m *Manifest) ChunkEncryptedSize(chunk ChunkMeta) (int, error) {
+	dataShards := m.DataShards
+	if dataShards <= 0 {
+		dataShards = chunker.DataShards
+	}
+	wrapOverhead := 0
+	if m.ShardWrap != "" {
+		wrapOverhead = crypto.Algorithm(m.ShardWrap).Overhead()
+	}
+	shardSize, shards := 0, 0
+	for _, shard := range m.Shards {
+		if shard.ChunkIndex != chunk.Index {
+			continue
+		}
+		if shards > 0 && shard.Size != shardSize {
+			return 0, fmt.Errorf("chunk %d: shards differ in size (%d and %d)", chunk.Index, shardSize, shard.Size)
+		}
+		shardSize = shard.Size
+		shards++
+	}
+
+	if encryptedSize := m.impliedEncryptedSize(chunk); encryptedSize > 0 {
+		if shards == 0 || chunk.EncryptedSize > 0 {
+			return encryptedSize, nil
+		}
+		sharded := encryptedSize
+		if m.PaddedSize > 0 {
+			sharded = max(sharded, m.PaddedSize)
+		}
+		if expected := m.storedShardSize(chunker.ExpectedShardSize(sharded, dataShards) + wrapOverhead); shardSize != expected {
+			return 0, fmt.Errorf("chunk %d: %d-byte shards can't hold a %d-byte encrypted chunk (expected %d)",
+				chunk.Index, shardSize, encryptedSize, expected)
+		}
+		return encryptedSize, nil
+	}
+
+	if shards == 0 {
+		return 0, fmt.Errorf("chunk %d: encrypted size not recorded and no shards to infer it from", chunk.Index)
+	}
+	if m.PaddedSize > 0 {
+		return 0, fmt.Errorf("chunk %d: encrypted size not recorded and padded shards don't reveal it", chunk.Index)
+	}
+	payload := shardSize
+	if m.ShardFEC != nil {
+		decoded, ok := m.ShardFEC.DecodedSize(shardSize)
+		if !ok {
+			return 0, fmt.Errorf("chunk %d: %d-byte shards don't fit %d+%d shard FEC", chunk.Index, shardSize, m.ShardFEC.Blocks, m.ShardFEC.ParityBlocks)
+		}
+		payload = decoded
+	}
+	payload -= wrapOverhead
+	if payload <= 0 {
+		return 0, fmt.Errorf("chunk %d: %d-byte shards are too small to hold a chunk", chunk.Index, shardSize)
+	}
+	low, high := (payload-1)*dataShards+1, payload*dataShards
+	if low != high {
+		return 0, fmt.Errorf("chunk %d: encrypted size not recorded and ambiguous: %d-byte shards fit anything from %d to %d bytes",
+			chunk.Index, shardSize, low, high)
+	}
+	return high, nil
+}
+
+// checkFarmerIndices enforces the invariant Farmers[i].Index == i. Code that
+// reads farmers by ShardMeta.FarmerIndex may index the slice directly, so a
+// duplicated or out-of-place index would silently name the wrong farmer.
+func (m *Manifest) checkFarmerIndices() error {
+	seen := make(map[int]bool, len(m.Farmers))
+	for i, farmer := range m.Farmers {
+		if seen[farmer.Index] {
+			return fmt.Errorf("farmer index %d listed more than once", farmer.Index)
+		}
+		seen[farmer.Index] = true
+		if farmer.Index != i {
+			return fmt.Errorf("farmer at position %d has index %d", i, farmer.Index)
+		}
+	}
+	return nil
+}
+
+// checkDistinctFarmers rejects farmers listed under more than one index.
+// Shards spread across duplicate entries live on one host, so losing it drops
+// more shards than the parity budget assumes.
+func (m *Manifest) checkDistinctFarmers() error {
+	endpoints := make(map[string]int)
+	addresses := make(map[string]int)
+	for _, farmer := range m.Farmers {
+		if farmer.Endpoint != "" {
+			if other, ok := endpoints[farmer.Endpoint]; ok {
+				return fmt.Errorf("farmers %d and %d share endpoint %s", other, farmer.Index, farmer.Endpoint)
+			}
+			endpoints[farmer.Endpoint] = farmer.Index
+		}
+		if farmer.Address != "" {
+			if other, ok := addresses[farmer.Address]; ok {
+				return fmt.Errorf("farmers %d and %d share address %s", other, farmer.Index, farmer.Address)
+			}
+			addresses[farmer.Address] = farmer.Index
+		}
+	}
+	return nil
+}
+
+// SharedChunk is a pair of chunks with identical plaintext hashes in two manifests
+type SharedChunk struct {
+	Hash   string `json:"hash"`    // shared plaintext chunk hash
+	AIndex int    `json:"a_index"` // chunk index in manifest a
+	BIndex int    `json:"b_index"` // chunk index in manifest b
+	Size   int    `json:"size"`    // chunk size in bytes
+}
+
+// SharedBytes returns the total size of the shared chunks
+func SharedBytes(pairs []SharedChunk) int64 {
+	var total int64
+	for _, pair := range pairs {
+		total += int64(pair.Size)
+	}
+	return total
+}
+
+// FindSharedChunks finds chunks of b whose hash also appears in a.
+// Builds a hash set over a, so runs in O(n+m). Each chunk of b is paired
+// with the first chunk of a carrying the same hash; len() of the result is
+// the shared count and SharedBytes gives the storage that dedup would save.
+// Manifests with ciphertext chunk hashes share nothing.
+func FindSharedChunks(a, b *Manifest) []SharedChunk {
+	if a == nil || b == nil {
+		return nil
+	}
+	// Ciphertext hashes never match across uploads
+	if a.HashesCiphertext() || b.HashesCiphertext() {
+		return nil
+	}
+
+	// hash → first chunk index in a
+	seen := make(map[string]int, len(a.Chunks))
+	for _, chunk := range a.Chunks {
+		if _, ok := seen[chunk.Hash]; !ok {
+			seen[chunk.Hash] = chunk.Index
+		}
+	}
+
+	var pairs []SharedChunk
+	for _, chunk := range b.Chunks {
+		aIndex, ok := seen[chunk.Hash]
+		if !ok {
+			continue
+		}
+		pairs = append(pairs, SharedChunk{
+			Hash:   chunk.Hash,
+			AIndex: aIndex,
+			BIndex: chunk.Index,
+			Size:   chunk.Size,
+		})
+	}
+
+	return pairs
+}
+
+// ManifestSummary is a compact overview of a manifest for CLIs and dashboards.
+// Never includes the encryption key.
+type ManifestSummary struct {
+	BlobID        string    `json:"blob_id"`
+	FileName      string    `json:"file_name"`
+	FileSize      int64     `json:"file_size"`
+	ChunkCount    int       `json:"chunk_count"`
+	TotalShards   int       `json:"total_shards"`   // shard entries across all chunks
+	UniqueFarmers int       `json:"unique_farmers"` // farmers holding at least one shard
+	Regions       []string  `json:"regions"`        // sorted distinct regions of those farmers
+	DataShards    int       `json:"data_shards"`
+	ParityShards  int       `json:"parity_shards"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Summary computes a ManifestSummary from the manifest's fields
+func (m *Manifest) Summary() ManifestSummary {
+	farmers := make(map[int]bool)
+	regionSet := make(map[string]bool)
+	for _, shard := range m.Shards {
+		if farmers[shard.FarmerIndex] {
+			continue
+		}
+		farmers[shard.FarmerIndex] = true
+		if farmer := m.GetFarmerForShard(shard); farmer != nil && farmer.Region != "" {
+			regionSet[farmer.Region] = true
+		}
+	}
+
+	regions := make([]string, 0, len(regionSet))
+	for region := range regionSet {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+
+	return ManifestSummary{
+		BlobID:        m.BlobID,
+		FileName:      m.FileName,
+		FileSize:      m.FileSize,
+		ChunkCount:    len(m.Chunks),
+		TotalShards:   len(m.Shards),
+		UniqueFarmers: len(farmers),
+		Regions:       regions,
+		DataShards:    m.DataShards,
+		ParityShards:  m.ParityShards,
+		CreatedAt:     m.CreatedAt,
+	}
+}
+
+// String renders the summary on one line for logs
+func (s ManifestSummary) String() string {
+	return fmt.Sprintf("%s (%d bytes): %d chunks, %d shards (%d+%d) on %d farmers in %d regions %v, created %s",
+		s.FileName, s.FileSize, s.ChunkCount, s.TotalShards, s.DataShards, s.ParityShards,
+		s.UniqueFarmers, len(s.Regions), s.Regions, s.CreatedAt.Format(time.RFC3339))
+}
+
+// ErrWrongKey is returned by CheckKey when a key doesn't match the manifest
+var ErrWrongKey = errors.New("wrong encryption key")
+
+// CheckKey verifies key against the manifest's KeyCommitment, returning ErrWrongKey
+// on mismatch. Manifests without a commitment accept any key.
+func (m *Manifest) CheckKey(key []byte) error {
+	if m.KeyCommitment == "" {
+		return nil
+	}
+	if !hmac.Equal([]byte(crypto.KeyCommitment(key)), []byte(m.KeyCommitment)) {
+		return ErrWrongKey
+	}
+	return nil
+}
+
 // GetEncryptionKey returns the encryption key as bytes
 func (m *Manifest) GetEncryptionKey() ([]byte, error) {
 	return hex.DecodeString(m.EncryptionKey)
 }
 
+// KeyFromPassphrase derives the encryption key of a manifest with KDF set,
+// returning ErrWrongKey if the passphrase doesn't match its KeyCommitment
+func (m *Manifest) KeyFromPassphrase(passphrase []byte) ([]byte, error) {
+	if m.KDF == nil {
+		return nil, fmt.Errorf("manifest %s key is not derived from a passphrase", m.BlobID)
+	}
+	key, err := crypto.DeriveKey(passphrase, *m.KDF)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	if err := m.CheckKey(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// GetPublisherPublicKey returns the publisher's ed25519 public key.
+// Returns an error if the manifest doesn't record one.
+func (m *Manifest) GetPublisherPublicKey() (ed25519.PublicKey, error) {
+	if m.PublisherPublicKey == "" {
+		return nil, fmt.Errorf("manifest %s has no publisher public key", m.BlobID)
+	}
+	key, err := hex.DecodeString(m.PublisherPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid publisher public key: %w", err)
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid publisher public key size: expected %d, got %d", ed25519.PublicKeySize, len(key))
+	}
+	return ed25519.PublicKey(key), nil
+}
+
 // CalculateFileHash computes SHA256 hash of entire file
 func CalculateFileHash(filePath string) (string, error) {
 	// Read the JSON manifest from the specified path
@@ -192,4 +1326,51 @@ func CalculateFileHash(filePath string) (string, error) {
 	// Compute SHA256 hash of the file data
 	hash := sha256.Sum256(data)
 	return hex.EncodeToString(hash[:]), nil
-}
\ No newline at end of file
+}
+// VerifyLocalFile checks a local file against the manifest chunk by chunk, using
+// the manifest's chunk size, without hashing the whole file up front or writing
+// anything. Fails at the first chunk whose hash or size differs, or if the file
+// has a different number of chunks. Manifests with ciphertext chunk hashes can't
+// be checked this way.
+func VerifyLocalFile(m *Manifest, path string) error {
+	if m.HashesCiphertext() {
+		return fmt.Errorf("manifest records ciphertext chunk hashes; local file can't be compared")
+	}
+	byIndex := make(map[int]ChunkMeta, len(m.Chunks))
+	for _, c := range m.Chunks {
+		byIndex[c.Index] = c
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	// Cancelling releases the chunk reader when a mismatch stops us early
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	n := 0
+	for result := range chunker.StreamChunkReader(ctx, file, chunker.Config{ChunkSize: m.ChunkSize}) {
+		if result.Err != nil {
+			return result.Err
+		}
+		chunk := result.Chunk
+		if chunk.Index >= m.ChunkCount {
+			return fmt.Errorf("file has more than the manifest's %d chunks", m.ChunkCount)
+		}
+		meta, ok := byIndex[chunk.Index]
+		if !ok {
+			return fmt.Errorf("chunk %d is not listed in the manifest", chunk.Index)
+		}
+		if chunk.Size != meta.Size || chunk.Hash != meta.Hash {
+			return fmt.Errorf("chunk %d does not match manifest", chunk.Index)
+		}
+		n++
+	}
+	if n != m.ChunkCount {
+		return fmt.Errorf("file has %d chunks, manifest records %d", n, m.ChunkCount)
+	}
+	return nil
+}
@@ -2,8 +2,18 @@ package manifest
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/crypto"
 )
 
 // ============================================================================
@@ -103,6 +113,25 @@ func TestBlobID_Uniqueness(t *testing.T) {
 	}
 }
 
+func TestGenerateBlobID_Deterministic(t *testing.T) {
+	saved := randReader
+	t.Cleanup(func() { randReader = saved })
+
+	randReader = bytes.NewReader(bytes.Repeat([]byte{0xab}, 32))
+	want := "0x" + strings.Repeat("ab", 32)
+	if got := generateBlobID(); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+
+	// An exhausted reader is a broken test setup, not a silent zero ID
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic when the reader runs dry")
+		}
+	}()
+	generateBlobID()
+}
+
 // ============================================================================
 // SAVE/LOAD TESTS
 // ============================================================================
@@ -233,6 +262,97 @@ func TestLoad_NonExistent(t *testing.T) {
 	}
 }
 
+func TestLoadLimited(t *testing.T) {
+	chunks := []ChunkMeta{{Index: 0, Hash: "hash0", Size: 1024}}
+	shards := []ShardMeta{{ChunkIndex: 0, ShardIndex: 0, Hash: "s0", Size: 256, FarmerIndex: 0}}
+	farmers := []FarmerInfo{{Index: 0, Address: "0xF1", Endpoint: "https://f1.io", Region: "us"}}
+	key := []byte("test-key-32-bytes-long-padding!!")
+	m := New("test.bin", 1024, "hash", chunks, shards, farmers, key, "0xPub")
+
+	testFile := "test-limited-manifest.json"
+	defer os.Remove(testFile)
+	if err := m.Save(testFile); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Within limit
+	loaded, err := LoadLimited(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("LoadLimited failed: %v", err)
+	}
+	if loaded.BlobID != m.BlobID {
+		t.Error("BlobID mismatch")
+	}
+
+	// One byte over the limit
+	if _, err := LoadLimited(bytes.NewReader(data), int64(len(data)-1)); err == nil {
+		t.Error("Expected error for manifest exceeding size limit")
+	}
+
+	// Non-JSON blob rejected by sniff
+	if _, err := LoadLimited(bytes.NewReader([]byte("\x89PNG\r\n\x1a\n")), 1024); err == nil {
+		t.Error("Expected error for non-manifest input")
+	}
+
+	// JSON object that isn't a manifest
+	if _, err := LoadLimited(bytes.NewReader([]byte(`{"hello": "world"}`)), 1024); err == nil {
+		t.Error("Expected error for JSON without manifest fields")
+	}
+
+	// Invalid limit
+	if _, err := LoadLimited(bytes.NewReader(data), 0); err == nil {
+		t.Error("Expected error for zero size limit")
+	}
+}
+
+func TestLoad_RejectsImplausibleCounts(t *testing.T) {
+	testFile := "test-limits.json"
+	defer os.Remove(testFile)
+
+	chunks := []ChunkMeta{{Index: 0, Hash: "hash0", Size: 1024}}
+	valid := New("small.bin", 1024, "hash", chunks, nil, nil, make([]byte, 32), "0xPub")
+
+	cases := []struct {
+		name   string
+		mutate func(m *Manifest)
+	}{
+		{"chunk count beyond limit", func(m *Manifest) { m.FileSize = 1 << 62; m.ChunkCount = 1 << 40 }},
+		{"chunk count beyond file size", func(m *Manifest) { m.ChunkCount = 2 }},
+		{"negative chunk count", func(m *Manifest) { m.ChunkCount = -1 }},
+		{"too many shards per chunk", func(m *Manifest) { m.ParityShards = 1000; m.TotalShards = 1004 }},
+		{"too many shard entries", func(m *Manifest) { m.Shards = make([]ShardMeta, 7) }},
+	}
+	for _, c := range cases {
+		m := *valid
+		c.mutate(&m)
+		if err := m.Save(testFile); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(testFile); err == nil {
+			t.Errorf("%s: expected Load to fail", c.name)
+		}
+		data, _ := os.ReadFile(testFile)
+		if _, err := LoadLimited(bytes.NewReader(data), int64(len(data))); err == nil {
+			t.Errorf("%s: expected LoadLimited to fail", c.name)
+		}
+	}
+
+	// Limits are configurable
+	if err := valid.Save(testFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(testFile); err != nil {
+		t.Fatalf("Load of valid manifest failed: %v", err)
+	}
+	if _, err := LoadWithLimits(testFile, Limits{MaxChunks: 0, MaxShardsPerChunk: 256}); err == nil {
+		t.Error("Expected error with MaxChunks 0")
+	}
+}
+
 // ============================================================================
 // CHUNK QUERY TESTS
 // ============================================================================
@@ -267,6 +387,53 @@ func TestGetChunkHash(t *testing.T) {
 	}
 }
 
+func TestChunkOffsets(t *testing.T) {
+	key := []byte("test-key-32-bytes-long-padding!!")
+
+	// Fixed-size chunks with a short last one
+	uniform := New("test.bin", 2500, "filehash", []ChunkMeta{
+		{Index: 0, Hash: "hash0", Size: 1024},
+		{Index: 1, Hash: "hash1", Size: 1024},
+		{Index: 2, Hash: "hash2", Size: 452},
+	}, nil, nil, key, "0xPublisher")
+	got, err := uniform.ChunkOffsets()
+	if err != nil {
+		t.Fatalf("ChunkOffsets failed: %v", err)
+	}
+	want := []ChunkRange{{0, 0, 1024}, {1, 1024, 2048}, {2, 2048, 2500}}
+	if !slices.Equal(got, want) {
+		t.Errorf("Uniform ranges: got %v, want %v", got, want)
+	}
+
+	// Content-defined chunk sizes, listed out of order
+	cdc := New("test.bin", 10000, "filehash", []ChunkMeta{
+		{Index: 2, Hash: "hash2", Size: 6000},
+		{Index: 0, Hash: "hash0", Size: 3100},
+		{Index: 1, Hash: "hash1", Size: 900},
+	}, nil, nil, key, "0xPublisher")
+	got, err = cdc.ChunkOffsets()
+	if err != nil {
+		t.Fatalf("ChunkOffsets failed: %v", err)
+	}
+	want = []ChunkRange{{0, 0, 3100}, {1, 3100, 4000}, {2, 4000, 10000}}
+	if !slices.Equal(got, want) {
+		t.Errorf("CDC ranges: got %v, want %v", got, want)
+	}
+
+	// Sizes that don't add up to the file size
+	cdc.FileSize = 10001
+	if _, err := cdc.ChunkOffsets(); err == nil {
+		t.Error("Expected an error when chunk sizes don't sum to FileSize")
+	}
+
+	// A gap in the chunk indices
+	cdc.FileSize = 10000
+	cdc.Chunks[0].Index = 3
+	if _, err := cdc.ChunkOffsets(); err == nil {
+		t.Error("Expected an error for a missing chunk index")
+	}
+}
+
 // ============================================================================
 // SHARD QUERY TESTS
 // ============================================================================
@@ -465,180 +632,1404 @@ func TestGetFarmersForChunk_Deduplication(t *testing.T) {
 	}
 }
 
-// ============================================================================
-// ENCRYPTION KEY TESTS
-// ============================================================================
+func TestVerifyShardData(t *testing.T) {
+	data := []byte("shard bytes held by a farmer")
+	sum := sha256.Sum256(data)
+	shards := []ShardMeta{
+		{ChunkIndex: 0, ShardIndex: 0, Hash: "other", Size: 4},
+		{ChunkIndex: 1, ShardIndex: 3, Hash: hex.EncodeToString(sum[:]), Size: len(data)},
+	}
+	m := New("f.bin", 1, "h", nil, shards, nil, make([]byte, 32), "0xPub")
 
-func TestGetEncryptionKey(t *testing.T) {
-	key := []byte("test-key-32-bytes-long-padding!!")
-	chunks := []ChunkMeta{{Index: 0, Hash: "hash0", Size: 1024}}
-	shards := []ShardMeta{}
-	farmers := []FarmerInfo{}
+	if err := m.VerifyShardData(1, 3, data); err != nil {
+		t.Errorf("Expected matching shard to verify, got %v", err)
+	}
+	if err := m.VerifyShardData(1, 3, data[:10]); err == nil {
+		t.Error("Expected size mismatch error")
+	}
 
-	m := New("test.bin", 1024, "hash", chunks, shards, farmers, key, "0xPub")
+	tampered := append([]byte(nil), data...)
+	tampered[0] ^= 0xFF
+	if err := m.VerifyShardData(1, 3, tampered); err == nil {
+		t.Error("Expected hash mismatch error")
+	}
 
-	retrievedKey, err := m.GetEncryptionKey()
-	if err != nil {
-		t.Fatalf("GetEncryptionKey failed: %v", err)
+	if err := m.VerifyShardData(7, 0, data); err == nil {
+		t.Error("Expected error for shard not in manifest")
 	}
+}
 
-	if len(retrievedKey) != 32 {
-		t.Errorf("Expected key size 32, got %d", len(retrievedKey))
+func TestUnrecoverableChunks(t *testing.T) {
+	farmers := []FarmerInfo{
+		{Index: 0, Address: "0xF0", Endpoint: "https://f0.io", Region: "us-east"},
+		{Index: 1, Address: "0xF1", Endpoint: "https://f1.io", Region: "us-west"},
+		{Index: 2, Address: "0xF2", Endpoint: "https://f2.io", Region: "eu-west"},
+		{Index: 3, Address: "0xF3", Endpoint: "https://f3.io", Region: "ap-south"},
+		{Index: 4, Address: "0xF4", Endpoint: "https://f4.io", Region: "us-east-2"},
+		{Index: 5, Address: "0xF5", Endpoint: "https://f5.io", Region: "eu-central"},
 	}
 
-	// Compare with original
-	if !bytes.Equal(key, retrievedKey) {
-		t.Error("Retrieved key doesn't match original")
+	// Chunk 0: shard i on farmer i; Chunk 1: shards packed onto farmers 0 and 1
+	var shards []ShardMeta
+	for shardIdx := 0; shardIdx < 6; shardIdx++ {
+		shards = append(shards, ShardMeta{ChunkIndex: 0, ShardIndex: shardIdx, Hash: "c0", Size: 256, FarmerIndex: shardIdx})
+		shards = append(shards, ShardMeta{ChunkIndex: 1, ShardIndex: shardIdx, Hash: "c1", Size: 256, FarmerIndex: shardIdx % 2})
 	}
-}
 
-func TestGetEncryptionKey_InvalidHex(t *testing.T) {
-	m := &Manifest{
-		EncryptionKey: "invalid-hex-string", // Not valid hex
+	chunks := []ChunkMeta{
+		{Index: 0, Hash: "hash0", Size: 1024},
+		{Index: 1, Hash: "hash1", Size: 1024},
 	}
 
-	_, err := m.GetEncryptionKey()
-	if err == nil {
-		t.Error("Expected error for invalid hex string")
+	key := []byte("test-key-32-bytes-long-padding!!")
+	m := New("test.bin", 2048, "hash", chunks, shards, farmers, key, "0xPublisher")
+
+	// All farmers up: nothing unrecoverable
+	all := map[int]bool{0: true, 1: true, 2: true, 3: true, 4: true, 5: true}
+	if got := m.UnrecoverableChunks(all); len(got) != 0 {
+		t.Errorf("Expected no unrecoverable chunks, got %v", got)
+	}
+
+	// Farmers 0 and 1 down: chunk 0 keeps 4 shards, chunk 1 loses everything
+	degraded := map[int]bool{2: true, 3: true, 4: true, 5: true}
+	got := m.UnrecoverableChunks(degraded)
+	if len(got) != 1 || got[0] != 1 {
+		t.Errorf("Expected [1] unrecoverable, got %v", got)
+	}
+
+	// Farmers 0, 1 and 2 down: both chunks fail
+	got = m.UnrecoverableChunks(map[int]bool{3: true, 4: true, 5: true})
+	if len(got) != 2 {
+		t.Errorf("Expected 2 unrecoverable chunks, got %v", got)
 	}
 }
 
-// ============================================================================
-// FILE HASH TESTS
-// ============================================================================
+func TestMinimalRecoverySet_RecoveryMargin(t *testing.T) {
+	farmers := make([]FarmerInfo, 6)
+	for i := range farmers {
+		farmers[i] = FarmerInfo{Index: i, Endpoint: fmt.Sprintf("https://f%d.io", i)}
+	}
 
-func TestCalculateFileHash(t *testing.T) {
-	testFile := "test-hash.bin"
-	testData := []byte("test data for hashing")
-	if err := os.WriteFile(testFile, testData, 0644); err != nil {
-		t.Fatal(err)
+	// Chunk 0: shard i on farmer i
+	var shards []ShardMeta
+	for shardIdx := 0; shardIdx < 6; shardIdx++ {
+		shards = append(shards, ShardMeta{ChunkIndex: 0, ShardIndex: shardIdx, FarmerIndex: shardIdx})
 	}
-	defer os.Remove(testFile)
+	chunks := []ChunkMeta{{Index: 0}}
+	m := New("test.bin", 1024, "hash", chunks, shards, farmers, make([]byte, 32), "0xPub")
 
-	hash, err := CalculateFileHash(testFile)
+	set, err := m.MinimalRecoverySet()
 	if err != nil {
-		t.Fatalf("CalculateFileHash failed: %v", err)
+		t.Fatalf("MinimalRecoverySet failed: %v", err)
+	}
+	if len(set) != 4 {
+		t.Errorf("Expected 4 farmers to recover a 4+2 chunk, got %v", set)
+	}
+	if got := m.RecoveryMargin(); got != 2 {
+		t.Errorf("Expected margin 2 with one shard per farmer, got %d", got)
 	}
 
-	if hash == "" {
-		t.Error("Hash is empty")
+	// Chunk 1 packs its shards onto farmers 0 and 1: losing either breaks it
+	for shardIdx := 0; shardIdx < 6; shardIdx++ {
+		m.Shards = append(m.Shards, ShardMeta{ChunkIndex: 1, ShardIndex: shardIdx, FarmerIndex: shardIdx % 2})
 	}
+	m.Chunks = append(m.Chunks, ChunkMeta{Index: 1})
 
-	// Calculate hash again, should be deterministic
-	hash2, _ := CalculateFileHash(testFile)
-	if hash != hash2 {
-		t.Error("Hash should be deterministic")
+	set, err = m.MinimalRecoverySet()
+	if err != nil {
+		t.Fatalf("MinimalRecoverySet failed: %v", err)
+	}
+	available := make(map[int]bool)
+	for _, f := range set {
+		available[f] = true
+	}
+	if !available[0] || !available[1] {
+		t.Errorf("Recovery set %v must include farmers 0 and 1", set)
+	}
+	if bad := m.UnrecoverableChunks(available); len(bad) != 0 {
+		t.Errorf("Recovery set %v leaves chunks %v unrecoverable", set, bad)
+	}
+	if got := m.RecoveryMargin(); got != 0 {
+		t.Errorf("Expected margin 0, got %d", got)
 	}
 
-	// Verify hash length (64 hex chars for SHA256)
-	if len(hash) != 64 {
-		t.Errorf("Expected hash length 64, got %d", len(hash))
+	// Chunk 2 only has 3 shards anywhere
+	for shardIdx := 0; shardIdx < 3; shardIdx++ {
+		m.Shards = append(m.Shards, ShardMeta{ChunkIndex: 2, ShardIndex: shardIdx, FarmerIndex: shardIdx})
+	}
+	m.Chunks = append(m.Chunks, ChunkMeta{Index: 2})
+	if _, err := m.MinimalRecoverySet(); err == nil {
+		t.Error("Expected error for unrecoverable blob")
+	}
+	if got := m.RecoveryMargin(); got != -1 {
+		t.Errorf("Expected margin -1 for unrecoverable blob, got %d", got)
 	}
 }
 
-func TestCalculateFileHash_NonExistent(t *testing.T) {
-	_, err := CalculateFileHash("nonexistent-file.bin")
-	if err == nil {
-		t.Error("Expected error for non-existent file")
+func TestChunksByFarmerSet(t *testing.T) {
+	farmers := make([]FarmerInfo, 8)
+	for i := range farmers {
+		farmers[i] = FarmerInfo{Index: i, Endpoint: fmt.Sprintf("https://f%d.io", i)}
+	}
+
+	// Chunks 0 and 2 share farmers 0-5 (in different shard orders), chunk 1 uses 2-7
+	var shards []ShardMeta
+	for shardIdx := 0; shardIdx < 6; shardIdx++ {
+		shards = append(shards,
+			ShardMeta{ChunkIndex: 0, ShardIndex: shardIdx, FarmerIndex: shardIdx},
+			ShardMeta{ChunkIndex: 1, ShardIndex: shardIdx, FarmerIndex: shardIdx + 2},
+			ShardMeta{ChunkIndex: 2, ShardIndex: shardIdx, FarmerIndex: 5 - shardIdx},
+		)
+	}
+	// Chunk 3 doubles up on farmer 7 and has a shard on an unknown farmer
+	shards = append(shards,
+		ShardMeta{ChunkIndex: 3, ShardIndex: 0, FarmerIndex: 7},
+		ShardMeta{ChunkIndex: 3, ShardIndex: 1, FarmerIndex: 7},
+		ShardMeta{ChunkIndex: 3, ShardIndex: 2, FarmerIndex: 9},
+	)
+	chunks := []ChunkMeta{{Index: 0}, {Index: 1}, {Index: 2}, {Index: 3}, {Index: 4}}
+	m := New("test.bin", 1024, "hash", chunks, shards, farmers, make([]byte, 32), "0xPub")
+
+	got := m.ChunksByFarmerSet()
+	want := map[string][]int{
+		"0,1,2,3,4,5": {0, 2},
+		"2,3,4,5,6,7": {1},
+		"7":           {3},
+		"":            {4},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d farmer sets, got %v", len(want), got)
+	}
+	for key, chunks := range want {
+		if !slices.Equal(got[key], chunks) {
+			t.Errorf("Farmer set %q: expected chunks %v, got %v", key, chunks, got[key])
+		}
 	}
 }
 
-// ============================================================================
-// INTEGRATION TEST
-// ============================================================================
+func TestValidate_MinRegions(t *testing.T) {
+	farmers := []FarmerInfo{
+		{Index: 0, Address: "0xF0", Endpoint: "https://f0.io", Region: "us-east"},
+		{Index: 1, Address: "0xF1", Endpoint: "https://f1.io", Region: "us-east"},
+		{Index: 2, Address: "0xF2", Endpoint: "https://f2.io", Region: "eu-west"},
+	}
+
+	// Chunk 0 spans us-east + eu-west, chunk 1 only us-east
+	shards := []ShardMeta{
+		{ChunkIndex: 0, ShardIndex: 0, Hash: "c0s0", Size: 256, FarmerIndex: 0},
+		{ChunkIndex: 0, ShardIndex: 1, Hash: "c0s1", Size: 256, FarmerIndex: 2},
+		{ChunkIndex: 1, ShardIndex: 0, Hash: "c1s0", Size: 256, FarmerIndex: 0},
+		{ChunkIndex: 1, ShardIndex: 1, Hash: "c1s1", Size: 256, FarmerIndex: 1},
+	}
 
-func TestManifest_CompleteWorkflow(t *testing.T) {
-	// Simulate a complete manifest creation for a 2-chunk file
-	
-	// Step 1: Define chunks
 	chunks := []ChunkMeta{
-		{Index: 0, Hash: "abc123def456", Size: 1048576},
-		{Index: 1, Hash: "789ghi012jkl", Size: 524288}, // Partial last chunk
+		{Index: 0, Hash: "hash0", Size: 1024, Regions: 2},
+		{Index: 1, Hash: "hash1", Size: 1024, Regions: 2},
 	}
 
-	// Step 2: Define shards (6 shards per chunk)
-	var shards []ShardMeta
-	for chunkIdx := 0; chunkIdx < 2; chunkIdx++ {
-		for shardIdx := 0; shardIdx < 6; shardIdx++ {
-			shards = append(shards, ShardMeta{
-				ChunkIndex:  chunkIdx,
-				ShardIndex:  shardIdx,
-				Hash:        "shard_hash_placeholder",
-				Size:        262144,
-				FarmerIndex: shardIdx, // Farmer i stores all shard_i
-			})
-		}
+	key := []byte("test-key-32-bytes-long-padding!!")
+	m := New("test.bin", 2048, "hash", chunks, shards, farmers, key, "0xPublisher")
+
+	if m.RegionSpread(0) != 2 {
+		t.Errorf("Expected chunk 0 to span 2 regions, got %d", m.RegionSpread(0))
+	}
+	if m.RegionSpread(1) != 1 {
+		t.Errorf("Expected chunk 1 to span 1 region, got %d", m.RegionSpread(1))
 	}
 
-	// Step 3: Define farmers
+	// No constraint: always valid
+	if err := m.Validate(); err != nil {
+		t.Errorf("Unconstrained manifest should validate: %v", err)
+	}
+
+	// Chunk 1's placement no longer satisfies the recorded constraint
+	m.MinRegions = 2
+	if err := m.Validate(); err == nil {
+		t.Error("Expected MinRegions violation for chunk 1")
+	}
+
+	// Fix placement: move chunk 1 shard 1 to eu-west
+	m.Shards[3].FarmerIndex = 2
+	if err := m.Validate(); err != nil {
+		t.Errorf("Expected valid manifest after fixing placement: %v", err)
+	}
+}
+
+func TestValidate_DuplicateFarmers(t *testing.T) {
 	farmers := []FarmerInfo{
-		{Index: 0, Address: "0xF0", Endpoint: "https://f0.dbxn.io:4433", Region: "us-east-1"},
-		{Index: 1, Address: "0xF1", Endpoint: "https://f1.dbxn.io:4433", Region: "us-west-1"},
-		{Index: 2, Address: "0xF2", Endpoint: "https://f2.dbxn.io:4433", Region: "eu-west-1"},
-		{Index: 3, Address: "0xF3", Endpoint: "https://f3.dbxn.io:4433", Region: "ap-south-1"},
-		{Index: 4, Address: "0xF4", Endpoint: "https://f4.dbxn.io:4433", Region: "us-east-2"},
-		{Index: 5, Address: "0xF5", Endpoint: "https://f5.dbxn.io:4433", Region: "eu-central-1"},
+		{Index: 0, Address: "0xF0", Endpoint: "https://f0.io"},
+		{Index: 1, Address: "0xF1", Endpoint: "https://f0.io"}, // same host, second index
+		{Index: 2, Address: "0xF2", Endpoint: "https://f2.io"},
 	}
+	m := New("test.bin", 1024, "hash", nil, nil, farmers, make([]byte, 32), "0xPub")
 
-	// Step 4: Create manifest
-	key := []byte("strong-encryption-key-32-bytes!!")
-	m := New(
-		"video.mp4",
-		1572864, // 1.5 MB
-		"original_file_hash_abc123",
-		chunks,
-		shards,
-		farmers,
-		key,
-		"0xPublisher123",
-	)
+	err := m.Validate()
+	if err == nil || !strings.Contains(err.Error(), "https://f0.io") {
+		t.Errorf("Expected duplicate endpoint error, got %v", err)
+	}
 
-	// Step 5: Save to file
-	testFile := "test-complete-manifest.json"
-	defer os.Remove(testFile)
+	m.Farmers[1].Endpoint = "https://f1.io"
+	if err := m.Validate(); err != nil {
+		t.Errorf("Expected distinct farmers to validate: %v", err)
+	}
 
-	if err := m.Save(testFile); err != nil {
-		t.Fatalf("Failed to save manifest: %v", err)
+	m.Farmers[2].Address = "0xF0"
+	if err := m.Validate(); err == nil {
+		t.Error("Expected duplicate address error")
 	}
 
-	// Step 6: Load from file
-	loaded, err := Load(testFile)
-	if err != nil {
-		t.Fatalf("Failed to load manifest: %v", err)
+	// Unset addresses aren't duplicates of each other
+	m.Farmers[0].Address, m.Farmers[2].Address = "", ""
+	if err := m.Validate(); err != nil {
+		t.Errorf("Expected empty addresses to be ignored: %v", err)
 	}
+}
 
-	// Step 7: Verify queries work correctly
-	
-	// Query chunk 0 shards
-	chunk0Shards := loaded.GetShardsForChunk(0)
-	if len(chunk0Shards) != 6 {
-		t.Errorf("Expected 6 shards for chunk 0, got %d", len(chunk0Shards))
+func TestValidateShardSizes(t *testing.T) {
+	farmers := []FarmerInfo{{Index: 0, Endpoint: "https://f0.io"}}
+	// 1000-byte chunk + 40 bytes of encryption overhead over 4 data shards: 260 each
+	chunks := []ChunkMeta{{Index: 0, Hash: "hash0", Size: 1000}}
+	var shards []ShardMeta
+	for i := 0; i < 6; i++ {
+		shards = append(shards, ShardMeta{ChunkIndex: 0, ShardIndex: i, Hash: "h", Size: 260})
 	}
+	m := New("test.bin", 1000, "hash", chunks, shards, farmers, make([]byte, 32), "0xPub")
 
-	// Query farmers for chunk 1
-	chunk1Farmers := loaded.GetFarmersForChunk(1)
-	if len(chunk1Farmers) != 6 {
-		t.Errorf("Expected 6 farmers for chunk 1, got %d", len(chunk1Farmers))
+	if err := m.ValidateShardSizes(); err != nil {
+		t.Errorf("Expected consistent shard sizes to validate: %v", err)
 	}
 
-	// Verify farmer lookup for specific shard
-	shard := chunk0Shards[0]
-	farmer := loaded.GetFarmerForShard(shard)
-	if farmer == nil {
-		t.Fatal("Expected farmer, got nil")
+	// A recorded EncryptedSize takes precedence over Size + overhead
+	m.Chunks[0].EncryptedSize = 1040
+	if err := m.ValidateShardSizes(); err != nil {
+		t.Errorf("Expected recorded encrypted size to validate: %v", err)
 	}
-	if farmer.Index != shard.FarmerIndex {
-		t.Errorf("Farmer index mismatch: expected %d, got %d", shard.FarmerIndex, farmer.Index)
+
+	m.Shards[4].Size = 259
+	err := m.ValidateShardSizes()
+	if err == nil || !strings.Contains(err.Error(), "chunk 0 shard 4") {
+		t.Errorf("Expected chunk 0 shard 4 to be reported, got %v", err)
 	}
+	m.Shards[4].Size = 260
 
-	// Verify encryption key
-	retrievedKey, err := loaded.GetEncryptionKey()
-	if err != nil {
-		t.Fatalf("Failed to retrieve key: %v", err)
+	// Padded chunks are sized from PaddedSize, wrapped shards carry the wrap overhead
+	m.PaddedSize = 4000
+	if err := m.ValidateShardSizes(); err == nil {
+		t.Error("Expected unpadded shard sizes to be rejected for a padded manifest")
 	}
-	if !bytes.Equal(retrievedKey, key) {
-		t.Error("Encryption key mismatch")
+	m.ShardWrap = "xchacha20-poly1305"
+	for i := range m.Shards {
+		m.Shards[i].Size = 1000 + 40
 	}
+	if err := m.ValidateShardSizes(); err != nil {
+		t.Errorf("Expected padded, wrapped shard sizes to validate: %v", err)
+	}
+}
 
-	t.Log("✅ Complete workflow test passed")
+func TestRecompute(t *testing.T) {
+	farmers := []FarmerInfo{
+		{Index: 0, Endpoint: "https://f0.io"},
+		{Index: 1, Endpoint: "https://f1.io"},
+	}
+	chunks := []ChunkMeta{{Index: 0, Hash: "hash0", Size: 1024}}
+	shards := []ShardMeta{
+		{ChunkIndex: 0, ShardIndex: 0, Hash: "c0s0", Size: 256, FarmerIndex: 0},
+		{ChunkIndex: 0, ShardIndex: 1, Hash: "c0s1", Size: 256, FarmerIndex: 1},
+	}
+	m := New("test.bin", 1024, "hash", chunks, shards, farmers, make([]byte, 32), "0xPub")
+
+	// Append a chunk without touching the scalar fields
+	m.Chunks = append(m.Chunks, ChunkMeta{Index: 1, Hash: "hash1", Size: 300})
+	m.Shards = append(m.Shards, ShardMeta{ChunkIndex: 1, ShardIndex: 0, Hash: "c1s0", Size: 80, FarmerIndex: 1})
+	m.TotalShards = 0
+
+	if err := m.Recompute(); err != nil {
+		t.Fatalf("Recompute failed: %v", err)
+	}
+	if m.ChunkCount != 2 || m.FileSize != 1324 || m.TotalShards != m.DataShards+m.ParityShards {
+		t.Errorf("Expected 2 chunks, 1324 bytes, %d total shards; got %d, %d, %d",
+			m.DataShards+m.ParityShards, m.ChunkCount, m.FileSize, m.TotalShards)
+	}
+	if err := m.Validate(); err != nil {
+		t.Errorf("Recomputed manifest should validate: %v", err)
+	}
+
+	cases := []struct {
+		name   string
+		mutate func(m *Manifest)
+	}{
+		{"unknown farmer", func(m *Manifest) { m.Shards[0].FarmerIndex = 5 }},
+		{"unlisted chunk", func(m *Manifest) { m.Shards[0].ChunkIndex = 9 }},
+		{"shard index out of range", func(m *Manifest) { m.Shards[0].ShardIndex = m.TotalShards }},
+		{"duplicate shard", func(m *Manifest) { m.Shards[1].ShardIndex = 0 }},
+		{"duplicate chunk", func(m *Manifest) { m.Chunks[1].Index = 0 }},
+		{"farmer index drift", func(m *Manifest) { m.Farmers[1].Index = 3 }},
+	}
+	for _, c := range cases {
+		broken := *m
+		broken.Chunks = append([]ChunkMeta(nil), m.Chunks...)
+		broken.Shards = append([]ShardMeta(nil), m.Shards...)
+		broken.Farmers = append([]FarmerInfo(nil), m.Farmers...)
+		c.mutate(&broken)
+		if err := broken.Recompute(); err == nil {
+			t.Errorf("%s: expected error", c.name)
+		}
+	}
+}
+
+// ============================================================================
+// FARMER REMAP TESTS
+// ============================================================================
+
+func TestRemapFarmerEndpoints(t *testing.T) {
+	farmers := []FarmerInfo{
+		{Index: 0, Address: "0xA", Endpoint: "http://old-a:8080", Region: "us-east-1"},
+		{Index: 1, Address: "0xB", Endpoint: "http://old-b:8080", Region: "eu-west-1"},
+		{Index: 2, Address: "0xC", Endpoint: "http://c:8080", Region: "ap-south-1"},
+	}
+	shards := []ShardMeta{{ChunkIndex: 0, ShardIndex: 0, FarmerIndex: 1}}
+	m := New("f.bin", 1, "h", nil, shards, farmers, make([]byte, 32), "0xPub")
+
+	changed := m.RemapFarmerEndpoints(map[string]string{
+		"http://old-a:8080": "https://a.dbxn.io",
+		"http://old-b:8080": "https://b.dbxn.io",
+		"http://unknown":    "https://x.dbxn.io",
+		"http://c:8080":     "http://c:8080", // unchanged
+	})
+	if changed != 2 {
+		t.Errorf("Expected 2 farmers changed, got %d", changed)
+	}
+
+	farmer := m.GetFarmerForShard(m.Shards[0])
+	if farmer.Endpoint != "https://b.dbxn.io" || farmer.Address != "0xB" || farmer.Region != "eu-west-1" || farmer.Index != 1 {
+		t.Errorf("Unexpected farmer after remap: %+v", *farmer)
+	}
+	if m.Farmers[2].Endpoint != "http://c:8080" {
+		t.Errorf("Unmapped farmer changed: %s", m.Farmers[2].Endpoint)
+	}
+}
+
+func TestRemapFarmersByAddress(t *testing.T) {
+	farmers := []FarmerInfo{
+		{Index: 0, Address: "0xA", Endpoint: "http://a:8080"},
+		{Index: 1, Address: "", Endpoint: "http://anon:8080"},
+	}
+	m := New("f.bin", 1, "h", nil, nil, farmers, make([]byte, 32), "0xPub")
+
+	changed := m.RemapFarmersByAddress(map[string]string{
+		"0xA": "https://a.dbxn.io",
+		"":    "https://should-not-apply",
+	})
+	if changed != 1 {
+		t.Errorf("Expected 1 farmer changed, got %d", changed)
+	}
+	if m.Farmers[0].Endpoint != "https://a.dbxn.io" {
+		t.Errorf("Expected farmer 0 remapped, got %s", m.Farmers[0].Endpoint)
+	}
+	if m.Farmers[1].Endpoint != "http://anon:8080" {
+		t.Error("Farmer without an address must not be remapped")
+	}
+}
+
+// ============================================================================
+// DEDUP TESTS
+// ============================================================================
+
+func TestFindSharedChunks(t *testing.T) {
+	key := []byte("test-key-32-bytes-long-padding!!")
+
+	a := New("a.bin", 3072, "ha", []ChunkMeta{
+		{Index: 0, Hash: "common1", Size: 1024},
+		{Index: 1, Hash: "only-a", Size: 1024},
+		{Index: 2, Hash: "common2", Size: 1024},
+	}, nil, nil, key, "0xPub")
+
+	b := New("b.bin", 2560, "hb", []ChunkMeta{
+		{Index: 0, Hash: "common2", Size: 1024},
+		{Index: 1, Hash: "only-b", Size: 1024},
+		{Index: 2, Hash: "common1", Size: 512},
+	}, nil, nil, key, "0xPub")
+
+	pairs := FindSharedChunks(a, b)
+
+	if len(pairs) != 2 {
+		t.Fatalf("Expected 2 shared chunks, got %d", len(pairs))
+	}
+	if SharedBytes(pairs) != 1536 {
+		t.Errorf("Expected 1536 shared bytes, got %d", SharedBytes(pairs))
+	}
+
+	// Pairs follow b's chunk order
+	if pairs[0].AIndex != 2 || pairs[0].BIndex != 0 {
+		t.Errorf("Wrong first pair: %+v", pairs[0])
+	}
+	if pairs[1].AIndex != 0 || pairs[1].BIndex != 2 {
+		t.Errorf("Wrong second pair: %+v", pairs[1])
+	}
+
+	// Nothing shared
+	c := New("c.bin", 1024, "hc", []ChunkMeta{{Index: 0, Hash: "unique", Size: 1024}}, nil, nil, key, "0xPub")
+	if got := FindSharedChunks(a, c); len(got) != 0 || SharedBytes(got) != 0 {
+		t.Errorf("Expected no shared chunks, got %+v", got)
+	}
+
+	// Nil manifests are handled
+	if got := FindSharedChunks(nil, b); len(got) != 0 {
+		t.Error("Expected empty result for nil manifest")
+	}
+}
+
+// ============================================================================
+// SUMMARY TESTS
+// ============================================================================
+
+func TestSummary(t *testing.T) {
+	farmers := []FarmerInfo{
+		{Index: 0, Region: "us-east-1"},
+		{Index: 1, Region: "eu-west-1"},
+		{Index: 2, Region: "us-east-1"},
+		{Index: 3, Region: "ap-south-1"}, // holds nothing
+	}
+	chunks := []ChunkMeta{{Index: 0, Size: 100}, {Index: 1, Size: 50}}
+	shards := []ShardMeta{
+		{ChunkIndex: 0, ShardIndex: 0, FarmerIndex: 0},
+		{ChunkIndex: 0, ShardIndex: 1, FarmerIndex: 1},
+		{ChunkIndex: 1, ShardIndex: 0, FarmerIndex: 2},
+		{ChunkIndex: 1, ShardIndex: 1, FarmerIndex: 0},
+	}
+	m := New("report.pdf", 150, "h", chunks, shards, farmers, make([]byte, 32), "0xPub")
+
+	s := m.Summary()
+	if s.FileName != "report.pdf" || s.FileSize != 150 || s.ChunkCount != 2 || s.TotalShards != 4 {
+		t.Errorf("Unexpected basic fields: %+v", s)
+	}
+	if s.UniqueFarmers != 3 {
+		t.Errorf("Expected 3 unique farmers, got %d", s.UniqueFarmers)
+	}
+	if len(s.Regions) != 2 || s.Regions[0] != "eu-west-1" || s.Regions[1] != "us-east-1" {
+		t.Errorf("Expected sorted regions [eu-west-1 us-east-1], got %v", s.Regions)
+	}
+	if s.DataShards != 4 || s.ParityShards != 2 {
+		t.Errorf("Unexpected erasure config %d+%d", s.DataShards, s.ParityShards)
+	}
+
+	str := s.String()
+	if !strings.Contains(str, "report.pdf") || !strings.Contains(str, "2 chunks") {
+		t.Errorf("Unexpected String(): %s", str)
+	}
+	if strings.Contains(str, m.EncryptionKey) {
+		t.Error("Summary must not include the encryption key")
+	}
+}
+
+// ============================================================================
+// FETCH PLAN TESTS
+// ============================================================================
+
+func TestFetchPlanForChunk(t *testing.T) {
+	farmers := []FarmerInfo{
+		{Index: 0, Endpoint: "http://a:8080"},
+		{Index: 1, Endpoint: "http://b:8080"},
+	}
+	shards := []ShardMeta{
+		{ChunkIndex: 0, ShardIndex: 1, Hash: "s01", Size: 100, FarmerIndex: 1},
+		{ChunkIndex: 1, ShardIndex: 0, Hash: "s10", Size: 100, FarmerIndex: 0},
+		{ChunkIndex: 0, ShardIndex: 0, Hash: "s00", Size: 100, FarmerIndex: 0},
+	}
+	m := New("f.bin", 200, "h", nil, shards, farmers, make([]byte, 32), "0xPub")
+
+	plan, err := m.FetchPlanForChunk(0)
+	if err != nil {
+		t.Fatalf("FetchPlanForChunk failed: %v", err)
+	}
+	if len(plan) != 2 {
+		t.Fatalf("Expected 2 fetches, got %d", len(plan))
+	}
+
+	// Ordered by shard index, resolved to the owning farmer
+	want := ShardFetch{Endpoint: "http://a:8080", BlobID: m.BlobID, ChunkIndex: 0, ShardIndex: 0, Hash: "s00", Size: 100}
+	if plan[0] != want {
+		t.Errorf("Unexpected first fetch: %+v", plan[0])
+	}
+	if plan[1].ShardIndex != 1 || plan[1].Endpoint != "http://b:8080" {
+		t.Errorf("Unexpected second fetch: %+v", plan[1])
+	}
+
+	wantURL := "http://b:8080/shards/" + m.BlobID + "/0/1"
+	if plan[1].URL() != wantURL {
+		t.Errorf("Expected URL %s, got %s", wantURL, plan[1].URL())
+	}
+}
+
+func TestShardAddress(t *testing.T) {
+	// The format is part of the farmer storage contract; don't change it
+	addr := ShardAddress("0xabc", 12, 5)
+	if addr != "0xabc/12/5" {
+		t.Fatalf("Expected 0xabc/12/5, got %s", addr)
+	}
+	blobID, chunk, shard, err := ParseShardAddress(addr)
+	if err != nil || blobID != "0xabc" || chunk != 12 || shard != 5 {
+		t.Errorf("ParseShardAddress(%q) = %s, %d, %d, %v", addr, blobID, chunk, shard, err)
+	}
+
+	fetch := ShardFetch{Endpoint: "http://farmer:8080", BlobID: "0xabc", ChunkIndex: 12, ShardIndex: 5}
+	if fetch.URL() != "http://farmer:8080/shards/0xabc/12/5" {
+		t.Errorf("Unexpected fetch URL %s", fetch.URL())
+	}
+
+	for _, bad := range []string{"", "0xabc/12", "0xabc/12/5/1", "/12/5", "0xabc/-1/5", "0xabc/012/5", "0xabc/+1/5", "0xabc/1/x"} {
+		if _, _, _, err := ParseShardAddress(bad); err == nil {
+			t.Errorf("Expected error for %q", bad)
+		}
+	}
+}
+
+func TestLocalEndpoint(t *testing.T) {
+	endpoint := LocalEndpoint("/var/shards")
+	if endpoint != "file:///var/shards" {
+		t.Fatalf("Expected file:///var/shards, got %s", endpoint)
+	}
+	dir, ok := LocalEndpointDir(endpoint)
+	if !ok || dir != filepath.FromSlash("/var/shards") {
+		t.Errorf("LocalEndpointDir(%q) = %q, %v", endpoint, dir, ok)
+	}
+	if _, ok := LocalEndpointDir("http://farmer:8080"); ok {
+		t.Error("HTTP endpoint reported as local")
+	}
+
+	path, err := LocalShardPath(dir, "0xabc", 12, 5)
+	if err != nil || path != filepath.Join(dir, "0xabc", "12", "5") {
+		t.Errorf("LocalShardPath = %q, %v", path, err)
+	}
+	for _, bad := range []string{"", "..", "a/b", `a\b`} {
+		if _, err := LocalShardPath(dir, bad, 0, 0); err == nil {
+			t.Errorf("Expected error for blob id %q", bad)
+		}
+		if _, err := LocalPinPath(dir, bad); err == nil {
+			t.Errorf("Expected pin path error for blob id %q", bad)
+		}
+	}
+}
+
+func TestCanonicalEncoding_Vectors(t *testing.T) {
+	// Test vectors: these bytes are part of every hash and signature built on them
+	cases := []struct {
+		blobID       string
+		chunk, shard int
+		want         string
+	}{
+		{"0xab", 1, 2, "0000000000000004" + "30786162" + "0000000000000001" + "0000000000000002"},
+		{"", 0, 0, "0000000000000000" + "0000000000000000" + "0000000000000000"},
+		{"b", 258, 65536, "0000000000000001" + "62" + "0000000000000102" + "0000000000010000"},
+	}
+	for _, c := range cases {
+		got := hex.EncodeToString(CanonicalShardID(c.blobID, c.chunk, c.shard))
+		if got != c.want {
+			t.Errorf("CanonicalShardID(%q, %d, %d) = %s, want %s", c.blobID, c.chunk, c.shard, got, c.want)
+		}
+	}
+
+	if got := hex.EncodeToString(AppendCanonicalInt(nil, -1)); got != "ffffffffffffffff" {
+		t.Errorf("AppendCanonicalInt(-1) = %s", got)
+	}
+	// Length prefixes keep field boundaries apart
+	if bytes.Equal(CanonicalShardID("a1", 2, 3), CanonicalShardID("a", 12, 3)) {
+		t.Error("Distinct tuples encode identically")
+	}
+}
+
+func TestFetchPlanForChunk_Errors(t *testing.T) {
+	farmers := []FarmerInfo{{Index: 0, Endpoint: "http://a:8080"}}
+	shards := []ShardMeta{{ChunkIndex: 0, ShardIndex: 0, FarmerIndex: 5}}
+	m := New("f.bin", 100, "h", nil, shards, farmers, make([]byte, 32), "0xPub")
+
+	if _, err := m.FetchPlanForChunk(0); err == nil {
+		t.Error("Expected error for shard with unknown farmer")
+	}
+	if _, err := m.FetchPlanForChunk(3); err == nil {
+		t.Error("Expected error for chunk with no shards")
+	}
+}
+
+// ============================================================================
+// ENCRYPTION KEY TESTS
+// ============================================================================
+
+func TestGetEncryptionKey(t *testing.T) {
+	key := []byte("test-key-32-bytes-long-padding!!")
+	chunks := []ChunkMeta{{Index: 0, Hash: "hash0", Size: 1024}}
+	shards := []ShardMeta{}
+	farmers := []FarmerInfo{}
+
+	m := New("test.bin", 1024, "hash", chunks, shards, farmers, key, "0xPub")
+
+	retrievedKey, err := m.GetEncryptionKey()
+	if err != nil {
+		t.Fatalf("GetEncryptionKey failed: %v", err)
+	}
+
+	if len(retrievedKey) != 32 {
+		t.Errorf("Expected key size 32, got %d", len(retrievedKey))
+	}
+
+	// Compare with original
+	if !bytes.Equal(key, retrievedKey) {
+		t.Error("Retrieved key doesn't match original")
+	}
+}
+
+func TestGetPublisherPublicKey(t *testing.T) {
+	m := New("test.bin", 1024, "hash", nil, nil, nil, make([]byte, 32), "0xPub")
+	if _, err := m.GetPublisherPublicKey(); err == nil {
+		t.Error("Expected error when no public key is recorded")
+	}
+
+	pub := bytes.Repeat([]byte{0xAB}, 32)
+	m.PublisherPublicKey = hex.EncodeToString(pub)
+	got, err := m.GetPublisherPublicKey()
+	if err != nil {
+		t.Fatalf("GetPublisherPublicKey failed: %v", err)
+	}
+	if !bytes.Equal(got, pub) {
+		t.Error("Public key doesn't match")
+	}
+
+	m.PublisherPublicKey = hex.EncodeToString(pub[:31])
+	if _, err := m.GetPublisherPublicKey(); err == nil {
+		t.Error("Expected error for wrong-size public key")
+	}
+}
+
+func TestRecoveryKit(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	chunks := []ChunkMeta{{Index: 0, Hash: "hash0", Size: 1024}, {Index: 1, Hash: "hash1", Size: 10}}
+	m := New("test.bin", 1034, "hash", chunks, nil, nil, key, "0xPub")
+
+	kit, err := RecoveryKit(m)
+	if err != nil {
+		t.Fatalf("RecoveryKit failed: %v", err)
+	}
+	if !strings.HasPrefix(kit, RecoveryKitPrefix+"-") {
+		t.Errorf("Expected kit to start with %s-, got %s", RecoveryKitPrefix, kit)
+	}
+
+	// Case, spacing and line breaks don't matter
+	info, err := ParseRecoveryKit(" " + strings.ToLower(strings.ReplaceAll(kit, "-", "\n")))
+	if err != nil {
+		t.Fatalf("ParseRecoveryKit failed: %v", err)
+	}
+	if info.BlobID != m.BlobID || !bytes.Equal(info.Key, key) {
+		t.Error("Parsed kit doesn't match the manifest")
+	}
+	if !info.Matches(m) {
+		t.Error("Expected kit to match its manifest")
+	}
+	other := *m
+	other.Chunks = []ChunkMeta{{Index: 0, Hash: "other", Size: 1024}}
+	if info.Matches(&other) {
+		t.Error("Expected kit not to match a manifest with other chunks")
+	}
+
+	// Every single-character typo is caught
+	body := kit[len(RecoveryKitPrefix):]
+	for i, c := range body {
+		if c == '-' {
+			continue
+		}
+		typo := []byte(body)
+		typo[i] = 'A'
+		if c == 'A' {
+			typo[i] = 'B'
+		}
+		if _, err := ParseRecoveryKit(RecoveryKitPrefix + string(typo)); err == nil {
+			t.Errorf("Typo at position %d not detected", i)
+		}
+	}
+
+	// Deterministic blob IDs are carried verbatim
+	m.BlobID = "my-backup"
+	kit, err = RecoveryKit(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := ParseRecoveryKit(kit); err != nil || info.BlobID != "my-backup" {
+		t.Errorf("Expected blob ID my-backup, got %v, %v", info, err)
+	}
+	if _, err := ParseRecoveryKit("NOTAKIT-AAAA"); err == nil {
+		t.Error("Expected error for wrong prefix")
+	}
+}
+
+func TestAnchor(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	chunks := []ChunkMeta{{Index: 0, Hash: "hash0", Size: 1034}}
+	shards := []ShardMeta{{ChunkIndex: 0, ShardIndex: 0, Hash: "s0", Size: 256, FarmerIndex: 0}}
+	farmers := []FarmerInfo{{Index: 0, Endpoint: "http://a"}}
+	m := New("test.bin", 1034, "hash", chunks, shards, farmers, key, "0xPub")
+	digest := AnchorBytes(m)
+	if len(digest) != sha256.Size {
+		t.Fatalf("Expected a %d-byte digest, got %d", sha256.Size, len(digest))
+	}
+
+	// The digest survives a save/load round trip
+	path := "test-anchor.json"
+	defer os.Remove(path)
+	m.Anchor = &Anchor{Scheme: "rfc3161", Digest: hex.EncodeToString(digest), Token: append([]byte("tst:"), digest...)}
+	if err := m.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(AnchorBytes(loaded), digest) {
+		t.Error("Expected the same anchor digest after reloading the manifest")
+	}
+	if err := VerifyAnchor(loaded, loaded.Anchor.Token); err != nil {
+		t.Errorf("VerifyAnchor failed: %v", err)
+	}
+
+	// Storage layout and the key may change without breaking the anchor
+	moved := *loaded
+	moved.Farmers = []FarmerInfo{{Index: 0, Endpoint: "http://b"}}
+	moved.Shards = nil
+	moved.EncryptionKey = ""
+	moved.DataShards, moved.ParityShards = 6, 3
+	if err := VerifyAnchor(&moved, loaded.Anchor.Token); err != nil {
+		t.Errorf("Expected anchor to survive re-placement, got %v", err)
+	}
+
+	// Content changes don't
+	edited := *loaded
+	edited.Chunks = []ChunkMeta{{Index: 0, Hash: "other", Size: 1034}}
+	if err := VerifyAnchor(&edited, loaded.Anchor.Token); err == nil || !strings.Contains(err.Error(), "changed since") {
+		t.Errorf("Expected error for edited manifest, got %v", err)
+	}
+	edited.Anchor = nil
+	if err := VerifyAnchor(&edited, loaded.Anchor.Token); err == nil {
+		t.Error("Expected token not to cover an edited manifest")
+	}
+	if err := VerifyAnchor(loaded, []byte("unrelated token")); err == nil {
+		t.Error("Expected error for a token without the digest")
+	}
+}
+
+func TestChunkEncryptedSize(t *testing.T) {
+	chunks := []ChunkMeta{{Index: 0, Hash: "hash0", Size: 1034}}
+	shards := []ShardMeta{{ChunkIndex: 0, ShardIndex: 0, Hash: "s0", Size: 269}, {ChunkIndex: 0, ShardIndex: 1, Hash: "s1", Size: 269}}
+	m := New("test.bin", 1034, "hash", chunks, shards, nil, bytes.Repeat([]byte{1}, 32), "0xPub")
+
+	// Recorded, implied from the plaintext size, or from the file size
+	if size, err := m.ChunkEncryptedSize(ChunkMeta{Index: 0, Size: 1034, EncryptedSize: 2000}); err != nil || size != 2000 {
+		t.Errorf("Expected recorded size 2000, got %d (%v)", size, err)
+	}
+	if size, err := m.ChunkEncryptedSize(m.Chunks[0]); err != nil || size != 1034+crypto.Overhead {
+		t.Errorf("Expected %d, got %d (%v)", 1034+crypto.Overhead, size, err)
+	}
+	if size, err := m.ChunkEncryptedSize(ChunkMeta{Index: 0}); err != nil || size != 1034+crypto.Overhead {
+		t.Errorf("Expected size from FileSize, got %d (%v)", size, err)
+	}
+
+	// Without any plaintext size, shards over 4 data shards are ambiguous
+	m.FileSize = 0
+	if _, err := m.ChunkEncryptedSize(ChunkMeta{Index: 0}); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("Expected ambiguity error, got %v", err)
+	}
+	// ... but not over a single data shard
+	m.DataShards = 1
+	if size, err := m.ChunkEncryptedSize(ChunkMeta{Index: 0}); err != nil || size != 269 {
+		t.Errorf("Expected 269 from a single data shard, got %d (%v)", size, err)
+	}
+
+	m.Shards[1].Size = 270
+	if _, err := m.ChunkEncryptedSize(ChunkMeta{Index: 0}); err == nil {
+		t.Error("Expected error for shards of different sizes")
+	}
+}
+
+func TestEncryptFields(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	chunks := []ChunkMeta{{Index: 0, Hash: "hash0", Size: 1034}}
+	shards := []ShardMeta{{ChunkIndex: 0, ShardIndex: 0, Hash: "s0", Size: 256, FarmerIndex: 0}}
+	farmers := []FarmerInfo{{Index: 0, Endpoint: "http://a"}}
+	m := New("secret-plans.pdf", 1034, "filehash", chunks, shards, farmers, key, "0xPub")
+	plainAnchor := AnchorBytes(m)
+
+	if err := m.EncryptFields(key, FieldFileName, FieldOriginalFileHash); err != nil {
+		t.Fatalf("EncryptFields failed: %v", err)
+	}
+	if strings.Contains(m.FileName, "secret") || m.OriginalFileHash == "filehash" {
+		t.Error("Expected fields to hold ciphertext")
+	}
+	if bytes.Equal(AnchorBytes(m), plainAnchor) {
+		t.Error("Expected the anchor digest to cover the ciphertext form")
+	}
+	// Validate and Save need no key
+	if err := m.Validate(); err != nil {
+		t.Errorf("Validate failed on encrypted manifest: %v", err)
+	}
+	path := "test-encrypt-fields.json"
+	defer os.Remove(path)
+	if err := m.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, err := loaded.DecryptedFileName(key); err != nil || name != "secret-plans.pdf" {
+		t.Errorf("Expected decrypted name secret-plans.pdf, got %q (%v)", name, err)
+	}
+	if hash, err := loaded.DecryptedOriginalFileHash(key); err != nil || hash != "filehash" {
+		t.Errorf("Expected decrypted hash filehash, got %q (%v)", hash, err)
+	}
+	if _, err := loaded.DecryptedFileName(bytes.Repeat([]byte{0x43}, 32)); err == nil {
+		t.Error("Expected error decrypting with the wrong key")
+	}
+
+	// A ciphertext moved into another field doesn't open
+	swapped := *loaded
+	swapped.FileName, swapped.OriginalFileHash = loaded.OriginalFileHash, loaded.FileName
+	if _, err := swapped.DecryptedFileName(key); err == nil {
+		t.Error("Expected error for a ciphertext moved between fields")
+	}
+
+	// Unencrypted fields come back as they are
+	if name, err := New("plain.txt", 10, "h", nil, nil, nil, key, "0xPub").DecryptedFileName(nil); err != nil || name != "plain.txt" {
+		t.Errorf("Expected plain.txt, got %q (%v)", name, err)
+	}
+	if err := m.EncryptFields(key, "blob_id"); err == nil {
+		t.Error("Expected error for a field that can't be encrypted")
+	}
+	bad := *loaded
+	bad.EncryptedFields = []string{FieldFileName, FieldFileName}
+	if err := bad.Validate(); err == nil {
+		t.Error("Expected Validate to reject a field listed twice")
+	}
+}
+
+func TestSegment(t *testing.T) {
+	var chunks []ChunkMeta
+	var shards []ShardMeta
+	var farmers []FarmerInfo
+	for f := 0; f < 6; f++ {
+		farmers = append(farmers, FarmerInfo{Index: f, Endpoint: fmt.Sprintf("http://farmer%d", f)})
+	}
+	for c := 0; c < 5; c++ {
+		size := 1024 * 1024
+		if c == 4 {
+			size = 100
+		}
+		chunks = append(chunks, ChunkMeta{Index: c, Hash: fmt.Sprintf("hash%d", c), Size: size})
+		for s := 0; s < 6; s++ {
+			shards = append(shards, ShardMeta{ChunkIndex: c, ShardIndex: s, FarmerIndex: (c + s) % 6, Hash: "h"})
+		}
+	}
+	m := New("big.bin", 4*1024*1024+100, "filehash", chunks, shards, farmers, bytes.Repeat([]byte{1}, 32), "0xPub")
+
+	root, segments, err := Segment(m, 2)
+	if err != nil {
+		t.Fatalf("Segment failed: %v", err)
+	}
+	if len(segments) != 3 || len(root.Segments) != 3 {
+		t.Fatalf("Expected 3 segments, got %d", len(segments))
+	}
+	if len(root.Chunks) != 0 || len(root.Shards) != 0 || root.ChunkCount != 5 || root.FileSize != m.FileSize {
+		t.Error("Expected root to keep blob-wide fields only")
+	}
+	if len(m.Chunks) != 5 || len(m.Shards) != 30 || m.IsSegmented() {
+		t.Error("Segment modified its input")
+	}
+	if err := root.ValidateSegments(); err != nil {
+		t.Errorf("ValidateSegments failed: %v", err)
+	}
+
+	for i, seg := range segments {
+		if err := seg.Validate(); err != nil {
+			t.Errorf("Segment %d doesn't validate: %v", i, err)
+		}
+		if len(seg.Shards) != 6*len(seg.Chunks) {
+			t.Errorf("Segment %d has %d shards for %d chunks", i, len(seg.Shards), len(seg.Chunks))
+		}
+
+		// Hashes survive a save/load round trip
+		path := fmt.Sprintf("test-segment-%d.json", i)
+		defer os.Remove(path)
+		if err := seg.Save(path); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := Load(path)
+		if err != nil {
+			t.Fatalf("Load of segment %d failed: %v", i, err)
+		}
+		if err := root.VerifySegment(root.Segments[i], loaded); err != nil {
+			t.Errorf("VerifySegment %d failed: %v", i, err)
+		}
+	}
+	if segments[2].FileSize != 100 || segments[2].ChunkCount != 1 {
+		t.Errorf("Expected last segment to cover one chunk, got %d chunks / %d bytes", segments[2].ChunkCount, segments[2].FileSize)
+	}
+
+	if ref, err := root.SegmentFor(3); err != nil || ref.Index != 1 {
+		t.Errorf("Expected chunk 3 in segment 1, got %v, %v", ref, err)
+	}
+	if _, err := root.SegmentFor(5); err == nil {
+		t.Error("Expected error for chunk past the last segment")
+	}
+
+	// A segment presented under another segment's reference is refused
+	if err := root.VerifySegment(root.Segments[0], segments[1]); err == nil {
+		t.Error("Expected error verifying segment 1 as segment 0")
+	}
+	edited := *segments[0]
+	edited.Shards = edited.Shards[1:]
+	if err := root.VerifySegment(root.Segments[0], &edited); err == nil || !strings.Contains(err.Error(), "hash mismatch") {
+		t.Errorf("Expected hash mismatch for edited segment, got %v", err)
+	}
+
+	gap := *root
+	gap.Segments = append([]SegmentRef(nil), root.Segments[0], root.Segments[2])
+	if err := gap.ValidateSegments(); err == nil {
+		t.Error("Expected error for segments leaving a gap")
+	}
+	if _, _, err := Segment(root, 2); err == nil {
+		t.Error("Expected error segmenting a segmented root")
+	}
+}
+
+func TestSealOpenSealed(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	m := New("test.bin", 1024, "hash", []ChunkMeta{{Index: 0, Hash: "hash0", Size: 1024}}, nil, nil, key, "0xPub")
+
+	sealed, err := m.Seal(key)
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	if bytes.Contains(sealed, []byte(m.EncryptionKey)) {
+		t.Error("Sealed manifest exposes the encryption key")
+	}
+
+	opened, err := OpenSealed(sealed, key, m.BlobID)
+	if err != nil {
+		t.Fatalf("OpenSealed failed: %v", err)
+	}
+	if opened.BlobID != m.BlobID || opened.EncryptionKey != m.EncryptionKey || len(opened.Chunks) != 1 {
+		t.Error("Opened manifest doesn't match original")
+	}
+
+	if _, err := OpenSealed(sealed, bytes.Repeat([]byte{0x43}, 32), m.BlobID); err == nil {
+		t.Error("Expected error opening with the wrong key")
+	}
+	if _, err := OpenSealed(sealed, key, "0xother"); err == nil {
+		t.Error("Expected error for a manifest of another blob")
+	}
+}
+
+func TestCheckKey(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	m := New("test.bin", 1024, "hash", nil, nil, nil, key, "0xPub")
+
+	if strings.Contains(m.KeyCommitment, m.EncryptionKey) || m.KeyCommitment == "" {
+		t.Errorf("Unexpected key commitment %q", m.KeyCommitment)
+	}
+	if err := m.CheckKey(key); err != nil {
+		t.Errorf("CheckKey rejected the right key: %v", err)
+	}
+	if err := m.CheckKey(bytes.Repeat([]byte{0x43}, 32)); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("Expected ErrWrongKey, got %v", err)
+	}
+
+	// Manifests from before commitments accept any key
+	m.KeyCommitment = ""
+	if err := m.CheckKey(bytes.Repeat([]byte{0x43}, 32)); err != nil {
+		t.Errorf("Expected no check without a commitment, got %v", err)
+	}
+}
+
+func TestGetEncryptionKey_InvalidHex(t *testing.T) {
+	m := &Manifest{
+		EncryptionKey: "invalid-hex-string", // Not valid hex
+	}
+
+	_, err := m.GetEncryptionKey()
+	if err == nil {
+		t.Error("Expected error for invalid hex string")
+	}
+}
+
+// ============================================================================
+// FILE HASH TESTS
+// ============================================================================
+
+func TestCalculateFileHash(t *testing.T) {
+	testFile := "test-hash.bin"
+	testData := []byte("test data for hashing")
+	if err := os.WriteFile(testFile, testData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+
+	hash, err := CalculateFileHash(testFile)
+	if err != nil {
+		t.Fatalf("CalculateFileHash failed: %v", err)
+	}
+
+	if hash == "" {
+		t.Error("Hash is empty")
+	}
+
+	// Calculate hash again, should be deterministic
+	hash2, _ := CalculateFileHash(testFile)
+	if hash != hash2 {
+		t.Error("Hash should be deterministic")
+	}
+
+	// Verify hash length (64 hex chars for SHA256)
+	if len(hash) != 64 {
+		t.Errorf("Expected hash length 64, got %d", len(hash))
+	}
+}
+
+func TestCalculateFileHash_NonExistent(t *testing.T) {
+	_, err := CalculateFileHash("nonexistent-file.bin")
+	if err == nil {
+		t.Error("Expected error for non-existent file")
+	}
+}
+
+func TestVerifyLocalFile(t *testing.T) {
+	testFile := "test-verify-local.bin"
+	data := make([]byte, 2500)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	if err := os.WriteFile(testFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+
+	// 1000-byte chunks: two full, one partial
+	var chunks []ChunkMeta
+	for i := 0; i*1000 < len(data); i++ {
+		part := data[i*1000 : min((i+1)*1000, len(data))]
+		sum := sha256.Sum256(part)
+		chunks = append(chunks, ChunkMeta{Index: i, Hash: hex.EncodeToString(sum[:]), Size: len(part)})
+	}
+	m := New("local.bin", int64(len(data)), "", chunks, nil, nil, make([]byte, 32), "0xPub")
+	m.ChunkSize = 1000
+
+	if err := VerifyLocalFile(m, testFile); err != nil {
+		t.Fatalf("VerifyLocalFile failed: %v", err)
+	}
+
+	// A changed byte is reported at its chunk
+	data[1500] ^= 0xFF
+	os.WriteFile(testFile, data, 0644)
+	if err := VerifyLocalFile(m, testFile); err == nil || !strings.Contains(err.Error(), "chunk 1") {
+		t.Errorf("Expected chunk 1 mismatch, got %v", err)
+	}
+	data[1500] ^= 0xFF
+
+	// Extra or missing chunks
+	os.WriteFile(testFile, append(data, make([]byte, 1000)...), 0644)
+	if err := VerifyLocalFile(m, testFile); err == nil {
+		t.Error("Expected error for longer file")
+	}
+	os.WriteFile(testFile, data[:2000], 0644)
+	if err := VerifyLocalFile(m, testFile); err == nil {
+		t.Error("Expected error for shorter file")
+	}
+
+	// Chunking must follow the manifest's chunk size
+	os.WriteFile(testFile, data, 0644)
+	m.ChunkSize = 500
+	if err := VerifyLocalFile(m, testFile); err == nil {
+		t.Error("Expected error when chunked at a different size")
+	}
+
+	m.ChunkSize = 1000
+	m.ChunkHashDomain = ChunkHashCiphertext
+	if err := VerifyLocalFile(m, testFile); err == nil {
+		t.Error("Expected error for ciphertext chunk hashes")
+	}
+}
+
+func TestVerifyLocalFileWithOptions_SkipsVerifiedChunks(t *testing.T) {
+	testFile := "test-verify-incremental.bin"
+	statePath := "test-verify-incremental.state"
+	defer os.Remove(testFile)
+	defer os.Remove(statePath)
+	data := make([]byte, 2500)
+	for i := range data {
+		data[i] = byte(i * 11)
+	}
+	if err := os.WriteFile(testFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var chunks []ChunkMeta
+	for i := 0; i*1000 < len(data); i++ {
+		part := data[i*1000 : min((i+1)*1000, len(data))]
+		sum := sha256.Sum256(part)
+		chunks = append(chunks, ChunkMeta{Index: i, Hash: hex.EncodeToString(sum[:]), Size: len(part)})
+	}
+	m := New("local.bin", int64(len(data)), "", chunks, nil, nil, make([]byte, 32), "0xPub")
+	m.ChunkSize = 1000
+	opts := VerifyOptions{StatePath: statePath}
+
+	if err := VerifyLocalFileWithOptions(m, testFile, opts); err != nil {
+		t.Fatalf("First verify failed: %v", err)
+	}
+	if _, err := os.Stat(statePath); err != nil {
+		t.Fatalf("Expected verify state to be saved: %v", err)
+	}
+
+	// Corrupt a byte but keep size and mtime: the verified chunk isn't read again
+	info, _ := os.Stat(testFile)
+	corrupted := append([]byte(nil), data...)
+	corrupted[1500] ^= 0xFF
+	os.WriteFile(testFile, corrupted, 0644)
+	os.Chtimes(testFile, info.ModTime(), info.ModTime())
+	if err := VerifyLocalFileWithOptions(m, testFile, opts); err != nil {
+		t.Errorf("Expected unchanged-looking file to be skipped, got %v", err)
+	}
+
+	// Expired verifications are redone and catch it
+	if err := VerifyLocalFileWithOptions(m, testFile, VerifyOptions{StatePath: statePath, MaxAge: time.Nanosecond}); err == nil || !strings.Contains(err.Error(), "chunk 1") {
+		t.Errorf("Expected chunk 1 mismatch once verification expired, got %v", err)
+	}
+	if err := VerifyLocalFileWithOptions(m, testFile, opts); err == nil {
+		t.Error("Expected failed chunk to stay unverified")
+	}
+
+	// A new mtime invalidates the whole state
+	os.WriteFile(testFile, data, 0644)
+	if err := VerifyLocalFileWithOptions(m, testFile, opts); err != nil {
+		t.Fatalf("Verify of restored file failed: %v", err)
+	}
+	os.WriteFile(testFile, corrupted, 0644)
+	later := info.ModTime().Add(time.Hour)
+	os.Chtimes(testFile, later, later)
+	if err := VerifyLocalFileWithOptions(m, testFile, opts); err == nil || !strings.Contains(err.Error(), "chunk 1") {
+		t.Errorf("Expected chunk 1 mismatch after mtime change, got %v", err)
+	}
+
+	// State from another manifest is ignored
+	os.WriteFile(testFile, data, 0644)
+	VerifyLocalFileWithOptions(m, testFile, opts)
+	other := *m
+	other.Chunks = append([]ChunkMeta(nil), m.Chunks...)
+	other.Chunks[2].Hash = strings.Repeat("0", 64)
+	if err := VerifyLocalFileWithOptions(&other, testFile, opts); err == nil {
+		t.Error("Expected state recorded for another chunk list to be discarded")
+	}
+}
+
+func TestVerifyStatePath(t *testing.T) {
+	tempDir := t.TempDir()
+	path, err := VerifyStatePath(tempDir, "blob-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(path) != tempDir {
+		t.Errorf("Expected verify state in %s, got %s", tempDir, path)
+	}
+	if _, err := VerifyStatePath(tempDir, "../blob-1"); err == nil {
+		t.Error("Expected a blob ID outside the temp dir to be rejected")
+	}
+}
+
+// ============================================================================
+// INTEGRATION TEST
+// ============================================================================
+
+func TestManifest_CompleteWorkflow(t *testing.T) {
+	// Simulate a complete manifest creation for a 2-chunk file
+	
+	// Step 1: Define chunks
+	chunks := []ChunkMeta{
+		{Index: 0, Hash: "abc123def456", Size: 1048576},
+		{Index: 1, Hash: "789ghi012jkl", Size: 524288}, // Partial last chunk
+	}
+
+	// Step 2: Define shards (6 shards per chunk)
+	var shards []ShardMeta
+	for chunkIdx := 0; chunkIdx < 2; chunkIdx++ {
+		for shardIdx := 0; shardIdx < 6; shardIdx++ {
+			shards = append(shards, ShardMeta{
+				ChunkIndex:  chunkIdx,
+				ShardIndex:  shardIdx,
+				Hash:        "shard_hash_placeholder",
+				Size:        262144,
+				FarmerIndex: shardIdx, // Farmer i stores all shard_i
+			})
+		}
+	}
+
+	// Step 3: Define farmers
+	farmers := []FarmerInfo{
+		{Index: 0, Address: "0xF0", Endpoint: "https://f0.dbxn.io:4433", Region: "us-east-1"},
+		{Index: 1, Address: "0xF1", Endpoint: "https://f1.dbxn.io:4433", Region: "us-west-1"},
+		{Index: 2, Address: "0xF2", Endpoint: "https://f2.dbxn.io:4433", Region: "eu-west-1"},
+		{Index: 3, Address: "0xF3", Endpoint: "https://f3.dbxn.io:4433", Region: "ap-south-1"},
+		{Index: 4, Address: "0xF4", Endpoint: "https://f4.dbxn.io:4433", Region: "us-east-2"},
+		{Index: 5, Address: "0xF5", Endpoint: "https://f5.dbxn.io:4433", Region: "eu-central-1"},
+	}
+
+	// Step 4: Create manifest
+	key := []byte("strong-encryption-key-32-bytes!!")
+	m := New(
+		"video.mp4",
+		1572864, // 1.5 MB
+		"original_file_hash_abc123",
+		chunks,
+		shards,
+		farmers,
+		key,
+		"0xPublisher123",
+	)
+
+	// Step 5: Save to file
+	testFile := "test-complete-manifest.json"
+	defer os.Remove(testFile)
+
+	if err := m.Save(testFile); err != nil {
+		t.Fatalf("Failed to save manifest: %v", err)
+	}
+
+	// Step 6: Load from file
+	loaded, err := Load(testFile)
+	if err != nil {
+		t.Fatalf("Failed to load manifest: %v", err)
+	}
+
+	// Step 7: Verify queries work correctly
+	
+	// Query chunk 0 shards
+	chunk0Shards := loaded.GetShardsForChunk(0)
+	if len(chunk0Shards) != 6 {
+		t.Errorf("Expected 6 shards for chunk 0, got %d", len(chunk0Shards))
+	}
+
+	// Query farmers for chunk 1
+	chunk1Farmers := loaded.GetFarmersForChunk(1)
+	if len(chunk1Farmers) != 6 {
+		t.Errorf("Expected 6 farmers for chunk 1, got %d", len(chunk1Farmers))
+	}
+
+	// Verify farmer lookup for specific shard
+	shard := chunk0Shards[0]
+	farmer := loaded.GetFarmerForShard(shard)
+	if farmer == nil {
+		t.Fatal("Expected farmer, got nil")
+	}
+	if farmer.Index != shard.FarmerIndex {
+		t.Errorf("Farmer index mismatch: expected %d, got %d", shard.FarmerIndex, farmer.Index)
+	}
+
+	// Verify encryption key
+	retrievedKey, err := loaded.GetEncryptionKey()
+	if err != nil {
+		t.Fatalf("Failed to retrieve key: %v", err)
+	}
+	if !bytes.Equal(retrievedKey, key) {
+		t.Error("Encryption key mismatch")
+	}
+
+	t.Log("✅ Complete workflow test passed")
+}
+
+func TestGetFarmerForShard_IndexNotPosition(t *testing.T) {
+	// Farmers sorted by region, so positions no longer match indices
+	farmers := []FarmerInfo{
+		{Index: 2, Address: "0xF2", Endpoint: "https://f2.io", Region: "ap-south-1"},
+		{Index: 0, Address: "0xF0", Endpoint: "https://f0.io", Region: "eu-west-1"},
+		{Index: 1, Address: "0xF1", Endpoint: "https://f1.io", Region: "us-east-1"},
+	}
+	m := New("test.bin", 1024, "hash", nil, nil, farmers, make([]byte, 32), "0xPub")
+
+	for index, want := range []string{"https://f0.io", "https://f1.io", "https://f2.io"} {
+		farmer := m.GetFarmerForShard(ShardMeta{FarmerIndex: index})
+		if farmer == nil {
+			t.Fatalf("Farmer %d not found", index)
+		}
+		if farmer.Endpoint != want {
+			t.Errorf("Farmer %d resolved to %s, expected %s", index, farmer.Endpoint, want)
+		}
+	}
+	if err := m.Validate(); err == nil || !strings.Contains(err.Error(), "position") {
+		t.Errorf("Expected Validate to reject farmers out of position, got %v", err)
+	}
+
+	// A duplicated index is ambiguous: no farmer is returned and Validate rejects it
+	m.Farmers = []FarmerInfo{
+		{Index: 0, Endpoint: "https://f0.io"},
+		{Index: 1, Endpoint: "https://f1.io"},
+		{Index: 1, Endpoint: "https://f2.io"},
+	}
+	if farmer := m.GetFarmerForShard(ShardMeta{FarmerIndex: 2}); farmer != nil {
+		t.Errorf("Expected no farmer for unlisted index 2, got %s", farmer.Endpoint)
+	}
+	if err := m.Validate(); err == nil || !strings.Contains(err.Error(), "more than once") {
+		t.Errorf("Expected duplicate farmer index error, got %v", err)
+	}
+}
+
+func TestPlanRedundancyIncrease(t *testing.T) {
+	// 4+2 over six farmers in two regions, every farmer holding a shard of each chunk
+	var farmers []FarmerInfo
+	for i := 0; i < 6; i++ {
+		farmers = append(farmers, FarmerInfo{Index: i, Address: fmt.Sprintf("0xF%d", i), Endpoint: fmt.Sprintf("https://f%d.io", i), Region: []string{"us", "eu"}[i%2]})
+	}
+	chunks := []ChunkMeta{{Index: 0, Hash: "h0", Size: 1024}, {Index: 1, Hash: "h1", Size: 512}}
+	var shards []ShardMeta
+	for _, chunk := range chunks {
+		for s := 0; s < 6; s++ {
+			shards = append(shards, ShardMeta{ChunkIndex: chunk.Index, ShardIndex: s, Size: 100 * (chunk.Index + 1), FarmerIndex: s})
+		}
+	}
+	m := New("test.bin", 1536, "hash", chunks, shards, farmers, make([]byte, 32), "0xPub")
+
+	pool := []FarmerInfo{
+		{Endpoint: "https://f0.io", Region: "ap"}, // already listed
+		{Endpoint: "https://p1.io", Region: "us"}, // region already used
+		{Endpoint: "https://p2.io", Address: "0xF3", Region: "sa"}, // address already listed
+		{Endpoint: "https://p3.io", Region: "ap"},
+		{Endpoint: "https://p4.io", Region: "eu"},
+	}
+	plan, err := m.PlanRedundancyIncrease(4, pool)
+	if err != nil {
+		t.Fatalf("PlanRedundancyIncrease failed: %v", err)
+	}
+	if plan.CurrentParity != 2 || plan.TargetParity != 4 || plan.DataShards != 4 {
+		t.Errorf("Unexpected erasure config %d+%d→%d", plan.DataShards, plan.CurrentParity, plan.TargetParity)
+	}
+	// 4+4 needs eight farmers: the new region first, then pool order
+	if len(plan.NewFarmers) != 2 || plan.NewFarmers[0].Endpoint != "https://p3.io" || plan.NewFarmers[1].Endpoint != "https://p1.io" {
+		t.Fatalf("Unexpected farmers added: %+v", plan.NewFarmers)
+	}
+	if plan.NewFarmers[0].Index != 6 || plan.NewFarmers[1].Index != 7 {
+		t.Errorf("New farmers should be indexed after the manifest's, got %d and %d", plan.NewFarmers[0].Index, plan.NewFarmers[1].Index)
+	}
+	for _, chunk := range plan.Chunks {
+		if chunk.AdditionalShards != 2 {
+			t.Errorf("Chunk %d: expected 2 additional shards, got %d", chunk.ChunkIndex, chunk.AdditionalShards)
+		}
+		if !slices.Equal(chunk.Candidates, []int{6, 7}) {
+			t.Errorf("Chunk %d: expected the new farmers as candidates, got %v", chunk.ChunkIndex, chunk.Candidates)
+		}
+	}
+	if plan.AdditionalShards != 4 || plan.AdditionalBytes != 2*100+2*200 {
+		t.Errorf("Expected 4 shards and 600 bytes added, got %d and %d", plan.AdditionalShards, plan.AdditionalBytes)
+	}
+	if len(m.Farmers) != 6 {
+		t.Error("PlanRedundancyIncrease modified the manifest")
+	}
+
+	if _, err := m.PlanRedundancyIncrease(2, pool); err == nil {
+		t.Error("Expected an error for a target that isn't an increase")
+	}
+	if _, err := m.PlanRedundancyIncrease(6, pool); err == nil {
+		t.Error("Expected an error when the pool can't supply enough farmers")
+	}
 }
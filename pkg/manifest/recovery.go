@@ -0,0 +1,184 @@
+package manifest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// RecoveryKitPrefix starts every recovery kit and names its format version
+const RecoveryKitPrefix = "DBXN1"
+
+// recoveryDigestSize is the length of the chunk list digest a kit carries
+const recoveryDigestSize = 16
+
+// recoveryChecksumSize is the length of the typo-catching checksum
+const recoveryChecksumSize = 4
+
+// recoveryGroup is the number of characters between dashes
+const recoveryGroup = 5
+
+// Blob ID encodings inside a kit
+const (
+	kitBlobIDRandom = 0 // "0x" + 64 hex digits, stored as 32 raw bytes (NewBlobID)
+	kitBlobIDString = 1 // anything else, stored as a length-prefixed string
+)
+
+var kitEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// RecoveryInfo is what a recovery kit holds: enough, with a reachable fleet, to
+// restore a blob via retriever.FetchManifest or retriever.RebuildManifest
+type RecoveryInfo struct {
+	BlobID string
+	Key    []byte // chunk encryption key
+	Digest []byte // leading bytes of the manifest's chunk list digest
+}
+
+// Matches reports whether m is the manifest the kit was made from: same blob ID
+// and the same chunks (index, size and hash). A manifest rebuilt with the key
+// whose chunk hashes were restored matches too.
+func (r *RecoveryInfo) Matches(m *Manifest) bool {
+	digest, err := hex.DecodeString(chunkListDigest(m))
+	if err != nil {
+		return false
+	}
+	return m.BlobID == r.BlobID && bytes.Equal(digest[:recoveryDigestSize], r.Digest)
+}
+
+// RecoveryKit encodes a blob's ID, encryption key and a digest of its chunk list
+// into a short string to print or keep in a password manager. It is
+// RecoveryKitPrefix followed by dash-separated groups of base32 (uppercase
+// letters and digits, so it also fits a QR code's alphanumeric mode), ending in a
+// checksum that ParseRecoveryKit uses to catch typos. The kit holds the key:
+// treat it like the manifest itself.
+func RecoveryKit(m *Manifest) (string, error) {
+	key, err := m.GetEncryptionKey()
+	if err != nil {
+		return "", err
+	}
+	if len(key) == 0 || len(key) > 255 {
+		return "", fmt.Errorf("encryption key of %d bytes can't go in a recovery kit", len(key))
+	}
+	digest, err := hex.DecodeString(chunkListDigest(m))
+	if err != nil {
+		return "", err
+	}
+
+	var payload []byte
+	if raw, ok := randomBlobIDBytes(m.BlobID); ok {
+		payload = append(payload, kitBlobIDRandom)
+		payload = append(payload, raw...)
+	} else {
+		if m.BlobID == "" || len(m.BlobID) > 255 {
+			return "", fmt.Errorf("blob id of %d bytes can't go in a recovery kit", len(m.BlobID))
+		}
+		payload = append(payload, kitBlobIDString, byte(len(m.BlobID)))
+		payload = append(payload, m.BlobID...)
+	}
+	payload = append(payload, byte(len(key)))
+	payload = append(payload, key...)
+	payload = append(payload, digest[:recoveryDigestSize]...)
+	payload = append(payload, kitChecksum(payload)...)
+
+	body := kitEncoding.EncodeToString(payload)
+	groups := []string{RecoveryKitPrefix}
+	for len(body) > 0 {
+		n := min(recoveryGroup, len(body))
+		groups = append(groups, body[:n])
+		body = body[n:]
+	}
+	return strings.Join(groups, "-"), nil
+}
+
+// ParseRecoveryKit decodes a kit made by RecoveryKit. Case, dashes and
+// whitespace are ignored; a mistyped character fails the checksum.
+func ParseRecoveryKit(s string) (*RecoveryInfo, error) {
+	clean := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' || r == '\t' || r == '\n' || r == '\r' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(s))
+	body, ok := strings.CutPrefix(clean, RecoveryKitPrefix)
+	if !ok {
+		return nil, fmt.Errorf("not a recovery kit: expected prefix %s", RecoveryKitPrefix)
+	}
+	data, err := kitEncoding.DecodeString(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recovery kit: %w", err)
+	}
+	// The last character carries unused bits a typo can change without
+	// changing the bytes, so only the canonical spelling is accepted
+	if kitEncoding.EncodeToString(data) != body {
+		return nil, fmt.Errorf("recovery kit checksum mismatch: check it for typos")
+	}
+	if len(data) < recoveryChecksumSize {
+		return nil, fmt.Errorf("recovery kit is truncated")
+	}
+	payload, sum := data[:len(data)-recoveryChecksumSize], data[len(data)-recoveryChecksumSize:]
+	if !bytes.Equal(kitChecksum(payload), sum) {
+		return nil, fmt.Errorf("recovery kit checksum mismatch: check it for typos")
+	}
+
+	r := &kitReader{data: payload}
+	info := &RecoveryInfo{}
+	switch r.byte() {
+	case kitBlobIDRandom:
+		info.BlobID = "0x" + hex.EncodeToString(r.bytes(32))
+	case kitBlobIDString:
+		info.BlobID = string(r.bytes(int(r.byte())))
+	default:
+		return nil, fmt.Errorf("recovery kit has an unknown blob id encoding")
+	}
+	info.Key = r.bytes(int(r.byte()))
+	info.Digest = r.bytes(recoveryDigestSize)
+	if r.err || len(r.data) != 0 {
+		return nil, fmt.Errorf("recovery kit is malformed")
+	}
+	return info, nil
+}
+
+// randomBlobIDBytes returns the raw bytes of a blob ID in NewBlobID's format
+func randomBlobIDBytes(blobID string) ([]byte, bool) {
+	digits, ok := strings.CutPrefix(blobID, "0x")
+	if !ok || len(digits) != 64 || strings.ToLower(digits) != digits {
+		return nil, false
+	}
+	raw, err := hex.DecodeString(digits)
+	return raw, err == nil
+}
+
+// kitChecksum is the leading bytes of SHA-256 over the kit prefix and payload
+func kitChecksum(payload []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte(RecoveryKitPrefix))
+	h.Write(payload)
+	return h.Sum(nil)[:recoveryChecksumSize]
+}
+
+// kitReader consumes a kit payload, remembering if it ran short
+type kitReader struct {
+	data []byte
+	err  bool
+}
+
+func (r *kitReader) bytes(n int) []byte {
+	if n > len(r.data) {
+		r.err = true
+		r.data = nil
+		return nil
+	}
+	b := r.data[:n:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *kitReader) byte() byte {
+	if b := r.bytes(1); len(b) == 1 {
+		return b[0]
+	}
+	return 0
+}
@@ -0,0 +1,162 @@
+package manifest
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/chunker"
+)
+
+// RedundancyPlan previews raising a blob's parity (PlanRedundancyIncrease):
+// which farmers to add and how many shards and bytes it costs, before anything
+// is fetched or stored. retriever.IncreaseRedundancy carries it out.
+type RedundancyPlan struct {
+	BlobID           string
+	DataShards       int
+	CurrentParity    int
+	TargetParity     int
+	NewFarmers       []FarmerInfo          // pool farmers to add, indexed after the manifest's own
+	Chunks           []ChunkRedundancyPlan // one per chunk, in manifest order
+	AdditionalShards int                   // shards stored beyond today's, all chunks together
+	AdditionalBytes  int64                 // storage those shards add
+}
+
+// ChunkRedundancyPlan is what one chunk needs to reach the target parity
+type ChunkRedundancyPlan struct {
+	ChunkIndex       int
+	AdditionalShards int
+	ShardSize        int   // size of each shard; unchanged, as DataShards is
+	Candidates       []int // farmer indices not holding the chunk, regions it lacks first
+}
+
+// Farmers returns m's farmers with the plan's NewFarmers appended: the farmer
+// list the blob is resharded across
+func (p RedundancyPlan) Farmers(m *Manifest) []FarmerInfo {
+	return append(slices.Clone(m.Farmers), p.NewFarmers...)
+}
+
+// PlanRedundancyIncrease works out how to raise the blob from its current parity
+// to targetParity shards per chunk, keeping DataShards. Every chunk gets
+// targetParity minus the current parity extra shards. Farmers are added from
+// pool only as needed: enough for a distinct farmer per shard, and for every
+// chunk to have candidates not already holding one of its shards. Pool farmers
+// sharing an endpoint or address with a listed farmer are skipped, and those in
+// regions the blob doesn't use yet are preferred. m is not modified.
+func (m *Manifest) PlanRedundancyIncrease(targetParity int, pool []FarmerInfo) (RedundancyPlan, error) {
+	if m.IsSegmented() {
+		return RedundancyPlan{}, fmt.Errorf("manifest %s is segmented; plan each segment", m.BlobID)
+	}
+	dataShards, parityShards := m.DataShards, m.ParityShards
+	if dataShards <= 0 {
+		dataShards, parityShards = chunker.DataShards, chunker.ParityShards
+	}
+	if targetParity <= parityShards {
+		return RedundancyPlan{}, fmt.Errorf("target parity %d doesn't exceed the current %d", targetParity, parityShards)
+	}
+	if err := chunker.ValidateErasureConfig(dataShards, targetParity); err != nil {
+		return RedundancyPlan{}, err
+	}
+	extra := targetParity - parityShards
+
+	holders := make(map[int]map[int]bool, len(m.Chunks)) // chunk index → farmer indices
+	shardSizes := make(map[int]int, len(m.Chunks))
+	for _, shard := range m.Shards {
+		if holders[shard.ChunkIndex] == nil {
+			holders[shard.ChunkIndex] = make(map[int]bool)
+		}
+		holders[shard.ChunkIndex][shard.FarmerIndex] = true
+		shardSizes[shard.ChunkIndex] = shard.Size
+	}
+
+	// Enough farmers for a distinct one per shard, and free ones for every chunk
+	need := dataShards + targetParity - len(m.Farmers)
+	for _, chunk := range m.Chunks {
+		need = max(need, extra-(len(m.Farmers)-len(holders[chunk.Index])))
+	}
+	added, err := m.pickPoolFarmers(pool, need)
+	if err != nil {
+		return RedundancyPlan{}, err
+	}
+
+	plan := RedundancyPlan{
+		BlobID:        m.BlobID,
+		DataShards:    dataShards,
+		CurrentParity: parityShards,
+		TargetParity:  targetParity,
+		NewFarmers:    added,
+	}
+	farmers := plan.Farmers(m)
+	for _, chunk := range m.Chunks {
+		covered := make(map[string]bool)
+		for idx := range holders[chunk.Index] {
+			if idx >= 0 && idx < len(farmers) {
+				covered[farmers[idx].Region] = true
+			}
+		}
+		var fresh, rest []int
+		for _, farmer := range farmers {
+			switch {
+			case holders[chunk.Index][farmer.Index]:
+			case farmer.Region != "" && !covered[farmer.Region]:
+				fresh = append(fresh, farmer.Index)
+			default:
+				rest = append(rest, farmer.Index)
+			}
+		}
+		plan.Chunks = append(plan.Chunks, ChunkRedundancyPlan{
+			ChunkIndex:       chunk.Index,
+			AdditionalShards: extra,
+			ShardSize:        shardSizes[chunk.Index],
+			Candidates:       append(fresh, rest...),
+		})
+		plan.AdditionalShards += extra
+		plan.AdditionalBytes += int64(extra) * int64(shardSizes[chunk.Index])
+	}
+	return plan, nil
+}
+
+// pickPoolFarmers returns need farmers from pool, indexed after m's own, skipping
+// any that share an endpoint or address with a farmer already chosen or listed.
+// Farmers in regions not yet used come first, then the rest in pool order.
+func (m *Manifest) pickPoolFarmers(pool []FarmerInfo, need int) ([]FarmerInfo, error) {
+	if need <= 0 {
+		return nil, nil
+	}
+	endpoints := make(map[string]bool)
+	addresses := make(map[string]bool)
+	regions := make(map[string]bool)
+	for _, farmer := range m.Farmers {
+		endpoints[farmer.Endpoint] = true
+		addresses[farmer.Address] = true
+		regions[farmer.Region] = true
+	}
+	distinct := func(farmer FarmerInfo) bool {
+		return farmer.Endpoint != "" && !endpoints[farmer.Endpoint] &&
+			(farmer.Address == "" || !addresses[farmer.Address])
+	}
+
+	var picked []FarmerInfo
+	take := func(farmer FarmerInfo) {
+		farmer.Index = len(m.Farmers) + len(picked)
+		picked = append(picked, farmer)
+		endpoints[farmer.Endpoint] = true
+		if farmer.Address != "" {
+			addresses[farmer.Address] = true
+		}
+		regions[farmer.Region] = true
+	}
+	for _, farmer := range pool {
+		if len(picked) < need && farmer.Region != "" && !regions[farmer.Region] && distinct(farmer) {
+			take(farmer)
+		}
+	}
+	for _, farmer := range pool {
+		if len(picked) < need && distinct(farmer) {
+			take(farmer)
+		}
+	}
+	if len(picked) < need {
+		return nil, fmt.Errorf("need %d more farmers, pool has %d not already listed", need, len(picked))
+	}
+	return picked, nil
+}
@@ -0,0 +1,154 @@
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// SegmentRef locates one segment of a segmented blob in its root manifest
+type SegmentRef struct {
+	Index      int    `json:"index"`       // position in Manifest.Segments
+	FirstChunk int    `json:"first_chunk"` // index of the segment's first chunk
+	ChunkCount int    `json:"chunk_count"` // number of consecutive chunks it covers
+	Hash       string `json:"hash"`        // SegmentHash of the segment manifest
+}
+
+// IsSegmented reports whether m is the root of a segmented blob
+func (m *Manifest) IsSegmented() bool {
+	return len(m.Segments) > 0
+}
+
+// Segment splits a blob's manifest so no single manifest has to hold millions
+// of shard entries. Each segment is a manifest of its own covering
+// chunksPerSegment consecutive chunks (the last one may be shorter), with their
+// shards and the farmer list; its FileSize and ChunkCount describe just that
+// range. The root keeps the blob-wide fields (size, file hash, key, erasure
+// config) but no chunks, shards or farmers, and references each segment by
+// SegmentHash. Segments validate on their own; VerifySegment checks one against
+// the root. m must list chunks 0..ChunkCount-1 and is left unchanged.
+func Segment(m *Manifest, chunksPerSegment int) (root *Manifest, segments []*Manifest, err error) {
+	if chunksPerSegment <= 0 {
+		return nil, nil, fmt.Errorf("invalid chunks per segment %d", chunksPerSegment)
+	}
+	if m.IsSegmented() {
+		return nil, nil, fmt.Errorf("manifest %s is already segmented", m.BlobID)
+	}
+	chunks := append([]ChunkMeta(nil), m.Chunks...)
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].Index < chunks[j].Index })
+	if len(chunks) != m.ChunkCount {
+		return nil, nil, fmt.Errorf("manifest lists %d chunks, chunk_count is %d", len(chunks), m.ChunkCount)
+	}
+	for i, chunk := range chunks {
+		if chunk.Index != i {
+			return nil, nil, fmt.Errorf("chunk %d missing from manifest", i)
+		}
+	}
+	byChunk := make(map[int][]ShardMeta, len(chunks))
+	for _, shard := range m.Shards {
+		byChunk[shard.ChunkIndex] = append(byChunk[shard.ChunkIndex], shard)
+	}
+
+	root = &Manifest{}
+	*root = *m
+	root.Chunks, root.Shards, root.Farmers = nil, nil, nil
+	for first := 0; first < len(chunks); first += chunksPerSegment {
+		seg := &Manifest{}
+		*seg = *m
+		seg.Chunks = chunks[first:min(first+chunksPerSegment, len(chunks))]
+		seg.Shards = nil
+		for _, chunk := range seg.Chunks {
+			seg.Shards = append(seg.Shards, byChunk[chunk.Index]...)
+		}
+		seg.Farmers = append([]FarmerInfo(nil), m.Farmers...)
+		seg.Files, seg.Anchor = nil, nil
+		if err := seg.Recompute(); err != nil {
+			return nil, nil, fmt.Errorf("segment %d: %w", len(segments), err)
+		}
+		if err := seg.Validate(); err != nil {
+			return nil, nil, fmt.Errorf("segment %d: %w", len(segments), err)
+		}
+		hash, err := SegmentHash(seg)
+		if err != nil {
+			return nil, nil, err
+		}
+		root.Segments = append(root.Segments, SegmentRef{
+			Index:      len(segments),
+			FirstChunk: first,
+			ChunkCount: len(seg.Chunks),
+			Hash:       hash,
+		})
+		segments = append(segments, seg)
+	}
+	return root, segments, nil
+}
+
+// SegmentHash is the hex SHA-256 of a segment's compact JSON encoding. The
+// encoding is deterministic, so a segment hashes the same after a Save/Load
+// round trip whatever indentation it was stored with.
+func SegmentHash(seg *Manifest) (string, error) {
+	data, err := json.Marshal(seg)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal segment: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ValidateSegments checks a root's segment list tiles chunks 0..ChunkCount-1
+// in order, without gaps or overlaps
+func (m *Manifest) ValidateSegments() error {
+	next := 0
+	for i, ref := range m.Segments {
+		if ref.Index != i || ref.FirstChunk != next || ref.ChunkCount <= 0 {
+			return fmt.Errorf("segment %d: expected index %d starting at chunk %d, got index %d covering %d chunks from %d",
+				i, i, next, ref.Index, ref.ChunkCount, ref.FirstChunk)
+		}
+		next += ref.ChunkCount
+	}
+	if next != m.ChunkCount {
+		return fmt.Errorf("segments cover %d chunks, chunk_count is %d", next, m.ChunkCount)
+	}
+	return nil
+}
+
+// SegmentFor returns the segment of a root manifest holding chunkIndex
+func (m *Manifest) SegmentFor(chunkIndex int) (SegmentRef, error) {
+	i := sort.Search(len(m.Segments), func(i int) bool {
+		return m.Segments[i].FirstChunk+m.Segments[i].ChunkCount > chunkIndex
+	})
+	if chunkIndex < 0 || i == len(m.Segments) || chunkIndex < m.Segments[i].FirstChunk {
+		return SegmentRef{}, fmt.Errorf("chunk %d not in any segment", chunkIndex)
+	}
+	return m.Segments[i], nil
+}
+
+// VerifySegment checks seg is the segment the root references as ref: same
+// blob, the recorded hash, exactly the chunks ref covers, and a valid manifest
+// in its own right
+func (m *Manifest) VerifySegment(ref SegmentRef, seg *Manifest) error {
+	if ref.Index < 0 || ref.Index >= len(m.Segments) || m.Segments[ref.Index] != ref {
+		return fmt.Errorf("segment %d is not referenced by manifest %s", ref.Index, m.BlobID)
+	}
+	if seg.BlobID != m.BlobID {
+		return fmt.Errorf("segment %d belongs to blob %s, not %s", ref.Index, seg.BlobID, m.BlobID)
+	}
+	hash, err := SegmentHash(seg)
+	if err != nil {
+		return err
+	}
+	if hash != ref.Hash {
+		return fmt.Errorf("segment %d hash mismatch: expected %s, got %s", ref.Index, ref.Hash, hash)
+	}
+	if len(seg.Chunks) != ref.ChunkCount {
+		return fmt.Errorf("segment %d lists %d chunks, expected %d", ref.Index, len(seg.Chunks), ref.ChunkCount)
+	}
+	for i, chunk := range seg.Chunks {
+		if chunk.Index != ref.FirstChunk+i {
+			return fmt.Errorf("segment %d: chunk %d out of place", ref.Index, chunk.Index)
+		}
+	}
+	return seg.Validate()
+}
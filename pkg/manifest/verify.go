@@ -0,0 +1,185 @@
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/chunker"
+	"github.com/Abhinav-kodes/dbxn/pkg/scratch"
+)
+
+// VerifyOptions makes VerifyLocalFileWithOptions incremental
+type VerifyOptions struct {
+	// StatePath is a sidecar recording when each chunk last verified. Chunks
+	// verified since the file was last modified are skipped; "" verifies everything.
+	StatePath string
+
+	// MaxAge re-verifies chunks whose last verification is older than this even
+	// if the file looks unchanged, so repeated scrubs still catch silent
+	// corruption that leaves mtime alone (0 = never expire).
+	MaxAge time.Duration
+}
+
+// VerifyStatePath returns a StatePath for scrubs of blobID kept in
+// scratch.Dir(tempDir), for callers with no better place for it. The state is
+// only a cache: losing it costs one full read.
+func VerifyStatePath(tempDir, blobID string) (string, error) {
+	if err := checkLocalBlobID(blobID); err != nil {
+		return "", err
+	}
+	return filepath.Join(scratch.Dir(tempDir), "verify-"+blobID+".json"), nil
+}
+
+// verifyState is the sidecar persisted at VerifyOptions.StatePath
+type verifyState struct {
+	BlobID    string  `json:"blob_id"`
+	Chunks    string  `json:"chunks"` // chunkListDigest of the manifest verified against
+	ChunkSize int     `json:"chunk_size"`
+	FileSize  int64   `json:"file_size"`
+	ModTime   int64   `json:"mod_time"` // file mtime, Unix nanoseconds
+	Verified  []int64 `json:"verified"` // per chunk: Unix time last verified, 0 = never
+}
+
+// VerifyLocalFileWithOptions is VerifyLocalFile for periodic scrubs of large
+// files. With opts.StatePath set, a chunk that verified on an earlier run is
+// skipped as long as the file's size and mtime are unchanged since. An mtime
+// doesn't say which bytes a write touched, so any change to either discards the
+// whole state and every chunk is read again. The state is only kept if the file
+// didn't change while it was being verified.
+func VerifyLocalFileWithOptions(m *Manifest, path string, opts VerifyOptions) error {
+	if opts.StatePath == "" {
+		return VerifyLocalFile(m, path)
+	}
+	if m.HashesCiphertext() {
+		return fmt.Errorf("manifest records ciphertext chunk hashes; local file can't be compared")
+	}
+	chunkSize := m.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = chunker.ChunkSize
+	}
+	byIndex := make(map[int]ChunkMeta, len(m.Chunks))
+	for _, c := range m.Chunks {
+		byIndex[c.Index] = c
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+	size := info.Size()
+	if n := (size + int64(chunkSize) - 1) / int64(chunkSize); n != int64(m.ChunkCount) {
+		return fmt.Errorf("file has %d chunks, manifest records %d", n, m.ChunkCount)
+	}
+
+	state := loadVerifyState(opts.StatePath, m, chunkSize, info)
+	now := time.Now()
+	buf := make([]byte, chunkSize)
+	for i := 0; i < m.ChunkCount; i++ {
+		if last := state.Verified[i]; last != 0 && (opts.MaxAge <= 0 || now.Sub(time.Unix(last, 0)) < opts.MaxAge) {
+			continue
+		}
+
+		meta, ok := byIndex[i]
+		if !ok {
+			return fmt.Errorf("chunk %d is not listed in the manifest", i)
+		}
+		offset := int64(i) * int64(chunkSize)
+		n := int(min(int64(chunkSize), size-offset))
+		if _, err := io.ReadFull(io.NewSectionReader(file, offset, int64(n)), buf[:n]); err != nil {
+			return fmt.Errorf("failed to read chunk %d: %w", i, err)
+		}
+		sum := sha256.Sum256(buf[:n])
+		if n != meta.Size || hex.EncodeToString(sum[:]) != meta.Hash {
+			state.Verified[i] = 0
+			saveVerifyState(opts.StatePath, state, file, info)
+			return fmt.Errorf("chunk %d does not match manifest", i)
+		}
+		state.Verified[i] = now.Unix()
+	}
+
+	return saveVerifyState(opts.StatePath, state, file, info)
+}
+
+// chunkListDigest identifies the chunk list a verify state was recorded against
+func chunkListDigest(m *Manifest) string {
+	h := sha256.New()
+	for _, c := range m.Chunks {
+		b := AppendCanonicalInt(nil, int64(c.Index))
+		b = AppendCanonicalInt(b, int64(c.Size))
+		h.Write(AppendCanonicalString(b, c.Hash))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// loadVerifyState reads the sidecar at path, or starts a fresh state if there
+// is none or it was recorded for another manifest or an earlier file version
+func loadVerifyState(path string, m *Manifest, chunkSize int, info os.FileInfo) *verifyState {
+	fresh := &verifyState{
+		BlobID:    m.BlobID,
+		Chunks:    chunkListDigest(m),
+		ChunkSize: chunkSize,
+		FileSize:  info.Size(),
+		ModTime:   info.ModTime().UnixNano(),
+		Verified:  make([]int64, m.ChunkCount),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fresh
+	}
+	var state verifyState
+	if err := json.Unmarshal(data, &state); err != nil ||
+		state.BlobID != fresh.BlobID ||
+		state.Chunks != fresh.Chunks ||
+		state.ChunkSize != fresh.ChunkSize ||
+		state.FileSize != fresh.FileSize ||
+		state.ModTime != fresh.ModTime ||
+		len(state.Verified) != m.ChunkCount {
+		return fresh
+	}
+	return &state
+}
+
+// saveVerifyState writes the sidecar atomically (temp file + rename), unless the
+// file changed since info was taken: then the state is removed instead, since
+// chunks read before the change no longer vouch for it
+func saveVerifyState(path string, state *verifyState, file *os.File, info os.FileInfo) error {
+	now, err := file.Stat()
+	if err != nil || now.Size() != info.Size() || !now.ModTime().Equal(info.ModTime()) {
+		os.Remove(path)
+		return fmt.Errorf("file changed while being verified")
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal verify state: %w", err)
+	}
+	tmp, err := scratch.Create(filepath.Dir(path), "verify-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write verify state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write verify state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+// Package metrics is the hook the publisher and retriever report live counters
+// and histograms through, alongside their end-of-run UploadStats/DownloadStats.
+package metrics
+
+// Labels qualify a sample, e.g. Labels{FarmerLabel: endpoint}. nil means none.
+type Labels map[string]string
+
+// Metrics receives samples at the publisher's and retriever's key points. Names
+// and label keys follow Prometheus conventions. Implementations must be safe for
+// concurrent use and cheap: they're called from upload and download workers.
+type Metrics interface {
+	IncCounter(name string, labels Labels, delta float64)
+	ObserveHistogram(name string, labels Labels, value float64)
+}
+
+// Metric names reported by the publisher and retriever
+const (
+	ShardsUploaded      = "dbxn_shards_uploaded_total"
+	BytesUploaded       = "dbxn_uploaded_bytes_total"
+	WireBytesUploaded   = "dbxn_uploaded_wire_bytes_total" // shard bytes as sent, encoding and retries included
+	ShardUploadErrors   = "dbxn_shard_upload_errors_total" // by farmer
+	ShardUploadSeconds  = "dbxn_shard_upload_seconds"      // histogram, by farmer
+	ShardsDownloaded    = "dbxn_shards_downloaded_total"
+	BytesDownloaded     = "dbxn_downloaded_bytes_total"
+	ShardDownloadErrors = "dbxn_shard_download_errors_total" // by farmer
+	ChunksReconstructed = "dbxn_chunks_reconstructed_total"
+	ReconstructSeconds  = "dbxn_chunk_reconstruct_seconds" // histogram
+)
+
+// FarmerLabel is the label holding a farmer endpoint
+const FarmerLabel = "farmer"
+
+// nop discards every sample
+type nop struct{}
+
+func (nop) IncCounter(string, Labels, float64)       {}
+func (nop) ObserveHistogram(string, Labels, float64) {}
+
+// Or returns m, or a no-op Metrics if m is nil, so call sites needn't check
+func Or(m Metrics) Metrics {
+	if m == nil {
+		return nop{}
+	}
+	return m
+}
@@ -0,0 +1,173 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets returns histogram upper bounds in seconds, the same as the
+// Prometheus client's defaults
+func DefaultBuckets() []float64 {
+	return []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+}
+
+// histogram holds cumulative-ready bucket counts for one series
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative; last is +Inf
+	sum    float64
+	count  uint64
+}
+
+// Registry is an in-memory Metrics that serves its samples in the Prometheus
+// text exposition format, so a /metrics endpoint needs no client library:
+//
+//	reg := metrics.NewRegistry()
+//	cfg.Metrics = reg
+//	http.Handle("/metrics", reg)
+//
+// Code already using the Prometheus client can instead implement Metrics with a
+// few lines forwarding to its CounterVec and HistogramVec.
+type Registry struct {
+	buckets []float64
+
+	mu         sync.Mutex
+	counters   map[string]map[string]float64    // name → rendered labels → value
+	histograms map[string]map[string]*histogram // name → rendered labels → histogram
+}
+
+// NewRegistry creates an empty registry using DefaultBuckets for histograms
+func NewRegistry() *Registry {
+	return &Registry{
+		buckets:    DefaultBuckets(),
+		counters:   make(map[string]map[string]float64),
+		histograms: make(map[string]map[string]*histogram),
+	}
+}
+
+// IncCounter adds delta to a counter
+func (r *Registry) IncCounter(name string, labels Labels, delta float64) {
+	key := renderLabels(labels)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.counters[name] == nil {
+		r.counters[name] = make(map[string]float64)
+	}
+	r.counters[name][key] += delta
+}
+
+// ObserveHistogram records one value in a histogram
+func (r *Registry) ObserveHistogram(name string, labels Labels, value float64) {
+	key := renderLabels(labels)
+	bucket := sort.SearchFloat64s(r.buckets, value) // first bound >= value; len = +Inf
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.histograms[name] == nil {
+		r.histograms[name] = make(map[string]*histogram)
+	}
+	h := r.histograms[name][key]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(r.buckets)+1)}
+		r.histograms[name][key] = h
+	}
+	h.counts[bucket]++
+	h.sum += value
+	h.count++
+}
+
+// Counter returns a counter's current value (0 if never incremented)
+func (r *Registry) Counter(name string, labels Labels) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.counters[name][renderLabels(labels)]
+}
+
+// WriteText writes every series in the Prometheus text exposition format, sorted
+// by name and labels
+func (r *Registry) WriteText(w io.Writer) error {
+	var b strings.Builder
+	r.mu.Lock()
+	for _, name := range sortedKeys(r.counters) {
+		fmt.Fprintf(&b, "# TYPE %s counter\n", name)
+		series := r.counters[name]
+		for _, labels := range sortedKeys(series) {
+			fmt.Fprintf(&b, "%s%s %s\n", name, braced(labels), formatValue(series[labels]))
+		}
+	}
+	for _, name := range sortedKeys(r.histograms) {
+		fmt.Fprintf(&b, "# TYPE %s histogram\n", name)
+		series := r.histograms[name]
+		for _, labels := range sortedKeys(series) {
+			h := series[labels]
+			var cumulative uint64
+			for i, bound := range r.buckets {
+				cumulative += h.counts[i]
+				fmt.Fprintf(&b, "%s_bucket%s %d\n", name, braced(joinLabels(labels, `le="`+formatValue(bound)+`"`)), cumulative)
+			}
+			fmt.Fprintf(&b, "%s_bucket%s %d\n", name, braced(joinLabels(labels, `le="+Inf"`)), h.count)
+			fmt.Fprintf(&b, "%s_sum%s %s\n", name, braced(labels), formatValue(h.sum))
+			fmt.Fprintf(&b, "%s_count%s %d\n", name, braced(labels), h.count)
+		}
+	}
+	r.mu.Unlock()
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ServeHTTP serves WriteText for a Prometheus scrape
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	r.WriteText(w)
+}
+
+// labelEscaper escapes label values per the exposition format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// renderLabels renders labels as `k1="v1",k2="v2"` sorted by key, the series key
+func renderLabels(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(labels))
+	for k, v := range labels {
+		parts = append(parts, k+`="`+labelEscaper.Replace(v)+`"`)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// joinLabels appends one rendered label to a rendered set
+func joinLabels(labels, extra string) string {
+	if labels == "" {
+		return extra
+	}
+	return labels + "," + extra
+}
+
+// braced wraps rendered labels in braces, or returns "" for none
+func braced(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+// formatValue formats a sample value the way Prometheus parses it
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// sortedKeys returns a map's keys in ascending order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRegistry_TextFormat(t *testing.T) {
+	reg := NewRegistry()
+	reg.IncCounter(ShardsUploaded, nil, 2)
+	reg.IncCounter(ShardsUploaded, nil, 1)
+	reg.IncCounter(ShardUploadErrors, Labels{FarmerLabel: "https://f1.io"}, 1)
+	reg.IncCounter(ShardUploadErrors, Labels{FarmerLabel: `odd"name`}, 1)
+	reg.ObserveHistogram(ReconstructSeconds, nil, 0.02)
+	reg.ObserveHistogram(ReconstructSeconds, nil, 3)
+	reg.ObserveHistogram(ReconstructSeconds, nil, 60)
+
+	var b strings.Builder
+	if err := reg.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+
+	for _, want := range []string{
+		"# TYPE dbxn_shards_uploaded_total counter\ndbxn_shards_uploaded_total 3\n",
+		`dbxn_shard_upload_errors_total{farmer="https://f1.io"} 1` + "\n",
+		`dbxn_shard_upload_errors_total{farmer="odd\"name"} 1` + "\n",
+		"# TYPE dbxn_chunk_reconstruct_seconds histogram\n",
+		`dbxn_chunk_reconstruct_seconds_bucket{le="0.01"} 0` + "\n",
+		`dbxn_chunk_reconstruct_seconds_bucket{le="0.025"} 1` + "\n",
+		`dbxn_chunk_reconstruct_seconds_bucket{le="5"} 2` + "\n",
+		`dbxn_chunk_reconstruct_seconds_bucket{le="10"} 2` + "\n",
+		`dbxn_chunk_reconstruct_seconds_bucket{le="+Inf"} 3` + "\n",
+		"dbxn_chunk_reconstruct_seconds_sum 63.02\n",
+		"dbxn_chunk_reconstruct_seconds_count 3\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Output missing %q:\n%s", want, out)
+		}
+	}
+
+	// Served as-is for a scrape
+	rec := httptest.NewRecorder()
+	reg.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Body.String() != out {
+		t.Error("ServeHTTP should serve WriteText output")
+	}
+}
+
+func TestRegistry_ConcurrentUse(t *testing.T) {
+	reg := NewRegistry()
+	labels := Labels{FarmerLabel: "https://f0.io"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				reg.IncCounter(ShardDownloadErrors, labels, 1)
+				reg.ObserveHistogram(ShardUploadSeconds, labels, 0.1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := reg.Counter(ShardDownloadErrors, Labels{FarmerLabel: "https://f0.io"}); got != 800 {
+		t.Errorf("Expected 800, got %v", got)
+	}
+}
+
+func TestOr(t *testing.T) {
+	// A nil Metrics becomes a usable no-op
+	Or(nil).IncCounter(ShardsUploaded, nil, 1)
+	Or(nil).ObserveHistogram(ReconstructSeconds, nil, 1)
+
+	reg := NewRegistry()
+	if Or(reg) != Metrics(reg) {
+		t.Error("Or should return a non-nil Metrics unchanged")
+	}
+}
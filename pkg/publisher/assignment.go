@@ -0,0 +1,224 @@
+package publisher
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/chunker"
+	"github.com/Abhinav-kodes/dbxn/pkg/crypto"
+	"github.com/Abhinav-kodes/dbxn/pkg/manifest"
+)
+
+// ShardUnit is one (chunk, shard) produced by PrepareShards that needs a farmer.
+// Units are comparable so schedulers can key placement decisions by them.
+type ShardUnit struct {
+	ChunkIndex int    // which chunk
+	ShardIndex int    // which shard within the chunk
+	Hash       string // SHA256 of shard
+	Size       int    // shard size in bytes
+
+	blob *preparedBlob // encoding output shared by all units of one PrepareShards call
+}
+
+// preparedBlob holds everything PrepareShards encoded, until placement is decided
+type preparedBlob struct {
+	filePath   string
+	fileHash   string
+	key        []byte
+	paddedSize int
+	shardWrap  string
+	shardFEC   *chunker.ShardFEC
+	hashDomain string
+	cipher     chunkCipher
+	chunks     []manifest.ChunkMeta
+	data       map[shardKey][]byte
+}
+
+// PrepareShards chunks, encrypts and shards a file without assigning farmers.
+// Returns every shard unit in chunk/shard order for an external scheduler to place;
+// pass the decisions to UploadWithAssignment. cfg.UniformShardSize, cfg.ShardWrapKey,
+// cfg.ShardFEC, cfg.ChunkHashDomain, cfg.CommitChunks, cfg.BindChunkAAD and cfg.ChainChunks are honoured.
+func PrepareShards(filePath string, key []byte, cfg UploadConfig) ([]ShardUnit, error) {
+	if len(key) != crypto.KeySize {
+		return nil, fmt.Errorf("invalid key size: expected %d, got %d", crypto.KeySize, len(key))
+	}
+
+	fileHash, err := manifest.CalculateFileHash(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash file: %w", err)
+	}
+
+	paddedSize := 0
+	if cfg.UniformShardSize {
+		paddedSize = chunker.ChunkSize + chunkOverhead(cfg)
+	}
+	cipher := newChunkCipher(cfg)
+	chunks, shards, err := processFile(filePath, key, paddedSize, cfg.ChunkHashDomain, cipher, &UploadStats{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to process file: %w", err)
+	}
+	if cfg.ChainChunks {
+		chainChunks(chunks)
+	}
+	if cfg.ShardWrapKey != nil {
+		if err := wrapShards(shards, cfg.ShardWrapAlgorithm, cfg.ShardWrapKey); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.ShardFEC != nil {
+		if err := encodeShardFEC(shards, cfg.ShardFEC); err != nil {
+			return nil, err
+		}
+	}
+
+	blob := &preparedBlob{
+		filePath:   filePath,
+		fileHash:   fileHash,
+		key:        key,
+		paddedSize: paddedSize,
+		shardWrap:  shardWrapName(cfg),
+		shardFEC:   cfg.ShardFEC,
+		hashDomain: cfg.ChunkHashDomain,
+		cipher:     cipher,
+		chunks:     chunks,
+		data:       make(map[shardKey][]byte, len(shards)),
+	}
+	units := make([]ShardUnit, 0, len(shards))
+	for _, shard := range shards {
+		blob.data[shardKey{shard.ChunkIndex, shard.ShardIndex}] = shard.Data
+		units = append(units, ShardUnit{
+			ChunkIndex: shard.ChunkIndex,
+			ShardIndex: shard.ShardIndex,
+			Hash:       shard.Hash,
+			Size:       shard.Size,
+			blob:       blob,
+		})
+	}
+
+	return units, nil
+}
+
+// UploadWithAssignment distributes prepared shards according to an external placement
+// decision and builds the manifest. Every unit must be assigned; farmers are identified
+// by endpoint and re-indexed in the manifest. The manifest is saved if cfg.OutputPath is set.
+func UploadWithAssignment(
+	units []ShardUnit,
+	assignment map[ShardUnit]manifest.FarmerInfo,
+	cfg UploadConfig,
+) (*manifest.Manifest, *UploadStats, error) {
+	stats := &UploadStats{
+		StartTime: time.Now(),
+		Errors:    make([]error, 0),
+	}
+
+	if len(units) == 0 {
+		return nil, stats, fmt.Errorf("no shard units to upload")
+	}
+	blob := units[0].blob
+	if blob == nil {
+		return nil, stats, fmt.Errorf("shard units must come from PrepareShards")
+	}
+	if err := checkUnitsComplete(units, blob); err != nil {
+		return nil, stats, err
+	}
+
+	// Collect farmers in order of first use so manifest indices are stable
+	sorted := append([]ShardUnit(nil), units...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].ChunkIndex != sorted[j].ChunkIndex {
+			return sorted[i].ChunkIndex < sorted[j].ChunkIndex
+		}
+		return sorted[i].ShardIndex < sorted[j].ShardIndex
+	})
+
+	var farmers []manifest.FarmerInfo
+	farmerIndex := make(map[string]int) // endpoint → manifest index
+	shardMetas := make([]manifest.ShardMeta, 0, len(sorted))
+	shards := make([]chunker.Shard, 0, len(sorted))
+	for _, unit := range sorted {
+		info, ok := assignment[unit]
+		if !ok {
+			return nil, stats, fmt.Errorf("chunk %d shard %d: no farmer assigned", unit.ChunkIndex, unit.ShardIndex)
+		}
+		if info.Endpoint == "" {
+			return nil, stats, fmt.Errorf("chunk %d shard %d: assigned farmer has no endpoint", unit.ChunkIndex, unit.ShardIndex)
+		}
+
+		idx, ok := farmerIndex[info.Endpoint]
+		if !ok {
+			idx = len(farmers)
+			info.Index = idx
+			farmers = append(farmers, info)
+			farmerIndex[info.Endpoint] = idx
+		}
+
+		shardMetas = append(shardMetas, manifest.ShardMeta{
+			ChunkIndex:  unit.ChunkIndex,
+			ShardIndex:  unit.ShardIndex,
+			Hash:        unit.Hash,
+			Size:        unit.Size,
+			FarmerIndex: idx,
+		})
+		shards = append(shards, chunker.Shard{
+			ChunkIndex: unit.ChunkIndex,
+			ShardIndex: unit.ShardIndex,
+			Data:       blob.data[shardKey{unit.ChunkIndex, unit.ShardIndex}],
+			Hash:       unit.Hash,
+			Size:       unit.Size,
+		})
+	}
+
+	chunks := append([]manifest.ChunkMeta(nil), blob.chunks...)
+	m := assembleManifest(blob.filePath, blob.fileHash, chunks, shardMetas, farmers,
+		blob.key, cfg.PublisherAddress, cfg.MinRegions)
+	m.PaddedSize = blob.paddedSize
+	m.ShardWrap = blob.shardWrap
+	m.ShardFEC = blob.shardFEC
+	setPublisherKey(m, cfg.PublisherPublicKey)
+	m.ChunkHashDomain = blob.hashDomain
+	blob.cipher.apply(m)
+	if err := m.Validate(); err != nil {
+		return nil, stats, fmt.Errorf("assignment rejected: %w", err)
+	}
+
+	uploadStart := time.Now()
+	err := distributeShardsParallel(m, shards, farmers, cfg, stats)
+	stats.UploadDuration = time.Since(uploadStart)
+	if err != nil {
+		return nil, stats, fmt.Errorf("failed to distribute shards: %w", err)
+	}
+	if cfg.PinManifest {
+		if err := pinManifest(m, blob.key, cfg, stats); err != nil {
+			return nil, stats, fmt.Errorf("failed to pin manifest: %w", err)
+		}
+	}
+
+	if cfg.OutputPath != "" {
+		if err := m.Save(cfg.OutputPath); err != nil {
+			return nil, stats, fmt.Errorf("failed to save manifest: %w", err)
+		}
+	}
+
+	stats.EndTime = time.Now()
+	return m, stats, nil
+}
+
+// checkUnitsComplete ensures units cover every shard of one prepared blob exactly once
+func checkUnitsComplete(units []ShardUnit, blob *preparedBlob) error {
+	seen := make(map[shardKey]bool, len(units))
+	for _, unit := range units {
+		if unit.blob != blob {
+			return fmt.Errorf("shard units come from different PrepareShards calls")
+		}
+		key := shardKey{unit.ChunkIndex, unit.ShardIndex}
+		if seen[key] {
+			return fmt.Errorf("chunk %d shard %d listed twice", unit.ChunkIndex, unit.ShardIndex)
+		}
+		seen[key] = true
+	}
+	if len(seen) != len(blob.data) {
+		return fmt.Errorf("got %d shard units, blob has %d", len(seen), len(blob.data))
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package publisher
+
+import (
+	"crypto/rand"
+	"os"
+	"testing"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/chunker"
+	"github.com/Abhinav-kodes/dbxn/pkg/crypto"
+	"github.com/Abhinav-kodes/dbxn/pkg/manifest"
+)
+
+// ============================================================================
+// EXTERNAL ASSIGNMENT TESTS
+// ============================================================================
+
+func TestUploadWithAssignment_FollowsSchedule(t *testing.T) {
+	fleet, endpoints := newMockFleet(t, 3)
+
+	testFile := "test-assignment.bin"
+	testData := make([]byte, chunker.ChunkSize+500)
+	rand.Read(testData)
+	if err := os.WriteFile(testFile, testData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+
+	key, _ := crypto.GenerateKey()
+	units, err := PrepareShards(testFile, key, UploadConfig{})
+	if err != nil {
+		t.Fatalf("PrepareShards failed: %v", err)
+	}
+	if len(units) != 2*chunker.TotalShards {
+		t.Fatalf("Expected %d units, got %d", 2*chunker.TotalShards, len(units))
+	}
+
+	// External scheduler: shard i goes to farmer i % 3, regardless of chunk
+	assignment := make(map[ShardUnit]manifest.FarmerInfo, len(units))
+	for _, unit := range units {
+		assignment[unit] = manifest.FarmerInfo{Endpoint: endpoints[unit.ShardIndex%3]}
+	}
+
+	pub, _, _ := crypto.GenerateEd25519KeyPair()
+	m, stats, err := UploadWithAssignment(units, assignment, UploadConfig{Parallelism: 2, PublisherPublicKey: pub})
+	if err != nil {
+		t.Fatalf("UploadWithAssignment failed: %v", err)
+	}
+	if got, err := m.GetPublisherPublicKey(); err != nil || !got.Equal(pub) {
+		t.Errorf("Publisher public key not recorded: %v", err)
+	}
+	if m.PublisherAddress != crypto.AddressFromPublicKey(pub) {
+		t.Errorf("Expected address derived from public key, got %q", m.PublisherAddress)
+	}
+	if stats.ShardsUploaded != len(units) {
+		t.Errorf("Expected %d shards uploaded, got %d", len(units), stats.ShardsUploaded)
+	}
+	if m.FileSize != int64(len(testData)) {
+		t.Errorf("Expected file size %d, got %d", len(testData), m.FileSize)
+	}
+
+	for _, meta := range m.Shards {
+		farmer := m.GetFarmerForShard(meta)
+		if farmer == nil || farmer.Endpoint != endpoints[meta.ShardIndex%3] {
+			t.Errorf("Chunk %d shard %d not placed per assignment", meta.ChunkIndex, meta.ShardIndex)
+		}
+	}
+	for i, f := range fleet {
+		if f.count() != 4 {
+			t.Errorf("Farmer %d: expected 4 shards, got %d", i, f.count())
+		}
+	}
+}
+
+func TestUploadWithAssignment_Incomplete(t *testing.T) {
+	_, endpoints := newMockFleet(t, 1)
+
+	testFile := "test-assignment-missing.bin"
+	if err := os.WriteFile(testFile, []byte("small file"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+
+	key, _ := crypto.GenerateKey()
+	units, err := PrepareShards(testFile, key, UploadConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Leave the last unit unplaced
+	assignment := make(map[ShardUnit]manifest.FarmerInfo)
+	for _, unit := range units[:len(units)-1] {
+		assignment[unit] = manifest.FarmerInfo{Endpoint: endpoints[0]}
+	}
+	if _, _, err := UploadWithAssignment(units, assignment, UploadConfig{}); err == nil {
+		t.Error("Expected error when a unit has no farmer assigned")
+	}
+
+	// Dropping a unit from the list is rejected too
+	assignment[units[len(units)-1]] = manifest.FarmerInfo{Endpoint: endpoints[0]}
+	if _, _, err := UploadWithAssignment(units[1:], assignment, UploadConfig{}); err == nil {
+		t.Error("Expected error when units don't cover the whole blob")
+	}
+}
@@ -0,0 +1,219 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/crypto"
+	"github.com/Abhinav-kodes/dbxn/pkg/manifest"
+)
+
+// UploadDir packs a directory tree into a single blob and uploads it.
+// Files are concatenated in lexical path order and streamed through the chunking
+// pipeline; each chunk is encrypted, sharded and uploaded before later chunks pile
+// up, so memory stays bounded by a few chunks whatever the size of the tree.
+// The manifest's Files records each entry's relative path, mode and byte range,
+// from which retriever.UnpackDir recreates the tree. cfg.FilePath is ignored.
+//
+// Policy:
+//   - directories, including empty ones, are recorded and recreated
+//   - symlinks are never followed; a symlink anywhere in the tree fails the upload,
+//     as do devices, sockets and named pipes
+//   - a file that shrinks while being read fails the upload; bytes appended after
+//     the tree was walked are not included
+//
+// Cancelling ctx stops the walk, the file reader and the pipeline between chunks.
+// Shards already uploaded stay on their farmers; no manifest is saved.
+func UploadDir(ctx context.Context, dir string, cfg UploadConfig) (*manifest.Manifest, *UploadStats, error) {
+	stats := &UploadStats{
+		StartTime: time.Now(),
+		Errors:    make([]error, 0),
+	}
+
+	if cfg.Parallelism == 0 {
+		cfg.Parallelism = 4
+	}
+	cfg, err := withLocalShardDir(cfg)
+	if err != nil {
+		return nil, stats, fmt.Errorf("invalid config: %w", err)
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return nil, stats, fmt.Errorf("invalid config: cannot access directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, stats, fmt.Errorf("invalid config: %s is not a directory", dir)
+	}
+	if err := validateOptions(cfg); err != nil {
+		return nil, stats, fmt.Errorf("invalid config: %w", err)
+	}
+
+	fmt.Printf("📦 Starting directory upload: %s\n", filepath.Base(dir))
+	entries, err := walkDir(ctx, dir)
+	if err != nil {
+		return nil, stats, fmt.Errorf("failed to walk %s: %w", dir, err)
+	}
+	fmt.Printf("✓ Found %d entries\n", len(entries))
+
+	encKey, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, stats, fmt.Errorf("failed to generate key: %w", err)
+	}
+	m := newStreamManifest(filepath.Base(dir), encKey, cfg)
+	m.Files = entries
+	cfg.SniffContentType = false // packed files have no single type
+
+	reader := &dirReader{ctx: ctx, root: dir, entries: entries}
+	defer reader.Close()
+	if err := uploadStream(ctx, reader, m, encKey, cfg, stats); err != nil {
+		if ctx.Err() != nil {
+			return nil, stats, fmt.Errorf("upload of %s cancelled: %w", dir, err)
+		}
+		return nil, stats, fmt.Errorf("failed to upload %s: %w", dir, err)
+	}
+	fmt.Printf("✓ Uploaded: %d chunks (Blob ID: %s)\n", m.ChunkCount, m.BlobID[:16]+"...")
+	if cfg.PinManifest {
+		if err := pinManifest(m, encKey, cfg, stats); err != nil {
+			return nil, stats, fmt.Errorf("failed to pin manifest: %w", err)
+		}
+	}
+
+	if err := m.Save(cfg.OutputPath); err != nil {
+		return nil, stats, fmt.Errorf("failed to save manifest: %w", err)
+	}
+	fmt.Printf("✓ Manifest saved: %s\n", cfg.OutputPath)
+
+	stats.EndTime = time.Now()
+	printStats(stats)
+
+	return m, stats, nil
+}
+
+// walkDir lists the tree under root in lexical order, assigning each regular
+// file its byte range in the packed stream. See UploadDir for the policy.
+func walkDir(ctx context.Context, root string) ([]manifest.DirEntry, error) {
+	var entries []manifest.DirEntry
+	var offset int64
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		switch {
+		case d.Type()&fs.ModeSymlink != 0:
+			return fmt.Errorf("%s is a symlink; symlinks are not supported", rel)
+		case d.IsDir():
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			entries = append(entries, manifest.DirEntry{Path: rel, Dir: true, Mode: uint32(info.Mode().Perm())})
+		case d.Type().IsRegular():
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			entries = append(entries, manifest.DirEntry{
+				Path:   rel,
+				Offset: offset,
+				Size:   info.Size(),
+				Mode:   uint32(info.Mode().Perm()),
+			})
+			offset += info.Size()
+		default:
+			return fmt.Errorf("%s is not a regular file (%s)", rel, d.Type())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// dirReader concatenates the regular files of a walked tree, keeping at most
+// one file open. Reading fails once ctx is done.
+type dirReader struct {
+	ctx     context.Context
+	root    string
+	entries []manifest.DirEntry
+
+	next      int      // next entry to open
+	cur       *os.File // file being read, nil between files
+	path      string   // relative path of cur
+	remaining int64    // bytes of cur still expected
+}
+
+func (r *dirReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	for r.cur == nil {
+		if r.next >= len(r.entries) {
+			return 0, io.EOF
+		}
+		entry := r.entries[r.next]
+		r.next++
+		if entry.Dir || entry.Size == 0 {
+			continue
+		}
+
+		f, err := os.Open(filepath.Join(r.root, filepath.FromSlash(entry.Path)))
+		if err != nil {
+			return 0, fmt.Errorf("failed to open %s: %w", entry.Path, err)
+		}
+		r.cur, r.path, r.remaining = f, entry.Path, entry.Size
+	}
+
+	// Never read past the size recorded in the manifest
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.cur.Read(p)
+	r.remaining -= int64(n)
+
+	if r.remaining == 0 {
+		err = r.cur.Close()
+		r.cur = nil
+		if err != nil {
+			return n, fmt.Errorf("failed to close %s: %w", r.path, err)
+		}
+		return n, nil
+	}
+	if err == io.EOF {
+		return n, fmt.Errorf("%s shrank while being read", r.path)
+	}
+	if err != nil {
+		return n, fmt.Errorf("failed to read %s: %w", r.path, err)
+	}
+	return n, nil
+}
+
+// Close closes the file currently being read, if any
+func (r *dirReader) Close() error {
+	if r.cur == nil {
+		return nil
+	}
+	err := r.cur.Close()
+	r.cur = nil
+	return err
+}
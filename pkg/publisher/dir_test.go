@@ -0,0 +1,173 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/chunker"
+	"github.com/Abhinav-kodes/dbxn/pkg/retriever"
+)
+
+// ============================================================================
+// DIRECTORY UPLOAD TESTS
+// ============================================================================
+
+// writeTestTree creates a small tree under root and returns file path → contents
+func writeTestTree(t *testing.T, root string) map[string][]byte {
+	t.Helper()
+	files := map[string][]byte{
+		"a.txt":            []byte("hello"),
+		"empty.bin":        {},
+		"sub/big.bin":      make([]byte, chunker.ChunkSize+1234), // spans a chunk boundary
+		"sub/deep/tail.md": []byte("# tail"),
+	}
+	rand.Read(files["sub/big.bin"])
+
+	for name, data := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(root, "empty-dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestUploadDir_RoundTrip(t *testing.T) {
+	_, endpoints := newMockFleet(t, 6)
+
+	root, err := os.MkdirTemp(".", "test-upload-dir-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	files := writeTestTree(t, root)
+
+	manifestPath := "test-upload-dir-manifest.json"
+	defer os.Remove(manifestPath)
+
+	m, stats, err := UploadDir(context.Background(), root, UploadConfig{
+		FarmerEndpoints: endpoints,
+		OutputPath:      manifestPath,
+		Parallelism:     2,
+	})
+	if err != nil {
+		t.Fatalf("UploadDir failed: %v", err)
+	}
+
+	var total int64
+	for _, data := range files {
+		total += int64(len(data))
+	}
+	if m.FileSize != total {
+		t.Errorf("Expected blob size %d, got %d", total, m.FileSize)
+	}
+	if m.ChunkCount != 2 || len(m.Chunks) != 2 {
+		t.Errorf("Expected 2 chunks, got %d (%d listed)", m.ChunkCount, len(m.Chunks))
+	}
+	if stats.ShardsUploaded != 2*chunker.TotalShards {
+		t.Errorf("Expected %d shards uploaded, got %d", 2*chunker.TotalShards, stats.ShardsUploaded)
+	}
+
+	// Lexical order; directories listed, empty ones included
+	wantPaths := []string{"a.txt", "empty-dir", "empty.bin", "sub", "sub/big.bin", "sub/deep", "sub/deep/tail.md"}
+	if len(m.Files) != len(wantPaths) {
+		t.Fatalf("Expected %d entries, got %+v", len(wantPaths), m.Files)
+	}
+	for i, entry := range m.Files {
+		if entry.Path != wantPaths[i] {
+			t.Errorf("Entry %d: expected %s, got %s", i, wantPaths[i], entry.Path)
+		}
+	}
+
+	dest, err := os.MkdirTemp(".", "test-unpack-dir-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dest)
+	if err := retriever.UnpackDir(context.Background(), m, dest, retriever.DownloadConfig{}); err != nil {
+		t.Fatalf("UnpackDir failed: %v", err)
+	}
+
+	for name, want := range files {
+		got, err := os.ReadFile(filepath.Join(dest, filepath.FromSlash(name)))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: contents differ after round trip", name)
+		}
+	}
+	if info, err := os.Stat(filepath.Join(dest, "empty-dir")); err != nil || !info.IsDir() {
+		t.Errorf("Empty directory not recreated: %v", err)
+	}
+
+	// Unpacking never overwrites existing files
+	if err := retriever.UnpackDir(context.Background(), m, dest, retriever.DownloadConfig{}); err == nil {
+		t.Error("Expected error unpacking over existing files")
+	}
+}
+
+func TestUploadDir_RejectsSymlinks(t *testing.T) {
+	_, endpoints := newMockFleet(t, 6)
+
+	root, err := os.MkdirTemp(".", "test-upload-dir-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	writeTestTree(t, root)
+	if err := os.Symlink("a.txt", filepath.Join(root, "link")); err != nil {
+		t.Skipf("Symlinks unavailable: %v", err)
+	}
+
+	_, _, err = UploadDir(context.Background(), root, UploadConfig{
+		FarmerEndpoints: endpoints,
+		OutputPath:      "test-upload-dir-symlink.json",
+	})
+	if err == nil {
+		os.Remove("test-upload-dir-symlink.json")
+		t.Fatal("Expected error for tree containing a symlink")
+	}
+}
+
+func TestUploadDir_Cancelled(t *testing.T) {
+	fleet, endpoints := newMockFleet(t, 6)
+
+	root, err := os.MkdirTemp(".", "test-upload-dir-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	writeTestTree(t, root)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, err = UploadDir(ctx, root, UploadConfig{
+		FarmerEndpoints: endpoints,
+		OutputPath:      "test-upload-dir-cancelled.json",
+	})
+	if err == nil {
+		os.Remove("test-upload-dir-cancelled.json")
+		t.Fatal("Expected error for cancelled context")
+	}
+	if _, err := os.Stat("test-upload-dir-cancelled.json"); !os.IsNotExist(err) {
+		t.Error("Manifest should not be saved after cancellation")
+	}
+	for i, f := range fleet {
+		if f.count() != 0 {
+			t.Errorf("Farmer %d received %d shards after cancellation", i, f.count())
+		}
+	}
+}
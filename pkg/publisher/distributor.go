@@ -1 +1,550 @@
-package publisher
\ No newline at end of file
+package publisher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/chunker"
+	"github.com/Abhinav-kodes/dbxn/pkg/manifest"
+	"github.com/Abhinav-kodes/dbxn/pkg/metrics"
+	"github.com/Abhinav-kodes/dbxn/pkg/transport"
+)
+
+// shardKey identifies a shard within a blob
+type shardKey struct {
+	ChunkIndex int
+	ShardIndex int
+}
+
+// buildFarmerInfo converts endpoint list into manifest farmer entries.
+// Regions are looked up from the optional endpoint → region mapping.
+func buildFarmerInfo(endpoints []string, regions map[string]string) []manifest.FarmerInfo {
+	farmers := make([]manifest.FarmerInfo, len(endpoints))
+	for i, endpoint := range endpoints {
+		farmers[i] = manifest.FarmerInfo{
+			Index:    i,
+			Endpoint: endpoint,
+			Region:   regions[endpoint], // empty if unknown
+		}
+	}
+	return farmers
+}
+
+// countRegions returns the number of distinct non-empty regions in the farmer pool
+func countRegions(farmers []manifest.FarmerInfo) int {
+	regions := make(map[string]bool)
+	for _, farmer := range farmers {
+		if farmer.Region != "" {
+			regions[farmer.Region] = true
+		}
+	}
+	return len(regions)
+}
+
+// placeChunkShards decides which farmer stores each shard of a chunk.
+// Returns a slice indexed by shard index holding farmer indices.
+//
+// Strategy:
+//  1. Rotate the farmer order by chunk index so load spreads across farmers
+//  2. Pick one farmer from each new region until minRegions regions are covered
+//  3. Fill remaining shards with unused farmers on hosts not yet holding this chunk
+//  4. Then with unused farmers sharing a host, reusing farmers only when the pool is exhausted
+func placeChunkShards(chunkIndex int, farmers []manifest.FarmerInfo, minRegions int) ([]int, error) {
+	if len(farmers) == 0 {
+		return nil, fmt.Errorf("no farmers available")
+	}
+	if minRegions > chunker.TotalShards {
+		return nil, fmt.Errorf("MinRegions constraint unsatisfiable: %d regions requested but a chunk only has %d shards", minRegions, chunker.TotalShards)
+	}
+	if available := countRegions(farmers); minRegions > available {
+		return nil, fmt.Errorf("MinRegions constraint unsatisfiable: need %d distinct regions, farmer pool has %d", minRegions, available)
+	}
+
+	n := len(farmers)
+	start := chunkIndex % n
+	used := make([]bool, n)
+	usedHosts := make(map[string]bool)
+	placement := make([]int, 0, chunker.TotalShards)
+
+	// Pass 1: cover the required number of distinct regions
+	seenRegions := make(map[string]bool)
+	for i := 0; i < n && len(seenRegions) < minRegions; i++ {
+		idx := (start + i) % n
+		region := farmers[idx].Region
+		if region == "" || seenRegions[region] {
+			continue
+		}
+		seenRegions[region] = true
+		used[idx] = true
+		usedHosts[farmerHost(farmers[idx].Endpoint)] = true
+		placement = append(placement, idx)
+	}
+
+	// Pass 2: fill remaining shards with farmers on hosts not yet holding this chunk
+	for i := 0; i < n && len(placement) < chunker.TotalShards; i++ {
+		idx := (start + i) % n
+		host := farmerHost(farmers[idx].Endpoint)
+		if !used[idx] && !usedHosts[host] {
+			used[idx] = true
+			usedHosts[host] = true
+			placement = append(placement, idx)
+		}
+	}
+
+	// Pass 3: not enough distinct hosts, accept unused farmers sharing one
+	for i := 0; i < n && len(placement) < chunker.TotalShards; i++ {
+		idx := (start + i) % n
+		if !used[idx] {
+			used[idx] = true
+			placement = append(placement, idx)
+		}
+	}
+
+	// Pass 4: fewer farmers than shards, wrap around and double up
+	for i := 0; len(placement) < chunker.TotalShards; i++ {
+		placement = append(placement, (start+i)%n)
+	}
+
+	return placement, nil
+}
+
+// farmerHost returns the host an endpoint points at, ignoring scheme and port,
+// so two endpoints on one machine count as one failure domain
+func farmerHost(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Hostname() == "" {
+		return endpoint
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// distributeShardsParallel uploads every shard to the farmer assigned in the manifest
+// using a bounded worker pool. Succeeds as long as every chunk keeps DataShards shards,
+// or all TotalShards when cfg.RequireFullRedundancy is set. Achieved per-chunk
+// redundancy is recorded in stats either way.
+func distributeShardsParallel(
+	m *manifest.Manifest,
+	shards []chunker.Shard,
+	farmers []manifest.FarmerInfo,
+	cfg UploadConfig,
+	stats *UploadStats,
+) error {
+	return distributeShardsContext(context.Background(), m, shards, farmers, cfg, stats)
+}
+
+// distributeShardsContext is distributeShardsParallel stopping when ctx is
+// cancelled: shards not yet started are skipped and in-flight requests aborted
+func distributeShardsContext(
+	ctx context.Context,
+	m *manifest.Manifest,
+	shards []chunker.Shard,
+	farmers []manifest.FarmerInfo,
+	cfg UploadConfig,
+	stats *UploadStats,
+) error {
+	if err := verifyShardsAgainstManifest(m, shards); err != nil {
+		return err
+	}
+	uploaded := uploadShardsContext(ctx, m, shards, farmers, cfg, stats)
+	stats.recordRedundancy(m, uploaded)
+	return checkRedundancy(m, uploaded, cfg.RequireFullRedundancy)
+}
+
+// verifyShardsAgainstManifest checks every shard against its manifest entry before
+// anything is sent. A mismatch means the pipeline and the manifest disagree, so the
+// whole upload fails rather than storing shards no retriever could verify.
+func verifyShardsAgainstManifest(m *manifest.Manifest, shards []chunker.Shard) error {
+	metas := make(map[shardKey]manifest.ShardMeta, len(m.Shards))
+	for _, meta := range m.Shards {
+		metas[shardKey{meta.ChunkIndex, meta.ShardIndex}] = meta
+	}
+
+	for _, shard := range shards {
+		meta, ok := metas[shardKey{shard.ChunkIndex, shard.ShardIndex}]
+		if !ok {
+			return fmt.Errorf("chunk %d shard %d: not in manifest", shard.ChunkIndex, shard.ShardIndex)
+		}
+		if len(shard.Data) != meta.Size || !chunker.VerifyShard(shard.Data, meta.Hash) {
+			return fmt.Errorf("chunk %d shard %d: data does not match manifest hash", shard.ChunkIndex, shard.ShardIndex)
+		}
+	}
+	return nil
+}
+
+// uploadShardsParallel uploads shards to their assigned farmers with a bounded worker pool
+// of cfg.Parallelism workers (default 4), authenticating with cfg.FarmerTokens.
+// Returns the number of shards stored per chunk index; failures are recorded in stats.
+//
+// With cfg.UploadDeadline set, shards are queued so every chunk's first DataShards
+// go out before any parity shard, each request gets a fair share of the remaining
+// budget, and shards that time out or can't start in time are abandoned and counted
+// in stats.ShardsTimedOut.
+func uploadShardsParallel(
+	m *manifest.Manifest,
+	shards []chunker.Shard,
+	farmers []manifest.FarmerInfo,
+	cfg UploadConfig,
+	stats *UploadStats,
+) map[int]int {
+	return uploadShardsContext(context.Background(), m, shards, farmers, cfg, stats)
+}
+
+// uploadShardsContext is uploadShardsParallel under ctx: once it is cancelled no
+// further shard is started and in-flight requests are aborted
+func uploadShardsContext(
+	ctx context.Context,
+	m *manifest.Manifest,
+	shards []chunker.Shard,
+	farmers []manifest.FarmerInfo,
+	cfg UploadConfig,
+	stats *UploadStats,
+) map[int]int {
+	parallelism := cfg.Parallelism
+	if parallelism <= 0 {
+		parallelism = 4
+	}
+
+	// Resolve shard → farmer assignments from the manifest; farmers are looked up
+	// by FarmerInfo.Index, not by position
+	assignment := make(map[shardKey]int, len(m.Shards))
+	for _, meta := range m.Shards {
+		assignment[shardKey{meta.ChunkIndex, meta.ShardIndex}] = meta.FarmerIndex
+	}
+	roster := manifest.Manifest{Farmers: farmers}
+
+	// Per-chunk counters are allocated up front so workers only touch atomics
+	perChunk := make(map[int]*atomic.Int64)
+	for _, shard := range shards {
+		if perChunk[shard.ChunkIndex] == nil {
+			perChunk[shard.ChunkIndex] = new(atomic.Int64)
+		}
+	}
+	var shardsUploaded, bytesUploaded, wireBytes, shardsTimedOut, shardRetries atomic.Int64
+	mtr := metrics.Or(cfg.Metrics)
+
+	deadline := uploadDeadline(cfg, stats)
+	if !deadline.IsZero() {
+		shards = dataShardsFirst(shards)
+	}
+	var pending atomic.Int64 // shards not yet started
+	pending.Store(int64(len(shards)))
+
+	jobs := make(chan chunker.Shard)
+	var wg sync.WaitGroup
+
+	for w := 0; w < parallelism; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for shard := range jobs {
+				farmerIdx, ok := assignment[shardKey{shard.ChunkIndex, shard.ShardIndex}]
+				meta := manifest.ShardMeta{
+					ChunkIndex:  shard.ChunkIndex,
+					ShardIndex:  shard.ShardIndex,
+					Hash:        shard.Hash,
+					Size:        shard.Size,
+					FarmerIndex: farmerIdx,
+				}
+				farmer := roster.GetFarmerForShard(meta)
+				if !ok || farmer == nil {
+					stats.addError(fmt.Errorf("chunk %d shard %d: no farmer assigned", shard.ChunkIndex, shard.ShardIndex))
+					continue
+				}
+				endpoint := farmer.Endpoint
+
+				shardCtx := ctx
+				cancel := func() {}
+				if !deadline.IsZero() {
+					timeout, ok := requestTimeout(deadline, int(pending.Add(-1))+1, parallelism)
+					if !ok {
+						shardsTimedOut.Add(1)
+						stats.addError(fmt.Errorf("chunk %d shard %d: upload deadline passed before it started", shard.ChunkIndex, shard.ShardIndex))
+						continue
+					}
+					shardCtx, cancel = context.WithTimeout(ctx, timeout)
+				}
+
+				start := time.Now()
+				addr := manifest.ShardAddress(m.BlobID, shard.ChunkIndex, shard.ShardIndex)
+				var sent atomic.Int64
+				shardCtx = transport.WithWireCounter(shardCtx, &sent)
+				attempts, err := putShardWithRetry(shardCtx, shardSink(cfg, endpoint), addr, shard.Data, meta, cfg.MaxRetries)
+				elapsed := time.Since(start)
+				if sent.Load() == 0 && err == nil {
+					sent.Store(int64(len(shard.Data))) // sink doesn't report wire bytes
+				}
+				wireBytes.Add(sent.Load())
+				mtr.IncCounter(metrics.WireBytesUploaded, nil, float64(sent.Load()))
+				stats.recordFarmerDuration(endpoint, elapsed)
+				cancel()
+				farmerLabel := metrics.Labels{metrics.FarmerLabel: endpoint}
+				mtr.ObserveHistogram(metrics.ShardUploadSeconds, farmerLabel, elapsed.Seconds())
+				shardRetries.Add(int64(attempts - 1))
+				stats.addShardResult(ShardUploadResult{
+					ChunkIndex:  shard.ChunkIndex,
+					ShardIndex:  shard.ShardIndex,
+					FarmerIndex: farmerIdx,
+					Outcome:     ClassifyUploadError(err),
+					Attempts:    attempts,
+					Err:         err,
+				})
+
+				if err != nil {
+					if errors.Is(err, context.DeadlineExceeded) {
+						shardsTimedOut.Add(1)
+					}
+					mtr.IncCounter(metrics.ShardUploadErrors, farmerLabel, 1)
+					stats.addError(fmt.Errorf("chunk %d shard %d → farmer %d: %w", shard.ChunkIndex, shard.ShardIndex, farmerIdx, err))
+					continue
+				}
+				shardsUploaded.Add(1)
+				bytesUploaded.Add(int64(shard.Size))
+				mtr.IncCounter(metrics.ShardsUploaded, nil, 1)
+				mtr.IncCounter(metrics.BytesUploaded, nil, float64(shard.Size))
+				perChunk[shard.ChunkIndex].Add(1)
+			}
+		}()
+	}
+
+dispatch:
+	for _, shard := range shards {
+		select {
+		case jobs <- shard:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	// Workers are done; fold counters into the plain stats fields
+	stats.ShardsUploaded += int(shardsUploaded.Load())
+	stats.BytesUploaded += wireBytes.Load()
+	stats.LogicalBytes += bytesUploaded.Load()
+	stats.WireBytes += wireBytes.Load()
+	stats.ShardsTimedOut += int(shardsTimedOut.Load())
+	stats.ShardRetries += int(shardRetries.Load())
+	uploaded := make(map[int]int, len(perChunk)) // chunk index → shards stored
+	for chunkIndex, n := range perChunk {
+		uploaded[chunkIndex] = int(n.Load())
+	}
+
+	return uploaded
+}
+
+// uploadRetryBackoff is the pause before the first resend, growing linearly per attempt
+const uploadRetryBackoff = 50 * time.Millisecond
+
+// putShardWithRetry stores a shard through sink, resending it up to maxRetries
+// times while the failure is retryable. Returns the number of attempts made.
+// Stops early once ctx is done.
+func putShardWithRetry(ctx context.Context, sink transport.ShardSink, addr string, data []byte, meta manifest.ShardMeta, maxRetries int) (int, error) {
+	for attempt := 1; ; attempt++ {
+		err := sink.Put(ctx, addr, data, meta)
+		if ClassifyUploadError(err) != UploadRetryable || attempt > maxRetries || ctx.Err() != nil {
+			return attempt, err
+		}
+
+		select {
+		case <-time.After(time.Duration(attempt) * uploadRetryBackoff):
+		case <-ctx.Done():
+			return attempt, err
+		}
+	}
+}
+
+// uploadDeadline returns when cfg.UploadDeadline runs out, counted from the start
+// of the upload, or the zero time if there is no deadline
+func uploadDeadline(cfg UploadConfig, stats *UploadStats) time.Time {
+	if cfg.UploadDeadline <= 0 {
+		return time.Time{}
+	}
+	start := stats.StartTime
+	if start.IsZero() {
+		start = time.Now()
+	}
+	return start.Add(cfg.UploadDeadline)
+}
+
+// requestTimeout splits the budget left before deadline evenly across the rounds
+// of requests still to run, so a slow farmer can't use up time the remaining
+// shards need. pending counts the shard about to start. Returns false once the
+// deadline has passed.
+func requestTimeout(deadline time.Time, pending, parallelism int) (time.Duration, bool) {
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return 0, false
+	}
+	rounds := (pending + parallelism - 1) / parallelism
+	if rounds < 1 {
+		rounds = 1
+	}
+	return remaining / time.Duration(rounds), true
+}
+
+// dataShardsFirst returns the shards reordered so every chunk's first DataShards
+// shards come before any chunk's parity shards; a chunk is recoverable as soon as
+// DataShards of its shards are stored, whichever they are.
+func dataShardsFirst(shards []chunker.Shard) []chunker.Shard {
+	ordered := make([]chunker.Shard, len(shards))
+	copy(ordered, shards)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].ShardIndex < chunker.DataShards && ordered[j].ShardIndex >= chunker.DataShards
+	})
+	return ordered
+}
+
+// checkRedundancy ensures every chunk has at least DataShards shards stored,
+// or all TotalShards when requireFull is set
+func checkRedundancy(m *manifest.Manifest, stored map[int]int, requireFull bool) error {
+	need := chunker.DataShards
+	if requireFull {
+		need = chunker.TotalShards
+	}
+	for _, chunk := range m.Chunks {
+		if stored[chunk.Index] < need {
+			return fmt.Errorf("chunk %d: only %d/%d shards stored (need %d)",
+				chunk.Index, stored[chunk.Index], chunker.TotalShards, need)
+		}
+	}
+	return nil
+}
+
+// ShardSource returns the bytes of a shard, e.g. by re-reading a spill directory.
+// Returned data must hash to the manifest's ShardMeta.Hash, so it has to be the
+// shard exactly as originally produced: re-sharding the source file doesn't
+// reproduce it, since every chunk is encrypted under a fresh random nonce.
+type ShardSource func(chunkIndex, shardIndex int) ([]byte, error)
+
+// DistributeFromManifest resumes a partially distributed upload using only a saved manifest.
+// Each farmer is asked whether it already holds its assigned shards, cfg.Parallelism
+// checks at a time; a check that fails or times out (after 10s, or its share of
+// cfg.UploadDeadline) counts the shard as missing. Only missing shards are fetched from shardSource, verified
+// against the manifest and uploaded, a batch of cfg.Parallelism chunks at a time
+// so only that many chunks' shards are held in memory.
+func DistributeFromManifest(m *manifest.Manifest, shardSource ShardSource, cfg UploadConfig) (*UploadStats, error) {
+	stats := &UploadStats{
+		StartTime: time.Now(),
+		Errors:    make([]error, 0),
+	}
+
+	if shardSource == nil {
+		return stats, fmt.Errorf("shard source is required")
+	}
+	for _, meta := range m.Shards {
+		if m.GetFarmerForShard(meta) == nil {
+			return stats, fmt.Errorf("chunk %d shard %d: farmer index %d not in manifest", meta.ChunkIndex, meta.ShardIndex, meta.FarmerIndex)
+		}
+	}
+	parallelism := cfg.Parallelism
+	if parallelism <= 0 {
+		parallelism = 4
+	}
+
+	// Find shards farmers already hold
+	present := shardsPresent(m, cfg, parallelism, stats)
+	stored := make(map[int]int)
+	missing := make(map[int][]manifest.ShardMeta) // chunk index → shards to upload
+	var chunkOrder []int
+	for i, meta := range m.Shards {
+		if present[i] {
+			stored[meta.ChunkIndex]++
+			continue
+		}
+		if len(missing[meta.ChunkIndex]) == 0 {
+			chunkOrder = append(chunkOrder, meta.ChunkIndex)
+		}
+		missing[meta.ChunkIndex] = append(missing[meta.ChunkIndex], meta)
+	}
+
+	// Upload only what's missing, loading a batch of chunks' shards at a time
+	for start := 0; start < len(chunkOrder); start += parallelism {
+		var batch []chunker.Shard
+		for _, chunkIndex := range chunkOrder[start:min(start+parallelism, len(chunkOrder))] {
+			for _, meta := range missing[chunkIndex] {
+				// Load and verify the missing shard before sending it anywhere
+				data, err := shardSource(meta.ChunkIndex, meta.ShardIndex)
+				if err != nil {
+					return stats, fmt.Errorf("chunk %d shard %d: failed to load shard: %w", meta.ChunkIndex, meta.ShardIndex, err)
+				}
+				if len(data) != meta.Size || !chunker.VerifyShard(data, meta.Hash) {
+					return stats, fmt.Errorf("chunk %d shard %d: source data does not match manifest", meta.ChunkIndex, meta.ShardIndex)
+				}
+				batch = append(batch, chunker.Shard{
+					ChunkIndex: meta.ChunkIndex,
+					ShardIndex: meta.ShardIndex,
+					Data:       data,
+					Hash:       meta.Hash,
+					Size:       meta.Size,
+				})
+			}
+		}
+		stats.ShardsCreated += len(batch)
+
+		uploaded := uploadShardsParallel(m, batch, m.Farmers, cfg, stats)
+		for chunkIndex, n := range uploaded {
+			stored[chunkIndex] += n
+		}
+	}
+	stats.recordRedundancy(m, stored)
+
+	stats.EndTime = time.Now()
+	if err := checkRedundancy(m, stored, cfg.RequireFullRedundancy); err != nil {
+		return stats, err
+	}
+
+	return stats, nil
+}
+
+// shardsPresent asks each shard's farmer whether it already holds the shard,
+// parallelism requests at a time. With cfg.UploadDeadline set, each check gets a
+// fair share of the budget left, counting an upload after every check, so a hung
+// farmer can't use up the time the uploads need. Returns one entry per m.Shards
+// entry. A failed or timed out check is recorded in stats and counts as missing:
+// re-uploading is safer than risking a hole.
+func shardsPresent(m *manifest.Manifest, cfg UploadConfig, parallelism int, stats *UploadStats) []bool {
+	deadline := uploadDeadline(cfg, stats)
+	var pending atomic.Int64 // checks not yet started, plus the uploads they may lead to
+	pending.Store(int64(2 * len(m.Shards)))
+
+	present := make([]bool, len(m.Shards))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < parallelism; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				meta := m.Shards[i]
+				ctx, cancel := context.Background(), func() {}
+				if !deadline.IsZero() {
+					timeout, ok := requestTimeout(deadline, int(pending.Add(-1))+1, parallelism)
+					if !ok {
+						stats.addError(fmt.Errorf("chunk %d shard %d: upload deadline passed before its existence check", meta.ChunkIndex, meta.ShardIndex))
+						continue
+					}
+					ctx, cancel = context.WithTimeout(ctx, timeout)
+				}
+				exists, err := shardExists(ctx, cfg, m.GetFarmerForShard(meta).Endpoint, m.BlobID, meta.ChunkIndex, meta.ShardIndex)
+				cancel()
+				if err != nil {
+					stats.addError(fmt.Errorf("chunk %d shard %d: existence check failed: %w", meta.ChunkIndex, meta.ShardIndex, err))
+				}
+				present[i] = exists
+			}
+		}()
+	}
+	for i := range m.Shards {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return present
+}
@@ -0,0 +1,992 @@
+package publisher
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/chunker"
+	"github.com/Abhinav-kodes/dbxn/pkg/manifest"
+)
+
+// ============================================================================
+// MOCK FARMER
+// ============================================================================
+
+// mockFarmer is an in-memory farmer speaking the shard upload and fetch protocol
+type mockFarmer struct {
+	mu      sync.Mutex
+	shards  map[string][]byte // "blob/chunk/shard" → data
+	pins    map[string][]byte // blob ID → sealed manifest
+	badHash bool              // confirm uploads with a wrong hash
+	delay   time.Duration     // stall each upload this long before storing it
+	status  int               // answer every shard upload with this status instead (0 = accept)
+	flaky   int               // answer this many shard uploads with 503 before accepting
+	server  *httptest.Server
+
+	multipart map[string]*mockMultipart // upload ID → upload in progress
+	parts     int                       // multipart parts received
+}
+
+// mockMultipart is a shard being uploaded in parts
+type mockMultipart struct {
+	init MultipartInitRequest
+	data []byte
+	got  []bool // bytes written so far
+}
+
+func newMockFarmer() *mockFarmer {
+	f := &mockFarmer{shards: make(map[string][]byte), pins: make(map[string][]byte), multipart: make(map[string]*mockMultipart)}
+	f.server = httptest.NewServer(http.HandlerFunc(f.handle))
+	return f
+}
+
+func (f *mockFarmer) handle(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == shardUploadPath:
+		var req ShardUploadRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		status := f.status
+		if status == 0 && f.flaky > 0 {
+			f.flaky--
+			status = http.StatusServiceUnavailable
+		}
+		f.mu.Unlock()
+		if status != 0 {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		if f.delay > 0 {
+			select {
+			case <-time.After(f.delay):
+			case <-r.Context().Done():
+				return // client gave up; nothing stored
+			}
+		}
+		f.mu.Lock()
+		f.shards[manifest.ShardAddress(req.BlobID, req.ChunkIndex, req.ShardIndex)] = req.Data
+		confirmed := req.Hash
+		if f.badHash {
+			confirmed = strings.Repeat("0", len(req.Hash))
+		}
+		f.mu.Unlock()
+		json.NewEncoder(w).Encode(ShardUploadResponse{Status: "ok", Hash: confirmed})
+
+	case r.Method == http.MethodPost && r.URL.Path == shardMultipartPath:
+		var req MultipartInitRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		id := fmt.Sprintf("upload-%d", len(f.multipart))
+		f.multipart[id] = &mockMultipart{init: req, data: make([]byte, req.Size), got: make([]bool, req.Size)}
+		f.mu.Unlock()
+		json.NewEncoder(w).Encode(MultipartInitResponse{UploadID: id})
+
+	case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, shardMultipartPath+"/"):
+		id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, shardMultipartPath+"/"), "/")
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		up, ok := f.multipart[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch action {
+		case "parts":
+			var part MultipartPartRequest
+			if err := json.NewDecoder(r.Body).Decode(&part); err != nil || part.Offset < 0 || part.Offset+len(part.Data) > len(up.data) {
+				http.Error(w, "bad part", http.StatusBadRequest)
+				return
+			}
+			copy(up.data[part.Offset:], part.Data)
+			for i := range part.Data {
+				up.got[part.Offset+i] = true
+			}
+			f.parts++
+		case "complete":
+			for _, ok := range up.got {
+				if !ok {
+					http.Error(w, "missing parts", http.StatusBadRequest)
+					return
+				}
+			}
+			if !chunker.VerifyShard(up.data, up.init.Hash) {
+				http.Error(w, "hash mismatch", http.StatusBadRequest)
+				return
+			}
+			f.shards[manifest.ShardAddress(up.init.BlobID, up.init.ChunkIndex, up.init.ShardIndex)] = up.data
+			delete(f.multipart, id)
+			json.NewEncoder(w).Encode(ShardUploadResponse{Status: "ok", Hash: up.init.Hash})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+
+	case r.Method == http.MethodPost && r.URL.Path == manifest.PinPath:
+		var req ManifestPinRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		f.pins[req.BlobID] = req.Data
+		f.mu.Unlock()
+		json.NewEncoder(w).Encode(ShardUploadResponse{Status: "ok", Hash: req.Hash})
+
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, manifest.PinPath+"/"):
+		f.mu.Lock()
+		data, ok := f.pins[strings.TrimPrefix(r.URL.Path, manifest.PinPath+"/")]
+		f.mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+
+	case (r.Method == http.MethodHead || r.Method == http.MethodGet) && strings.HasPrefix(r.URL.Path, shardUploadPath+"/"):
+		key := strings.TrimPrefix(r.URL.Path, shardUploadPath+"/")
+		f.mu.Lock()
+		data, ok := f.shards[key]
+		f.mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *mockFarmer) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.shards)
+}
+
+// newMockFleet starts n mock farmers and returns them with their endpoints
+func newMockFleet(t *testing.T, n int) ([]*mockFarmer, []string) {
+	t.Helper()
+	farmers := make([]*mockFarmer, n)
+	endpoints := make([]string, n)
+	for i := range farmers {
+		farmers[i] = newMockFarmer()
+		endpoints[i] = farmers[i].server.URL
+		t.Cleanup(farmers[i].server.Close)
+	}
+	return farmers, endpoints
+}
+
+// ============================================================================
+// PLACEMENT TESTS
+// ============================================================================
+
+func TestPlaceChunkShards_AvoidsSharedHosts(t *testing.T) {
+	// Seven endpoints but only six hosts: two entries point at one machine
+	endpoints := []string{
+		"http://a:8080", "http://a:9090", "http://b:8080", "http://c:8080",
+		"http://d:8080", "http://e:8080", "http://f:8080",
+	}
+	farmers := buildFarmerInfo(endpoints, nil)
+
+	for chunk := 0; chunk < len(endpoints); chunk++ {
+		placement, err := placeChunkShards(chunk, farmers, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hosts := make(map[string]bool)
+		for _, idx := range placement {
+			host := farmerHost(farmers[idx].Endpoint)
+			if hosts[host] {
+				t.Errorf("Chunk %d: two shards placed on host %s: %v", chunk, host, placement)
+			}
+			hosts[host] = true
+		}
+	}
+
+	// With too few hosts, sharing is still preferred over doubling up on one farmer
+	farmers = buildFarmerInfo(endpoints[:6], nil) // hosts a, a, b, c, d, e
+	placement, err := placeChunkShards(0, farmers, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	used := make(map[int]bool)
+	for _, idx := range placement {
+		if used[idx] {
+			t.Errorf("Farmer %d holds two shards of one chunk: %v", idx, placement)
+		}
+		used[idx] = true
+	}
+}
+
+func TestPlaceChunkShards_MinRegions(t *testing.T) {
+	// Eight farmers in three regions, clustered so rotation alone wouldn't spread them
+	endpoints := []string{
+		"http://a:8080", "http://b:8080", "http://c:8080", "http://d:8080",
+		"http://e:8080", "http://f:8080", "http://g:8080", "http://h:8080",
+	}
+	regions := map[string]string{
+		"http://a:8080": "us", "http://b:8080": "us", "http://c:8080": "us", "http://d:8080": "us",
+		"http://e:8080": "us", "http://f:8080": "us", "http://g:8080": "eu", "http://h:8080": "ap",
+	}
+	farmers := buildFarmerInfo(endpoints, regions)
+
+	for chunk := 0; chunk < len(endpoints); chunk++ {
+		placement, err := placeChunkShards(chunk, farmers, 3)
+		if err != nil {
+			t.Fatalf("Chunk %d: %v", chunk, err)
+		}
+		if len(placement) != chunker.TotalShards {
+			t.Fatalf("Chunk %d: expected %d shards placed, got %d", chunk, chunker.TotalShards, len(placement))
+		}
+		spanned := make(map[string]bool)
+		for _, idx := range placement {
+			spanned[farmers[idx].Region] = true
+		}
+		if len(spanned) < 3 {
+			t.Errorf("Chunk %d: placement %v spans %d regions, expected 3", chunk, placement, len(spanned))
+		}
+	}
+
+	// More regions than the pool has
+	if _, err := placeChunkShards(0, farmers, 4); err == nil || !strings.Contains(err.Error(), "MinRegions constraint unsatisfiable") {
+		t.Errorf("Expected an unsatisfiable error for 4 regions from 3, got %v", err)
+	}
+
+	// More regions than a chunk has shards, even with enough regions in the pool
+	many := make(map[string]string)
+	for i, endpoint := range endpoints {
+		many[endpoint] = fmt.Sprintf("region-%d", i)
+	}
+	if _, err := placeChunkShards(0, buildFarmerInfo(endpoints, many), chunker.TotalShards+1); err == nil ||
+		!strings.Contains(err.Error(), "MinRegions constraint unsatisfiable") {
+		t.Errorf("Expected an unsatisfiable error for more regions than shards, got %v", err)
+	}
+}
+
+// ============================================================================
+// REDUNDANCY TESTS
+// ============================================================================
+
+func TestDistributeShards_RequireFullRedundancy(t *testing.T) {
+	fleet, endpoints := newMockFleet(t, 6)
+
+	testFile := "test-redundancy.bin"
+	testData := make([]byte, chunker.ChunkSize+100)
+	rand.Read(testData)
+	if err := os.WriteFile(testFile, testData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+
+	key := make([]byte, 32)
+	rand.Read(key)
+	chunks, allShards, err := processFile(testFile, key, 0, "", chunkCipher{}, &UploadStats{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	farmers := buildFarmerInfo(endpoints, nil)
+	m, err := buildManifest(testFile, "filehash", chunks, allShards, farmers, key, "0xPub", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One farmer is gone: every chunk loses exactly one shard
+	fleet[5].server.Close()
+
+	lenient := &UploadStats{}
+	if err := distributeShardsParallel(m, allShards, farmers, UploadConfig{}, lenient); err != nil {
+		t.Fatalf("Expected success at DataShards redundancy, got %v", err)
+	}
+	for _, chunk := range m.Chunks {
+		if got := lenient.ChunkRedundancy[chunk.Index]; got != chunker.TotalShards-1 {
+			t.Errorf("Chunk %d: expected redundancy %d, got %d", chunk.Index, chunker.TotalShards-1, got)
+		}
+	}
+	if len(lenient.DegradedChunks()) != len(m.Chunks) {
+		t.Errorf("Expected all %d chunks degraded, got %v", len(m.Chunks), lenient.DegradedChunks())
+	}
+
+	strict := &UploadStats{}
+	err = distributeShardsParallel(m, allShards, farmers, UploadConfig{RequireFullRedundancy: true}, strict)
+	if err == nil {
+		t.Error("Expected failure with RequireFullRedundancy and a missing farmer")
+	}
+	if len(strict.ChunkRedundancy) != len(m.Chunks) {
+		t.Error("Expected redundancy to be reported even when failing")
+	}
+}
+
+func TestDistributeShards_Multipart(t *testing.T) {
+	fleet, endpoints := newMockFleet(t, 6)
+
+	testFile := "test-multipart.bin"
+	testData := make([]byte, chunker.ChunkSize+100)
+	rand.Read(testData)
+	if err := os.WriteFile(testFile, testData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+
+	key := make([]byte, 32)
+	rand.Read(key)
+	chunks, allShards, err := processFile(testFile, key, 0, "", chunkCipher{}, &UploadStats{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	farmers := buildFarmerInfo(endpoints, nil)
+	m, err := buildManifest(testFile, "filehash", chunks, allShards, farmers, key, "0xPub", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Full-chunk shards (~256KB) go in 64KB parts; the small last chunk's don't
+	cfg := UploadConfig{MultipartThreshold: 4096, MultipartPartSize: 64 * 1024}
+	stats := &UploadStats{}
+	if err := distributeShardsParallel(m, allShards, farmers, cfg, stats); err != nil {
+		t.Fatalf("Multipart distribution failed: %v", err)
+	}
+	if stats.ShardsUploaded != len(allShards) {
+		t.Errorf("Expected %d shards uploaded, got %d", len(allShards), stats.ShardsUploaded)
+	}
+
+	parts := 0
+	for _, f := range fleet {
+		f.mu.Lock()
+		parts += f.parts
+		f.mu.Unlock()
+	}
+	if parts == 0 {
+		t.Error("Expected large shards to be sent in parts")
+	}
+	for _, s := range allShards {
+		meta := m.GetShardsForChunk(s.ChunkIndex)[s.ShardIndex]
+		f := fleet[meta.FarmerIndex]
+		f.mu.Lock()
+		stored := f.shards[manifest.ShardAddress(m.BlobID, s.ChunkIndex, s.ShardIndex)]
+		f.mu.Unlock()
+		if !chunker.VerifyShard(stored, s.Hash) {
+			t.Errorf("Chunk %d shard %d: stored bytes don't match", s.ChunkIndex, s.ShardIndex)
+		}
+	}
+}
+
+func TestUploadShardMultipart_FarmerRejectsIncomplete(t *testing.T) {
+	fleet, endpoints := newMockFleet(t, 1)
+
+	data := make([]byte, 10000)
+	rand.Read(data)
+	shards, err := chunker.ShardChunk(chunker.Chunk{Size: len(data)}, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := ShardUploadRequest{BlobID: "blob", Data: shards[0].Data, Hash: shards[0].Hash, Size: shards[0].Size}
+
+	// A wrong hash is caught by the farmer on complete
+	bad := req
+	bad.Hash = strings.Repeat("0", 64)
+	if _, err := uploadShardMultipart(context.Background(), endpoints[0], nil, bad, 1000); err == nil {
+		t.Error("Expected complete to fail on hash mismatch")
+	}
+	if fleet[0].count() != 0 {
+		t.Error("Nothing should be stored after a failed complete")
+	}
+
+	if _, err := uploadShardMultipart(context.Background(), endpoints[0], nil, req, 1000); err != nil {
+		t.Fatalf("Multipart upload failed: %v", err)
+	}
+	if fleet[0].count() != 1 {
+		t.Error("Expected the shard stored after complete")
+	}
+}
+
+func TestDistributeShards_RejectsWrongConfirmedHash(t *testing.T) {
+	fleet, endpoints := newMockFleet(t, 6)
+
+	testFile := "test-confirm-hash.bin"
+	testData := make([]byte, 5000)
+	rand.Read(testData)
+	if err := os.WriteFile(testFile, testData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+
+	key := make([]byte, 32)
+	rand.Read(key)
+	chunks, allShards, err := processFile(testFile, key, 0, "", chunkCipher{}, &UploadStats{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	farmers := buildFarmerInfo(endpoints, nil)
+	m, err := buildManifest(testFile, "filehash", chunks, allShards, farmers, key, "0xPub", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Farmer 2 answers 200 but confirms a hash that isn't the shard's
+	fleet[2].badHash = true
+
+	stats := &UploadStats{}
+	if err := distributeShardsParallel(m, allShards, farmers, UploadConfig{}, stats); err != nil {
+		t.Fatalf("Expected success at DataShards redundancy, got %v", err)
+	}
+	if stats.ShardsUploaded != chunker.TotalShards-1 {
+		t.Errorf("Expected %d confirmed shards, got %d", chunker.TotalShards-1, stats.ShardsUploaded)
+	}
+	if len(stats.Errors) != 1 || !strings.Contains(stats.Errors[0].Error(), "confirmed hash") {
+		t.Errorf("Expected one hash confirmation error, got %v", stats.Errors)
+	}
+
+	strict := &UploadStats{}
+	if err := distributeShardsParallel(m, allShards, farmers, UploadConfig{RequireFullRedundancy: true}, strict); err == nil {
+		t.Error("Expected a wrongly confirmed shard to count against full redundancy")
+	}
+}
+
+func TestDistributeShards_RejectsShardNotMatchingManifest(t *testing.T) {
+	fleet, endpoints := newMockFleet(t, 6)
+
+	testFile := "test-verify-before-post.bin"
+	testData := make([]byte, 5000)
+	rand.Read(testData)
+	if err := os.WriteFile(testFile, testData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+
+	key := make([]byte, 32)
+	rand.Read(key)
+	chunks, allShards, err := processFile(testFile, key, 0, "", chunkCipher{}, &UploadStats{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	farmers := buildFarmerInfo(endpoints, nil)
+	m, err := buildManifest(testFile, "filehash", chunks, allShards, farmers, key, "0xPub", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt one shard after the manifest was built
+	tampered := make([]byte, len(allShards[3].Data))
+	copy(tampered, allShards[3].Data)
+	tampered[0] ^= 0xFF
+	allShards[3].Data = tampered
+
+	err = distributeShardsParallel(m, allShards, farmers, UploadConfig{}, &UploadStats{})
+	if err == nil {
+		t.Fatal("Expected error for shard not matching its manifest hash")
+	}
+	want := fmt.Sprintf("chunk %d shard %d", allShards[3].ChunkIndex, allShards[3].ShardIndex)
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("Expected error to name %q, got %v", want, err)
+	}
+	for i, f := range fleet {
+		if f.count() != 0 {
+			t.Errorf("Farmer %d received %d shards despite the mismatch", i, f.count())
+		}
+	}
+}
+
+func TestDistributeShards_UploadDeadline(t *testing.T) {
+	fleet, endpoints := newMockFleet(t, 6)
+
+	testFile := "test-upload-deadline.bin"
+	testData := make([]byte, 2*chunker.ChunkSize+100)
+	rand.Read(testData)
+	if err := os.WriteFile(testFile, testData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+
+	key := make([]byte, 32)
+	rand.Read(key)
+	chunks, allShards, err := processFile(testFile, key, 0, "", chunkCipher{}, &UploadStats{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	farmers := buildFarmerInfo(endpoints, nil)
+	m, err := buildManifest(testFile, "filehash", chunks, allShards, farmers, key, "0xPub", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One farmer stalls far beyond the budget; it holds one shard of every chunk
+	fleet[4].delay = 10 * time.Second
+	slow := 0
+	for _, meta := range m.Shards {
+		if meta.FarmerIndex == 4 {
+			slow++
+		}
+	}
+
+	stats := &UploadStats{StartTime: time.Now()}
+	cfg := UploadConfig{Parallelism: 6, UploadDeadline: 2 * time.Second}
+	start := time.Now()
+	if err := distributeShardsParallel(m, allShards, farmers, cfg, stats); err != nil {
+		t.Fatalf("Expected success at DataShards redundancy, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("Distribution took %s, deadline was %s", elapsed, cfg.UploadDeadline)
+	}
+	if stats.ShardsTimedOut != slow {
+		t.Errorf("Expected %d timed-out shards, got %d", slow, stats.ShardsTimedOut)
+	}
+	if stats.ShardsUploaded != len(allShards)-slow {
+		t.Errorf("Expected %d shards uploaded, got %d", len(allShards)-slow, stats.ShardsUploaded)
+	}
+	if len(stats.DegradedChunks()) != len(m.Chunks) {
+		t.Errorf("Expected all %d chunks degraded, got %v", len(m.Chunks), stats.DegradedChunks())
+	}
+
+	// A budget already spent abandons everything before it starts
+	expired := &UploadStats{StartTime: time.Now().Add(-time.Minute)}
+	if err := distributeShardsParallel(m, allShards, farmers, cfg, expired); err == nil {
+		t.Error("Expected failure when the deadline has already passed")
+	}
+	if expired.ShardsTimedOut != len(allShards) || expired.ShardsUploaded != 0 {
+		t.Errorf("Expected all %d shards timed out, got %d timed out and %d uploaded", len(allShards), expired.ShardsTimedOut, expired.ShardsUploaded)
+	}
+}
+
+func TestDataShardsFirst(t *testing.T) {
+	var shards []chunker.Shard
+	for c := 0; c < 3; c++ {
+		for s := 0; s < chunker.TotalShards; s++ {
+			shards = append(shards, chunker.Shard{ChunkIndex: c, ShardIndex: s})
+		}
+	}
+
+	ordered := dataShardsFirst(shards)
+	dataCount := 3 * chunker.DataShards
+	for i, shard := range ordered {
+		if isData := shard.ShardIndex < chunker.DataShards; isData != (i < dataCount) {
+			t.Fatalf("Position %d holds chunk %d shard %d", i, shard.ChunkIndex, shard.ShardIndex)
+		}
+	}
+	if shards[chunker.DataShards].ShardIndex != chunker.DataShards {
+		t.Error("Input slice should not be reordered")
+	}
+}
+
+// ============================================================================
+// CONCURRENCY TESTS
+// ============================================================================
+
+// Run with -race: many workers update the same UploadStats
+func TestDistributeShards_ConcurrentStatsExact(t *testing.T) {
+	fleet, endpoints := newMockFleet(t, 8)
+
+	testFile := "test-concurrent-stats.bin"
+	testData := make([]byte, 5*chunker.ChunkSize+77)
+	rand.Read(testData)
+	if err := os.WriteFile(testFile, testData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+
+	key := make([]byte, 32)
+	rand.Read(key)
+	chunks, allShards, err := processFile(testFile, key, 0, "", chunkCipher{}, &UploadStats{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	farmers := buildFarmerInfo(endpoints, nil)
+	m, err := buildManifest(testFile, "filehash", chunks, allShards, farmers, key, "0xPub", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One dead farmer so errors are appended concurrently too
+	fleet[7].server.Close()
+	failing := 0
+	var expectedBytes int64
+	for _, meta := range m.Shards {
+		if meta.FarmerIndex == 7 {
+			failing++
+		} else {
+			expectedBytes += int64(meta.Size)
+		}
+	}
+
+	stats := &UploadStats{}
+	if err := distributeShardsParallel(m, allShards, farmers, UploadConfig{Parallelism: 16}, stats); err != nil {
+		t.Fatalf("Distribution failed: %v", err)
+	}
+
+	if stats.ShardsUploaded != len(allShards)-failing {
+		t.Errorf("Expected %d shards uploaded, got %d", len(allShards)-failing, stats.ShardsUploaded)
+	}
+	if stats.LogicalBytes != expectedBytes {
+		t.Errorf("Expected %d shard bytes stored, got %d", expectedBytes, stats.LogicalBytes)
+	}
+	if stats.BytesUploaded != stats.WireBytes {
+		t.Errorf("Expected BytesUploaded to report wire bytes %d, got %d", stats.WireBytes, stats.BytesUploaded)
+	}
+	// Shards travel base64 encoded in JSON: at least 4/3 of their size on the wire
+	if stats.WireBytes < expectedBytes*4/3 {
+		t.Errorf("Expected at least %d wire bytes, got %d", expectedBytes*4/3, stats.WireBytes)
+	}
+	if len(stats.Errors) != failing {
+		t.Errorf("Expected %d errors, got %d", failing, len(stats.Errors))
+	}
+	if len(stats.FarmerDurations) != len(endpoints) {
+		t.Errorf("Expected durations for %d farmers, got %d", len(endpoints), len(stats.FarmerDurations))
+	}
+
+	stored := 0
+	for _, f := range fleet[:7] {
+		stored += f.count()
+	}
+	if stored != stats.ShardsUploaded {
+		t.Errorf("Fleet holds %d shards, stats report %d", stored, stats.ShardsUploaded)
+	}
+}
+
+// ============================================================================
+// RESUME DISTRIBUTION TESTS
+// ============================================================================
+
+func TestDistributeFromManifest_UploadsOnlyMissing(t *testing.T) {
+	fleet, endpoints := newMockFleet(t, 6)
+
+	testFile := "test-resume.bin"
+	testData := make([]byte, 2*chunker.ChunkSize+100)
+	rand.Read(testData)
+	if err := os.WriteFile(testFile, testData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+
+	key := make([]byte, 32)
+	rand.Read(key)
+
+	// Build shards and manifest as Upload would, without distributing
+	stats := &UploadStats{}
+	chunks, allShards, err := processFile(testFile, key, 0, "", chunkCipher{}, stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	farmers := buildFarmerInfo(endpoints, nil)
+	m, err := buildManifest(testFile, "filehash", chunks, allShards, farmers, key, "0xPub", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a crash: only chunk 0 made it to the farmers
+	var chunk0 []chunker.Shard
+	for _, s := range allShards {
+		if s.ChunkIndex == 0 {
+			chunk0 = append(chunk0, s)
+		}
+	}
+	uploadShardsParallel(m, chunk0, farmers, UploadConfig{Parallelism: 2}, &UploadStats{})
+
+	// Source serves shards from memory and records what was requested
+	byKey := make(map[shardKey][]byte)
+	for _, s := range allShards {
+		byKey[shardKey{s.ChunkIndex, s.ShardIndex}] = s.Data
+	}
+	var mu sync.Mutex
+	requested := 0
+	source := func(chunkIndex, shardIndex int) ([]byte, error) {
+		mu.Lock()
+		requested++
+		mu.Unlock()
+		return byKey[shardKey{chunkIndex, shardIndex}], nil
+	}
+
+	resumeStats, err := DistributeFromManifest(m, source, UploadConfig{Parallelism: 3})
+	if err != nil {
+		t.Fatalf("DistributeFromManifest failed: %v", err)
+	}
+
+	// Chunks 1 and 2 were missing: 12 shards
+	if requested != 2*chunker.TotalShards {
+		t.Errorf("Expected %d shards loaded from source, got %d", 2*chunker.TotalShards, requested)
+	}
+	if resumeStats.ShardsUploaded != 2*chunker.TotalShards {
+		t.Errorf("Expected %d shards uploaded, got %d", 2*chunker.TotalShards, resumeStats.ShardsUploaded)
+	}
+
+	if len(resumeStats.FarmerDurations) == 0 {
+		t.Error("Expected per-farmer upload durations to be recorded")
+	}
+
+	total := 0
+	for _, f := range fleet {
+		total += f.count()
+	}
+	if total != len(allShards) {
+		t.Errorf("Expected %d shards stored across fleet, got %d", len(allShards), total)
+	}
+}
+
+func TestDistributeFromManifest_UploadsInChunkBatches(t *testing.T) {
+	fleet, endpoints := newMockFleet(t, 6)
+
+	testFile := "test-resume-batches.bin"
+	testData := make([]byte, 2*chunker.ChunkSize+100)
+	rand.Read(testData)
+	if err := os.WriteFile(testFile, testData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+
+	key := make([]byte, 32)
+	rand.Read(key)
+	chunks, allShards, err := processFile(testFile, key, 0, "", chunkCipher{}, &UploadStats{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	farmers := buildFarmerInfo(endpoints, nil)
+	m, err := buildManifest(testFile, "filehash", chunks, allShards, farmers, key, "0xPub", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byKey := make(map[shardKey][]byte)
+	for _, s := range allShards {
+		byKey[shardKey{s.ChunkIndex, s.ShardIndex}] = s.Data
+	}
+	// With Parallelism 1, each chunk's shards are stored before the next is loaded
+	storedWhenLoaded := make(map[int]int)
+	source := func(chunkIndex, shardIndex int) ([]byte, error) {
+		if shardIndex == 0 {
+			total := 0
+			for _, f := range fleet {
+				total += f.count()
+			}
+			storedWhenLoaded[chunkIndex] = total
+		}
+		return byKey[shardKey{chunkIndex, shardIndex}], nil
+	}
+
+	stats, err := DistributeFromManifest(m, source, UploadConfig{Parallelism: 1})
+	if err != nil {
+		t.Fatalf("DistributeFromManifest failed: %v", err)
+	}
+	if stats.ShardsCreated != len(allShards) || stats.ShardsUploaded != len(allShards) {
+		t.Errorf("Expected %d shards loaded and uploaded, got %d and %d", len(allShards), stats.ShardsCreated, stats.ShardsUploaded)
+	}
+	for chunkIndex := range len(m.Chunks) {
+		if want := chunkIndex * chunker.TotalShards; storedWhenLoaded[chunkIndex] != want {
+			t.Errorf("Chunk %d: expected %d shards stored before it was loaded, got %d", chunkIndex, want, storedWhenLoaded[chunkIndex])
+		}
+	}
+}
+
+func TestDistributeFromManifest_ReorderedFarmers(t *testing.T) {
+	fleet, endpoints := newMockFleet(t, 6)
+
+	testFile := "test-resume-reordered.bin"
+	testData := make([]byte, chunker.ChunkSize+100)
+	rand.Read(testData)
+	if err := os.WriteFile(testFile, testData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+
+	key := make([]byte, 32)
+	rand.Read(key)
+	chunks, allShards, err := processFile(testFile, key, 0, "", chunkCipher{}, &UploadStats{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := buildManifest(testFile, "filehash", chunks, allShards, buildFarmerInfo(endpoints, nil), key, "0xPub", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Same farmers, listed in reverse: FarmerIndex still names FarmerInfo.Index
+	slices.Reverse(m.Farmers)
+
+	// Chunk 0 shard 0 is already on its farmer
+	byKey := make(map[shardKey][]byte)
+	for _, s := range allShards {
+		byKey[shardKey{s.ChunkIndex, s.ShardIndex}] = s.Data
+	}
+	first := m.Shards[0]
+	holder := fleet[first.FarmerIndex]
+	holder.mu.Lock()
+	holder.shards[manifest.ShardAddress(m.BlobID, first.ChunkIndex, first.ShardIndex)] = byKey[shardKey{first.ChunkIndex, first.ShardIndex}]
+	holder.mu.Unlock()
+
+	stats, err := DistributeFromManifest(m, func(chunkIndex, shardIndex int) ([]byte, error) {
+		return byKey[shardKey{chunkIndex, shardIndex}], nil
+	}, UploadConfig{})
+	if err != nil {
+		t.Fatalf("DistributeFromManifest failed: %v", err)
+	}
+	if stats.ShardsUploaded != len(m.Shards)-1 {
+		t.Errorf("Expected %d shards uploaded, got %d", len(m.Shards)-1, stats.ShardsUploaded)
+	}
+	for _, meta := range m.Shards {
+		addr := manifest.ShardAddress(m.BlobID, meta.ChunkIndex, meta.ShardIndex)
+		f := fleet[meta.FarmerIndex]
+		f.mu.Lock()
+		_, ok := f.shards[addr]
+		f.mu.Unlock()
+		if !ok {
+			t.Errorf("Chunk %d shard %d not stored on farmer %d", meta.ChunkIndex, meta.ShardIndex, meta.FarmerIndex)
+		}
+	}
+}
+
+func TestDistributeFromManifest_UnreachableFarmerTimesOut(t *testing.T) {
+	_, endpoints := newMockFleet(t, 6)
+	// Farmer 0 accepts connections but never answers
+	release := make(chan struct{})
+	hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer hung.Close()
+	defer close(release)
+	endpoints[0] = hung.URL
+
+	testFile := "test-resume-hung.bin"
+	testData := make([]byte, 3000)
+	rand.Read(testData)
+	if err := os.WriteFile(testFile, testData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+
+	key := make([]byte, 32)
+	rand.Read(key)
+	chunks, allShards, err := processFile(testFile, key, 0, "", chunkCipher{}, &UploadStats{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := buildManifest(testFile, "filehash", chunks, allShards, buildFarmerInfo(endpoints, nil), key, "0xPub", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byKey := make(map[shardKey][]byte)
+	for _, s := range allShards {
+		byKey[shardKey{s.ChunkIndex, s.ShardIndex}] = s.Data
+	}
+
+	start := time.Now()
+	stats, err := DistributeFromManifest(m, func(chunkIndex, shardIndex int) ([]byte, error) {
+		return byKey[shardKey{chunkIndex, shardIndex}], nil
+	}, UploadConfig{UploadDeadline: 500 * time.Millisecond})
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("Expected the deadline to bound the existence checks, took %s", elapsed)
+	}
+	// The other five farmers still hold enough shards
+	if err != nil {
+		t.Fatalf("DistributeFromManifest failed: %v", err)
+	}
+	if stats.ShardsCreated != len(m.Shards) {
+		t.Errorf("Expected every shard treated as missing and sent, got %d of %d", stats.ShardsCreated, len(m.Shards))
+	}
+	found := false
+	for _, e := range stats.Errors {
+		found = found || strings.Contains(e.Error(), "existence check failed")
+	}
+	if !found {
+		t.Error("Expected the timed out existence check to be recorded")
+	}
+}
+
+func TestDistributeFromManifest_RejectsMismatchedSource(t *testing.T) {
+	_, endpoints := newMockFleet(t, 6)
+
+	m := manifest.New("x.bin", 10, "h",
+		[]manifest.ChunkMeta{{Index: 0, Hash: "c0", Size: 10}},
+		[]manifest.ShardMeta{{ChunkIndex: 0, ShardIndex: 0, Hash: "deadbeef", Size: 4, FarmerIndex: 0}},
+		buildFarmerInfo(endpoints, nil), make([]byte, 32), "0xPub")
+
+	source := func(chunkIndex, shardIndex int) ([]byte, error) {
+		return []byte("nope"), nil
+	}
+
+	if _, err := DistributeFromManifest(m, source, UploadConfig{}); err == nil {
+		t.Error("Expected error when source data doesn't match manifest hash")
+	}
+}
+
+// ============================================================================
+// RESPONSE CLASSIFICATION TESTS
+// ============================================================================
+
+func TestDistributeShards_ClassifiesFarmerResponses(t *testing.T) {
+	fleet, endpoints := newMockFleet(t, 6)
+
+	testFile := "test-classify.bin"
+	testData := make([]byte, 3000)
+	rand.Read(testData)
+	if err := os.WriteFile(testFile, testData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+
+	key := make([]byte, 32)
+	rand.Read(key)
+	chunks, allShards, err := processFile(testFile, key, 0, "", chunkCipher{}, &UploadStats{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	farmers := buildFarmerInfo(endpoints, nil)
+	m, err := buildManifest(testFile, "filehash", chunks, allShards, farmers, key, "0xPub", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One farmer already holds its shard, one rejects it as too large, one is
+	// briefly overloaded and one stays overloaded
+	fleet[0].status = http.StatusConflict
+	fleet[1].status = http.StatusRequestEntityTooLarge
+	fleet[2].flaky = 2
+	fleet[3].status = http.StatusServiceUnavailable
+
+	stats := &UploadStats{}
+	stored := uploadShardsParallel(m, allShards, farmers, UploadConfig{MaxRetries: 2}, stats)
+	if stored[0] != 4 {
+		t.Errorf("Expected 4 shards stored, got %d", stored[0])
+	}
+
+	want := map[int]struct {
+		outcome  UploadOutcome
+		attempts int
+	}{
+		0: {UploadStored, 1},
+		1: {UploadPermanent, 1},
+		2: {UploadStored, 3},
+		3: {UploadRetryable, 3},
+		4: {UploadStored, 1},
+		5: {UploadStored, 1},
+	}
+	if len(stats.ShardResults) != len(allShards) {
+		t.Fatalf("Expected %d shard results, got %d", len(allShards), len(stats.ShardResults))
+	}
+	for _, res := range stats.ShardResults {
+		w := want[res.FarmerIndex]
+		if res.Outcome != w.outcome || res.Attempts != w.attempts {
+			t.Errorf("Farmer %d: expected %s after %d attempts, got %s after %d (%v)",
+				res.FarmerIndex, w.outcome, w.attempts, res.Outcome, res.Attempts, res.Err)
+		}
+	}
+	if stats.ShardRetries != 4 {
+		t.Errorf("Expected 4 retries, got %d", stats.ShardRetries)
+	}
+}
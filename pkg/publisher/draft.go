@@ -0,0 +1,181 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/chunker"
+	"github.com/Abhinav-kodes/dbxn/pkg/manifest"
+	"github.com/Abhinav-kodes/dbxn/pkg/transport"
+)
+
+// draftSuffix marks an interrupted upload's manifest next to its output path
+const draftSuffix = ".draft"
+
+// DraftPath is where UploadContext saves the draft manifest of an interrupted
+// upload whose manifest was to be saved at outputPath
+func DraftPath(outputPath string) string {
+	return outputPath + draftSuffix
+}
+
+// draftSpillDir holds a draft's pending shards, laid out as a local endpoint:
+// next to the draft, or in tempDir when one is set
+func draftSpillDir(draftPath, blobID, tempDir string) string {
+	if tempDir == "" {
+		return draftPath + ".shards"
+	}
+	return filepath.Join(tempDir, "draft-"+blobID+".shards")
+}
+
+// storedShards returns the shards stats records as stored on their farmer
+func storedShards(stats *UploadStats) map[shardKey]bool {
+	stored := make(map[shardKey]bool, len(stats.ShardResults))
+	for _, res := range stats.ShardResults {
+		if res.Outcome == UploadStored {
+			stored[shardKey{res.ChunkIndex, res.ShardIndex}] = true
+		}
+	}
+	return stored
+}
+
+// saveDraft records an interrupted upload: every shard stats doesn't show as
+// stored is marked Pending and spilled to disk (see draftSpillDir), then the
+// manifest is saved with Draft set. The manifest goes last so a draft only exists
+// once its shards do.
+func saveDraft(m *manifest.Manifest, shards []chunker.Shard, stats *UploadStats, draftPath, tempDir string) error {
+	stored := storedShards(stats)
+	draft := *m
+	draft.Draft = true
+	draft.Shards = append([]manifest.ShardMeta(nil), m.Shards...)
+	metas := make(map[shardKey]manifest.ShardMeta, len(draft.Shards))
+	for i, meta := range draft.Shards {
+		draft.Shards[i].Pending = !stored[shardKey{meta.ChunkIndex, meta.ShardIndex}]
+		metas[shardKey{meta.ChunkIndex, meta.ShardIndex}] = meta
+	}
+
+	spill := transport.LocalDir{Dir: draftSpillDir(draftPath, m.BlobID, tempDir)}
+	for _, shard := range shards {
+		key := shardKey{shard.ChunkIndex, shard.ShardIndex}
+		if stored[key] {
+			continue
+		}
+		addr := manifest.ShardAddress(m.BlobID, shard.ChunkIndex, shard.ShardIndex)
+		if err := spill.Put(context.Background(), addr, shard.Data, metas[key]); err != nil {
+			return fmt.Errorf("failed to spill chunk %d shard %d: %w", shard.ChunkIndex, shard.ShardIndex, err)
+		}
+	}
+	return draft.Save(draftPath)
+}
+
+// ResumeUpload finishes an upload interrupted by cancelling UploadContext. Only
+// the shards the draft at draftPath marks Pending are uploaded, read back from
+// where they were spilled (cfg.TempDir must match the interrupted upload's); the
+// farmers, key and placement come from the draft.
+// Once every chunk is stored as Upload requires, the manifest is finished
+// (pinned if cfg.PinManifest is set) and saved to cfg.OutputPath, by default the
+// path the interrupted upload would have used, and the draft and its spilled
+// shards are removed. Otherwise the draft is updated with what did get stored,
+// so ResumeUpload can be run again.
+func ResumeUpload(draftPath string, cfg UploadConfig) (*manifest.Manifest, *UploadStats, error) {
+	stats := &UploadStats{
+		StartTime: time.Now(),
+		Errors:    make([]error, 0),
+	}
+
+	if cfg.Parallelism == 0 {
+		cfg.Parallelism = 4
+	}
+	if cfg.OutputPath == "" {
+		cfg.OutputPath = draftPath[:len(draftPath)-len(draftSuffix)]
+		if len(draftPath) <= len(draftSuffix) || DraftPath(cfg.OutputPath) != draftPath {
+			return nil, stats, fmt.Errorf("invalid config: output path is required for draft %s", draftPath)
+		}
+	}
+	if cfg.OutputPath == draftPath {
+		return nil, stats, fmt.Errorf("invalid config: output path must differ from the draft")
+	}
+
+	m, err := manifest.Load(draftPath)
+	if err != nil {
+		return nil, stats, fmt.Errorf("failed to load draft: %w", err)
+	}
+	if !m.Draft {
+		return nil, stats, fmt.Errorf("%s is not an upload draft", draftPath)
+	}
+
+	spill := transport.LocalDir{Dir: draftSpillDir(draftPath, m.BlobID, cfg.TempDir)}
+	var pending []chunker.Shard
+	for _, meta := range m.PendingShards() {
+		data, err := spill.Get(context.Background(), manifest.ShardAddress(m.BlobID, meta.ChunkIndex, meta.ShardIndex))
+		if err != nil {
+			return nil, stats, fmt.Errorf("chunk %d shard %d: failed to load spilled shard: %w", meta.ChunkIndex, meta.ShardIndex, err)
+		}
+		pending = append(pending, chunker.Shard{
+			ChunkIndex: meta.ChunkIndex,
+			ShardIndex: meta.ShardIndex,
+			Data:       data,
+			Hash:       meta.Hash,
+			Size:       meta.Size,
+		})
+	}
+	if err := verifyShardsAgainstManifest(m, pending); err != nil {
+		return nil, stats, fmt.Errorf("draft shards don't match the manifest: %w", err)
+	}
+	stats.ShardsCreated = len(pending)
+
+	uploadStart := time.Now()
+	uploadShardsParallel(m, pending, m.Farmers, cfg, stats)
+	stats.UploadDuration = time.Since(uploadStart)
+
+	stored := make(map[int]int)
+	newlyStored := storedShards(stats)
+	for i, meta := range m.Shards {
+		if newlyStored[shardKey{meta.ChunkIndex, meta.ShardIndex}] {
+			m.Shards[i].Pending = false
+		}
+		if !m.Shards[i].Pending {
+			stored[meta.ChunkIndex]++
+		}
+	}
+	stats.recordRedundancy(m, stored)
+	if err := checkRedundancy(m, stored, cfg.RequireFullRedundancy); err != nil {
+		if saveErr := m.Save(draftPath); saveErr != nil {
+			return nil, stats, fmt.Errorf("failed to distribute shards: %w; failed to update draft: %v", err, saveErr)
+		}
+		return nil, stats, fmt.Errorf("failed to distribute shards, draft kept: %w", err)
+	}
+
+	m.Draft = false
+	for i := range m.Shards {
+		m.Shards[i].Pending = false
+	}
+	if cfg.PinManifest {
+		key, err := resumeKey(m, cfg)
+		if err != nil {
+			return nil, stats, fmt.Errorf("failed to pin manifest: %w", err)
+		}
+		if err := pinManifest(m, key, cfg, stats); err != nil {
+			return nil, stats, fmt.Errorf("failed to pin manifest: %w", err)
+		}
+	}
+	if err := m.Save(cfg.OutputPath); err != nil {
+		return nil, stats, fmt.Errorf("failed to save manifest: %w", err)
+	}
+	os.Remove(draftPath)
+	os.RemoveAll(draftSpillDir(draftPath, m.BlobID, cfg.TempDir))
+
+	stats.EndTime = time.Now()
+	return m, stats, nil
+}
+
+// resumeKey returns a draft's encryption key: recorded in it, or derived from
+// cfg.Passphrase for passphrase uploads
+func resumeKey(m *manifest.Manifest, cfg UploadConfig) ([]byte, error) {
+	if m.KDF != nil {
+		return m.KeyFromPassphrase(cfg.Passphrase)
+	}
+	return m.GetEncryptionKey()
+}
@@ -0,0 +1,148 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/chunker"
+	"github.com/Abhinav-kodes/dbxn/pkg/manifest"
+	"github.com/Abhinav-kodes/dbxn/pkg/retriever"
+	"github.com/Abhinav-kodes/dbxn/pkg/transport"
+)
+
+// ============================================================================
+// INTERRUPTED UPLOAD TESTS
+// ============================================================================
+
+// interruptingSink stores shards until a shared budget runs out, then cancels
+// the upload and holds any further put until the cancellation lands
+type interruptingSink struct {
+	store  *memStore
+	budget *atomic.Int32
+	cancel context.CancelFunc
+}
+
+func (s *interruptingSink) Put(ctx context.Context, addr string, data []byte, meta manifest.ShardMeta) error {
+	if s.budget.Add(-1) < 0 {
+		s.cancel()
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return s.store.Put(ctx, addr, data, meta)
+}
+
+func TestUploadContext_ResumeAfterCancel(t *testing.T) {
+	testFile := "test-resume.bin"
+	testData := make([]byte, 2*chunker.ChunkSize+321)
+	rand.Read(testData)
+	if err := os.WriteFile(testFile, testData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+	manifestPath := "test-resume.json"
+	defer os.Remove(manifestPath)
+	draftPath := DraftPath(manifestPath)
+	defer os.Remove(draftPath)
+	tempDir := t.TempDir() // pending shards spill here
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	budget := &atomic.Int32{}
+	budget.Store(5)
+
+	var endpoints []string
+	stores := make(map[string]*memStore)
+	sinks := make(map[string]transport.ShardSink)
+	sources := make(map[string]transport.ShardSource)
+	for i := 0; i < chunker.TotalShards; i++ {
+		endpoint := fmt.Sprintf("mem://farmer-%d", i)
+		store := &memStore{shards: make(map[string][]byte)}
+		endpoints = append(endpoints, endpoint)
+		stores[endpoint] = store
+		sinks[endpoint] = &interruptingSink{store: store, budget: budget, cancel: cancel}
+		sources[endpoint] = store
+	}
+
+	_, _, err := UploadContext(ctx, UploadConfig{
+		FilePath:        testFile,
+		FarmerEndpoints: endpoints,
+		OutputPath:      manifestPath,
+		ShardSinks:      sinks,
+		Parallelism:     1,
+		TempDir:         tempDir,
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected cancelled upload, got %v", err)
+	}
+	if _, err := os.Stat(manifestPath); !os.IsNotExist(err) {
+		t.Error("Interrupted upload must not save a complete manifest")
+	}
+
+	draft, err := manifest.Load(draftPath)
+	if err != nil {
+		t.Fatalf("Draft not saved: %v", err)
+	}
+	if !draft.Draft {
+		t.Error("Draft manifest not marked as a draft")
+	}
+	spillDir := draftSpillDir(draftPath, draft.BlobID, tempDir)
+	if _, err := os.Stat(spillDir); err != nil {
+		t.Errorf("Expected pending shards spilled to TempDir: %v", err)
+	}
+	if _, err := os.Stat(draftPath + ".shards"); !os.IsNotExist(err) {
+		t.Error("Pending shards must not spill next to the draft when TempDir is set")
+	}
+	pending := len(draft.PendingShards())
+	if pending != len(draft.Shards)-5 {
+		t.Errorf("Expected %d pending shards, got %d", len(draft.Shards)-5, pending)
+	}
+	if _, err := retriever.Open(draft, retriever.DownloadConfig{ShardSources: sources}); err == nil {
+		t.Error("Open should refuse a draft manifest")
+	}
+
+	// Resume with sinks that no longer interrupt
+	resumeSinks := make(map[string]transport.ShardSink)
+	for endpoint, store := range stores {
+		resumeSinks[endpoint] = store
+	}
+	m, stats, err := ResumeUpload(draftPath, UploadConfig{ShardSinks: resumeSinks, TempDir: tempDir})
+	if err != nil {
+		t.Fatalf("ResumeUpload failed: %v", err)
+	}
+	if stats.ShardsUploaded != pending {
+		t.Errorf("Expected only the %d pending shards uploaded, got %d", pending, stats.ShardsUploaded)
+	}
+	if m.Draft || len(m.PendingShards()) != 0 {
+		t.Error("Finished manifest still marked as a draft")
+	}
+	if _, err := os.Stat(draftPath); !os.IsNotExist(err) {
+		t.Error("Draft should be removed once the upload is finished")
+	}
+	if _, err := os.Stat(spillDir); !os.IsNotExist(err) {
+		t.Error("Spilled shards should be removed once the upload is finished")
+	}
+
+	saved, err := manifest.Load(manifestPath)
+	if err != nil {
+		t.Fatalf("Manifest not saved at the original output path: %v", err)
+	}
+	reader, err := retriever.Open(saved, retriever.DownloadConfig{ShardSources: sources})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	got, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Download after resume failed: %v", err)
+	}
+	if !bytes.Equal(got, testData) {
+		t.Error("Downloaded data doesn't match original")
+	}
+}
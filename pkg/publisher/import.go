@@ -0,0 +1,177 @@
+package publisher
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/chunker"
+	"github.com/Abhinav-kodes/dbxn/pkg/manifest"
+)
+
+// maxArchiveManifest caps the manifest entry ImportBlob will read from an archive
+const maxArchiveManifest = 256 << 20 // 256MB
+
+// ImportBlob uploads a blob archive written by retriever.ExportBlob to the farmers
+// in cfg.FarmerEndpoints. Shards are placed on the new fleet as Upload would place
+// them (honouring cfg.FarmerRegions and cfg.MinRegions) and the manifest's farmer
+// list is replaced, so the blob no longer depends on its original farmers. The blob
+// ID, key and chunk metadata are kept: shards are uploaded exactly as archived.
+//
+// The archive must hold every shard the manifest lists, each matching its recorded
+// hash. The rewritten manifest is saved to cfg.OutputPath.
+func ImportBlob(archivePath string, cfg UploadConfig) (*manifest.Manifest, *UploadStats, error) {
+	stats := &UploadStats{
+		StartTime: time.Now(),
+		Errors:    make([]error, 0),
+	}
+
+	if cfg.Parallelism == 0 {
+		cfg.Parallelism = 4
+	}
+	cfg, err := withLocalShardDir(cfg)
+	if err != nil {
+		return nil, stats, fmt.Errorf("invalid config: %w", err)
+	}
+	if err := validateOptions(cfg); err != nil {
+		return nil, stats, fmt.Errorf("invalid config: %w", err)
+	}
+
+	m, shards, err := readBlobArchive(archivePath)
+	if err != nil {
+		return nil, stats, fmt.Errorf("failed to read %s: %w", archivePath, err)
+	}
+	if m.TotalShards != chunker.TotalShards {
+		return nil, stats, fmt.Errorf("blob uses %d shards per chunk, only %d can be placed", m.TotalShards, chunker.TotalShards)
+	}
+
+	// Re-place every chunk on the new fleet
+	farmers := buildFarmerInfo(cfg.FarmerEndpoints, cfg.FarmerRegions)
+	placements := make(map[int][]int, len(m.Chunks))
+	for _, chunk := range m.Chunks {
+		placement, err := placeChunkShards(chunk.Index, farmers, cfg.MinRegions)
+		if err != nil {
+			return nil, stats, fmt.Errorf("failed to place chunk %d: %w", chunk.Index, err)
+		}
+		placements[chunk.Index] = placement
+	}
+	for i, shard := range m.Shards {
+		placement, ok := placements[shard.ChunkIndex]
+		if !ok || shard.ShardIndex < 0 || shard.ShardIndex >= len(placement) {
+			return nil, stats, fmt.Errorf("chunk %d shard %d: not a shard of any listed chunk", shard.ChunkIndex, shard.ShardIndex)
+		}
+		m.Shards[i].FarmerIndex = placement[shard.ShardIndex]
+	}
+	m.Farmers = farmers
+	m.MinRegions = cfg.MinRegions
+	for i := range m.Chunks {
+		m.Chunks[i].Regions = m.RegionSpread(m.Chunks[i].Index)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, stats, fmt.Errorf("imported manifest is invalid: %w", err)
+	}
+
+	uploadStart := time.Now()
+	err = distributeShardsParallel(m, shards, farmers, cfg, stats)
+	stats.UploadDuration = time.Since(uploadStart)
+	if err != nil {
+		return nil, stats, fmt.Errorf("failed to distribute shards: %w", err)
+	}
+	if cfg.PinManifest {
+		key, err := m.GetEncryptionKey()
+		if err != nil {
+			return nil, stats, fmt.Errorf("failed to pin manifest: %w", err)
+		}
+		if err := pinManifest(m, key, cfg, stats); err != nil {
+			return nil, stats, fmt.Errorf("failed to pin manifest: %w", err)
+		}
+	}
+
+	if err := m.Save(cfg.OutputPath); err != nil {
+		return nil, stats, fmt.Errorf("failed to save manifest: %w", err)
+	}
+
+	stats.EndTime = time.Now()
+	return m, stats, nil
+}
+
+// readBlobArchive loads the manifest and every shard from a blob archive. Shards
+// are checked against the manifest as they are read.
+func readBlobArchive(path string) (*manifest.Manifest, []chunker.Shard, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	tr := tar.NewReader(f)
+
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read manifest entry: %w", err)
+	}
+	if hdr.Name != manifest.ArchiveManifestName {
+		return nil, nil, fmt.Errorf("first entry is %q, expected %s", hdr.Name, manifest.ArchiveManifestName)
+	}
+	m, err := manifest.LoadLimited(tr, maxArchiveManifest)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	metas := make(map[shardKey]manifest.ShardMeta, len(m.Shards))
+	for _, meta := range m.Shards {
+		metas[shardKey{meta.ChunkIndex, meta.ShardIndex}] = meta
+	}
+	shards := make([]chunker.Shard, 0, len(m.Shards))
+	seen := make(map[shardKey]bool, len(m.Shards))
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return nil, nil, fmt.Errorf("%s: not a regular file", hdr.Name)
+		}
+		blobID, chunkIndex, shardIndex, err := manifest.ParseArchiveShardPath(hdr.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+		key := shardKey{chunkIndex, shardIndex}
+		meta, ok := metas[key]
+		if blobID != m.BlobID || !ok {
+			return nil, nil, fmt.Errorf("%s: shard not in manifest", hdr.Name)
+		}
+		if seen[key] {
+			return nil, nil, fmt.Errorf("%s: shard archived twice", hdr.Name)
+		}
+		if hdr.Size != int64(meta.Size) {
+			return nil, nil, fmt.Errorf("%s: expected %d bytes, archive holds %d", hdr.Name, meta.Size, hdr.Size)
+		}
+
+		data := make([]byte, meta.Size)
+		if _, err := io.ReadFull(tr, data); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", hdr.Name, err)
+		}
+		if !chunker.VerifyShard(data, meta.Hash) {
+			return nil, nil, fmt.Errorf("%s: data does not match manifest hash", hdr.Name)
+		}
+		seen[key] = true
+		shards = append(shards, chunker.Shard{
+			ChunkIndex: chunkIndex,
+			ShardIndex: shardIndex,
+			Data:       data,
+			Hash:       meta.Hash,
+			Size:       meta.Size,
+		})
+	}
+
+	if missing := len(m.Shards) - len(shards); missing > 0 {
+		return nil, nil, fmt.Errorf("archive is missing %d of %d shards", missing, len(m.Shards))
+	}
+	return m, shards, nil
+}
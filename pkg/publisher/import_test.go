@@ -0,0 +1,138 @@
+package publisher
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/chunker"
+	"github.com/Abhinav-kodes/dbxn/pkg/retriever"
+)
+
+// ============================================================================
+// BLOB ARCHIVE TESTS
+// ============================================================================
+
+func TestImportBlob_MovesBlobToNewFleet(t *testing.T) {
+	oldFleet, oldEndpoints := newMockFleet(t, 6)
+	newFleet, newEndpoints := newMockFleet(t, 7)
+
+	testFile := "test-import-blob.bin"
+	testData := make([]byte, chunker.ChunkSize+777)
+	rand.Read(testData)
+	if err := os.WriteFile(testFile, testData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+	manifestPath := "test-import-blob.json"
+	defer os.Remove(manifestPath)
+	archivePath := "test-import-blob.tar"
+	defer os.Remove(archivePath)
+
+	m, _, err := Upload(UploadConfig{
+		FilePath:        testFile,
+		FarmerEndpoints: oldEndpoints,
+		OutputPath:      manifestPath,
+	})
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if err := retriever.ExportBlob(m, archivePath, retriever.DownloadConfig{}); err != nil {
+		t.Fatalf("ExportBlob failed: %v", err)
+	}
+
+	// The original fleet is gone
+	for _, f := range oldFleet {
+		f.server.Close()
+	}
+
+	imported, stats, err := ImportBlob(archivePath, UploadConfig{
+		FarmerEndpoints: newEndpoints,
+		OutputPath:      manifestPath,
+	})
+	if err != nil {
+		t.Fatalf("ImportBlob failed: %v", err)
+	}
+	if imported.BlobID != m.BlobID {
+		t.Errorf("Expected blob ID %s to be kept, got %s", m.BlobID, imported.BlobID)
+	}
+	if stats.ShardsUploaded != len(m.Shards) {
+		t.Errorf("Expected %d shards uploaded, got %d", len(m.Shards), stats.ShardsUploaded)
+	}
+	stored := 0
+	for _, f := range newFleet {
+		stored += f.count()
+	}
+	if stored != len(m.Shards) {
+		t.Errorf("Expected %d shards on the new fleet, got %d", len(m.Shards), stored)
+	}
+	for _, f := range imported.Farmers {
+		if slices.Contains(oldEndpoints, f.Endpoint) {
+			t.Errorf("Farmer %d still points at %s", f.Index, f.Endpoint)
+		}
+	}
+
+	reader, err := retriever.Open(imported, retriever.DownloadConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	got, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Download from new fleet failed: %v", err)
+	}
+	if !bytes.Equal(got, testData) {
+		t.Error("Downloaded data doesn't match original")
+	}
+}
+
+func TestImportBlob_RejectsDamagedArchive(t *testing.T) {
+	_, endpoints := newMockFleet(t, 6)
+
+	testFile := "test-import-damaged.bin"
+	testData := make([]byte, 5000)
+	rand.Read(testData)
+	if err := os.WriteFile(testFile, testData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+	manifestPath := "test-import-damaged.json"
+	defer os.Remove(manifestPath)
+	archivePath := "test-import-damaged.tar"
+	defer os.Remove(archivePath)
+
+	m, _, err := Upload(UploadConfig{
+		FilePath:        testFile,
+		FarmerEndpoints: endpoints,
+		OutputPath:      manifestPath,
+	})
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if err := retriever.ExportBlob(m, archivePath, retriever.DownloadConfig{}); err != nil {
+		t.Fatalf("ExportBlob failed: %v", err)
+	}
+
+	// Flip the last byte of the final shard's data (tar pads entries, then two zero blocks)
+	archive, err := os.ReadFile(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := m.Shards[len(m.Shards)-1]
+	shardData := archive[len(archive)-2*512-((last.Size+511)/512*512):]
+	shardData[last.Size-1] ^= 0xff
+	if err := os.WriteFile(archivePath, archive, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := ImportBlob(archivePath, UploadConfig{
+		FarmerEndpoints: endpoints,
+		OutputPath:      manifestPath,
+	}); err == nil || !strings.Contains(err.Error(), "does not match manifest hash") {
+		t.Errorf("Expected hash mismatch for damaged archive, got %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package publisher
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/manifest"
+	"github.com/Abhinav-kodes/dbxn/pkg/scratch"
+	"github.com/Abhinav-kodes/dbxn/pkg/transport"
+)
+
+// withLocalShardDir adds cfg.LocalShardDir to the farmer endpoints as a local
+// endpoint. The directory is made absolute so the manifest works from anywhere.
+func withLocalShardDir(cfg UploadConfig) (UploadConfig, error) {
+	if cfg.LocalShardDir == "" {
+		return cfg, nil
+	}
+	dir, err := filepath.Abs(cfg.LocalShardDir)
+	if err != nil {
+		return cfg, fmt.Errorf("local shard dir: %w", err)
+	}
+	cfg.FarmerEndpoints = append(append([]string(nil), cfg.FarmerEndpoints...), manifest.LocalEndpoint(dir))
+	return cfg, nil
+}
+
+// writeLocalPin stores a sealed manifest under a local endpoint's directory
+func writeLocalPin(dir string, req ManifestPinRequest) error {
+	path, err := manifest.LocalPinPath(dir, req.BlobID)
+	if err != nil {
+		return err
+	}
+	return transport.WriteFileAtomic(path, req.Data)
+}
+
+// sweepStaleTemp removes scratch temp files crashed runs left in cfg.TempDir and
+// below the local endpoints' directories, where LocalDir writes its temp files
+// beside each shard
+func sweepStaleTemp(cfg UploadConfig) {
+	removed, _ := scratch.CleanupStale(cfg.TempDir, scratch.StaleAge)
+	for _, endpoint := range cfg.FarmerEndpoints {
+		if dir, ok := manifest.LocalEndpointDir(endpoint); ok {
+			n, _ := scratch.CleanupStaleTree(dir, scratch.StaleAge)
+			removed += n
+		}
+	}
+	if removed > 0 {
+		fmt.Printf("🧹 Removed %d stale temp files\n", removed)
+	}
+}
@@ -0,0 +1,183 @@
+package publisher
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/chunker"
+	"github.com/Abhinav-kodes/dbxn/pkg/manifest"
+	"github.com/Abhinav-kodes/dbxn/pkg/retriever"
+	"github.com/Abhinav-kodes/dbxn/pkg/scratch"
+)
+
+// ============================================================================
+// LOCAL SHARD DIRECTORY TESTS
+// ============================================================================
+
+func TestUpload_LocalShardDirOnly(t *testing.T) {
+	testFile := "test-local-shards.bin"
+	testData := make([]byte, chunker.ChunkSize+4321)
+	rand.Read(testData)
+	if err := os.WriteFile(testFile, testData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+	manifestPath := "test-local-shards.json"
+	defer os.Remove(manifestPath)
+	dir, err := os.MkdirTemp(".", "test-local-shards-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// No farmers at all: every shard is staged on disk
+	m, stats, err := Upload(UploadConfig{
+		FilePath:      testFile,
+		OutputPath:    manifestPath,
+		LocalShardDir: dir,
+		PinManifest:   true,
+	})
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if stats.ShardsUploaded != len(m.Shards) {
+		t.Errorf("Expected %d shards written, got %d", len(m.Shards), stats.ShardsUploaded)
+	}
+	if len(m.Farmers) != 1 {
+		t.Fatalf("Expected one local farmer, got %d", len(m.Farmers))
+	}
+	localDir, ok := manifest.LocalEndpointDir(m.Farmers[0].Endpoint)
+	if !ok {
+		t.Fatalf("Farmer endpoint %s is not local", m.Farmers[0].Endpoint)
+	}
+	for _, shard := range m.Shards {
+		path, err := manifest.LocalShardPath(localDir, m.BlobID, shard.ChunkIndex, shard.ShardIndex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || !chunker.VerifyShard(data, shard.Hash) {
+			t.Errorf("Chunk %d shard %d not stored at %s: %v", shard.ChunkIndex, shard.ShardIndex, path, err)
+		}
+	}
+
+	// Retrievers read local endpoints directly, pinned manifest included
+	key, _ := m.GetEncryptionKey()
+	recovered, err := retriever.FetchManifest(m.BlobID, []string{m.Farmers[0].Endpoint}, key)
+	if err != nil {
+		t.Fatalf("FetchManifest from local dir failed: %v", err)
+	}
+	reader, err := retriever.Open(recovered, retriever.DownloadConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	got, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Download from local dir failed: %v", err)
+	}
+	if !bytes.Equal(got, testData) {
+		t.Error("Downloaded data doesn't match original")
+	}
+
+	// Resuming from the manifest finds every local shard in place
+	resumed, err := DistributeFromManifest(m, func(int, int) ([]byte, error) {
+		t.Error("Shard source called for a shard already on disk")
+		return nil, os.ErrNotExist
+	}, UploadConfig{})
+	if err != nil || resumed.ShardsUploaded != 0 {
+		t.Errorf("Expected nothing to resume, got %d uploaded, %v", resumed.ShardsUploaded, err)
+	}
+}
+
+func TestUpload_SweepsStaleTempFiles(t *testing.T) {
+	testFile := "test-sweep.bin"
+	if err := os.WriteFile(testFile, []byte("sweep me"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+	manifestPath := "test-sweep.json"
+	defer os.Remove(manifestPath)
+	dir := t.TempDir()
+	tempDir := t.TempDir()
+
+	// Leftovers of a crashed run: in TempDir and beside an older blob's shard
+	nested := filepath.Join(dir, "old-blob", "0")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * scratch.StaleAge)
+	var stale []string
+	for _, d := range []string{tempDir, nested} {
+		f, err := scratch.Create(d, "local-*")
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		os.Chtimes(f.Name(), old, old)
+		stale = append(stale, f.Name())
+	}
+
+	if _, _, err := Upload(UploadConfig{FilePath: testFile, OutputPath: manifestPath, LocalShardDir: dir, TempDir: tempDir}); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	for _, path := range stale {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected stale temp file %s removed at startup", path)
+		}
+	}
+}
+
+func TestUpload_LocalShardDirAlongsideFarmers(t *testing.T) {
+	fleet, endpoints := newMockFleet(t, 5)
+
+	testFile := "test-hybrid-shards.bin"
+	testData := make([]byte, 5000)
+	rand.Read(testData)
+	if err := os.WriteFile(testFile, testData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+	manifestPath := "test-hybrid-shards.json"
+	defer os.Remove(manifestPath)
+	dir, err := os.MkdirTemp(".", "test-hybrid-shards-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m, _, err := Upload(UploadConfig{
+		FilePath:        testFile,
+		FarmerEndpoints: endpoints,
+		OutputPath:      manifestPath,
+		LocalShardDir:   dir,
+	})
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if len(m.Farmers) != 6 {
+		t.Fatalf("Expected 5 farmers plus the local dir, got %d", len(m.Farmers))
+	}
+	remote := 0
+	for _, f := range fleet {
+		remote += f.count()
+	}
+	if remote != chunker.TotalShards-1 {
+		t.Errorf("Expected %d shards on farmers and one on disk, got %d remote", chunker.TotalShards-1, remote)
+	}
+
+	reader, err := retriever.Open(m, retriever.DownloadConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	got, err := io.ReadAll(reader)
+	if err != nil || !bytes.Equal(got, testData) {
+		t.Errorf("Hybrid download failed: %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package publisher
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/farmer"
+	"github.com/Abhinav-kodes/dbxn/pkg/manifest"
+)
+
+// ManifestPinRequest is the JSON payload storing a sealed manifest on a farmer
+type ManifestPinRequest struct {
+	BlobID string `json:"blob_id"`
+	Data   []byte `json:"data"` // manifest sealed with manifest.Seal
+	Hash   string `json:"hash"` // SHA256 of Data
+}
+
+// pinManifest stores the manifest, sealed under encKey, on the first
+// cfg.PinManifestCopies farmers (all of them by default) so it can be recovered
+// with retriever.FetchManifest. Fails only if no farmer stored it; individual
+// failures are recorded in stats.
+func pinManifest(m *manifest.Manifest, encKey []byte, cfg UploadConfig, stats *UploadStats) error {
+	sealed, err := m.Seal(encKey)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(sealed)
+	req := ManifestPinRequest{BlobID: m.BlobID, Data: sealed, Hash: hex.EncodeToString(sum[:])}
+
+	targets := m.Farmers
+	if cfg.PinManifestCopies > 0 && cfg.PinManifestCopies < len(targets) {
+		targets = targets[:cfg.PinManifestCopies]
+	}
+	for _, f := range targets {
+		if err := uploadManifestPin(f.Endpoint, cfg.FarmerTokens, req); err != nil {
+			stats.addError(fmt.Errorf("manifest pin → farmer %d: %w", f.Index, err))
+			continue
+		}
+		stats.ManifestPins++
+	}
+	if stats.ManifestPins == 0 {
+		return fmt.Errorf("manifest pinned on none of %d farmers", len(targets))
+	}
+	return nil
+}
+
+// uploadManifestPin POSTs a sealed manifest to a farmer, which must confirm its hash
+func uploadManifestPin(endpoint string, tokens farmer.AuthTokens, req ManifestPinRequest) error {
+	if dir, ok := manifest.LocalEndpointDir(endpoint); ok {
+		return writeLocalPin(dir, req)
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, endpoint+manifest.PinPath, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	tokens.Apply(httpReq, endpoint)
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("farmer returned %s: %s", resp.Status, string(msg))
+	}
+
+	var pinResp ShardUploadResponse
+	if err := json.NewDecoder(resp.Body).Decode(&pinResp); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	if pinResp.Hash != req.Hash {
+		return fmt.Errorf("farmer confirmed hash %q, expected %s", pinResp.Hash, req.Hash)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package publisher
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/manifest"
+)
+
+// sniffLen is how many leading bytes http.DetectContentType considers
+const sniffLen = 512
+
+// sniffedExtensions gives the usual extension of the types http.DetectContentType
+// reports, for naming blobs uploaded without a file name
+var sniffedExtensions = map[string]string{
+	"text/plain":         ".txt",
+	"text/html":          ".html",
+	"text/xml":           ".xml",
+	"application/pdf":    ".pdf",
+	"application/zip":    ".zip",
+	"application/x-gzip": ".gz",
+	"application/wasm":   ".wasm",
+	"image/png":          ".png",
+	"image/jpeg":         ".jpg",
+	"image/gif":          ".gif",
+	"image/webp":         ".webp",
+	"image/bmp":          ".bmp",
+	"audio/mpeg":         ".mp3",
+	"audio/wave":         ".wav",
+	"video/mp4":          ".mp4",
+	"video/webm":         ".webm",
+	"font/woff2":         ".woff2",
+}
+
+// sniffContentType records the MIME type of a blob whose first bytes are data
+// in m.ContentType and, if the blob has no name, names it "blob" plus the
+// type's extension. Empty data has no type to detect and is left alone.
+func sniffContentType(m *manifest.Manifest, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	m.ContentType = http.DetectContentType(data[:min(len(data), sniffLen)])
+	if m.FileName != "" {
+		return
+	}
+	mediaType, _, err := mime.ParseMediaType(m.ContentType)
+	if ext := sniffedExtensions[mediaType]; err == nil && ext != "" {
+		m.FileName = "blob" + ext
+	}
+}
+
+// sniffFileContentType is sniffContentType over the start of the file at path
+func sniffFileContentType(m *manifest.Manifest, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to sniff content type: %w", err)
+	}
+	defer f.Close()
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return fmt.Errorf("failed to sniff content type: %w", err)
+	}
+	sniffContentType(m, head[:n])
+	return nil
+}
@@ -0,0 +1,283 @@
+package publisher
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/chunker"
+	"github.com/Abhinav-kodes/dbxn/pkg/crypto"
+	"github.com/Abhinav-kodes/dbxn/pkg/manifest"
+)
+
+// BlobWriter uploads everything written to it as one blob, so the pipeline composes
+// with io.Copy and io.Pipe instead of needing a file path. Written bytes are chunked,
+// encrypted, sharded and uploaded as each chunk fills; Close uploads the final partial
+// chunk and saves the manifest to cfg.OutputPath. Read the blob back as a stream with
+// retriever.Open, whose BlobReader is an io.Reader.
+//
+// Write blocks while a chunk is being uploaded. A BlobWriter is not safe for
+// concurrent Writes.
+type BlobWriter struct {
+	pw     *io.PipeWriter
+	cancel context.CancelFunc
+	key    []byte
+	m      *manifest.Manifest
+	stats  *UploadStats
+	cfg    UploadConfig
+
+	done    chan struct{} // closed when the pipeline has returned
+	err     error         // pipeline or save error, valid once done is closed
+	aborted error         // set by CloseWithError
+	finish  sync.Once
+}
+
+// NewBlobWriter starts a streaming upload encrypted under key (a new key is
+// generated if key is nil). cfg.FilePath is optional and only names the blob in
+// the manifest.
+func NewBlobWriter(key []byte, cfg UploadConfig) (*BlobWriter, error) {
+	if cfg.Parallelism == 0 {
+		cfg.Parallelism = 4
+	}
+	cfg, err := withLocalShardDir(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if err := validateOptions(cfg); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if key == nil {
+		var err error
+		if key, err = crypto.GenerateKey(); err != nil {
+			return nil, fmt.Errorf("failed to generate key: %w", err)
+		}
+	} else if len(key) != crypto.KeySize {
+		return nil, fmt.Errorf("invalid key size: expected %d, got %d", crypto.KeySize, len(key))
+	}
+
+	name := ""
+	if cfg.FilePath != "" {
+		name = filepath.Base(cfg.FilePath)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pr, pw := io.Pipe()
+	w := &BlobWriter{
+		pw:     pw,
+		cancel: cancel,
+		key:    key,
+		m:      newStreamManifest(name, key, cfg),
+		stats: &UploadStats{
+			StartTime: time.Now(),
+			Errors:    make([]error, 0),
+		},
+		cfg:  cfg,
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(w.done)
+		w.err = uploadStream(ctx, pr, w.m, key, cfg, w.stats)
+		// Unblocks and fails further Writes if the pipeline stopped early
+		if w.err != nil {
+			pr.CloseWithError(w.err)
+		} else {
+			pr.Close()
+		}
+	}()
+	return w, nil
+}
+
+// Write feeds p into the upload pipeline. It fails once the pipeline has failed.
+func (w *BlobWriter) Write(p []byte) (int, error) {
+	return w.pw.Write(p)
+}
+
+// Close uploads any buffered data, waits for all shards and saves the manifest.
+// Further calls return the same result.
+func (w *BlobWriter) Close() error {
+	w.pw.Close()
+	return w.wait()
+}
+
+// CloseWithError abandons the upload; the pipeline stops and no manifest is saved.
+// Shards already uploaded stay on their farmers.
+func (w *BlobWriter) CloseWithError(err error) error {
+	if err == nil {
+		err = fmt.Errorf("aborted by caller")
+	}
+	w.aborted = err
+	w.pw.CloseWithError(err)
+	w.cancel()
+	return w.wait()
+}
+
+// wait blocks until the pipeline has returned and finalizes the upload once
+func (w *BlobWriter) wait() error {
+	<-w.done
+	w.finish.Do(func() {
+		defer w.cancel()
+		if w.aborted != nil {
+			// The pipeline may have seen the cancellation before the abort error
+			w.err = fmt.Errorf("upload aborted: %w", w.aborted)
+			return
+		}
+		if w.err != nil {
+			return
+		}
+		if w.cfg.PinManifest {
+			if err := pinManifest(w.m, w.key, w.cfg, w.stats); err != nil {
+				w.err = fmt.Errorf("failed to pin manifest: %w", err)
+				return
+			}
+		}
+		if err := w.m.Save(w.cfg.OutputPath); err != nil {
+			w.err = fmt.Errorf("failed to save manifest: %w", err)
+			return
+		}
+		w.stats.EndTime = time.Now()
+	})
+	return w.err
+}
+
+// Manifest returns the blob's manifest. It is complete only after Close returns nil.
+func (w *BlobWriter) Manifest() *manifest.Manifest {
+	return w.m
+}
+
+// Stats returns upload statistics. They are final only after Close has returned.
+func (w *BlobWriter) Stats() *UploadStats {
+	return w.stats
+}
+
+// newStreamManifest creates the manifest for a blob uploaded as it is read, before
+// any chunk exists: chunks are uploaded under its BlobID as they're produced.
+func newStreamManifest(name string, encKey []byte, cfg UploadConfig) *manifest.Manifest {
+	farmers := buildFarmerInfo(cfg.FarmerEndpoints, cfg.FarmerRegions)
+	m := manifest.New(name, 0, "", nil, nil, farmers, encKey, cfg.PublisherAddress)
+	m.MinRegions = cfg.MinRegions
+	if cfg.UniformShardSize {
+		m.PaddedSize = chunker.ChunkSize + chunkOverhead(cfg)
+	}
+	m.ShardWrap = shardWrapName(cfg)
+	m.ShardFEC = cfg.ShardFEC
+	setPublisherKey(m, cfg.PublisherPublicKey)
+	m.ChunkHashDomain = cfg.ChunkHashDomain
+	m.ChunkCommitment = cfg.CommitChunks
+	m.ChunkAAD = cfg.BindChunkAAD
+	return m
+}
+
+// uploadStream reads r to EOF through the chunking pipeline, uploading each chunk's
+// shards while the next chunk is encrypted and sharded. At most one chunk uploads
+// at a time, so memory stays bounded by a few chunks. It fills in m's chunks,
+// shards (in manifest.SortShards order), size and hash and checks redundancy;
+// saving the manifest is left to the caller. Cancelling ctx stops the pipeline
+// between chunks.
+func uploadStream(ctx context.Context, r io.Reader, m *manifest.Manifest, encKey []byte, cfg UploadConfig, stats *UploadStats) error {
+	// Also releases the chunk producer if we return early
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	hasher := sha256.New()
+	stored := make(map[int]int) // chunk index → shards stored, written by the uploading goroutine
+
+	// uploading is closed once the previous chunk's shards are uploaded; every
+	// return waits for it so no upload outlives the call
+	var uploading chan struct{}
+	waitUpload := func() {
+		if uploading != nil {
+			<-uploading
+		}
+	}
+	defer waitUpload()
+
+	for result := range chunker.StreamChunkReader(ctx, io.TeeReader(r, hasher), chunker.Config{PrefetchBytes: cfg.ChunkPrefetchBytes}) {
+		if result.Err != nil {
+			return result.Err
+		}
+		chunk := result.Chunk
+		if cfg.SniffContentType && chunk.Index == 0 {
+			sniffContentType(m, chunk.Data)
+		}
+
+		meta, shards, err := encodeChunk(chunk, encKey, m.PaddedSize, cfg.ChunkHashDomain, manifestCipher(m), stats)
+		if err != nil {
+			return fmt.Errorf("failed to process chunk: %w", err)
+		}
+		if cfg.ShardWrapKey != nil {
+			if err := wrapShards(shards, cfg.ShardWrapAlgorithm, cfg.ShardWrapKey); err != nil {
+				return err
+			}
+		}
+		if cfg.ShardFEC != nil {
+			if err := encodeShardFEC(shards, cfg.ShardFEC); err != nil {
+				return err
+			}
+		}
+
+		placement, err := placeChunkShards(chunk.Index, m.Farmers, cfg.MinRegions)
+		if err != nil {
+			return fmt.Errorf("failed to build manifest: %w", err)
+		}
+		metas := make([]manifest.ShardMeta, len(shards))
+		for i, shard := range shards {
+			metas[i] = manifest.ShardMeta{
+				ChunkIndex:  shard.ChunkIndex,
+				ShardIndex:  shard.ShardIndex,
+				Hash:        shard.Hash,
+				Size:        shard.Size,
+				FarmerIndex: placement[shard.ShardIndex],
+			}
+		}
+		m.Chunks = append(m.Chunks, meta)
+		m.Shards = append(m.Shards, metas...)
+		m.FileSize += int64(chunk.Size)
+
+		// Upload against a view holding only this chunk's shards so assignment
+		// lookups don't grow with the blob; the shard data is dropped afterwards
+		view := *m
+		view.Shards = metas
+		if err := verifyShardsAgainstManifest(&view, shards); err != nil {
+			return fmt.Errorf("failed to distribute shards: %w", err)
+		}
+		waitUpload()
+		done := make(chan struct{})
+		uploading = done
+		go func() {
+			defer close(done)
+			uploadStart := time.Now()
+			stored[chunk.Index] = uploadShardsParallel(&view, shards, view.Farmers, cfg, stats)[chunk.Index]
+			stats.UploadDuration += time.Since(uploadStart)
+		}()
+	}
+	waitUpload()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Chunks are encoded in order, but the manifest mustn't depend on that
+	manifest.SortShards(m.Shards)
+	m.ChunkCount = len(m.Chunks)
+	m.OriginalFileHash = hex.EncodeToString(hasher.Sum(nil))
+	for i := range m.Chunks {
+		m.Chunks[i].Regions = m.RegionSpread(m.Chunks[i].Index)
+	}
+	if cfg.ChainChunks {
+		chainChunks(m.Chunks)
+	}
+
+	stats.recordRedundancy(m, stored)
+	if err := checkRedundancy(m, stored, cfg.RequireFullRedundancy); err != nil {
+		return fmt.Errorf("failed to distribute shards: %w", err)
+	}
+	if err := m.Validate(); err != nil {
+		return fmt.Errorf("invalid manifest: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package publisher
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/chunker"
+	"github.com/Abhinav-kodes/dbxn/pkg/manifest"
+	"github.com/Abhinav-kodes/dbxn/pkg/retriever"
+)
+
+// ============================================================================
+// BLOB WRITER TESTS
+// ============================================================================
+
+func TestBlobWriter_PipeRoundTrip(t *testing.T) {
+	_, endpoints := newMockFleet(t, 6)
+
+	manifestPath := "test-blob-writer-manifest.json"
+	defer os.Remove(manifestPath)
+
+	testData := make([]byte, 2*chunker.ChunkSize+777)
+	rand.Read(testData)
+
+	w, err := NewBlobWriter(nil, UploadConfig{
+		FarmerEndpoints: endpoints,
+		OutputPath:      manifestPath,
+		FilePath:        "piped.bin",
+	})
+	if err != nil {
+		t.Fatalf("NewBlobWriter failed: %v", err)
+	}
+
+	// Producer process → pipe → BlobWriter, in small uneven writes
+	pr, pw := io.Pipe()
+	go func() {
+		for off := 0; off < len(testData); off += 40000 {
+			end := min(off+40000, len(testData))
+			if _, err := pw.Write(testData[off:end]); err != nil {
+				return
+			}
+		}
+		pw.Close()
+	}()
+	if _, err := io.Copy(w, pr); err != nil {
+		t.Fatalf("Copy into BlobWriter failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	m := w.Manifest()
+	if m.FileName != "piped.bin" {
+		t.Errorf("Expected file name piped.bin, got %s", m.FileName)
+	}
+	if m.FileSize != int64(len(testData)) || m.ChunkCount != 3 {
+		t.Errorf("Expected %d bytes in 3 chunks, got %d bytes in %d chunks", len(testData), m.FileSize, m.ChunkCount)
+	}
+	if w.Stats().ShardsUploaded != 3*chunker.TotalShards {
+		t.Errorf("Expected %d shards uploaded, got %d", 3*chunker.TotalShards, w.Stats().ShardsUploaded)
+	}
+	if _, err := os.Stat(manifestPath); err != nil {
+		t.Errorf("Manifest not saved: %v", err)
+	}
+	if _, err := w.Write([]byte("late")); err == nil {
+		t.Error("Expected Write after Close to fail")
+	}
+
+	// BlobReader → pipe → consumer process
+	blob, err := retriever.Open(m, retriever.DownloadConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blob.Close()
+	out, in := io.Pipe()
+	go func() {
+		_, err := io.Copy(in, blob)
+		in.CloseWithError(err)
+	}()
+	got, err := io.ReadAll(out)
+	if err != nil {
+		t.Fatalf("Reading through pipe failed: %v", err)
+	}
+	if !bytes.Equal(got, testData) {
+		t.Error("Data differs after pipe round trip")
+	}
+}
+
+func TestBlobWriter_CloseWithError(t *testing.T) {
+	_, endpoints := newMockFleet(t, 6)
+
+	manifestPath := "test-blob-writer-aborted.json"
+	defer os.Remove(manifestPath)
+
+	w, err := NewBlobWriter(nil, UploadConfig{
+		FarmerEndpoints: endpoints,
+		OutputPath:      manifestPath,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(make([]byte, 1000)); err != nil {
+		t.Fatal(err)
+	}
+
+	producerFailed := errors.New("producer failed")
+	if err := w.CloseWithError(producerFailed); !errors.Is(err, producerFailed) {
+		t.Errorf("Expected producer error, got %v", err)
+	}
+	if err := w.Close(); !errors.Is(err, producerFailed) {
+		t.Errorf("Expected Close after abort to repeat the error, got %v", err)
+	}
+	if _, err := os.Stat(manifestPath); !os.IsNotExist(err) {
+		t.Error("Manifest should not be saved after abort")
+	}
+}
+
+func TestBlobWriter_ShardOrderIsStable(t *testing.T) {
+	_, endpoints := newMockFleet(t, 6)
+
+	manifestPath := "test-blob-writer-order.json"
+	defer os.Remove(manifestPath)
+
+	testData := make([]byte, 4*chunker.ChunkSize+321)
+	rand.Read(testData)
+	key := make([]byte, 32)
+	rand.Read(key)
+
+	// Shards of a chunk upload concurrently, and while the next chunk is sharded,
+	// so they complete in a different order every run; the manifest mustn't
+	upload := func() []manifest.ShardMeta {
+		w, err := NewBlobWriter(key, UploadConfig{
+			FarmerEndpoints: endpoints,
+			OutputPath:      manifestPath,
+			Parallelism:     8,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(testData); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		return w.Manifest().Shards
+	}
+	first, second := upload(), upload()
+
+	if len(first) != 5*chunker.TotalShards {
+		t.Fatalf("Expected %d shards, got %d", 5*chunker.TotalShards, len(first))
+	}
+	for i, shard := range first {
+		if shard.ChunkIndex != i/chunker.TotalShards || shard.ShardIndex != i%chunker.TotalShards {
+			t.Fatalf("Shard %d is chunk %d shard %d, expected sorted order", i, shard.ChunkIndex, shard.ShardIndex)
+		}
+	}
+	// Chunk encryption uses fresh nonces, so compare everything but the hash
+	for i := range first {
+		a, b := first[i], second[i]
+		a.Hash, b.Hash = "", ""
+		if a != b {
+			t.Errorf("Shard %d differs between runs: %+v vs %+v", i, first[i], second[i])
+		}
+	}
+}
+
+func TestNewBlobWriter_InvalidKey(t *testing.T) {
+	_, err := NewBlobWriter(make([]byte, 16), UploadConfig{
+		FarmerEndpoints: []string{"http://localhost:1"},
+		OutputPath:      "unused.json",
+	})
+	if err == nil {
+		t.Error("Expected error for short key")
+	}
+}
+
+func TestBlobWriter_SniffContentType(t *testing.T) {
+	_, endpoints := newMockFleet(t, 6)
+
+	manifestPath := "test-blob-writer-sniff.json"
+	defer os.Remove(manifestPath)
+
+	// A PNG signature followed by noise
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 5000)...)
+	rand.Read(png[8:])
+
+	upload := func(sniff bool) *manifest.Manifest {
+		w, err := NewBlobWriter(nil, UploadConfig{
+			FarmerEndpoints:  endpoints,
+			OutputPath:       manifestPath,
+			SniffContentType: sniff,
+		})
+		if err != nil {
+			t.Fatalf("NewBlobWriter failed: %v", err)
+		}
+		if _, err := w.Write(png); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		return w.Manifest()
+	}
+
+	m := upload(true)
+	if m.ContentType != "image/png" {
+		t.Errorf("Expected image/png, got %q", m.ContentType)
+	}
+	if m.FileName != "blob.png" {
+		t.Errorf("Expected suggested name blob.png, got %q", m.FileName)
+	}
+
+	// Opt-in: blank metadata stays blank
+	m = upload(false)
+	if m.ContentType != "" || m.FileName != "" {
+		t.Errorf("Expected no sniffing by default, got %q named %q", m.ContentType, m.FileName)
+	}
+}
@@ -2,37 +2,246 @@ package publisher
 
 import (
 	"bytes"
+	"cmp"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/Abhinav-kodes/dbxn/pkg/crypto"
 	"github.com/Abhinav-kodes/dbxn/pkg/chunker"
+	"github.com/Abhinav-kodes/dbxn/pkg/farmer"
 	"github.com/Abhinav-kodes/dbxn/pkg/manifest"
+	"github.com/Abhinav-kodes/dbxn/pkg/metrics"
+	"github.com/Abhinav-kodes/dbxn/pkg/transport"
 )
 
 // UploadConfig holds configuration for file upload
 type UploadConfig struct {
 	FilePath         string   // Path to file to upload
 	FarmerEndpoints  []string // List of farmer HTTP endpoints
-	PublisherAddress string   // Publisher's wallet address
+	PublisherAddress string   // Publisher's wallet address (default: derived from PublisherPublicKey)
+	PublisherPublicKey ed25519.PublicKey // Optional publisher identity key, recorded in the manifest for verifiers
 	OutputPath       string   // Where to save manifest.json
 	Parallelism      int      // Number of parallel uploads (default: 4)
+	FarmerRegions    map[string]string // Optional endpoint → region mapping (e.g. "us-east-1")
+	MinRegions       int      // Minimum distinct regions each chunk's shards must span (0 = no constraint)
+	FarmerTokens     farmer.AuthTokens // Optional endpoint → Authorization header value (redacted when printed)
+
+	// TempDir holds scratch data that needn't sit next to its target (default:
+	// $TMPDIR): an interrupted upload spills its pending shards here rather than
+	// next to the draft when set, and ResumeUpload must then be given the same
+	// TempDir. Uploads start by removing stale scratch temp files from it and from
+	// the local endpoints' directories. Temp files renamed into place (shards
+	// written to a LocalDir, manifests) stay beside their target so the rename is
+	// atomic.
+	TempDir string
+
+	// UniformShardSize pads every encrypted chunk to the maximum encrypted chunk
+	// size before sharding, so all shards in a blob have the same length and
+	// shard sizes no longer leak file size to observers of farmer traffic.
+	// Costs up to one chunk of padding × TotalShards/DataShards per blob; a small
+	// file stores as much as a full chunk would (1.5MB with 4+2 erasure coding
+	// unless ChunkSize is set lower).
+	UniformShardSize bool
+
+	// ChunkSize is the plaintext bytes per chunk for file uploads, at most
+	// chunker.ChunkSize (0 = chunker.RecommendChunkSize for the file's size, or
+	// chunker.ChunkSize with UniformShardSize so padding keeps hiding the size)
+	ChunkSize int
+
+	// RequireFullRedundancy fails the upload unless every chunk has all TotalShards
+	// stored, instead of accepting any chunk that still has DataShards.
+	RequireFullRedundancy bool
+
+	// ShardWrapKey, if set, wraps every shard in an outer encryption layer before
+	// upload so farmers can't correlate shards of the same chunk. The key is managed
+	// separately and never written to the manifest; retrievers need it to peel the
+	// layer. ShardWrapAlgorithm selects the cipher (default XChaCha20-Poly1305).
+	ShardWrapKey       []byte
+	ShardWrapAlgorithm crypto.Algorithm
+
+	// ShardFEC, if set, encodes every stored shard with inner error correction
+	// (outside any ShardWrap layer), so retrievers can correct small errors within
+	// a shard instead of losing it. chunker.DefaultShardFEC costs about 3%; see
+	// chunker.ShardFEC for what it corrects.
+	ShardFEC *chunker.ShardFEC
+
+	// ChunkHashDomain selects what ChunkMeta.Hash covers: manifest.ChunkHashPlaintext
+	// (default) keeps dedup and local file checks working but lets anyone holding the
+	// manifest confirm known content chunk by chunk; manifest.ChunkHashCiphertext
+	// hides content identity at the cost of both. See manifest.ChunkHashPlaintext.
+	ChunkHashDomain string
+
+	// ChainChunks links each chunk's metadata to its predecessors through
+	// ChunkMeta.PrevHash, so a reordered or dropped chunk is detectable without
+	// the whole file (see retriever.VerifyChunkChain).
+	ChainChunks bool
+
+	// CommitChunks encrypts chunks with crypto.EncryptChunkCommitted, binding each
+	// to the key so no other key can open it (recorded as Manifest.ChunkCommitment).
+	// Worth setting once chunks may be shared between recipients.
+	CommitChunks bool
+
+	// BindChunkAAD authenticates each chunk together with the blob ID, manifest
+	// version and chunk index (manifest.ChunkAssociatedData), so a chunk's
+	// ciphertext can't be replayed into another blob or position. Recorded as
+	// Manifest.ChunkAAD.
+	BindChunkAAD bool
+
+	// EncryptFields lists manifest metadata fields (manifest.FieldFileName,
+	// manifest.FieldOriginalFileHash) to store as ciphertext under the data key,
+	// so the manifest can be shared without revealing them (file uploads only)
+	EncryptFields []string
+
+	// SniffContentType detects the blob's MIME type from its first bytes
+	// (http.DetectContentType) and records it as Manifest.ContentType. A blob
+	// without a file name, as from a BlobWriter with no FilePath, is also named
+	// "blob" plus the type's usual extension. Off by default so metadata left
+	// blank stays blank (file and BlobWriter uploads only).
+	SniffContentType bool
+
+	// Passphrase, if set, derives the encryption key with KDF instead of generating
+	// a random one, and the key is left out of the manifest: retrievers need the
+	// passphrase (retriever.DownloadConfig.Passphrase). KDF.Algorithm defaults to
+	// Argon2id, unset cost parameters to crypto.DefaultKDFParams, and a random salt
+	// is generated unless one is given. The parameters are recorded as
+	// Manifest.KDF (file uploads only).
+	Passphrase []byte
+	KDF        crypto.KDFParams
+
+	// UploadDeadline bounds shard distribution, counted from the start of the upload
+	// (0 = no deadline). Each request's timeout is a share of the budget left, and
+	// shards that can't finish in time are abandoned; the upload still succeeds if
+	// every chunk stored DataShards shards (see RequireFullRedundancy).
+	UploadDeadline time.Duration
+
+	// PinManifest also stores the manifest, sealed under the blob's encryption key,
+	// on the first PinManifestCopies farmers (0 = all), so retriever.FetchManifest
+	// can recover it from BlobID, endpoints and key if the local copy is lost.
+	PinManifest       bool
+	PinManifestCopies int
+
+	// Metrics, if set, receives live shard upload counters and latencies
+	// (metrics.ShardsUploaded and friends) as the upload runs
+	Metrics metrics.Metrics
+
+	// MultipartThreshold uploads shards larger than this many bytes with the
+	// multipart protocol (see shardMultipartPath) instead of a single POST
+	// (0 = never). MultipartPartSize sets the part length (default
+	// DefaultMultipartPartSize); parts of one shard are sent concurrently.
+	MultipartThreshold int
+	MultipartPartSize  int
+
+	// ShardSinks stores shards for the listed farmer endpoints through a custom
+	// transport instead of HTTP (see package transport). Local endpoints need no entry.
+	ShardSinks map[string]transport.ShardSink
+
+	// ChunkPrefetchBytes lets streamed uploads (NewBlobWriter) read and hash
+	// chunks ahead of encryption and upload until this many bytes are waiting
+	// (see chunker.Config.PrefetchBytes; 0 = chunker.DefaultStreamBuffer chunks)
+	ChunkPrefetchBytes int64
+
+	// MaxRetries resends a shard whose upload failed transiently (transport error,
+	// 408, 429 or 5xx) up to this many times to the same farmer. Permanent
+	// rejections (other 4xx, a wrong hash confirmation) are never retried.
+	MaxRetries int
+
+	// LocalShardDir adds a local directory to FarmerEndpoints as one more farmer
+	// (manifest.LocalEndpoint): its share of the shards is written there, keyed
+	// by ShardAddress, instead of being uploaded. On its own it stages the whole
+	// blob on disk with no farmers at all; retrievers read it back directly.
+	LocalShardDir string
 }
 
+// shardUploadPath is the farmer endpoint accepting ShardUploadRequest payloads.
+// Stored shards are addressed below it as /shards/{manifest.ShardAddress}.
+const shardUploadPath = "/shards"
+
 // UploadStats tracks upload progress
 type UploadStats struct {
 	ChunksProcessed  int // Total chunks processed
 	ShardsCreated    int // Total shards created
 	ShardsUploaded   int // Total shards uploaded
-	BytesUploaded    int64 // Total bytes uploaded
+	BytesUploaded    int64 // Total bytes sent to farmers (same as WireBytes)
+	LogicalBytes     int64 // Shard bytes stored on farmers
+	WireBytes        int64 // Bytes actually sent for shards: encoded request bodies, retries and failed attempts included
 	StartTime        time.Time // Upload start time
 	EndTime          time.Time // Upload end time
 	Errors           []error // List of errors encountered during upload
+
+	// Phase timers (cumulative)
+	HashDuration     time.Duration // Hashing the original file
+	ChunkDuration    time.Duration // Reading and chunking the file
+	EncryptDuration  time.Duration // Encrypting chunks
+	ShardDuration    time.Duration // Erasure coding encrypted chunks
+	UploadDuration   time.Duration // Distributing shards to farmers (wall time)
+	FarmerDurations  map[string]time.Duration // Cumulative upload time per farmer endpoint
+	ChunkRedundancy  map[int]int // Shards stored per chunk index (TotalShards = full redundancy)
+	ShardsTimedOut   int // Shards abandoned because UploadDeadline ran out
+	ManifestPins     int // Farmers holding a sealed copy of the manifest (PinManifest)
+	ShardRetries     int // Upload attempts that resent a shard after a transient failure
+	ShardResults     []ShardUploadResult // How each shard upload ended, in completion order
+
+	mu sync.Mutex // guards Errors, FarmerDurations and ShardResults while workers are running
+}
+
+// UploadOutcome classifies how a shard upload ended
+type UploadOutcome int
+
+const (
+	// UploadStored: the farmer holds the shard (2xx with a matching hash, or 409 already stored)
+	UploadStored UploadOutcome = iota
+	// UploadRetryable: a transient failure worth resending (transport error, timeout, 408, 429, 5xx)
+	UploadRetryable
+	// UploadPermanent: resending can't help (any other 4xx, such as 413, or a wrong hash confirmation)
+	UploadPermanent
+)
+
+func (o UploadOutcome) String() string {
+	switch o {
+	case UploadStored:
+		return "stored"
+	case UploadRetryable:
+		return "retryable"
+	default:
+		return "permanent"
+	}
+}
+
+// ClassifyUploadError returns the outcome a shard upload error stands for. Sinks
+// mark transient failures with transport.RetryableError; anything else is permanent.
+func ClassifyUploadError(err error) UploadOutcome {
+	switch {
+	case err == nil:
+		return UploadStored
+	case transport.IsRetryable(err):
+		return UploadRetryable
+	default:
+		return UploadPermanent
+	}
+}
+
+// ShardUploadResult is the final outcome of uploading one shard. A retryable
+// outcome means the shard still failed after cfg.MaxRetries resends.
+type ShardUploadResult struct {
+	ChunkIndex  int
+	ShardIndex  int
+	FarmerIndex int
+	Outcome     UploadOutcome
+	Attempts    int   // requests sent, retries included
+	Err         error // nil when stored
 }
 
 // ShardUploadRequest is the JSON payload sent to farmers
@@ -49,27 +258,47 @@ type ShardUploadRequest struct {
 type ShardUploadResponse struct {
 	Status  string `json:"status"`
 	Message string `json:"message"`
-	Hash    string `json:"hash"` // Farmer confirms hash
+	Hash    string `json:"hash"` // Farmer confirms hash; must equal the uploaded shard's hash
 }
 
 // Upload orchestrates the complete file upload process 
 func Upload(config UploadConfig) (*manifest.Manifest, *UploadStats, error) {
+	return UploadContext(context.Background(), config)
+}
+
+// UploadContext is Upload that can be interrupted, e.g. by SIGINT through
+// signal.NotifyContext. Cancelling ctx while shards are being distributed stops
+// starting new ones, aborts those in flight and saves a draft manifest at
+// DraftPath(config.OutputPath), with the shards not yet stored spilled next to
+// it (or in config.TempDir), so ResumeUpload can finish the blob later. The returned error wraps
+// ctx.Err(). Cancelling earlier, before anything is uploaded, saves nothing.
+func UploadContext(ctx context.Context, config UploadConfig) (*manifest.Manifest, *UploadStats, error) {
 	stats := &UploadStats{
 		StartTime: time.Now(),
 		Errors:    make([]error, 0),
 	}
 
 	// Validate config
+	if config.Parallelism == 0 {
+		config.Parallelism = 4
+	}
+	config, err := withLocalShardDir(config)
+	if err != nil {
+		return nil, stats, fmt.Errorf("invalid config: %w", err)
+	}
 	if err := validateConfig(config); err != nil {
 		return nil, stats, fmt.Errorf("invalid config: %w", err)
 	}
+	sweepStaleTemp(config)
 
 	fmt.Printf("📦 Starting upload: %s\n", filepath.Base(config.FilePath))
 	fmt.Printf("🌐 Farmers: %d endpoints\n", len(config.FarmerEndpoints))
 
 	// Step 1: Calculate original file hash
 	fmt.Println("\n📊 Calculating file hash...")
+	hashStart := time.Now()
 	fileHash, err := manifest.CalculateFileHash(config.FilePath)
+	stats.HashDuration = time.Since(hashStart)
 	if err != nil {
 		return nil, stats, fmt.Errorf("failed to hash file: %w", err)
 	}
@@ -77,40 +306,111 @@ func Upload(config UploadConfig) (*manifest.Manifest, *UploadStats, error) {
 
 	// Step 2: Generate encryption key
 	fmt.Println("\n🔐 Generating encryption key...")
-	encKey, err := crypto.GenerateKey()
+	encKey, kdf, err := uploadKey(config)
 	if err != nil {
-		return nil, stats, fmt.Errorf("failed to generate key: %w", err)
+		return nil, stats, err
 	}
 	fmt.Println("✓ Encryption key generated")
 
 	// Step 3: Process file (chunk → encrypt → shard)
 	fmt.Println("\n⚙️  Processing file...")
-	chunks, allShards, err := processFile(config.FilePath, encKey, stats)
+	chunkSize, err := uploadChunkSize(config)
+	if err != nil {
+		return nil, stats, err
+	}
+	paddedSize := 0
+	if config.UniformShardSize {
+		paddedSize = chunkSize + chunkOverhead(config)
+	}
+	cipher := newChunkCipher(config)
+	chunks, allShards, err := processFileChunked(config.FilePath, chunkSize, encKey, paddedSize, config.ChunkHashDomain, cipher, stats)
 	if err != nil {
 		return nil, stats, fmt.Errorf("failed to process file: %w", err)
 	}
 
 	fmt.Printf("✓ Processed: %d chunks → %d shards\n", len(chunks), len(allShards))
+	if config.ChainChunks {
+		chainChunks(chunks)
+	}
+
+	// Optional outer layer, applied after hashing so the manifest describes stored bytes
+	if config.ShardWrapKey != nil {
+		if err := wrapShards(allShards, config.ShardWrapAlgorithm, config.ShardWrapKey); err != nil {
+			return nil, stats, err
+		}
+		fmt.Println("✓ Shards wrapped for storage")
+	}
+	if config.ShardFEC != nil {
+		if err := encodeShardFEC(allShards, config.ShardFEC); err != nil {
+			return nil, stats, err
+		}
+	}
 
 	// Step 4: Build manifest with farmer assignments
 	fmt.Println("\n📋 Building manifest...")
-	farmers := buildFarmerInfo(config.FarmerEndpoints)
-	m := buildManifest(
+	farmers := buildFarmerInfo(config.FarmerEndpoints, config.FarmerRegions)
+	m, err := buildManifest(
 		config.FilePath,
+		fileHash,
 		chunks,
 		allShards,
 		farmers,
 		encKey,
 		config.PublisherAddress,
+		config.MinRegions,
 	)
+	if err != nil {
+		return nil, stats, fmt.Errorf("failed to build manifest: %w", err)
+	}
+	m.ChunkSize = chunkSize
+	m.PaddedSize = paddedSize
+	m.ShardWrap = shardWrapName(config)
+	m.ShardFEC = config.ShardFEC
+	setPublisherKey(m, config.PublisherPublicKey)
+	m.ChunkHashDomain = config.ChunkHashDomain
+	cipher.apply(m)
+	if config.SniffContentType {
+		if err := sniffFileContentType(m, config.FilePath); err != nil {
+			return nil, stats, fmt.Errorf("failed to build manifest: %w", err)
+		}
+	}
+	if err := m.EncryptFields(encKey, config.EncryptFields...); err != nil {
+		return nil, stats, fmt.Errorf("failed to build manifest: %w", err)
+	}
+	if kdf != nil {
+		m.KDF = kdf
+		m.EncryptionKey = ""
+	}
 	fmt.Printf("✓ Manifest created (Blob ID: %s)\n", m.BlobID[:16]+"...")
 
 	// Step 5: Distribute shards to farmers
 	fmt.Println("\n🚀 Uploading shards to farmers...")
-	if err := distributeShardsParallel(m, allShards, farmers, config.Parallelism, stats); err != nil {
+	if err := ctx.Err(); err != nil {
+		return nil, stats, fmt.Errorf("upload cancelled: %w", err)
+	}
+	uploadStart := time.Now()
+	err = distributeShardsContext(ctx, m, allShards, farmers, config, stats)
+	stats.UploadDuration = time.Since(uploadStart)
+	if err != nil && ctx.Err() != nil {
+		draftPath := DraftPath(config.OutputPath)
+		if draftErr := saveDraft(m, allShards, stats, draftPath, config.TempDir); draftErr != nil {
+			return nil, stats, fmt.Errorf("upload interrupted (%w); failed to save draft: %v", ctx.Err(), draftErr)
+		}
+		fmt.Printf("⏸  Upload interrupted; draft saved: %s\n", draftPath)
+		return nil, stats, fmt.Errorf("upload interrupted, resume from %s: %w", draftPath, ctx.Err())
+	}
+	if err != nil {
 		return nil, stats, fmt.Errorf("failed to distribute shards: %w", err)
 	}
 
+	if config.PinManifest {
+		fmt.Println("\n📌 Pinning manifest on farmers...")
+		if err := pinManifest(m, encKey, config, stats); err != nil {
+			return nil, stats, fmt.Errorf("failed to pin manifest: %w", err)
+		}
+		fmt.Printf("✓ Manifest pinned on %d farmers\n", stats.ManifestPins)
+	}
+
 	// Step 6: Save manifest
 	fmt.Println("\n💾 Saving manifest...")
 	if err := m.Save(config.OutputPath); err != nil {
@@ -122,4 +422,783 @@ func Upload(config UploadConfig) (*manifest.Manifest, *UploadStats, error) {
 	printStats(stats)
 
 	return m, stats, nil
-}
\ No newline at end of file
+}
+
+// validateConfig checks required upload settings
+func validateConfig(config UploadConfig) error {
+	if config.FilePath == "" {
+		return fmt.Errorf("file path is required")
+	}
+	info, err := os.Stat(config.FilePath)
+	if err != nil {
+		return fmt.Errorf("cannot access file: %w", err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", config.FilePath)
+	}
+	return validateOptions(config)
+}
+
+// validateOptions checks the settings shared by file and directory uploads
+func validateOptions(config UploadConfig) error {
+	if len(config.FarmerEndpoints) == 0 {
+		return fmt.Errorf("at least one farmer endpoint is required")
+	}
+	seen := make(map[string]bool, len(config.FarmerEndpoints))
+	for _, endpoint := range config.FarmerEndpoints {
+		if seen[endpoint] {
+			return fmt.Errorf("farmer endpoint %s listed more than once", endpoint)
+		}
+		seen[endpoint] = true
+	}
+	if config.OutputPath == "" {
+		return fmt.Errorf("output path is required")
+	}
+	if config.Parallelism < 0 {
+		return fmt.Errorf("parallelism must be positive, got %d", config.Parallelism)
+	}
+	switch config.ChunkHashDomain {
+	case "", manifest.ChunkHashPlaintext, manifest.ChunkHashCiphertext:
+	default:
+		return fmt.Errorf("unknown chunk hash domain %q", config.ChunkHashDomain)
+	}
+	if config.MultipartThreshold < 0 || config.MultipartPartSize < 0 {
+		return fmt.Errorf("multipart threshold and part size must not be negative")
+	}
+	if config.PinManifestCopies < 0 {
+		return fmt.Errorf("PinManifestCopies must not be negative, got %d", config.PinManifestCopies)
+	}
+	if config.MinRegions < 0 {
+		return fmt.Errorf("MinRegions must not be negative, got %d", config.MinRegions)
+	}
+	for _, field := range config.EncryptFields {
+		switch field {
+		case manifest.FieldFileName, manifest.FieldOriginalFileHash:
+		default:
+			return fmt.Errorf("manifest field %q can't be encrypted", field)
+		}
+	}
+	if config.ChunkSize < 0 || config.ChunkSize > chunker.ChunkSize {
+		return fmt.Errorf("ChunkSize must be between 0 and %d, got %d", chunker.ChunkSize, config.ChunkSize)
+	}
+	if config.ShardWrapKey != nil {
+		if err := config.ShardWrapAlgorithm.ValidateKey(config.ShardWrapKey); err != nil {
+			return fmt.Errorf("shard wrap key: %w", err)
+		}
+	}
+	if config.ShardFEC != nil {
+		if err := config.ShardFEC.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uploadKey generates a random encryption key, or derives one from
+// config.Passphrase and returns the KDF parameters used
+func uploadKey(config UploadConfig) ([]byte, *crypto.KDFParams, error) {
+	if len(config.Passphrase) == 0 {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to generate key: %w", err)
+		}
+		return key, nil, nil
+	}
+	params := config.KDF
+	if params.Algorithm == "" {
+		params.Algorithm = crypto.KDFArgon2id
+	}
+	defaults, err := crypto.DefaultKDFParams(params.Algorithm)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid KDF: %w", err)
+	}
+	if params.Iterations == 0 && params.Memory == 0 && params.Parallelism == 0 {
+		defaults.Salt = cmp.Or(params.Salt, defaults.Salt)
+		params = defaults
+	} else if params.Salt == "" {
+		params.Salt = defaults.Salt
+	}
+	key, err := crypto.DeriveKey(config.Passphrase, params)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	return key, &params, nil
+}
+
+// uploadChunkSize resolves config.ChunkSize for the file being uploaded
+func uploadChunkSize(config UploadConfig) (int, error) {
+	if config.ChunkSize > 0 {
+		return config.ChunkSize, nil
+	}
+	if config.UniformShardSize {
+		return chunker.ChunkSize, nil
+	}
+	info, err := os.Stat(config.FilePath)
+	if err != nil {
+		return 0, fmt.Errorf("cannot access file: %w", err)
+	}
+	return chunker.RecommendChunkSize(info.Size()), nil
+}
+
+// chunkOverhead is the bytes encryption adds to each chunk under config
+func chunkOverhead(config UploadConfig) int {
+	return crypto.ChunkOptions{Committed: config.CommitChunks}.Overhead()
+}
+
+// chunkCipher is how encodeChunk encrypts a blob's chunks
+type chunkCipher struct {
+	committed bool   // key commitment (CommitChunks)
+	blobID    string // blob chunks are bound to with associated data (empty = unbound)
+}
+
+// newChunkCipher derives chunk encryption from config. Binding chunks needs the
+// blob ID before the manifest exists, so it is picked here.
+func newChunkCipher(config UploadConfig) chunkCipher {
+	c := chunkCipher{committed: config.CommitChunks}
+	if config.BindChunkAAD {
+		c.blobID = manifest.NewBlobID()
+	}
+	return c
+}
+
+// manifestCipher is the chunk encryption a manifest records
+func manifestCipher(m *manifest.Manifest) chunkCipher {
+	c := chunkCipher{committed: m.ChunkCommitment}
+	if m.ChunkAAD {
+		c.blobID = m.BlobID
+	}
+	return c
+}
+
+// options returns the encryption options for one chunk, matching what
+// Manifest.ChunkOptions gives the retriever
+func (c chunkCipher) options(chunkIndex int) crypto.ChunkOptions {
+	opts := crypto.ChunkOptions{Committed: c.committed}
+	if c.blobID != "" {
+		opts.AAD = manifest.ChunkAssociatedData(c.blobID, manifest.FormatVersion, chunkIndex)
+	}
+	return opts
+}
+
+// apply records the cipher in m, adopting the blob ID chunks were bound to
+func (c chunkCipher) apply(m *manifest.Manifest) {
+	m.ChunkCommitment = c.committed
+	if c.blobID != "" {
+		m.BlobID = c.blobID
+		m.ChunkAAD = true
+	}
+}
+
+// processFile streams the file through chunk → encrypt → shard
+// Returns chunk metadata and all shards of the encrypted chunks.
+// A non-zero paddedSize pads each encrypted chunk to that length before sharding.
+// hashDomain selects what ChunkMeta.Hash covers (see manifest.ChunkHashDomain).
+func processFile(filePath string, encKey []byte, paddedSize int, hashDomain string, cipher chunkCipher, stats *UploadStats) ([]manifest.ChunkMeta, []chunker.Shard, error) {
+	return processFileChunked(filePath, chunker.ChunkSize, encKey, paddedSize, hashDomain, cipher, stats)
+}
+
+// processFileChunked is processFile with an explicit chunk size
+func processFileChunked(filePath string, chunkSize int, encKey []byte, paddedSize int, hashDomain string, cipher chunkCipher, stats *UploadStats) ([]manifest.ChunkMeta, []chunker.Shard, error) {
+	var chunks []manifest.ChunkMeta
+	var allShards []chunker.Shard
+
+	// Time spent waiting on the chunk reader counts as chunking
+	waitStart := time.Now()
+	for result := range chunker.StreamChunkFileWithConfig(filePath, chunker.Config{ChunkSize: chunkSize}) {
+		stats.ChunkDuration += time.Since(waitStart)
+		if result.Err != nil {
+			return nil, nil, result.Err
+		}
+		chunk := result.Chunk
+
+		meta, shards, err := encodeChunk(chunk, encKey, paddedSize, hashDomain, cipher, stats)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		chunks = append(chunks, meta)
+		allShards = append(allShards, shards...)
+		waitStart = time.Now()
+	}
+
+	return chunks, allShards, nil
+}
+
+// encodeChunk encrypts one plaintext chunk and erasure-codes the ciphertext.
+// Returns the chunk's manifest metadata, hashed in hashDomain.
+func encodeChunk(chunk chunker.Chunk, encKey []byte, paddedSize int, hashDomain string, cipher chunkCipher, stats *UploadStats) (manifest.ChunkMeta, []chunker.Shard, error) {
+	// Encrypt chunk plaintext
+	encryptStart := time.Now()
+	encrypted, err := crypto.EncryptChunkWithOptions(chunk.Data, encKey, cipher.options(chunk.Index))
+	stats.EncryptDuration += time.Since(encryptStart)
+	if err != nil {
+		return manifest.ChunkMeta{}, nil, fmt.Errorf("failed to encrypt chunk %d: %w", chunk.Index, err)
+	}
+
+	// Manifest keeps the plaintext size; the hash is plaintext unless configured otherwise
+	meta := manifest.ChunkMeta{Index: chunk.Index, Hash: chunk.Hash, Size: chunk.Size, EncryptedSize: len(encrypted)}
+	if hashDomain == manifest.ChunkHashCiphertext {
+		sum := sha256.Sum256(encrypted)
+		meta.Hash = hex.EncodeToString(sum[:])
+	}
+
+	// Shards encode the ciphertext, so size the chunk accordingly
+	encChunk := chunk
+	encChunk.Size = len(encrypted)
+	shardStart := time.Now()
+	var shards []chunker.Shard
+	if paddedSize > 0 {
+		shards, err = chunker.ShardChunkPadded(encChunk, encrypted, paddedSize)
+	} else {
+		shards, err = chunker.ShardChunk(encChunk, encrypted)
+	}
+	stats.ShardDuration += time.Since(shardStart)
+	if err != nil {
+		return manifest.ChunkMeta{}, nil, fmt.Errorf("failed to shard chunk %d: %w", chunk.Index, err)
+	}
+
+	stats.ChunksProcessed++
+	stats.ShardsCreated += len(shards)
+	return meta, shards, nil
+}
+
+// chainChunks sets PrevHash on chunks, which must be in file order
+func chainChunks(chunks []manifest.ChunkMeta) {
+	prev := manifest.ChainGenesis
+	for i := range chunks {
+		chunks[i].PrevHash = prev
+		prev = manifest.ChainLink(prev, chunks[i].Hash)
+	}
+}
+
+// wrapShards encrypts each shard in place with the storage-layer key and
+// updates its hash and size to describe the wrapped bytes farmers will store
+func wrapShards(shards []chunker.Shard, alg crypto.Algorithm, key []byte) error {
+	for i := range shards {
+		wrapped, err := crypto.EncryptChunkWith(alg, shards[i].Data, key)
+		if err != nil {
+			return fmt.Errorf("failed to wrap chunk %d shard %d: %w", shards[i].ChunkIndex, shards[i].ShardIndex, err)
+		}
+		hash := sha256.Sum256(wrapped)
+		shards[i].Data = wrapped
+		shards[i].Hash = hex.EncodeToString(hash[:])
+		shards[i].Size = len(wrapped)
+	}
+	return nil
+}
+
+// encodeShardFEC replaces every shard with its stored form under fec. It runs
+// last, after any wrapping, so errors are corrected before anything else reads
+// the shard.
+func encodeShardFEC(shards []chunker.Shard, fec *chunker.ShardFEC) error {
+	for i := range shards {
+		encoded, err := fec.EncodeShard(shards[i])
+		if err != nil {
+			return err
+		}
+		shards[i] = encoded
+	}
+	return nil
+}
+
+// shardWrapName is the manifest's ShardWrap value for a config ("" when unwrapped)
+func shardWrapName(config UploadConfig) string {
+	if config.ShardWrapKey == nil {
+		return ""
+	}
+	if config.ShardWrapAlgorithm == "" {
+		return string(crypto.AlgXChaCha20Poly1305)
+	}
+	return string(config.ShardWrapAlgorithm)
+}
+
+// buildManifest assigns shards to farmers and assembles the manifest
+func buildManifest(
+	filePath string,
+	fileHash string,
+	chunks []manifest.ChunkMeta,
+	shards []chunker.Shard,
+	farmers []manifest.FarmerInfo,
+	encKey []byte,
+	publisher string,
+	minRegions int,
+) (*manifest.Manifest, error) {
+	// Decide placement per chunk
+	placements := make(map[int][]int, len(chunks))
+	for i := range chunks {
+		placement, err := placeChunkShards(chunks[i].Index, farmers, minRegions)
+		if err != nil {
+			return nil, err
+		}
+		placements[chunks[i].Index] = placement
+	}
+
+	// Record shard metadata with assigned farmers
+	shardMetas := make([]manifest.ShardMeta, 0, len(shards))
+	for _, shard := range shards {
+		shardMetas = append(shardMetas, manifest.ShardMeta{
+			ChunkIndex:  shard.ChunkIndex,
+			ShardIndex:  shard.ShardIndex,
+			Hash:        shard.Hash,
+			Size:        shard.Size,
+			FarmerIndex: placements[shard.ChunkIndex][shard.ShardIndex],
+		})
+	}
+
+	return assembleManifest(filePath, fileHash, chunks, shardMetas, farmers, encKey, publisher, minRegions), nil
+}
+
+// assembleManifest builds the manifest once every shard has a farmer assigned
+func assembleManifest(
+	filePath string,
+	fileHash string,
+	chunks []manifest.ChunkMeta,
+	shardMetas []manifest.ShardMeta,
+	farmers []manifest.FarmerInfo,
+	encKey []byte,
+	publisher string,
+	minRegions int,
+) *manifest.Manifest {
+	var fileSize int64
+	for _, chunk := range chunks {
+		fileSize += int64(chunk.Size)
+	}
+	manifest.SortShards(shardMetas)
+
+	m := manifest.New(
+		filepath.Base(filePath),
+		fileSize,
+		fileHash,
+		chunks,
+		shardMetas,
+		farmers,
+		encKey,
+		publisher,
+	)
+	m.MinRegions = minRegions
+
+	// Record achieved region spread so Validate() can re-check it later
+	for i := range m.Chunks {
+		m.Chunks[i].Regions = m.RegionSpread(m.Chunks[i].Index)
+	}
+
+	return m
+}
+
+// setPublisherKey records the publisher's public key in the manifest and derives
+// PublisherAddress from it when no address was configured
+func setPublisherKey(m *manifest.Manifest, pub ed25519.PublicKey) {
+	if pub == nil {
+		return
+	}
+	m.PublisherPublicKey = crypto.KeyToHex(pub)
+	if m.PublisherAddress == "" {
+		m.PublisherAddress = crypto.AddressFromPublicKey(pub)
+	}
+}
+
+// uploadShard POSTs a single shard to a farmer. The upload only succeeds if the
+// farmer's response confirms the shard's hash; a missing or different hash is an
+// error, so truncated, corrupted or unstored shards count as failed uploads.
+func uploadShard(ctx context.Context, endpoint string, tokens farmer.AuthTokens, req ShardUploadRequest) (*ShardUploadResponse, error) {
+	var uploadResp ShardUploadResponse
+	if err := postJSON(ctx, endpoint, endpoint+shardUploadPath, tokens, req, &uploadResp); err != nil {
+		return nil, err
+	}
+
+	// A 2xx alone isn't proof of storage: the farmer must confirm the exact bytes
+	if uploadResp.Hash != req.Hash {
+		return nil, fmt.Errorf("farmer confirmed hash %q, expected %s", uploadResp.Hash, req.Hash)
+	}
+
+	return &uploadResp, nil
+}
+
+// shardMultipartPath is the farmer endpoint for uploading one shard in parts,
+// modelled on S3 multipart uploads:
+//
+//	POST /multipart                    MultipartInitRequest → MultipartInitResponse
+//	POST /multipart/{upload_id}/parts  MultipartPartRequest, any order, concurrently
+//	POST /multipart/{upload_id}/complete                    → ShardUploadResponse
+//
+// The farmer writes each part at its offset and, on complete, stores the shard only
+// if it has all Size bytes and they hash to Hash. A part may be re-sent; the
+// latest copy wins. Uploads never completed are the farmer's to expire.
+const shardMultipartPath = "/multipart"
+
+// DefaultMultipartPartSize is the part length when MultipartPartSize is unset
+const DefaultMultipartPartSize = 256 * 1024
+
+// multipartParallelism caps concurrent part uploads for one shard
+const multipartParallelism = 4
+
+// MultipartInitRequest opens a multipart upload for one shard
+type MultipartInitRequest struct {
+	BlobID     string `json:"blob_id"`
+	ChunkIndex int    `json:"chunk_index"`
+	ShardIndex int    `json:"shard_index"`
+	Hash       string `json:"hash"` // SHA256 of the whole shard, checked on complete
+	Size       int    `json:"size"` // whole shard size in bytes
+}
+
+// MultipartInitResponse returns the ID parts and complete are sent under
+type MultipartInitResponse struct {
+	UploadID string `json:"upload_id"`
+}
+
+// MultipartPartRequest carries the shard bytes starting at Offset
+type MultipartPartRequest struct {
+	Offset int    `json:"offset"`
+	Data   []byte `json:"data"` // base64 encoded by json.Marshal
+}
+
+// HTTPSink uploads shards to an HTTP farmer, the default ShardSink for endpoints
+// without an entry in UploadConfig.ShardSinks
+type HTTPSink struct {
+	Endpoint string
+	Tokens   farmer.AuthTokens
+
+	// MultipartThreshold and MultipartPartSize as in UploadConfig
+	MultipartThreshold int
+	MultipartPartSize  int
+}
+
+// Put uploads a shard in one POST, or with the multipart protocol when it
+// exceeds MultipartThreshold. A 409 means the farmer already stores the shard
+// and counts as success; 408, 429, 5xx and transport errors are retryable.
+func (s HTTPSink) Put(ctx context.Context, addr string, data []byte, meta manifest.ShardMeta) error {
+	err := s.put(ctx, addr, data, meta)
+	var status *FarmerStatusError
+	if errors.As(err, &status) && status.StatusCode == http.StatusConflict {
+		return nil
+	}
+	return err
+}
+
+func (s HTTPSink) put(ctx context.Context, addr string, data []byte, meta manifest.ShardMeta) error {
+	blobID, _, _, err := manifest.ParseShardAddress(addr)
+	if err != nil {
+		return err
+	}
+	req := ShardUploadRequest{
+		BlobID:     blobID,
+		ChunkIndex: meta.ChunkIndex,
+		ShardIndex: meta.ShardIndex,
+		Data:       data,
+		Hash:       meta.Hash,
+		Size:       meta.Size,
+	}
+	if s.MultipartThreshold > 0 && len(data) > s.MultipartThreshold {
+		partSize := s.MultipartPartSize
+		if partSize <= 0 {
+			partSize = DefaultMultipartPartSize
+		}
+		_, err = uploadShardMultipart(ctx, s.Endpoint, s.Tokens, req, partSize)
+		return err
+	}
+	_, err = uploadShard(ctx, s.Endpoint, s.Tokens, req)
+	return err
+}
+
+// Has asks the farmer whether it already stores a shard (HEAD request)
+func (s HTTPSink) Has(ctx context.Context, addr string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, manifest.ShardURL(s.Endpoint, addr), nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to build request: %w", err)
+	}
+	s.Tokens.Apply(req, s.Endpoint)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("farmer returned %s", resp.Status)
+	}
+}
+
+// SinkFor returns the sink cfg would upload shards to endpoint with, e.g. for
+// retriever.DownloadConfig.RepairSink
+func SinkFor(cfg UploadConfig) func(endpoint string) transport.ShardSink {
+	return func(endpoint string) transport.ShardSink {
+		return shardSink(cfg, endpoint)
+	}
+}
+
+// shardSink returns how shards reach endpoint: cfg.ShardSinks first, then the
+// local directory a local endpoint names, then HTTP
+func shardSink(cfg UploadConfig, endpoint string) transport.ShardSink {
+	if sink, ok := cfg.ShardSinks[endpoint]; ok {
+		return sink
+	}
+	if dir, ok := manifest.LocalEndpointDir(endpoint); ok {
+		return transport.LocalDir{Dir: dir}
+	}
+	return HTTPSink{
+		Endpoint:           endpoint,
+		Tokens:             cfg.FarmerTokens,
+		MultipartThreshold: cfg.MultipartThreshold,
+		MultipartPartSize:  cfg.MultipartPartSize,
+	}
+}
+
+// uploadShardMultipart uploads a shard as concurrent partSize parts and completes
+// it once every part is accepted. The farmer must confirm the whole shard's hash.
+func uploadShardMultipart(ctx context.Context, endpoint string, tokens farmer.AuthTokens, req ShardUploadRequest, partSize int) (*ShardUploadResponse, error) {
+	var init MultipartInitResponse
+	err := postJSON(ctx, endpoint, endpoint+shardMultipartPath, tokens, MultipartInitRequest{
+		BlobID:     req.BlobID,
+		ChunkIndex: req.ChunkIndex,
+		ShardIndex: req.ShardIndex,
+		Hash:       req.Hash,
+		Size:       len(req.Data),
+	}, &init)
+	if err != nil {
+		return nil, fmt.Errorf("multipart init: %w", err)
+	}
+	if init.UploadID == "" || strings.ContainsAny(init.UploadID, "/?#") {
+		return nil, fmt.Errorf("multipart init: farmer returned invalid upload id %q", init.UploadID)
+	}
+	uploadURL := endpoint + shardMultipartPath + "/" + init.UploadID
+
+	// First failing part cancels the rest
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	sem := make(chan struct{}, multipartParallelism)
+	errs := make(chan error, 1)
+	var wg sync.WaitGroup
+	for offset := 0; offset < len(req.Data); offset += partSize {
+		part := MultipartPartRequest{Offset: offset, Data: req.Data[offset:min(offset+partSize, len(req.Data))]}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := postJSON(ctx, endpoint, uploadURL+"/parts", tokens, part, nil); err != nil {
+				select {
+				case errs <- fmt.Errorf("multipart part at offset %d: %w", part.Offset, err):
+				default:
+				}
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+	select {
+	case err := <-errs:
+		return nil, err
+	default:
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var resp ShardUploadResponse
+	if err := postJSON(ctx, endpoint, uploadURL+"/complete", tokens, struct{}{}, &resp); err != nil {
+		return nil, fmt.Errorf("multipart complete: %w", err)
+	}
+	if resp.Hash != req.Hash {
+		return nil, fmt.Errorf("farmer confirmed hash %q, expected %s", resp.Hash, req.Hash)
+	}
+	return &resp, nil
+}
+
+// postJSON POSTs body as JSON to url and decodes a 2xx response into out (if non-nil)
+func postJSON(ctx context.Context, endpoint, url string, tokens farmer.AuthTokens, body, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	tokens.Apply(httpReq, endpoint)
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return &transport.RetryableError{Err: fmt.Errorf("request failed: %w", err)}
+	}
+	defer resp.Body.Close()
+	transport.AddWireBytes(ctx, len(data)) // a response means the whole body was sent
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		err := &FarmerStatusError{StatusCode: resp.StatusCode, Status: resp.Status, Message: string(msg)}
+		if retryableStatus(resp.StatusCode) {
+			return &transport.RetryableError{Err: err}
+		}
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// FarmerStatusError is a farmer's non-2xx answer to a POST
+type FarmerStatusError struct {
+	StatusCode int
+	Status     string
+	Message    string // start of the response body
+}
+
+func (e *FarmerStatusError) Error() string {
+	return fmt.Sprintf("farmer returned %s: %s", e.Status, e.Message)
+}
+
+// retryableStatus reports whether a farmer status is transient: a timeout,
+// throttling, or a server-side failure
+func retryableStatus(code int) bool {
+	return code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500
+}
+
+// shardExistsTimeout bounds each existence check, so an unreachable farmer
+// can't stall a resumed distribution
+const shardExistsTimeout = 10 * time.Second
+
+// shardExists asks a farmer whether it already stores a shard, giving up after
+// shardExistsTimeout or once ctx is done. Sinks that can't tell report false,
+// so the shard is sent again.
+func shardExists(ctx context.Context, cfg UploadConfig, endpoint, blobID string, chunkIndex, shardIndex int) (bool, error) {
+	checker, ok := shardSink(cfg, endpoint).(transport.ShardChecker)
+	if !ok {
+		return false, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, shardExistsTimeout)
+	defer cancel()
+	return checker.Has(ctx, manifest.ShardAddress(blobID, chunkIndex, shardIndex))
+}
+
+// printStats prints a summary of the upload
+func printStats(stats *UploadStats) {
+	duration := stats.EndTime.Sub(stats.StartTime)
+	fmt.Println("\n📈 Upload summary")
+	fmt.Printf("   Chunks processed: %d\n", stats.ChunksProcessed)
+	fmt.Printf("   Shards created:   %d\n", stats.ShardsCreated)
+	fmt.Printf("   Shards uploaded:  %d\n", stats.ShardsUploaded)
+	fmt.Printf("   Bytes stored:     %d\n", stats.LogicalBytes)
+	fmt.Printf("   Bytes on wire:    %d\n", stats.WireBytes)
+	fmt.Printf("   Duration:         %s\n", duration.Round(time.Millisecond))
+	if len(stats.Errors) > 0 {
+		fmt.Printf("   ⚠️  Errors: %d\n", len(stats.Errors))
+	}
+	if stats.ShardsTimedOut > 0 {
+		fmt.Printf("   ⚠️  Shards timed out: %d\n", stats.ShardsTimedOut)
+	}
+	if degraded := stats.DegradedChunks(); len(degraded) > 0 {
+		fmt.Printf("   ⚠️  Chunks below full redundancy: %d\n", len(degraded))
+	}
+	stats.PrintBreakdown()
+}
+
+// addError records a failure; safe for concurrent use
+func (s *UploadStats) addError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Errors = append(s.Errors, err)
+}
+
+// addShardResult records how a shard upload ended; safe for concurrent use
+func (s *UploadStats) addShardResult(res ShardUploadResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ShardResults = append(s.ShardResults, res)
+}
+
+// recordFarmerDuration adds time spent uploading to a farmer; safe for concurrent use
+func (s *UploadStats) recordFarmerDuration(endpoint string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.FarmerDurations == nil {
+		s.FarmerDurations = make(map[string]time.Duration)
+	}
+	s.FarmerDurations[endpoint] += d
+}
+
+// recordRedundancy stores the achieved shard count for every chunk in the manifest
+func (s *UploadStats) recordRedundancy(m *manifest.Manifest, stored map[int]int) {
+	s.ChunkRedundancy = make(map[int]int, len(m.Chunks))
+	for _, chunk := range m.Chunks {
+		s.ChunkRedundancy[chunk.Index] = stored[chunk.Index]
+	}
+}
+
+// DegradedChunks returns chunk indices stored with fewer than TotalShards shards
+func (s *UploadStats) DegradedChunks() []int {
+	var degraded []int
+	for index, n := range s.ChunkRedundancy {
+		if n < chunker.TotalShards {
+			degraded = append(degraded, index)
+		}
+	}
+	sort.Ints(degraded)
+	return degraded
+}
+
+// PhaseTotal returns the summed time of all pipeline phases
+func (s *UploadStats) PhaseTotal() time.Duration {
+	return s.HashDuration + s.ChunkDuration + s.EncryptDuration + s.ShardDuration + s.UploadDuration
+}
+
+// PrintBreakdown prints where upload time went, per phase and per farmer
+func (s *UploadStats) PrintBreakdown() {
+	s.WriteBreakdown(os.Stdout)
+}
+
+// WriteBreakdown writes the PrintBreakdown report to w
+func (s *UploadStats) WriteBreakdown(w io.Writer) {
+	total := s.PhaseTotal()
+	phases := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"Hash", s.HashDuration},
+		{"Chunk", s.ChunkDuration},
+		{"Encrypt", s.EncryptDuration},
+		{"Shard", s.ShardDuration},
+		{"Upload", s.UploadDuration},
+	}
+
+	fmt.Fprintln(w, "\n⏱️  Phase breakdown")
+	for _, p := range phases {
+		pct := 0.0
+		if total > 0 {
+			pct = float64(p.d) / float64(total) * 100
+		}
+		fmt.Fprintf(w, "   %-8s %12s  %5.1f%%\n", p.name, p.d.Round(time.Microsecond), pct)
+	}
+
+	if len(s.FarmerDurations) == 0 {
+		return
+	}
+
+	// Sort endpoints for stable output
+	endpoints := make([]string, 0, len(s.FarmerDurations))
+	for endpoint := range s.FarmerDurations {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+
+	fmt.Fprintln(w, "   Per-farmer upload time:")
+	for _, endpoint := range endpoints {
+		fmt.Fprintf(w, "     %-40s %12s\n", endpoint, s.FarmerDurations[endpoint].Round(time.Microsecond))
+	}
+}
@@ -0,0 +1,558 @@
+package publisher
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/chunker"
+	"github.com/Abhinav-kodes/dbxn/pkg/crypto"
+	"github.com/Abhinav-kodes/dbxn/pkg/manifest"
+	"github.com/Abhinav-kodes/dbxn/pkg/retriever"
+)
+
+// ============================================================================
+// PROCESS FILE TESTS
+// ============================================================================
+
+func TestProcessFile_UniformShardSize(t *testing.T) {
+	testFile := "test-uniform.bin"
+	testData := make([]byte, 2*chunker.ChunkSize+777) // small final chunk
+	rand.Read(testData)
+	if err := os.WriteFile(testFile, testData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+
+	key, _ := crypto.GenerateKey()
+	paddedSize := chunker.ChunkSize + crypto.Overhead
+
+	chunks, shards, err := processFile(testFile, key, paddedSize, "", chunkCipher{}, &UploadStats{})
+	if err != nil {
+		t.Fatalf("processFile failed: %v", err)
+	}
+
+	expected := chunker.ExpectedShardSize(paddedSize, chunker.DataShards)
+	for _, s := range shards {
+		if s.Size != expected {
+			t.Errorf("Chunk %d shard %d has size %d, expected uniform %d", s.ChunkIndex, s.ShardIndex, s.Size, expected)
+		}
+	}
+
+	// Last chunk reconstructs and decrypts to its real plaintext
+	last := chunks[len(chunks)-1]
+	var lastShards []chunker.Shard
+	for _, s := range shards {
+		if s.ChunkIndex == last.Index {
+			lastShards = append(lastShards, s)
+		}
+	}
+	encrypted, err := chunker.ReconstructChunkWithOptions(lastShards[2:], last.Size+crypto.Overhead,
+		chunker.ReconstructOptions{PaddedSize: paddedSize})
+	if err != nil {
+		t.Fatalf("Reconstruction failed: %v", err)
+	}
+	plaintext, err := crypto.DecryptChunk(encrypted, key)
+	if err != nil {
+		t.Fatalf("Decryption failed: %v", err)
+	}
+	if !chunker.VerifyChunk(plaintext, last.Hash) {
+		t.Error("Last chunk plaintext doesn't match its hash")
+	}
+}
+
+func TestProcessFile_RecordsEncryptedSize(t *testing.T) {
+	testFile := "test-encrypted-size.bin"
+	testData := make([]byte, chunker.ChunkSize+300)
+	rand.Read(testData)
+	if err := os.WriteFile(testFile, testData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+
+	key, _ := crypto.GenerateKey()
+	for _, committed := range []bool{false, true} {
+		chunks, _, err := processFile(testFile, key, 0, "", chunkCipher{committed: committed}, &UploadStats{})
+		if err != nil {
+			t.Fatalf("processFile failed: %v", err)
+		}
+		overhead := chunkOverhead(UploadConfig{CommitChunks: committed})
+		for _, c := range chunks {
+			if c.EncryptedSize != c.Size+overhead {
+				t.Errorf("committed=%v chunk %d: encrypted size %d, expected %d", committed, c.Index, c.EncryptedSize, c.Size+overhead)
+			}
+		}
+	}
+}
+
+func TestUpload_CiphertextChunkHashes(t *testing.T) {
+	_, endpoints := newMockFleet(t, 6)
+
+	testFile := "test-ciphertext-hash.bin"
+	testData := make([]byte, chunker.ChunkSize+321)
+	rand.Read(testData)
+	if err := os.WriteFile(testFile, testData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+	manifestPath := "test-ciphertext-hash.json"
+	defer os.Remove(manifestPath)
+
+	m, _, err := Upload(UploadConfig{
+		FilePath:        testFile,
+		FarmerEndpoints: endpoints,
+		OutputPath:      manifestPath,
+		ChunkHashDomain: manifest.ChunkHashCiphertext,
+	})
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if !m.HashesCiphertext() {
+		t.Fatal("Manifest doesn't record the ciphertext hash domain")
+	}
+
+	// Manifest must not reveal plaintext chunk hashes
+	plaintextHashes, err := chunker.ChunkHashesForFile(testFile, chunker.DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, chunk := range m.Chunks {
+		if chunk.Hash == plaintextHashes[i] {
+			t.Errorf("Chunk %d: manifest records the plaintext hash", i)
+		}
+	}
+
+	// Retriever verifies against the ciphertext domain
+	reader, err := retriever.Open(m, retriever.DownloadConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	got, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if !bytes.Equal(got, testData) {
+		t.Error("Downloaded data doesn't match original")
+	}
+
+	// A plaintext hash in a ciphertext-domain manifest is rejected
+	m.Chunks[0].Hash = plaintextHashes[0]
+	reader, _ = retriever.Open(m, retriever.DownloadConfig{})
+	defer reader.Close()
+	if _, err := reader.ReadAt(make([]byte, 10), 0); err == nil {
+		t.Error("Expected hash mismatch for plaintext hash in ciphertext domain")
+	}
+
+	if _, _, err := Upload(UploadConfig{
+		FilePath:        testFile,
+		FarmerEndpoints: endpoints,
+		OutputPath:      manifestPath,
+		ChunkHashDomain: "sha1",
+	}); err == nil {
+		t.Error("Expected error for unknown hash domain")
+	}
+}
+
+func TestUpload_PhaseBreakdown(t *testing.T) {
+	_, endpoints := newMockFleet(t, 6)
+
+	testFile := "test-phases.bin"
+	testData := make([]byte, 2*chunker.ChunkSize+100)
+	rand.Read(testData)
+	if err := os.WriteFile(testFile, testData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+	manifestPath := "test-phases.json"
+	defer os.Remove(manifestPath)
+
+	_, stats, err := Upload(UploadConfig{
+		FilePath:        testFile,
+		FarmerEndpoints: endpoints,
+		OutputPath:      manifestPath,
+	})
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+
+	phases := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"Hash", stats.HashDuration},
+		{"Chunk", stats.ChunkDuration},
+		{"Encrypt", stats.EncryptDuration},
+		{"Shard", stats.ShardDuration},
+		{"Upload", stats.UploadDuration},
+	}
+	var buf bytes.Buffer
+	stats.WriteBreakdown(&buf)
+	report := buf.String()
+	for _, p := range phases {
+		if p.d <= 0 {
+			t.Errorf("%s phase not timed", p.name)
+		}
+		if !strings.Contains(report, fmt.Sprintf("   %-8s %12s", p.name, p.d.Round(time.Microsecond))) {
+			t.Errorf("%s phase missing from the breakdown:\n%s", p.name, report)
+		}
+	}
+	if stats.PhaseTotal() != stats.HashDuration+stats.ChunkDuration+stats.EncryptDuration+stats.ShardDuration+stats.UploadDuration {
+		t.Error("PhaseTotal doesn't sum the phases")
+	}
+	for _, endpoint := range endpoints {
+		if stats.FarmerDurations[endpoint] <= 0 || !strings.Contains(report, endpoint) {
+			t.Errorf("Farmer %s missing from the breakdown", endpoint)
+		}
+	}
+}
+
+func TestUpload_ChunkSize(t *testing.T) {
+	_, endpoints := newMockFleet(t, 6)
+
+	testFile := "test-chunk-size.bin"
+	testData := make([]byte, 10000)
+	rand.Read(testData)
+	if err := os.WriteFile(testFile, testData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+	manifestPath := "test-chunk-size.json"
+	defer os.Remove(manifestPath)
+
+	// By default a small file gets a chunk size fitted to it
+	m, _, err := Upload(UploadConfig{FilePath: testFile, FarmerEndpoints: endpoints, OutputPath: manifestPath})
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if m.ChunkSize != 16*1024 || m.ChunkCount != 1 {
+		t.Errorf("Expected one 16KB chunk, got %d chunks of %d", m.ChunkCount, m.ChunkSize)
+	}
+
+	// An explicit size splits the file, and uniform padding only pads up to it
+	m, _, err = Upload(UploadConfig{
+		FilePath:         testFile,
+		FarmerEndpoints:  endpoints,
+		OutputPath:       manifestPath,
+		ChunkSize:        4096,
+		UniformShardSize: true,
+	})
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if m.ChunkCount != 3 || m.PaddedSize != 4096+crypto.Overhead {
+		t.Errorf("Expected 3 chunks padded to %d, got %d padded to %d", 4096+crypto.Overhead, m.ChunkCount, m.PaddedSize)
+	}
+	reader, err := retriever.Open(m, retriever.DownloadConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	got, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if !bytes.Equal(got, testData) {
+		t.Error("Downloaded data doesn't match original")
+	}
+
+	if _, _, err := Upload(UploadConfig{
+		FilePath:        testFile,
+		FarmerEndpoints: endpoints,
+		OutputPath:      manifestPath,
+		ChunkSize:       2 * chunker.ChunkSize,
+	}); err == nil {
+		t.Error("Expected error for a chunk size above chunker.ChunkSize")
+	}
+}
+
+func TestUpload_Passphrase(t *testing.T) {
+	_, endpoints := newMockFleet(t, 6)
+
+	testFile := "test-passphrase.bin"
+	testData := make([]byte, 5000)
+	rand.Read(testData)
+	if err := os.WriteFile(testFile, testData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+	manifestPath := "test-passphrase.json"
+	defer os.Remove(manifestPath)
+
+	passphrase := []byte("correct horse battery staple")
+	m, _, err := Upload(UploadConfig{
+		FilePath:        testFile,
+		FarmerEndpoints: endpoints,
+		OutputPath:      manifestPath,
+		Passphrase:      passphrase,
+		KDF:             crypto.KDFParams{Algorithm: crypto.KDFScrypt},
+	})
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if m.EncryptionKey != "" || m.KDF == nil || m.KDF.Algorithm != crypto.KDFScrypt {
+		t.Fatalf("Expected a scrypt KDF and no stored key, got %+v", m.KDF)
+	}
+
+	// The retriever derives the same key from the recorded parameters
+	loaded, err := manifest.Load(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := retriever.Open(loaded, retriever.DownloadConfig{Passphrase: passphrase})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	got, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if !bytes.Equal(got, testData) {
+		t.Error("Downloaded data doesn't match original")
+	}
+
+	if _, err := retriever.Open(loaded, retriever.DownloadConfig{Passphrase: []byte("wrong")}); !errors.Is(err, manifest.ErrWrongKey) {
+		t.Errorf("Expected ErrWrongKey for a wrong passphrase, got %v", err)
+	}
+	if _, err := retriever.Open(loaded, retriever.DownloadConfig{}); err == nil {
+		t.Error("Expected error without a passphrase")
+	}
+
+	// Weak explicit parameters are refused
+	if _, _, err := Upload(UploadConfig{
+		FilePath:        testFile,
+		FarmerEndpoints: endpoints,
+		OutputPath:      manifestPath,
+		Passphrase:      passphrase,
+		KDF:             crypto.KDFParams{Algorithm: crypto.KDFPBKDF2, Iterations: 1000},
+	}); err == nil {
+		t.Error("Expected error for weak KDF parameters")
+	}
+}
+
+func TestUpload_EncryptFields(t *testing.T) {
+	_, endpoints := newMockFleet(t, 6)
+
+	testFile := "test-encrypt-fields.bin"
+	testData := make([]byte, 5000)
+	rand.Read(testData)
+	if err := os.WriteFile(testFile, testData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+	manifestPath := "test-encrypt-fields.json"
+	defer os.Remove(manifestPath)
+
+	m, _, err := Upload(UploadConfig{
+		FilePath:        testFile,
+		FarmerEndpoints: endpoints,
+		OutputPath:      manifestPath,
+		EncryptFields:   []string{manifest.FieldFileName, manifest.FieldOriginalFileHash},
+	})
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	key, err := m.GetEncryptionKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, err := m.DecryptedFileName(key); err != nil || name != testFile {
+		t.Errorf("Expected decrypted name %s, got %q (%v)", testFile, name, err)
+	}
+
+	// The end-to-end hash check uses the decrypted hash
+	if err := retriever.VerifyFileHash(m, retriever.DownloadConfig{}); err != nil {
+		t.Errorf("VerifyFileHash failed: %v", err)
+	}
+	reader, err := retriever.Open(m, retriever.DownloadConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	got, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if !bytes.Equal(got, testData) {
+		t.Error("Downloaded data doesn't match original")
+	}
+
+	if _, _, err := Upload(UploadConfig{
+		FilePath:        testFile,
+		FarmerEndpoints: endpoints,
+		OutputPath:      manifestPath,
+		EncryptFields:   []string{"blob_id"},
+	}); err == nil {
+		t.Error("Expected error for a field that can't be encrypted")
+	}
+}
+
+func TestUpload_PinManifest(t *testing.T) {
+	fleet, endpoints := newMockFleet(t, 6)
+
+	testFile := "test-pin-manifest.bin"
+	testData := make([]byte, 5000)
+	rand.Read(testData)
+	if err := os.WriteFile(testFile, testData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+	manifestPath := "test-pin-manifest.json"
+	defer os.Remove(manifestPath)
+
+	m, stats, err := Upload(UploadConfig{
+		FilePath:          testFile,
+		FarmerEndpoints:   endpoints,
+		OutputPath:        manifestPath,
+		PinManifest:       true,
+		PinManifestCopies: 2,
+	})
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if stats.ManifestPins != 2 {
+		t.Errorf("Expected 2 manifest pins, got %d", stats.ManifestPins)
+	}
+	for i, f := range fleet {
+		f.mu.Lock()
+		_, pinned := f.pins[m.BlobID]
+		f.mu.Unlock()
+		if pinned != (i < 2) {
+			t.Errorf("Farmer %d: pinned=%v", i, pinned)
+		}
+	}
+
+	// Recoverable from BlobID, endpoints and key alone, even with a pin holder down
+	key, err := m.GetEncryptionKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fleet[0].server.Close()
+	recovered, err := retriever.FetchManifest(m.BlobID, endpoints, key)
+	if err != nil {
+		t.Fatalf("FetchManifest failed: %v", err)
+	}
+	reader, err := retriever.Open(recovered, retriever.DownloadConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	got, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Download with recovered manifest failed: %v", err)
+	}
+	if !bytes.Equal(got, testData) {
+		t.Error("Downloaded data doesn't match original")
+	}
+
+	wrongKey := make([]byte, len(key))
+	if _, err := retriever.FetchManifest(m.BlobID, endpoints, wrongKey); err == nil {
+		t.Error("Expected error fetching with the wrong key")
+	}
+}
+
+func TestWrapShards(t *testing.T) {
+	testFile := "test-wrap.bin"
+	testData := make([]byte, 5000)
+	rand.Read(testData)
+	if err := os.WriteFile(testFile, testData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+
+	key, _ := crypto.GenerateKey()
+	wrapKey, _ := crypto.GenerateKeySize(16)
+
+	_, shards, err := processFile(testFile, key, 0, "", chunkCipher{}, &UploadStats{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := make([][]byte, len(shards))
+	for i := range shards {
+		original[i] = shards[i].Data
+	}
+
+	if err := wrapShards(shards, crypto.AlgAESGCM, wrapKey); err != nil {
+		t.Fatalf("wrapShards failed: %v", err)
+	}
+
+	for i, s := range shards {
+		if !chunker.VerifyShard(s.Data, s.Hash) || s.Size != len(s.Data) {
+			t.Errorf("Shard %d: hash/size don't describe wrapped bytes", i)
+		}
+		unwrapped, err := crypto.DecryptChunkWith(crypto.AlgAESGCM, s.Data, wrapKey)
+		if err != nil {
+			t.Fatalf("Shard %d: unwrap failed: %v", i, err)
+		}
+		if !bytes.Equal(unwrapped, original[i]) {
+			t.Errorf("Shard %d: unwrapped data mismatch", i)
+		}
+	}
+
+	if got := shardWrapName(UploadConfig{ShardWrapKey: wrapKey}); got != string(crypto.AlgXChaCha20Poly1305) {
+		t.Errorf("Expected default wrap algorithm recorded, got %q", got)
+	}
+	if got := shardWrapName(UploadConfig{}); got != "" {
+		t.Errorf("Expected no wrap recorded by default, got %q", got)
+	}
+}
+
+func TestUpload_ShardFEC(t *testing.T) {
+	testFile := "test-fec.bin"
+	testData := make([]byte, 5000)
+	rand.Read(testData)
+	if err := os.WriteFile(testFile, testData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+	manifestPath := "test-fec.json"
+	defer os.Remove(manifestPath)
+	dir, err := os.MkdirTemp(".", "test-fec-shards-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// FEC goes outside the wrap layer
+	wrapKey, _ := crypto.GenerateKey()
+	m, _, err := Upload(UploadConfig{
+		FilePath:      testFile,
+		OutputPath:    manifestPath,
+		LocalShardDir: dir,
+		ShardWrapKey:  wrapKey,
+		ShardFEC:      &chunker.DefaultShardFEC,
+	})
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if m.ShardFEC == nil || *m.ShardFEC != chunker.DefaultShardFEC {
+		t.Fatalf("Expected the shard FEC recorded, got %+v", m.ShardFEC)
+	}
+	if err := m.ValidateShardSizes(); err != nil {
+		t.Errorf("Shard sizes don't account for the FEC: %v", err)
+	}
+
+	reader, err := retriever.Open(m, retriever.DownloadConfig{ShardWrapKey: wrapKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	got, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if !bytes.Equal(got, testData) {
+		t.Error("Downloaded data doesn't match original")
+	}
+
+	if _, _, err := Upload(UploadConfig{FilePath: testFile, LocalShardDir: dir, ShardFEC: &chunker.ShardFEC{Blocks: 4}}); err == nil {
+		t.Error("Expected an FEC config without parity blocks to be rejected")
+	}
+}
@@ -0,0 +1,36 @@
+package retriever
+
+import (
+	"fmt"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/manifest"
+)
+
+// VerifyChunkChain walks the PrevHash chain of a manifest uploaded with
+// UploadConfig.ChainChunks. It detects chunks that were reordered, dropped or
+// had their hash changed using only the manifest, so chunks can be checked
+// strictly in sequence without the whole file. It does not authenticate the
+// manifest itself: anyone able to rewrite it can recompute the chain.
+func VerifyChunkChain(m *manifest.Manifest) error {
+	if len(m.Chunks) != m.ChunkCount {
+		return fmt.Errorf("manifest lists %d chunks, chunk count is %d", len(m.Chunks), m.ChunkCount)
+	}
+	if len(m.Chunks) == 0 {
+		return nil
+	}
+	if m.Chunks[0].PrevHash == "" {
+		return fmt.Errorf("manifest %s has no chunk chain", m.BlobID)
+	}
+
+	prev := manifest.ChainGenesis
+	for i, chunk := range m.Chunks {
+		if chunk.Index != i {
+			return fmt.Errorf("chunk at position %d has index %d", i, chunk.Index)
+		}
+		if chunk.PrevHash != prev {
+			return fmt.Errorf("chunk %d: chain broken (prev hash mismatch)", i)
+		}
+		prev = manifest.ChainLink(prev, chunk.Hash)
+	}
+	return nil
+}
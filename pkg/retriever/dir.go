@@ -0,0 +1,103 @@
+package retriever
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/manifest"
+)
+
+// UnpackDir recreates a directory blob made by publisher.UploadDir under dest,
+// which is created if needed. Every entry path is checked to stay inside dest
+// before anything is written, and existing files are never overwritten.
+// Chunks are fetched on demand through a BlobReader, so memory stays bounded.
+// Cancelling ctx stops between reads and leaves the files written so far.
+func UnpackDir(ctx context.Context, m *manifest.Manifest, dest string, cfg DownloadConfig) error {
+	if m == nil || len(m.Files) == 0 {
+		return fmt.Errorf("manifest is not a directory blob")
+	}
+	for _, entry := range m.Files {
+		if !filepath.IsLocal(filepath.FromSlash(entry.Path)) {
+			return fmt.Errorf("unsafe path %q in manifest", entry.Path)
+		}
+		if !entry.Dir && (entry.Offset < 0 || entry.Size < 0 || entry.Offset+entry.Size > m.FileSize) {
+			return fmt.Errorf("%s: byte range [%d, %d) outside blob of %d bytes", entry.Path, entry.Offset, entry.Offset+entry.Size, m.FileSize)
+		}
+	}
+
+	blob, err := Open(m, cfg)
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dest, err)
+	}
+
+	for _, entry := range m.Files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		path := filepath.Join(dest, filepath.FromSlash(entry.Path))
+		if entry.Dir {
+			// Writable while unpacking; recorded modes are applied at the end
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return fmt.Errorf("failed to create %s: %w", entry.Path, err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to create parent of %s: %w", entry.Path, err)
+		}
+
+		data := &ctxReader{ctx: ctx, r: io.NewSectionReader(blob, entry.Offset, entry.Size)}
+		if err := writeNewFile(path, fs.FileMode(entry.Mode).Perm(), data); err != nil {
+			return fmt.Errorf("failed to unpack %s: %w", entry.Path, err)
+		}
+	}
+
+	// Deepest directories first, so a read-only parent doesn't block its children
+	for i := len(m.Files) - 1; i >= 0; i-- {
+		entry := m.Files[i]
+		if !entry.Dir {
+			continue
+		}
+		path := filepath.Join(dest, filepath.FromSlash(entry.Path))
+		if err := os.Chmod(path, fs.FileMode(entry.Mode).Perm()); err != nil {
+			return fmt.Errorf("failed to set mode of %s: %w", entry.Path, err)
+		}
+	}
+	return nil
+}
+
+// writeNewFile creates path (failing if it exists) and copies r into it
+func writeNewFile(path string, mode fs.FileMode, r io.Reader) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// ctxReader fails reads once ctx is done
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
@@ -0,0 +1,120 @@
+package retriever
+
+import (
+	"path/filepath"
+	"sync"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/manifest"
+	"github.com/Abhinav-kodes/dbxn/pkg/scratch"
+)
+
+// defaultParallelism is the number of chunks downloaded at once by default
+const defaultParallelism = 4
+
+// Download reconstructs a blob into outputPath, fetching and decrypting
+// cfg.Parallelism chunks at once. On failure outputPath is removed.
+func Download(m *manifest.Manifest, outputPath string, cfg DownloadConfig) error {
+	return DownloadVersioned([]*manifest.Manifest{m}, outputPath, cfg)
+}
+
+// sweepStaleTemp removes scratch temp files crashed runs left in cfg.TempDir,
+// next to outputPath and below m's directories on local endpoints
+func sweepStaleTemp(m *manifest.Manifest, outputPath string, cfg DownloadConfig) {
+	scratch.CleanupStale(cfg.TempDir, scratch.StaleAge)
+	scratch.CleanupStale(filepath.Dir(outputPath), scratch.StaleAge)
+	for _, farmer := range m.Farmers {
+		dir, ok := manifest.LocalEndpointDir(farmer.Endpoint)
+		if ok && filepath.IsLocal(m.BlobID) {
+			scratch.CleanupStaleTree(filepath.Join(dir, m.BlobID), scratch.StaleAge)
+		}
+	}
+}
+
+// orderedChunk is a fetched chunk waiting for its turn to be emitted
+type orderedChunk struct {
+	index int
+	data  []byte
+	err   error
+}
+
+// fetchChunksOrdered runs fetch for chunks 0..n-1 on cfg.Parallelism workers and
+// passes the results to emit strictly in index order. A chunk is only started
+// once fewer than cfg.MaxBufferedChunks are fetched or in flight but not yet
+// emitted, so a slow chunk stalls the workers instead of letting finished chunks
+// pile up. Each worker has its own DownloadStats; once all have stopped they are
+// added to cfg.Stats, if set. Stops at the first error.
+func fetchChunksOrdered(n int, cfg DownloadConfig, fetch func(i int, stats *DownloadStats) ([]byte, error), emit func(i int, data []byte) error) error {
+	parallelism := cfg.Parallelism
+	if parallelism <= 0 {
+		parallelism = defaultParallelism
+	}
+	if cfg.ReadRepair {
+		parallelism = 1
+	}
+	window := cfg.MaxBufferedChunks
+	if window <= 0 {
+		window = 2 * parallelism
+	}
+	window = max(window, parallelism)
+
+	done := make(chan struct{})
+	slots := make(chan struct{}, window) // one per chunk started but not emitted
+	jobs := make(chan int)
+	results := make(chan orderedChunk, window) // never blocks: at most window chunks are out
+
+	go func() {
+		defer close(jobs)
+		for i := 0; i < n; i++ {
+			select {
+			case slots <- struct{}{}:
+			case <-done:
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	workerStats := make([]DownloadStats, parallelism)
+	for w := 0; w < parallelism; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				data, err := fetch(i, &workerStats[w])
+				results <- orderedChunk{index: i, data: data, err: err}
+			}
+		}()
+	}
+	defer func() {
+		wg.Wait()
+		if cfg.Stats != nil {
+			for _, stats := range workerStats {
+				cfg.Stats.add(stats)
+			}
+		}
+	}()
+	defer close(done)
+
+	ready := make(map[int][]byte, window)
+	for next := 0; next < n; {
+		res := <-results
+		if res.err != nil {
+			return res.err
+		}
+		ready[res.index] = res.data
+		for data, ok := ready[next]; ok; data, ok = ready[next] {
+			delete(ready, next)
+			if err := emit(next, data); err != nil {
+				return err
+			}
+			<-slots
+			next++
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package retriever
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/chunker"
+	"github.com/Abhinav-kodes/dbxn/pkg/manifest"
+)
+
+// ExportBlob downloads every shard of m and writes them with the manifest to a
+// tar at archivePath, for cold backup or moving the blob to another fleet with
+// publisher.ImportBlob. Shards are stored as farmers hold them (still encrypted,
+// and wrapped if ShardWrap is set), so nothing is decrypted and no key is needed
+// beyond what the manifest already carries. See manifest.ArchiveManifestName for
+// the layout.
+//
+// Shards are fetched one at a time with cfg's tokens and retry settings and
+// verified against the manifest. Every shard must be fetched: an archive missing
+// redundancy would import as a degraded blob, so the export fails instead and
+// the partial archive is removed.
+func ExportBlob(m *manifest.Manifest, archivePath string, cfg DownloadConfig) (err error) {
+	if m == nil || len(m.Shards) == 0 {
+		return fmt.Errorf("manifest has no shards to export")
+	}
+
+	f, err := os.Create(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil && cerr != nil {
+			err = fmt.Errorf("failed to close archive: %w", cerr)
+		}
+		if err != nil {
+			os.Remove(archivePath)
+		}
+	}()
+
+	tw := tar.NewWriter(f)
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	if err := writeArchiveEntry(tw, manifest.ArchiveManifestName, data); err != nil {
+		return err
+	}
+
+	for _, shard := range m.Shards {
+		holder := m.GetFarmerForShard(shard)
+		if holder == nil {
+			return fmt.Errorf("chunk %d shard %d: farmer %d not in manifest", shard.ChunkIndex, shard.ShardIndex, shard.FarmerIndex)
+		}
+		fetch := manifest.ShardFetch{
+			Endpoint:   holder.Endpoint,
+			BlobID:     m.BlobID,
+			ChunkIndex: shard.ChunkIndex,
+			ShardIndex: shard.ShardIndex,
+			Hash:       shard.Hash,
+			Size:       shard.Size,
+		}
+		data, _, err := fetchShardWithRetry(context.Background(), fetch, cfg)
+		if err != nil {
+			return fmt.Errorf("chunk %d shard %d from %s: %w", shard.ChunkIndex, shard.ShardIndex, holder.Endpoint, err)
+		}
+		if !chunker.VerifyShard(data, shard.Hash) {
+			return fmt.Errorf("chunk %d shard %d from %s failed hash verification", shard.ChunkIndex, shard.ShardIndex, holder.Endpoint)
+		}
+		if err := writeArchiveEntry(tw, manifest.ArchiveShardPath(m.BlobID, shard.ChunkIndex, shard.ShardIndex), data); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+	return nil
+}
+
+// writeArchiveEntry writes one regular file to a blob archive
+func writeArchiveEntry(tw *tar.Writer, name string, data []byte) error {
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(data)),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
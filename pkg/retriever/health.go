@@ -0,0 +1,204 @@
+package retriever
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/chunker"
+	"github.com/Abhinav-kodes/dbxn/pkg/manifest"
+)
+
+// ChunkState classifies a chunk in a HealthReport
+type ChunkState int
+
+const (
+	// ChunkHealthy: every shard was fetched and verified
+	ChunkHealthy ChunkState = iota
+	// ChunkDegraded: some shards are missing, corrupt or unreachable, but at
+	// least DataShards verified, so the rest can be regenerated
+	ChunkDegraded
+	// ChunkLost: fewer than DataShards shards verified; the chunk can't be read
+	ChunkLost
+)
+
+func (s ChunkState) String() string {
+	switch s {
+	case ChunkHealthy:
+		return "healthy"
+	case ChunkDegraded:
+		return "degraded"
+	default:
+		return "lost"
+	}
+}
+
+// ChunkHealth is one chunk's entry in a HealthReport
+type ChunkHealth struct {
+	ChunkIndex int
+	State      ChunkState
+	Intact     []int // shard indices fetched and verified
+	Damaged    []int // shard indices missing, corrupt or unreachable
+
+	// Set by RepairFromReport for degraded chunks
+	Repaired  []int // shard indices stored again
+	RepairErr error // why the chunk isn't fully repaired (nil = it is)
+}
+
+// HealthReport is a scrub of a blob: the state of every shard of every chunk
+type HealthReport struct {
+	BlobID string
+	Chunks []ChunkHealth // in manifest chunk order
+}
+
+// Degraded returns the indices of the chunks RepairFromReport would repair
+func (r *HealthReport) Degraded() []int {
+	var indices []int
+	for _, chunk := range r.Chunks {
+		if chunk.State == ChunkDegraded {
+			indices = append(indices, chunk.ChunkIndex)
+		}
+	}
+	return indices
+}
+
+// CheckHealth scrubs a blob: every shard of every chunk is fetched and checked
+// against its manifest hash, cfg.Parallelism at a time (default 4). Nothing is
+// decrypted, so no key is needed. Shards whose fetch failed even transiently
+// count as damaged: the report describes what a reader could get right now.
+func CheckHealth(m *manifest.Manifest, cfg DownloadConfig) (*HealthReport, error) {
+	if m.IsSegmented() {
+		return nil, fmt.Errorf("manifest %s is segmented; check each segment", m.BlobID)
+	}
+	parallelism := cfg.Parallelism
+	if parallelism <= 0 {
+		parallelism = defaultParallelism
+	}
+
+	report := &HealthReport{BlobID: m.BlobID, Chunks: make([]ChunkHealth, len(m.Chunks))}
+	var fetches []manifest.ShardFetch
+	for i, chunk := range m.Chunks {
+		report.Chunks[i].ChunkIndex = chunk.Index
+		plan, err := m.FetchPlanForChunk(chunk.Index)
+		if err != nil {
+			return nil, err
+		}
+		fetches = append(fetches, plan...)
+	}
+
+	var mu sync.Mutex
+	intact := make(map[[2]int]bool, len(fetches)) // chunk, shard index → verified
+	jobs := make(chan manifest.ShardFetch)
+	var wg sync.WaitGroup
+	for w := 0; w < parallelism; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for fetch := range jobs {
+				data, _, err := fetchShardWithRetry(context.Background(), fetch, cfg)
+				if err == nil && chunker.VerifyShard(data, fetch.Hash) {
+					mu.Lock()
+					intact[[2]int{fetch.ChunkIndex, fetch.ShardIndex}] = true
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, fetch := range fetches {
+		jobs <- fetch
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i := range report.Chunks {
+		entry := &report.Chunks[i]
+		for _, shard := range m.GetShardsForChunk(entry.ChunkIndex) {
+			if intact[[2]int{entry.ChunkIndex, shard.ShardIndex}] {
+				entry.Intact = append(entry.Intact, shard.ShardIndex)
+			} else {
+				entry.Damaged = append(entry.Damaged, shard.ShardIndex)
+			}
+		}
+		slices.Sort(entry.Intact)
+		slices.Sort(entry.Damaged)
+		switch {
+		case len(entry.Intact) < m.DataShards:
+			entry.State = ChunkLost
+		case len(entry.Damaged) > 0:
+			entry.State = ChunkDegraded
+		}
+	}
+	return report, nil
+}
+
+// RepairFromReport repairs the chunks report marks degraded and touches no
+// other. Each is reconstructed from its surviving shards, checked against its
+// chunk hash, and its damaged shards regenerated and stored on their farmer,
+// or on a farmer not yet holding a shard of the chunk if that fails (see
+// DownloadConfig.ReadRepair; cfg.RepairSink stores them). The outcome of each
+// degraded chunk is recorded in its report entry. Returns a copy of m with
+// relocated shards' placements updated; save it in place of m. m is not
+// modified. Rerun CheckHealth on the result to pick up where a partial repair
+// left off.
+func RepairFromReport(m *manifest.Manifest, report *HealthReport, cfg DownloadConfig) (*manifest.Manifest, error) {
+	if report == nil || report.BlobID != m.BlobID {
+		return nil, fmt.Errorf("health report is not for blob %s", m.BlobID)
+	}
+	if m.ShardWrap != "" {
+		return nil, fmt.Errorf("shards are wrapped with %s and can't be regenerated byte for byte", m.ShardWrap)
+	}
+	key, err := resolveKey(m, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	out := *m
+	out.Chunks = slices.Clone(m.Chunks)
+	out.Shards = slices.Clone(m.Shards)
+	for i := range report.Chunks {
+		entry := &report.Chunks[i]
+		if entry.State != ChunkDegraded {
+			continue
+		}
+		entry.Repaired, entry.RepairErr = repairDegradedChunk(&out, entry, key, cfg)
+	}
+	return &out, nil
+}
+
+// repairDegradedChunk reconstructs one chunk from the shards the report found
+// intact and stores its damaged shards again. Returns the shards stored.
+func repairDegradedChunk(m *manifest.Manifest, entry *ChunkHealth, key []byte, cfg DownloadConfig) ([]int, error) {
+	at := slices.IndexFunc(m.Chunks, func(c manifest.ChunkMeta) bool { return c.Index == entry.ChunkIndex })
+	if at < 0 {
+		return nil, fmt.Errorf("chunk %d not in manifest", entry.ChunkIndex)
+	}
+	chunk, err := withEncryptedSize(m, m.Chunks[at])
+	if err != nil {
+		return nil, err
+	}
+
+	// Fetch only from the shards known to be intact
+	survivors := *m
+	survivors.Shards = slices.DeleteFunc(slices.Clone(m.GetShardsForChunk(chunk.Index)), func(s manifest.ShardMeta) bool {
+		return slices.Contains(entry.Damaged, s.ShardIndex)
+	})
+	shards, err := fetchChunkShards(&survivors, chunk.Index, cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := reconstructAndDecrypt(shards, chunk, key, m.ChunkHashDomain, m.ChunkOptions(chunk.Index), chunker.ReconstructOptions{
+		PaddedSize:   m.PaddedSize,
+		DataShards:   m.DataShards,
+		ParityShards: m.ParityShards,
+		TotalShards:  m.TotalShards,
+	}); err != nil {
+		return nil, err
+	}
+
+	repaired := repairChunk(m, chunk.Index, shards, entry.Damaged, cfg, nil)
+	if len(repaired) < len(entry.Damaged) {
+		return repaired, fmt.Errorf("chunk %d: %d of %d damaged shards couldn't be stored again", chunk.Index, len(entry.Damaged)-len(repaired), len(entry.Damaged))
+	}
+	return repaired, nil
+}
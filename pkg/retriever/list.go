@@ -0,0 +1,67 @@
+package retriever
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/farmer"
+)
+
+// maxBlobListPage caps how much a farmer may send back for one page of blob IDs
+const maxBlobListPage = 16 << 20 // 16MB
+
+// ListFarmerBlobs returns every blob ID a farmer reports holding shards for, in
+// ascending order, following farmer.BlobListPath pages until the last one.
+// Authenticates with cfg.FarmerTokens. Compare the result with the BlobIDs of known
+// manifests to find orphaned shards.
+func ListFarmerBlobs(endpoint string, cfg DownloadConfig) ([]string, error) {
+	var ids []string
+	after := ""
+	for {
+		page, err := fetchBlobListPage(endpoint, after, cfg.FarmerTokens)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, page.BlobIDs...)
+		if page.Next == "" {
+			return ids, nil
+		}
+		// A cursor that doesn't advance would page forever
+		if page.Next <= after {
+			return nil, fmt.Errorf("farmer returned non-advancing cursor %q after %q", page.Next, after)
+		}
+		after = page.Next
+	}
+}
+
+// fetchBlobListPage requests the page of blob IDs following after
+func fetchBlobListPage(endpoint, after string, tokens farmer.AuthTokens) (*farmer.BlobListResponse, error) {
+	u := endpoint + farmer.BlobListPath
+	if after != "" {
+		u += "?" + url.Values{"after": {after}}.Encode()
+	}
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	tokens.Apply(req, endpoint)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("farmer returned %s", resp.Status)
+	}
+
+	var page farmer.BlobListResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxBlobListPage)).Decode(&page); err != nil {
+		return nil, fmt.Errorf("failed to decode blob list: %w", err)
+	}
+	return &page, nil
+}
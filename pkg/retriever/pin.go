@@ -0,0 +1,65 @@
+package retriever
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/manifest"
+	"github.com/Abhinav-kodes/dbxn/pkg/transport"
+)
+
+// maxPinnedManifest caps how much a farmer may send back for a sealed manifest
+const maxPinnedManifest = 64 << 20 // 64MB
+
+// FetchManifest recovers a manifest pinned with publisher.UploadConfig.PinManifest.
+// Endpoints are tried in order; the first copy that decrypts under key and
+// describes blobID is returned. Farmers can't forge a copy without the key.
+func FetchManifest(blobID string, endpoints []string, key []byte) (*manifest.Manifest, error) {
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("at least one farmer endpoint is required")
+	}
+
+	var errs []error
+	for _, endpoint := range endpoints {
+		sealed, err := fetchPinnedManifest(endpoint, blobID)
+		if err == nil {
+			var m *manifest.Manifest
+			if m, err = manifest.OpenSealed(sealed, key, blobID); err == nil {
+				return m, nil
+			}
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", endpoint, err))
+	}
+	return nil, fmt.Errorf("manifest %s not recovered: %w", blobID, errors.Join(errs...))
+}
+
+// fetchPinnedManifest downloads the sealed manifest of blobID from one farmer
+func fetchPinnedManifest(endpoint, blobID string) ([]byte, error) {
+	if dir, ok := manifest.LocalEndpointDir(endpoint); ok {
+		path, err := manifest.LocalPinPath(dir, blobID)
+		if err != nil {
+			return nil, err
+		}
+		return transport.ReadFileLimited(path, maxPinnedManifest)
+	}
+	resp, err := http.Get(manifest.PinURL(endpoint, blobID))
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("farmer returned %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxPinnedManifest+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	if len(data) > maxPinnedManifest {
+		return nil, fmt.Errorf("manifest exceeds %d bytes", maxPinnedManifest)
+	}
+	return data, nil
+}
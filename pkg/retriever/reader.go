@@ -0,0 +1,395 @@
+package retriever
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"sync"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/manifest"
+)
+
+// defaultCacheSize is the number of decrypted chunks a BlobReader keeps by default
+const defaultCacheSize = 8
+
+// BlobReader gives random access to a blob, fetching and decrypting chunks on demand.
+// Implements io.ReaderAt, io.ReadSeeker and io.Closer, so it works with standard
+// APIs such as http.ServeContent without downloading the whole file.
+type BlobReader struct {
+	m      *manifest.Manifest
+	key    []byte
+	chunks map[int]manifest.ChunkMeta // chunk index → metadata
+	size   int64
+	cfg    DownloadConfig // fetch options (Overfetch, FarmerTokens)
+	load   SegmentLoader  // segmented blobs only: fetches segment manifests
+
+	segment    *manifest.Manifest // segment r.chunks was filled from (segmented blobs)
+	segmentRef manifest.SegmentRef
+
+	seq sync.Mutex // serializes Read and Seek, which may wait on fetches without mu
+
+	mu       sync.Mutex // guards everything below; released while a chunk is fetched
+	offset   int64      // current position for Read/Seek
+	hasher   hash.Hash  // running hash of Read from the start of the blob
+	hashed   int64      // bytes fed to hasher, all read in sequence from offset 0
+	cache    *chunkCache
+	inflight map[int]*chunkFetch // chunk index → fetch in progress
+	stats    DownloadStats
+	closed   bool
+}
+
+// chunkFetch is a chunk being fetched; readers wanting the same chunk wait on
+// done for its result instead of fetching it again
+type chunkFetch struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// Open prepares a lazy reader over a blob. Nothing is fetched until the first read.
+func Open(m *manifest.Manifest, cfg DownloadConfig) (*BlobReader, error) {
+	if m == nil {
+		return nil, fmt.Errorf("manifest is required")
+	}
+	if m.ChunkSize <= 0 {
+		return nil, fmt.Errorf("invalid chunk size %d in manifest", m.ChunkSize)
+	}
+	if m.IsSegmented() {
+		return nil, fmt.Errorf("manifest %s is segmented; use OpenSegmented", m.BlobID)
+	}
+	if m.Draft {
+		return nil, fmt.Errorf("manifest %s is an upload draft; finish it with publisher.ResumeUpload", m.BlobID)
+	}
+
+	if err := m.ValidateShardSizes(); err != nil {
+		return nil, err
+	}
+	key, err := resolveKey(m, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make(map[int]manifest.ChunkMeta, len(m.Chunks))
+	for _, chunk := range m.Chunks {
+		chunks[chunk.Index] = chunk
+	}
+
+	cacheSize := cfg.CacheSize
+	if cacheSize <= 0 {
+		cacheSize = defaultCacheSize
+	}
+
+	return &BlobReader{
+		m:      m,
+		key:    key,
+		chunks: chunks,
+		size:   m.FileSize,
+		cfg:    cfg,
+		hasher: sha256.New(),
+		cache:  newChunkCache(cacheSize),
+	}, nil
+}
+
+// SegmentLoader returns the segment manifest ref points at, e.g. by reading it
+// from disk or fetching it from a farmer. It needn't check it: the reader
+// verifies every segment against the root before using it.
+type SegmentLoader func(ref manifest.SegmentRef) (*manifest.Manifest, error)
+
+// OpenSegmented is Open for the root of a segmented blob (manifest.Segment).
+// Segments are loaded through load as reads reach their chunks, and only the
+// most recent one is kept, so memory stays bounded however many shards the
+// blob has.
+func OpenSegmented(root *manifest.Manifest, load SegmentLoader, cfg DownloadConfig) (*BlobReader, error) {
+	if root == nil || load == nil {
+		return nil, fmt.Errorf("root manifest and segment loader are required")
+	}
+	if !root.IsSegmented() {
+		return nil, fmt.Errorf("manifest %s is not segmented", root.BlobID)
+	}
+	if root.ChunkSize <= 0 {
+		return nil, fmt.Errorf("invalid chunk size %d in manifest", root.ChunkSize)
+	}
+	if err := root.ValidateSegments(); err != nil {
+		return nil, err
+	}
+	key, err := resolveKey(root, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheSize := cfg.CacheSize
+	if cacheSize <= 0 {
+		cacheSize = defaultCacheSize
+	}
+
+	return &BlobReader{
+		m:      root,
+		key:    key,
+		size:   root.FileSize,
+		cfg:    cfg,
+		load:   load,
+		hasher: sha256.New(),
+		cache:  newChunkCache(cacheSize),
+	}, nil
+}
+
+// Size returns the blob's plaintext size
+func (r *BlobReader) Size() int64 {
+	return r.size
+}
+
+// Stats returns a snapshot of fetch statistics so far
+func (r *BlobReader) Stats() DownloadStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stats
+}
+
+// ReadAt reads len(p) bytes starting at off, translating the range into chunk
+// fetches. Safe for concurrent use: calls needing different chunks fetch them in
+// parallel, and calls needing the same chunk share one fetch.
+func (r *BlobReader) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.readAt(p, off)
+}
+
+// readAt implements ReadAt; caller holds r.mu (see chunk)
+func (r *BlobReader) readAt(p []byte, off int64) (int, error) {
+	if r.closed {
+		return 0, errors.New("blob reader is closed")
+	}
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	if off >= r.size {
+		return 0, io.EOF
+	}
+
+	chunkSize := int64(r.m.ChunkSize)
+	n := 0
+	for n < len(p) && off < r.size {
+		if r.closed { // closed while a fetch had r.mu released
+			return n, errors.New("blob reader is closed")
+		}
+		index := int(off / chunkSize)
+		data, err := r.chunk(index)
+		if err != nil {
+			return n, err
+		}
+
+		within := int(off % chunkSize)
+		if within >= len(data) {
+			return n, fmt.Errorf("chunk %d is shorter than expected", index)
+		}
+		copied := copy(p[n:], data[within:])
+		n += copied
+		off += int64(copied)
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Read reads from the current offset and advances it. While reads run in
+// sequence from the start of the blob, their bytes are hashed; reaching the end
+// that way checks the whole blob against the manifest's OriginalFileHash and
+// returns a *FileHashError instead of io.EOF on mismatch.
+func (r *BlobReader) Read(p []byte) (int, error) {
+	r.seq.Lock()
+	defer r.seq.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n, err := r.readAt(p, r.offset)
+	if r.offset == r.hashed {
+		r.hasher.Write(p[:n])
+		r.hashed += int64(n)
+	}
+	r.offset += int64(n)
+
+	if err == io.EOF && r.hashed == r.size && r.m.OriginalFileHash != "" {
+		expected, err := r.m.DecryptedOriginalFileHash(r.key)
+		if err != nil {
+			return n, err
+		}
+		if got := hex.EncodeToString(r.hasher.Sum(nil)); got != expected {
+			return n, &FileHashError{Expected: expected, Got: got}
+		}
+	}
+	return n, err
+}
+
+// Seek sets the offset for the next Read
+func (r *BlobReader) Seek(offset int64, whence int) (int64, error) {
+	r.seq.Lock()
+	defer r.seq.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = r.offset + offset
+	case io.SeekEnd:
+		abs = r.size + offset
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if abs < 0 {
+		return 0, fmt.Errorf("negative position %d", abs)
+	}
+
+	r.offset = abs
+	return abs, nil
+}
+
+// Close releases cached chunks; further reads fail
+func (r *BlobReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closed = true
+	r.cache = newChunkCache(1)
+	r.segment, r.chunks = nil, nil
+	return nil
+}
+
+// chunk returns decrypted chunk data, fetching it on cache miss. Caller holds
+// r.mu, which is released for the fetch itself (or while waiting on another
+// reader's fetch of the same chunk) so other chunks can be read meanwhile.
+func (r *BlobReader) chunk(index int) ([]byte, error) {
+	if data, ok := r.cache.get(index); ok {
+		return data, nil
+	}
+	if call, ok := r.inflight[index]; ok {
+		r.mu.Unlock()
+		<-call.done
+		r.mu.Lock()
+		return call.data, call.err
+	}
+
+	m := r.m
+	if r.load != nil {
+		seg, err := r.loadSegment(index)
+		if err != nil {
+			return nil, err
+		}
+		m = seg
+	}
+	meta, ok := r.chunks[index]
+	if !ok {
+		return nil, fmt.Errorf("chunk %d not in manifest", index)
+	}
+
+	call := &chunkFetch{done: make(chan struct{})}
+	if r.inflight == nil {
+		r.inflight = make(map[int]*chunkFetch)
+	}
+	r.inflight[index] = call
+	r.mu.Unlock()
+
+	var stats DownloadStats
+	call.data, call.err = fetchChunk(m, meta, r.key, r.cfg, &stats)
+
+	r.mu.Lock()
+	delete(r.inflight, index)
+	close(call.done)
+	r.stats.add(stats)
+	if call.err != nil {
+		return nil, call.err
+	}
+	if !r.closed {
+		r.cache.put(index, call.data)
+	}
+	return call.data, nil
+}
+
+// loadSegment makes the segment holding chunk index current, loading and
+// verifying it unless it already is; caller holds r.mu
+func (r *BlobReader) loadSegment(index int) (*manifest.Manifest, error) {
+	ref, err := r.m.SegmentFor(index)
+	if err != nil {
+		return nil, err
+	}
+	if r.segment != nil && r.segmentRef == ref {
+		return r.segment, nil
+	}
+
+	seg, err := r.load(ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load segment %d: %w", ref.Index, err)
+	}
+	if err := r.m.VerifySegment(ref, seg); err != nil {
+		return nil, err
+	}
+	if err := seg.ValidateShardSizes(); err != nil {
+		return nil, fmt.Errorf("segment %d: %w", ref.Index, err)
+	}
+
+	r.chunks = make(map[int]manifest.ChunkMeta, len(seg.Chunks))
+	for _, chunk := range seg.Chunks {
+		r.chunks[chunk.Index] = chunk
+	}
+	r.segment, r.segmentRef = seg, ref
+	return seg, nil
+}
+
+// chunkCache is a bounded LRU of decrypted chunks (not safe for concurrent use)
+type chunkCache struct {
+	capacity int
+	order    *list.List            // front = most recently used
+	items    map[int]*list.Element // chunk index → element
+}
+
+// cacheEntry is the value stored in chunkCache.order
+type cacheEntry struct {
+	index int
+	data  []byte
+}
+
+func newChunkCache(capacity int) *chunkCache {
+	return &chunkCache{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[int]*list.Element),
+	}
+}
+
+func (c *chunkCache) get(index int) ([]byte, bool) {
+	elem, ok := c.items[index]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).data, true
+}
+
+func (c *chunkCache) put(index int, data []byte) {
+	if elem, ok := c.items[index]; ok {
+		elem.Value.(*cacheEntry).data = data
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.items[index] = c.order.PushFront(&cacheEntry{index: index, data: data})
+
+	// Evict least recently used
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).index)
+	}
+}
+
+func (c *chunkCache) len() int {
+	return c.order.Len()
+}
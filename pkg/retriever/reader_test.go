@@ -0,0 +1,284 @@
+package retriever
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/chunker"
+	"github.com/Abhinav-kodes/dbxn/pkg/crypto"
+	"github.com/Abhinav-kodes/dbxn/pkg/manifest"
+)
+
+// ============================================================================
+// BLOB READER TESTS
+// ============================================================================
+
+func TestBlobReader_ReadAll(t *testing.T) {
+	data := randomData(3*chunker.ChunkSize + 1234)
+	m, _ := newTestBlob(t, data, 6)
+
+	r, err := Open(m, DownloadConfig{})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer r.Close()
+
+	if r.Size() != int64(len(data)) {
+		t.Errorf("Expected size %d, got %d", len(data), r.Size())
+	}
+
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("Read data doesn't match original")
+	}
+}
+
+func TestBlobReader_ReadAtAcrossChunks(t *testing.T) {
+	data := randomData(3*chunker.ChunkSize + 1234)
+	m, _ := newTestBlob(t, data, 6)
+
+	r, err := Open(m, DownloadConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// Range spanning the chunk 0/1 boundary
+	off := int64(chunker.ChunkSize - 100)
+	buf := make([]byte, 300)
+	n, err := r.ReadAt(buf, off)
+	if err != nil {
+		t.Fatalf("ReadAt failed: %v", err)
+	}
+	if n != 300 || !bytes.Equal(buf, data[off:off+300]) {
+		t.Error("Cross-chunk ReadAt mismatch")
+	}
+
+	// Read past the end returns the tail and io.EOF
+	tail := make([]byte, 100)
+	n, err = r.ReadAt(tail, int64(len(data)-50))
+	if err != io.EOF || n != 50 {
+		t.Errorf("Expected 50 bytes and io.EOF, got %d, %v", n, err)
+	}
+	if !bytes.Equal(tail[:50], data[len(data)-50:]) {
+		t.Error("Tail data mismatch")
+	}
+
+	// Offset beyond the blob
+	if _, err := r.ReadAt(tail, int64(len(data))); err != io.EOF {
+		t.Errorf("Expected io.EOF at end of blob, got %v", err)
+	}
+}
+
+func TestBlobReader_Seek(t *testing.T) {
+	data := randomData(2*chunker.ChunkSize + 10)
+	m, _ := newTestBlob(t, data, 6)
+
+	r, err := Open(m, DownloadConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	pos, err := r.Seek(-10, io.SeekEnd)
+	if err != nil || pos != int64(len(data)-10) {
+		t.Fatalf("Seek from end failed: %d, %v", pos, err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data[len(data)-10:]) {
+		t.Error("Data after SeekEnd mismatch")
+	}
+
+	if _, err := r.Seek(-1, io.SeekStart); err == nil {
+		t.Error("Expected error seeking before start")
+	}
+}
+
+func TestBlobReader_CacheAvoidsRefetch(t *testing.T) {
+	data := randomData(3*chunker.ChunkSize + 10)
+	m, fleet := newTestBlob(t, data, 6)
+
+	r, err := Open(m, DownloadConfig{CacheSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	buf := make([]byte, 10)
+	r.ReadAt(buf, 0)
+	afterFirst := totalRequests(fleet)
+	if afterFirst != chunker.DataShards {
+		t.Errorf("Expected %d shard fetches for one chunk, got %d", chunker.DataShards, afterFirst)
+	}
+
+	// Cache hit: no new requests
+	r.ReadAt(buf, 100)
+	if totalRequests(fleet) != afterFirst {
+		t.Error("Expected cached chunk to be served without fetching")
+	}
+
+	// Touch chunks 1 and 2, evicting chunk 0 from a 2-entry cache
+	r.ReadAt(buf, int64(chunker.ChunkSize))
+	r.ReadAt(buf, int64(2*chunker.ChunkSize))
+	if r.cache.len() != 2 {
+		t.Errorf("Expected cache bounded at 2, got %d", r.cache.len())
+	}
+
+	before := totalRequests(fleet)
+	r.ReadAt(buf, 0)
+	if totalRequests(fleet) == before {
+		t.Error("Expected evicted chunk 0 to be refetched")
+	}
+}
+
+func TestBlobReader_ConcurrentReadAt(t *testing.T) {
+	data := randomData(2*chunker.ChunkSize + 10)
+	m, fleet := newTestBlob(t, data, 6)
+	const delay = 400 * time.Millisecond
+	for _, f := range fleet {
+		f.setDelay(delay)
+	}
+
+	r, err := Open(m, DownloadConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// Two readers per chunk: each chunk is fetched once, both chunks at once
+	offsets := []int64{0, 10, int64(chunker.ChunkSize), int64(chunker.ChunkSize) + 10}
+	var wg sync.WaitGroup
+	start := time.Now()
+	for _, off := range offsets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, 100)
+			if _, err := r.ReadAt(buf, off); err != nil {
+				t.Errorf("ReadAt %d failed: %v", off, err)
+			} else if !bytes.Equal(buf, data[off:off+100]) {
+				t.Errorf("ReadAt %d returned wrong data", off)
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	if got := totalRequests(fleet); got != 2*chunker.DataShards {
+		t.Errorf("Expected %d shard fetches for two chunks, got %d", 2*chunker.DataShards, got)
+	}
+	if elapsed >= 2*delay {
+		t.Errorf("Expected chunks fetched in parallel, took %s", elapsed)
+	}
+	if stats := r.Stats(); stats.ChunksFetched != 2 {
+		t.Errorf("Expected 2 chunks fetched, got %d", stats.ChunksFetched)
+	}
+}
+
+func TestOpen_WrongKey(t *testing.T) {
+	data := randomData(100)
+	m, fleet := newTestBlob(t, data, 6)
+
+	wrong, _ := crypto.GenerateKey()
+	if _, err := Open(m, DownloadConfig{Key: wrong}); !errors.Is(err, manifest.ErrWrongKey) {
+		t.Errorf("Expected ErrWrongKey, got %v", err)
+	}
+	if totalRequests(fleet) != 0 {
+		t.Error("Expected a wrong key to be rejected before any fetch")
+	}
+
+	right, _ := m.GetEncryptionKey()
+	if _, err := Open(m, DownloadConfig{Key: right}); err != nil {
+		t.Errorf("Open with the right key failed: %v", err)
+	}
+}
+
+func TestBlobReader_Closed(t *testing.T) {
+	data := randomData(100)
+	m, _ := newTestBlob(t, data, 6)
+
+	r, err := Open(m, DownloadConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+
+	if _, err := r.ReadAt(make([]byte, 10), 0); err == nil {
+		t.Error("Expected error reading from closed reader")
+	}
+}
+
+func TestOpenSegmented_LoadsSegmentsLazily(t *testing.T) {
+	data := randomData(4*chunker.ChunkSize + 77)
+	m, _ := newTestBlob(t, data, 6)
+
+	root, segments, err := manifest.Segment(m, 2)
+	if err != nil {
+		t.Fatalf("Segment failed: %v", err)
+	}
+	if len(segments) != 3 {
+		t.Fatalf("Expected 3 segments, got %d", len(segments))
+	}
+	var loads []int
+	load := func(ref manifest.SegmentRef) (*manifest.Manifest, error) {
+		loads = append(loads, ref.Index)
+		return segments[ref.Index], nil
+	}
+
+	if _, err := Open(root, DownloadConfig{}); err == nil {
+		t.Error("Expected Open to refuse a segmented root")
+	}
+	r, err := OpenSegmented(root, load, DownloadConfig{})
+	if err != nil {
+		t.Fatalf("OpenSegmented failed: %v", err)
+	}
+	defer r.Close()
+	if len(loads) != 0 {
+		t.Errorf("Expected no segment loaded before the first read, got %v", loads)
+	}
+
+	// Reading the last chunk only touches the last segment
+	tail := make([]byte, 77)
+	if _, err := r.ReadAt(tail, int64(4*chunker.ChunkSize)); err != nil {
+		t.Fatalf("ReadAt failed: %v", err)
+	}
+	if !bytes.Equal(tail, data[4*chunker.ChunkSize:]) || len(loads) != 1 || loads[0] != 2 {
+		t.Errorf("Expected the tail from segment 2 alone, loaded %v", loads)
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("Read data doesn't match original")
+	}
+
+	// A segment that doesn't match its hash in the root is refused
+	tampered := *segments[0]
+	tampered.Farmers = append([]manifest.FarmerInfo(nil), tampered.Farmers...)
+	tampered.Farmers[0].Endpoint = "http://127.0.0.1:1"
+	segments[0] = &tampered
+	r2, err := OpenSegmented(root, load, DownloadConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r2.Close()
+	if _, err := r2.ReadAt(make([]byte, 10), 0); err == nil || !strings.Contains(err.Error(), "hash mismatch") {
+		t.Errorf("Expected hash mismatch for tampered segment, got %v", err)
+	}
+}
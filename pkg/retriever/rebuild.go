@@ -0,0 +1,232 @@
+package retriever
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/chunker"
+	"github.com/Abhinav-kodes/dbxn/pkg/crypto"
+	"github.com/Abhinav-kodes/dbxn/pkg/farmer"
+	"github.com/Abhinav-kodes/dbxn/pkg/manifest"
+)
+
+// maxTrailingZeros bounds how many zero bytes a ciphertext may end in before
+// RebuildManifest gives up guessing its length (each extra byte is 1/256 as likely)
+const maxTrailingZeros = 16
+
+// IncompleteRebuildError lists chunks RebuildManifest couldn't fully recover.
+// It is returned together with the best-effort manifest.
+type IncompleteRebuildError struct {
+	Chunks []int // chunk indices with fewer than DataShards shards found, or that failed to decrypt
+}
+
+func (e *IncompleteRebuildError) Error() string {
+	return fmt.Sprintf("manifest rebuilt with %d unrecoverable chunks: %v", len(e.Chunks), e.Chunks)
+}
+
+// RebuildManifest reassembles a lost manifest by asking every farmer which shards
+// it holds for blobID (farmer.BlobShardsHandler). Farmer indices follow endpoints.
+// This is a last resort: farmers aren't trusted beyond the hashes they report, and
+// what the manifest recorded about the file itself is gone.
+//
+// The encryption key cannot be recovered. Without cfg.Key the result only maps
+// shards to farmers: chunk sizes and hashes are unknown and nothing can be
+// downloaded with it. Re-supply the key in cfg.Key and every recoverable chunk is
+// fetched and decrypted once to restore its size and plaintext hash, giving a
+// manifest that downloads normally. Blobs stored with a ShardWrap layer or
+// ShardFEC are not supported.
+//
+// If some chunks have fewer than DataShards shards (or don't decrypt), the manifest
+// is returned along with an *IncompleteRebuildError listing them.
+func RebuildManifest(blobID string, endpoints []string, cfg DownloadConfig) (*manifest.Manifest, error) {
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("at least one farmer endpoint is required")
+	}
+
+	farmers := make([]manifest.FarmerInfo, len(endpoints))
+	for i, endpoint := range endpoints {
+		farmers[i] = manifest.FarmerInfo{Index: i, Endpoint: endpoint}
+	}
+	m := manifest.New("", 0, "", nil, nil, farmers, cfg.Key, "")
+	m.BlobID = blobID
+	if len(cfg.Key) == 0 {
+		m.KeyCommitment = ""
+	}
+
+	// First farmer to report a shard wins; a shard found twice is one shard
+	seen := make(map[[2]int]bool)
+	var errs []error
+	for i, endpoint := range endpoints {
+		stored, err := fetchBlobShards(endpoint, blobID, cfg.FarmerTokens)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", endpoint, err))
+			continue
+		}
+		for _, s := range stored {
+			key := [2]int{s.ChunkIndex, s.ShardIndex}
+			if s.ChunkIndex < 0 || s.ShardIndex < 0 || s.ShardIndex >= m.TotalShards || seen[key] {
+				continue
+			}
+			seen[key] = true
+			m.Shards = append(m.Shards, manifest.ShardMeta{
+				ChunkIndex:  s.ChunkIndex,
+				ShardIndex:  s.ShardIndex,
+				Hash:        s.Hash,
+				Size:        s.Size,
+				FarmerIndex: i,
+			})
+		}
+	}
+	if len(m.Shards) == 0 {
+		return nil, fmt.Errorf("no farmer reported shards for %s: %w", blobID, errors.Join(errs...))
+	}
+	sort.Slice(m.Shards, func(i, j int) bool {
+		a, b := m.Shards[i], m.Shards[j]
+		if a.ChunkIndex != b.ChunkIndex {
+			return a.ChunkIndex < b.ChunkIndex
+		}
+		return a.ShardIndex < b.ShardIndex
+	})
+
+	// Chunks are numbered densely; a gap is a chunk no farmer reported
+	m.ChunkCount = m.Shards[len(m.Shards)-1].ChunkIndex + 1
+	m.Chunks = make([]manifest.ChunkMeta, m.ChunkCount)
+	for i := range m.Chunks {
+		m.Chunks[i].Index = i
+	}
+	reachable := make(map[int]bool, len(farmers))
+	for i := range farmers {
+		reachable[i] = true
+	}
+	incomplete := m.UnrecoverableChunks(reachable)
+
+	if len(cfg.Key) > 0 {
+		incomplete = restoreChunkMeta(m, cfg, incomplete)
+	}
+
+	if len(incomplete) > 0 {
+		return m, &IncompleteRebuildError{Chunks: incomplete}
+	}
+	return m, nil
+}
+
+// restoreChunkMeta fetches and decrypts every recoverable chunk of a rebuilt
+// manifest to fill in its size and plaintext hash, and the file size and hash
+// when every chunk is recovered. Returns the updated list of unrecoverable chunks.
+func restoreChunkMeta(m *manifest.Manifest, cfg DownloadConfig, unrecoverable []int) []int {
+	skip := make(map[int]bool, len(unrecoverable))
+	for _, i := range unrecoverable {
+		skip[i] = true
+	}
+
+	fileHash := sha256.New()
+	for i := range m.Chunks {
+		if skip[i] {
+			continue
+		}
+		plaintext, paddedShard, err := recoverChunk(m, i, cfg)
+		if err != nil {
+			unrecoverable = append(unrecoverable, i)
+			continue
+		}
+		sum := sha256.Sum256(plaintext)
+		m.Chunks[i].Size = len(plaintext)
+		m.Chunks[i].EncryptedSize = len(plaintext) + m.ChunkOverhead()
+		m.Chunks[i].Hash = hex.EncodeToString(sum[:])
+		m.FileSize += int64(len(plaintext))
+		fileHash.Write(plaintext)
+		if paddedShard > 0 {
+			m.PaddedSize = chunker.ChunkSize + m.ChunkOverhead()
+			if chunker.ExpectedShardSize(m.PaddedSize, m.DataShards) != paddedShard {
+				// Padded to a smaller UploadConfig.ChunkSize
+				m.PaddedSize = paddedShard * m.DataShards
+			}
+		}
+	}
+
+	sort.Ints(unrecoverable)
+	if len(unrecoverable) == 0 {
+		m.OriginalFileHash = hex.EncodeToString(fileHash.Sum(nil))
+	}
+	return unrecoverable
+}
+
+// chunkFormats are the chunk encryption settings recoverChunk tries, plainest first
+var chunkFormats = []struct{ committed, aad bool }{
+	{false, false}, {true, false}, {false, true}, {true, true},
+}
+
+// recoverChunk reconstructs a chunk without knowing its size. Erasure coding pads
+// the ciphertext with zeros, so the true length is found by trimming them and
+// trying the few lengths the AEAD tag could end at, in each of chunkFormats. The
+// format that opens the chunk is left set in m; m is unchanged if none does. If the
+// shards were cut from a UniformShardSize-padded chunk, also returns their size.
+func recoverChunk(m *manifest.Manifest, chunkIndex int, cfg DownloadConfig) ([]byte, int, error) {
+	shards, err := fetchChunkShards(m, chunkIndex, cfg, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	shardSize := len(shards[0].Data)
+	full, err := chunker.ReconstructChunkWithOptions(shards, shardSize*m.DataShards, chunker.ReconstructOptions{
+		DataShards:   m.DataShards,
+		ParityShards: m.ParityShards,
+		TotalShards:  m.TotalShards,
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("chunk %d: %w", chunkIndex, err)
+	}
+
+	committed, aad := m.ChunkCommitment, m.ChunkAAD
+	trimmed := len(bytes.TrimRight(full, "\x00"))
+	for size := max(trimmed, crypto.Overhead); size <= min(trimmed+maxTrailingZeros, len(full)); size++ {
+		for _, format := range chunkFormats {
+			m.ChunkCommitment, m.ChunkAAD = format.committed, format.aad
+			plaintext, err := crypto.DecryptChunkWithOptions(full[:size], cfg.Key, m.ChunkOptions(chunkIndex))
+			if err != nil {
+				continue
+			}
+			if chunker.ExpectedShardSize(size, m.DataShards) == shardSize {
+				return plaintext, 0, nil
+			}
+			return plaintext, shardSize, nil
+		}
+	}
+	m.ChunkCommitment, m.ChunkAAD = committed, aad
+	return nil, 0, fmt.Errorf("chunk %d: no length decrypts under the given key", chunkIndex)
+}
+
+// fetchBlobShards asks one farmer which shards it stores for blobID
+func fetchBlobShards(endpoint, blobID string, tokens farmer.AuthTokens) ([]farmer.StoredShard, error) {
+	req, err := http.NewRequest(http.MethodGet, endpoint+farmer.BlobListPath+"/"+url.PathEscape(blobID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	tokens.Apply(req, endpoint)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("farmer returned %s", resp.Status)
+	}
+
+	var list farmer.BlobShardsResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxBlobListPage)).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to decode shard list: %w", err)
+	}
+	if list.BlobID != blobID {
+		return nil, fmt.Errorf("farmer listed shards of %s, asked for %s", list.BlobID, blobID)
+	}
+	return list.Shards, nil
+}
@@ -0,0 +1,141 @@
+package retriever
+
+import (
+	"context"
+	"slices"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/chunker"
+	"github.com/Abhinav-kodes/dbxn/pkg/manifest"
+	"github.com/Abhinav-kodes/dbxn/pkg/transport"
+)
+
+// repairSink returns how repaired shards reach endpoint: cfg.RepairSink first,
+// then the local directory a local endpoint names. nil means none.
+func repairSink(cfg DownloadConfig, endpoint string) transport.ShardSink {
+	if cfg.RepairSink != nil {
+		if sink := cfg.RepairSink(endpoint); sink != nil {
+			return sink
+		}
+	}
+	if dir, ok := manifest.LocalEndpointDir(endpoint); ok {
+		return transport.LocalDir{Dir: dir}
+	}
+	return nil
+}
+
+// repairChunk regenerates the damaged shards of a chunk from shards, the verified
+// set it was just reconstructed from, and stores each on its farmer, falling back
+// to farmers that hold no shard of the chunk. A shard is only stored if it
+// regenerates to the exact hash the manifest records. Outcomes go to stats; the
+// indices of the shards stored again are returned.
+func repairChunk(m *manifest.Manifest, chunkIndex int, shards []chunker.Shard, damaged []int, cfg DownloadConfig, stats *DownloadStats) []int {
+	if stats == nil {
+		stats = &DownloadStats{}
+	}
+	if m.ShardWrap != "" {
+		// Re-wrapping draws a fresh nonce, so the stored bytes would no longer match the manifest
+		stats.RepairsFailed += len(damaged)
+		return nil
+	}
+	regenerated, err := chunker.RegenerateShards(shards, damaged, m.DataShards, m.ParityShards)
+	if err != nil {
+		stats.RepairsFailed += len(damaged)
+		return nil
+	}
+
+	var repaired []int
+	for _, shard := range regenerated {
+		if m.ShardFEC != nil {
+			// Encoding is deterministic, so this is byte for byte what was stored
+			if shard, err = m.ShardFEC.EncodeShard(shard); err != nil {
+				stats.RepairsFailed++
+				continue
+			}
+		}
+		i := slices.IndexFunc(m.Shards, func(s manifest.ShardMeta) bool {
+			return s.ChunkIndex == chunkIndex && s.ShardIndex == shard.ShardIndex
+		})
+		if i < 0 || m.Shards[i].Hash != shard.Hash {
+			stats.RepairsFailed++
+			continue
+		}
+
+		farmerIdx, ok := storeRepairedShard(m, m.Shards[i], shard.Data, cfg)
+		if !ok {
+			stats.RepairsFailed++
+			continue
+		}
+		stats.ShardsRepaired++
+		repaired = append(repaired, shard.ShardIndex)
+		if farmerIdx != m.Shards[i].FarmerIndex {
+			m.Shards[i].FarmerIndex = farmerIdx
+			for c := range m.Chunks {
+				if m.Chunks[c].Index == chunkIndex {
+					m.Chunks[c].Regions = m.RegionSpread(chunkIndex)
+				}
+			}
+			stats.ShardsRelocated++
+		}
+	}
+	return repaired
+}
+
+// storeRepairedShard puts a regenerated shard on its assigned farmer, or else on
+// the first farmer holding no shard of its chunk whose use keeps the chunk within
+// m.MinRegions. Returns the farmer that stored it.
+func storeRepairedShard(m *manifest.Manifest, meta manifest.ShardMeta, data []byte, cfg DownloadConfig) (int, bool) {
+	holders := make(map[int]bool)
+	for _, s := range m.GetShardsForChunk(meta.ChunkIndex) {
+		if s.ShardIndex != meta.ShardIndex {
+			holders[s.FarmerIndex] = true
+		}
+	}
+	candidates := []int{meta.FarmerIndex}
+	for _, farmer := range m.Farmers {
+		if farmer.Index != meta.FarmerIndex && !holders[farmer.Index] {
+			candidates = append(candidates, farmer.Index)
+		}
+	}
+
+	addr := manifest.ShardAddress(m.BlobID, meta.ChunkIndex, meta.ShardIndex)
+	for _, farmerIdx := range candidates {
+		farmer := m.GetFarmerForShard(manifest.ShardMeta{FarmerIndex: farmerIdx})
+		if farmer == nil {
+			continue
+		}
+		if farmerIdx != meta.FarmerIndex && m.MinRegions > 0 && regionSpreadWith(m, meta, farmerIdx) < m.MinRegions {
+			continue
+		}
+		sink := repairSink(cfg, farmer.Endpoint)
+		if sink == nil {
+			continue
+		}
+
+		ctx, cancel := context.Background(), func() {}
+		if cfg.AttemptTimeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, cfg.AttemptTimeout)
+		}
+		stored := meta
+		stored.FarmerIndex = farmerIdx
+		err := sink.Put(ctx, addr, data, stored)
+		cancel()
+		if err == nil {
+			return farmerIdx, true
+		}
+	}
+	return 0, false
+}
+
+// regionSpreadWith is the region spread of meta's chunk if meta moved to farmerIdx
+func regionSpreadWith(m *manifest.Manifest, meta manifest.ShardMeta, farmerIdx int) int {
+	regions := make(map[string]bool)
+	for _, s := range m.GetShardsForChunk(meta.ChunkIndex) {
+		if s.ShardIndex == meta.ShardIndex {
+			s.FarmerIndex = farmerIdx
+		}
+		if farmer := m.GetFarmerForShard(s); farmer != nil && farmer.Region != "" {
+			regions[farmer.Region] = true
+		}
+	}
+	return len(regions)
+}
@@ -0,0 +1,228 @@
+package retriever
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/chunker"
+	"github.com/Abhinav-kodes/dbxn/pkg/crypto"
+	"github.com/Abhinav-kodes/dbxn/pkg/manifest"
+	"github.com/Abhinav-kodes/dbxn/pkg/transport"
+)
+
+// ReshardBlob moves a blob to a new erasure config, e.g. from 4+2 to 10+4, without
+// the original file. Each chunk's ciphertext is reconstructed from its current
+// shards, split into newData+newParity shards, and checked by reconstructing and
+// decrypting it from the new shards against the chunk hash before anything is
+// stored. The new shards are written through cfg.RepairSink (local endpoints need
+// none) to the manifest's farmers, never onto a farmer holding the same shard
+// address under the old config, so m stays readable until the returned manifest
+// replaces it. Blobs with a ShardWrap layer need cfg.ShardWrapKey to re-wrap.
+//
+// Placement is deterministic, so an interrupted run is resumed by running it
+// again on m with the same newData and newParity: shards a sink reports already stored (transport.ShardChecker) are
+// skipped. Wrapping draws fresh nonces, so wrapped shards are always rewritten.
+// m is not modified; old shards are left for the caller to delete.
+func ReshardBlob(m *manifest.Manifest, newData, newParity int, cfg DownloadConfig) (*manifest.Manifest, error) {
+	if err := chunker.ValidateErasureConfig(newData, newParity); err != nil {
+		return nil, err
+	}
+	newTotal := newData + newParity
+	if newTotal > len(m.Farmers) {
+		return nil, fmt.Errorf("%d shards per chunk need as many farmers, manifest lists %d", newTotal, len(m.Farmers))
+	}
+	if m.ShardWrap != "" && cfg.ShardWrapKey == nil {
+		return nil, fmt.Errorf("shards are wrapped with %s but no ShardWrapKey was given", m.ShardWrap)
+	}
+	key, err := resolveKey(m, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	out := *m
+	out.Chunks = slices.Clone(m.Chunks)
+	out.Shards = make([]manifest.ShardMeta, 0, len(m.Chunks)*newTotal)
+	out.DataShards = newData
+	out.ParityShards = newParity
+	out.TotalShards = newTotal
+
+	for _, chunk := range m.Chunks {
+		shards, err := reshardChunk(m, chunk, key, newData, newParity, cfg)
+		if err != nil {
+			return nil, err
+		}
+		placement, err := reshardPlacement(m, chunk.Index, newTotal)
+		if err != nil {
+			return nil, err
+		}
+		for _, shard := range shards {
+			meta := manifest.ShardMeta{
+				ChunkIndex:  chunk.Index,
+				ShardIndex:  shard.ShardIndex,
+				Hash:        shard.Hash,
+				Size:        shard.Size,
+				FarmerIndex: placement[shard.ShardIndex],
+			}
+			if err := storeReshardedShard(m, meta, shard.Data, cfg); err != nil {
+				return nil, fmt.Errorf("chunk %d shard %d: %w", chunk.Index, shard.ShardIndex, err)
+			}
+			out.Shards = append(out.Shards, meta)
+		}
+	}
+
+	for i := range out.Chunks {
+		out.Chunks[i].Regions = out.RegionSpread(out.Chunks[i].Index)
+	}
+	if err := out.ValidateShardSizes(); err != nil {
+		return nil, err
+	}
+	if err := out.Validate(); err != nil {
+		return nil, fmt.Errorf("resharded manifest is invalid: %w", err)
+	}
+	return &out, nil
+}
+
+// IncreaseRedundancy carries out a plan from m.PlanRedundancyIncrease: the
+// plan's new farmers are added to the farmer list and the blob is resharded to
+// the target parity across it (see ReshardBlob, whose cfg requirements apply).
+// Every shard is rewritten, not just the additional ones. m is not modified.
+func IncreaseRedundancy(m *manifest.Manifest, plan manifest.RedundancyPlan, cfg DownloadConfig) (*manifest.Manifest, error) {
+	if plan.BlobID != m.BlobID {
+		return nil, fmt.Errorf("plan is for blob %s, manifest is %s", plan.BlobID, m.BlobID)
+	}
+	for i, farmer := range plan.NewFarmers {
+		if farmer.Index != len(m.Farmers)+i {
+			return nil, fmt.Errorf("plan's farmer %s has index %d; the manifest's farmers changed since planning", farmer.Endpoint, farmer.Index)
+		}
+	}
+	grown := *m
+	grown.Farmers = plan.Farmers(m)
+	return ReshardBlob(&grown, plan.DataShards, plan.TargetParity, cfg)
+}
+
+// reshardChunk recovers a chunk's ciphertext from its current shards and splits
+// it into newData+newParity shards, wrapped and FEC-encoded as the blob's shards
+// are. The new set is verified by a reconstruct-and-decrypt round trip from the
+// shards that exercise parity the most (the last newData).
+func reshardChunk(m *manifest.Manifest, chunk manifest.ChunkMeta, key []byte, newData, newParity int, cfg DownloadConfig) ([]chunker.Shard, error) {
+	chunk, err := withEncryptedSize(m, chunk)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := fetchChunkShards(m, chunk.Index, cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	enc := m.ChunkOptions(chunk.Index)
+	encrypted, err := chunker.ReconstructChunkWithOptions(shards, chunk.EncryptedSize, chunker.ReconstructOptions{
+		PaddedSize:   m.PaddedSize,
+		DataShards:   m.DataShards,
+		ParityShards: m.ParityShards,
+		TotalShards:  m.TotalShards,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("chunk %d: %w", chunk.Index, err)
+	}
+
+	encoded := encrypted
+	if m.PaddedSize > 0 {
+		encoded = make([]byte, m.PaddedSize) // zero-filled tail is the padding
+		copy(encoded, encrypted)
+	}
+	newShards, err := chunker.ShardChunkWithConfig(chunker.Chunk{Index: chunk.Index, Size: len(encoded)}, encoded, newData, newParity)
+	if err != nil {
+		return nil, fmt.Errorf("chunk %d: %w", chunk.Index, err)
+	}
+
+	opts := chunker.ReconstructOptions{
+		PaddedSize:   m.PaddedSize,
+		DataShards:   newData,
+		ParityShards: newParity,
+		TotalShards:  newData + newParity,
+	}
+	if _, err := reconstructAndDecrypt(newShards[newParity:], chunk, key, m.ChunkHashDomain, enc, opts); err != nil {
+		return nil, fmt.Errorf("chunk %d: resharded shards don't round-trip: %w", chunk.Index, err)
+	}
+
+	if m.ShardWrap != "" {
+		for i := range newShards {
+			wrapped, err := crypto.EncryptChunkWith(crypto.Algorithm(m.ShardWrap), newShards[i].Data, cfg.ShardWrapKey)
+			if err != nil {
+				return nil, fmt.Errorf("chunk %d shard %d: failed to wrap: %w", chunk.Index, i, err)
+			}
+			sum := sha256.Sum256(wrapped)
+			newShards[i].Data = wrapped
+			newShards[i].Hash = hex.EncodeToString(sum[:])
+			newShards[i].Size = len(wrapped)
+		}
+	}
+	if m.ShardFEC != nil {
+		for i := range newShards {
+			if newShards[i], err = m.ShardFEC.EncodeShard(newShards[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return newShards, nil
+}
+
+// reshardPlacement assigns newTotal shards of a chunk to distinct farmers (by
+// FarmerInfo.Index), rotating by chunk index, and never puts shard s on the
+// farmer already holding the old shard s, whose bytes it would overwrite
+func reshardPlacement(m *manifest.Manifest, chunkIndex, newTotal int) ([]int, error) {
+	oldHolder := make(map[int]int)
+	for _, s := range m.GetShardsForChunk(chunkIndex) {
+		oldHolder[s.ShardIndex] = s.FarmerIndex
+	}
+
+	n := len(m.Farmers)
+	used := make(map[int]bool, newTotal)
+	placement := make([]int, newTotal)
+	for s := 0; s < newTotal; s++ {
+		placed := false
+		for step := 0; step < n && !placed; step++ {
+			farmerIdx := m.Farmers[(chunkIndex+s+1+step)%n].Index
+			if holder, ok := oldHolder[s]; used[farmerIdx] || (ok && holder == farmerIdx) {
+				continue
+			}
+			used[farmerIdx] = true
+			placement[s] = farmerIdx
+			placed = true
+		}
+		if !placed {
+			return nil, fmt.Errorf("chunk %d: no farmer left for shard %d", chunkIndex, s)
+		}
+	}
+	return placement, nil
+}
+
+// storeReshardedShard writes a new shard to its farmer unless the sink reports
+// it already stored (unwrapped shards only: those regenerate byte-identically)
+func storeReshardedShard(m *manifest.Manifest, meta manifest.ShardMeta, data []byte, cfg DownloadConfig) error {
+	farmer := m.GetFarmerForShard(meta)
+	if farmer == nil {
+		return fmt.Errorf("farmer index %d not in manifest", meta.FarmerIndex)
+	}
+	endpoint := farmer.Endpoint
+	sink := repairSink(cfg, endpoint)
+	if sink == nil {
+		return fmt.Errorf("no sink to store shards on %s (set DownloadConfig.RepairSink)", endpoint)
+	}
+
+	ctx, cancel := context.Background(), func() {}
+	if cfg.AttemptTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, cfg.AttemptTimeout)
+	}
+	defer cancel()
+
+	addr := manifest.ShardAddress(m.BlobID, meta.ChunkIndex, meta.ShardIndex)
+	if checker, ok := sink.(transport.ShardChecker); ok && m.ShardWrap == "" {
+		if stored, err := checker.Has(ctx, addr); err == nil && stored {
+			return nil
+		}
+	}
+	return sink.Put(ctx, addr, data, meta)
+}
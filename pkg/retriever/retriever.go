@@ -0,0 +1,517 @@
+package retriever
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/chunker"
+	"github.com/Abhinav-kodes/dbxn/pkg/crypto"
+	"github.com/Abhinav-kodes/dbxn/pkg/farmer"
+	"github.com/Abhinav-kodes/dbxn/pkg/manifest"
+	"github.com/Abhinav-kodes/dbxn/pkg/metrics"
+	"github.com/Abhinav-kodes/dbxn/pkg/transport"
+)
+
+// DownloadConfig holds configuration for retrieving a blob
+type DownloadConfig struct {
+	Key        []byte // Decryption key (default: manifest's EncryptionKey)
+	Passphrase []byte // Derives the key of manifests with a KDF when Key is not set
+	CacheSize  int    // Decrypted chunks kept in memory by BlobReader (default: 8)
+
+	// Parallelism fetches, reconstructs and decrypts this many chunks at once when
+	// downloading to a file (default 4); chunks are still written in order.
+	// MaxBufferedChunks caps chunks started but not yet written, bounding memory
+	// to about that many chunks (default 2*Parallelism, at least Parallelism).
+	// ReadRepair rewrites the manifest, so downloads with it run one chunk at a time.
+	Parallelism       int
+	MaxBufferedChunks int
+
+	FarmerTokens farmer.AuthTokens // Optional endpoint → Authorization header value (redacted when printed)
+	ShardWrapKey []byte            // Storage-layer key for manifests with ShardWrap set
+
+	// Overfetch requests this many shards beyond DataShards per chunk in parallel and
+	// reconstructs from whichever DataShards verify first, cancelling the rest.
+	// Trades bandwidth for tail latency; capped at the chunk's shard count.
+	// DownloadStats.OverfetchSaves counts the chunks it sped up.
+	Overfetch int
+
+	// MaxRetries retries a shard fetch that failed transiently (transport error,
+	// timeout, 5xx) up to this many times on the same farmer before moving on to
+	// another shard. Missing shards (4xx) and hash mismatches are never retried.
+	// AttemptTimeout bounds each attempt (0 = no per-attempt limit).
+	MaxRetries     int
+	AttemptTimeout time.Duration
+
+	// Metrics, if set, receives live shard download, per-farmer error and chunk
+	// reconstruction samples (metrics.ShardsDownloaded and friends)
+	Metrics metrics.Metrics
+
+	// ShardSources reads the listed farmer endpoints through a custom transport
+	// instead of HTTP (see package transport). Local endpoints need no entry.
+	ShardSources map[string]transport.ShardSource
+
+	// ReadRepair heals a blob as it is read: when a chunk is reconstructed despite
+	// shards that were missing or corrupt, those shards are regenerated from the
+	// rest and stored again on their farmer, or on a farmer not yet holding a
+	// shard of the chunk if that fails. A moved shard updates the manifest in
+	// place (DownloadStats.ShardsRelocated, see Stats); save it afterwards. Best-effort: a
+	// failed repair never fails the read. Blobs with a ShardWrap layer are not
+	// repaired.
+	ReadRepair bool
+
+	// RepairSink returns how repaired shards reach endpoint, or nil for none. It
+	// also stores the new shards of ReshardBlob. publisher.SinkFor builds one for
+	// HTTP farmers. Local endpoints need none.
+	RepairSink func(endpoint string) transport.ShardSink
+
+	// Stats, if set, has Download's and DownloadVersioned's counters added to it
+	// once they return, failed or not: check ShardsRelocated to know the manifest
+	// changed and needs saving. A BlobReader reports through Stats() instead.
+	Stats *DownloadStats
+
+	// TempDir is where scratch data that needn't sit next to its target goes
+	// (default: $TMPDIR), e.g. manifest.VerifyStatePath(TempDir, blobID). Download
+	// starts by removing stale scratch temp files from it, from the output's
+	// directory and from the blob's directories on local endpoints. Temp files
+	// renamed into place (the assembly sidecar, shards read repair writes to a
+	// local endpoint) stay beside their target so the rename is atomic.
+	TempDir string
+}
+
+// DownloadStats tracks retrieval progress
+type DownloadStats struct {
+	ChunksFetched   int // Chunks reconstructed and decrypted
+	ShardsFetched   int // Shards downloaded and verified
+	ShardsFailed    int // Shard fetches that errored or failed verification
+	OverfetchSaves  int // Chunks completed with an over-fetched shard instead of waiting on a slow or failed one
+	ShardAttempts   int // Shard requests sent, retries included
+	ShardRetries    int // Requests that retried a transient failure
+	ShardsCorrected int // Shards that failed verification but were corrected by their ShardFEC
+
+	// ReadRepair (see DownloadConfig.ReadRepair)
+	ShardsRepaired  int // Missing or corrupt shards stored again
+	ShardsRelocated int // Of those, shards stored on a replacement farmer (manifest updated)
+	RepairsFailed   int // Shards that couldn't be repaired
+}
+
+// add sums other's counters into s
+func (s *DownloadStats) add(other DownloadStats) {
+	s.ChunksFetched += other.ChunksFetched
+	s.ShardsFetched += other.ShardsFetched
+	s.ShardsFailed += other.ShardsFailed
+	s.OverfetchSaves += other.OverfetchSaves
+	s.ShardAttempts += other.ShardAttempts
+	s.ShardRetries += other.ShardRetries
+	s.ShardsCorrected += other.ShardsCorrected
+	s.ShardsRepaired += other.ShardsRepaired
+	s.ShardsRelocated += other.ShardsRelocated
+	s.RepairsFailed += other.RepairsFailed
+}
+
+// FileHashError reports a reconstructed blob whose whole-file hash doesn't match
+// the manifest's OriginalFileHash. Every chunk passed its own hash check, so the
+// chunks were stitched together wrongly or the manifest's chunk list is
+// inconsistent with the file it claims to describe: treat the data as corrupt.
+type FileHashError struct {
+	Expected string // OriginalFileHash recorded in the manifest
+	Got      string // SHA-256 of the reconstructed data
+}
+
+func (e *FileHashError) Error() string {
+	return fmt.Sprintf("reconstructed file hash %s doesn't match the manifest's %s: data may be corrupt despite passing per-chunk checks", e.Got, e.Expected)
+}
+
+// retryBackoff is the pause before the first retry, growing linearly per attempt
+const retryBackoff = 50 * time.Millisecond
+
+// maxShardResponse caps how much a farmer may send back for a single shard
+const maxShardResponse = 64 << 20 // 64MB
+
+// resolveKey returns the configured key or falls back to the manifest's key.
+// The key is checked against the manifest's commitment before anything is fetched.
+func resolveKey(m *manifest.Manifest, cfg DownloadConfig) ([]byte, error) {
+	key := cfg.Key
+	if len(key) == 0 && m.KDF != nil {
+		if len(cfg.Passphrase) == 0 {
+			return nil, fmt.Errorf("manifest key is derived from a passphrase (%s); set Passphrase or Key", m.KDF.Algorithm)
+		}
+		return m.KeyFromPassphrase(cfg.Passphrase)
+	}
+	if len(key) == 0 {
+		var err error
+		if key, err = m.GetEncryptionKey(); err != nil {
+			return nil, fmt.Errorf("invalid manifest encryption key: %w", err)
+		}
+	}
+	if err := m.CheckKey(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// HTTPSource fetches shards from an HTTP farmer, the default ShardSource for
+// endpoints without an entry in DownloadConfig.ShardSources
+type HTTPSource struct {
+	Endpoint string
+	Tokens   farmer.AuthTokens
+}
+
+// Get downloads a shard. Transport errors and 5xx responses are retryable.
+func (s HTTPSource) Get(ctx context.Context, addr string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, manifest.ShardURL(s.Endpoint, addr), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	s.Tokens.Apply(req, s.Endpoint)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, &transport.RetryableError{Err: fmt.Errorf("request failed: %w", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, &transport.RetryableError{Err: fmt.Errorf("farmer returned %s", resp.Status)}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("farmer returned %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxShardResponse))
+	if err != nil {
+		return nil, &transport.RetryableError{Err: fmt.Errorf("failed to read shard: %w", err)}
+	}
+	return data, nil
+}
+
+// shardSource returns how shards are read from endpoint: cfg.ShardSources first,
+// then the local directory a local endpoint names, then HTTP
+func shardSource(cfg DownloadConfig, endpoint string) transport.ShardSource {
+	if source, ok := cfg.ShardSources[endpoint]; ok {
+		return source
+	}
+	if dir, ok := manifest.LocalEndpointDir(endpoint); ok {
+		return transport.LocalDir{Dir: dir, MaxRead: maxShardResponse}
+	}
+	return HTTPSource{Endpoint: endpoint, Tokens: cfg.FarmerTokens}
+}
+
+// fetchShard reads a single shard through its farmer's ShardSource
+func fetchShard(ctx context.Context, fetch manifest.ShardFetch, cfg DownloadConfig) ([]byte, error) {
+	addr := manifest.ShardAddress(fetch.BlobID, fetch.ChunkIndex, fetch.ShardIndex)
+	return shardSource(cfg, fetch.Endpoint).Get(ctx, addr)
+}
+
+// fetchShardWithRetry fetches a shard, retrying transient failures up to
+// cfg.MaxRetries times, each attempt bounded by cfg.AttemptTimeout.
+// Returns the number of attempts made. Stops early once ctx is done.
+func fetchShardWithRetry(ctx context.Context, fetch manifest.ShardFetch, cfg DownloadConfig) ([]byte, int, error) {
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := ctx, func() {}
+		if cfg.AttemptTimeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, cfg.AttemptTimeout)
+		}
+		data, err := fetchShard(attemptCtx, fetch, cfg)
+		cancel()
+
+		if err == nil || !transport.IsRetryable(err) || attempt > cfg.MaxRetries || ctx.Err() != nil {
+			return data, attempt, err
+		}
+
+		select {
+		case <-time.After(time.Duration(attempt) * retryBackoff):
+		case <-ctx.Done():
+			return nil, attempt, err
+		}
+	}
+}
+
+// shardResult is the outcome of one shard fetch
+type shardResult struct {
+	order     int // position in the fetch order; >= DataShards means over-fetched
+	shard     chunker.Shard
+	attempts  int // requests made for this shard
+	err       error
+	damaged   bool // the farmer answered, but the shard is missing or corrupt
+	corrected bool // the shard was corrupt and ShardFEC corrected it
+}
+
+// fetchChunkShards downloads verified shards of a chunk until DataShards are collected.
+// DataShards+overfetch shards are requested in parallel, data shards first so a healthy
+// fleet needs no parity reconstruction. Each failure launches the next untried shard;
+// once enough shards verify, outstanding requests are cancelled. stats may be nil.
+func fetchChunkShards(m *manifest.Manifest, chunkIndex int, cfg DownloadConfig, stats *DownloadStats) ([]chunker.Shard, error) {
+	shards, _, err := fetchChunkShardSet(m, chunkIndex, cfg, stats)
+	return shards, err
+}
+
+// fetchChunkShardSet is fetchChunkShards that also returns the indices of shards
+// found missing or corrupt along the way. Transient failures aren't counted:
+// the shard may well be intact.
+func fetchChunkShardSet(m *manifest.Manifest, chunkIndex int, cfg DownloadConfig, stats *DownloadStats) ([]chunker.Shard, []int, error) {
+	plan, err := m.FetchPlanForChunk(chunkIndex)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if stats == nil {
+		stats = &DownloadStats{}
+	}
+	if m.ShardWrap != "" && cfg.ShardWrapKey == nil {
+		return nil, nil, fmt.Errorf("chunk %d: shards are wrapped with %s but no ShardWrapKey was given", chunkIndex, m.ShardWrap)
+	}
+	overfetch := max(cfg.Overfetch, 0)
+	want := m.DataShards
+	initial := min(want+overfetch, len(plan))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Buffered so abandoned fetches never block after we return
+	results := make(chan shardResult, len(plan))
+	launch := func(order int) {
+		fetch := plan[order]
+		go func() {
+			data, attempts, err := fetchShardWithRetry(ctx, fetch, cfg)
+			if err != nil {
+				results <- shardResult{order: order, attempts: attempts, damaged: !transport.IsRetryable(err) && ctx.Err() == nil,
+					err: fmt.Errorf("shard %d from %s: %w", fetch.ShardIndex, fetch.Endpoint, err)}
+				return
+			}
+			corrected := false
+			if m.ShardFEC != nil {
+				data, corrected, err = decodeShardFEC(m.ShardFEC, data, fetch.Hash)
+				if err != nil {
+					results <- shardResult{order: order, attempts: attempts, damaged: true, err: fmt.Errorf("shard %d from %s: %w", fetch.ShardIndex, fetch.Endpoint, err)}
+					return
+				}
+			} else if !chunker.VerifyShard(data, fetch.Hash) {
+				results <- shardResult{order: order, attempts: attempts, damaged: true, err: fmt.Errorf("shard %d from %s failed hash verification", fetch.ShardIndex, fetch.Endpoint)}
+				return
+			}
+
+			// Manifest hashes describe the stored (wrapped) bytes; peel the outer layer after
+			// verifying. The AEAD authenticates the inner shard, whose hash isn't recorded.
+			hash := fetch.Hash
+			if m.ShardFEC != nil {
+				sum := sha256.Sum256(data)
+				hash = hex.EncodeToString(sum[:])
+			}
+			if m.ShardWrap != "" {
+				data, err = crypto.DecryptChunkWith(crypto.Algorithm(m.ShardWrap), data, cfg.ShardWrapKey)
+				if err != nil {
+					results <- shardResult{order: order, attempts: attempts, err: fmt.Errorf("shard %d: failed to unwrap: %w", fetch.ShardIndex, err)}
+					return
+				}
+				sum := sha256.Sum256(data)
+				hash = hex.EncodeToString(sum[:])
+			}
+
+			results <- shardResult{order: order, attempts: attempts, corrected: corrected, shard: chunker.Shard{
+				ChunkIndex: chunkIndex,
+				ShardIndex: fetch.ShardIndex,
+				Data:       data,
+				Hash:       hash,
+				Size:       len(data),
+			}}
+		}()
+	}
+
+	next := 0
+	for ; next < initial; next++ {
+		launch(next)
+	}
+	inflight := initial
+
+	mtr := metrics.Or(cfg.Metrics)
+	var shards []chunker.Shard
+	var damaged []int
+	var lastErr error
+	usedExtra := false
+	for inflight > 0 && len(shards) < want {
+		res := <-results
+		inflight--
+		stats.ShardAttempts += res.attempts
+		stats.ShardRetries += max(res.attempts-1, 0)
+
+		if res.err != nil {
+			stats.ShardsFailed++
+			mtr.IncCounter(metrics.ShardDownloadErrors, metrics.Labels{metrics.FarmerLabel: plan[res.order].Endpoint}, 1)
+			lastErr = res.err
+			if res.damaged {
+				damaged = append(damaged, plan[res.order].ShardIndex)
+			}
+			if next < len(plan) {
+				launch(next)
+				next++
+				inflight++
+			}
+			continue
+		}
+
+		stats.ShardsFetched++
+		if res.corrected {
+			// Usable, but the stored copy is still corrupt: repair rewrites it
+			stats.ShardsCorrected++
+			damaged = append(damaged, plan[res.order].ShardIndex)
+		}
+		mtr.IncCounter(metrics.ShardsDownloaded, nil, 1)
+		mtr.IncCounter(metrics.BytesDownloaded, nil, float64(res.shard.Size))
+		shards = append(shards, res.shard)
+		if res.order >= want && res.order < initial {
+			usedExtra = true
+		}
+	}
+
+	if len(shards) < want {
+		return nil, nil, fmt.Errorf("chunk %d: only %d/%d shards available (last error: %v)", chunkIndex, len(shards), want, lastErr)
+	}
+	if usedExtra {
+		stats.OverfetchSaves++
+	}
+	return shards, damaged, nil
+}
+
+// decodeShardFEC checks a stored shard against its manifest hash and returns
+// the shard inside its ShardFEC encoding. A shard that fails the check is
+// corrected, then accepted only if it re-encodes to exactly the stored bytes the
+// hash describes. Reports whether a correction was needed.
+func decodeShardFEC(fec *chunker.ShardFEC, stored []byte, hash string) ([]byte, bool, error) {
+	intact := chunker.VerifyShard(stored, hash)
+	data, blocks, err := fec.Decode(stored)
+	switch {
+	case err != nil && intact:
+		return nil, false, fmt.Errorf("shard matches its hash but isn't valid shard FEC: %w", err)
+	case err != nil:
+		return nil, false, fmt.Errorf("failed hash verification and can't be corrected: %w", err)
+	case intact:
+		return data, false, nil
+	}
+	reencoded, err := fec.Encode(data)
+	if err != nil || !chunker.VerifyShard(reencoded, hash) {
+		return nil, false, fmt.Errorf("failed hash verification; correcting %d blocks didn't restore it", blocks)
+	}
+	return data, true, nil
+}
+
+// fetchChunk downloads, reconstructs and decrypts a single chunk. stats may be nil.
+func fetchChunk(m *manifest.Manifest, chunk manifest.ChunkMeta, key []byte, cfg DownloadConfig, stats *DownloadStats) ([]byte, error) {
+	chunk, err := withEncryptedSize(m, chunk)
+	if err != nil {
+		return nil, err
+	}
+	shards, damaged, err := fetchChunkShardSet(m, chunk.Index, cfg, stats)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	plaintext, err := reconstructAndDecrypt(shards, chunk, key, m.ChunkHashDomain, m.ChunkOptions(chunk.Index), chunker.ReconstructOptions{
+		PaddedSize:   m.PaddedSize,
+		DataShards:   m.DataShards,
+		ParityShards: m.ParityShards,
+		TotalShards:  m.TotalShards,
+	})
+	if err != nil {
+		return nil, err
+	}
+	mtr := metrics.Or(cfg.Metrics)
+	mtr.IncCounter(metrics.ChunksReconstructed, nil, 1)
+	mtr.ObserveHistogram(metrics.ReconstructSeconds, nil, time.Since(start).Seconds())
+
+	if stats != nil {
+		stats.ChunksFetched++
+	}
+	// The shard set just reconstructed a chunk that decrypted, so it can stand in
+	// for the shards that were missing or corrupt
+	if cfg.ReadRepair && len(damaged) > 0 {
+		repairChunk(m, chunk.Index, shards, damaged, cfg, stats)
+	}
+	return plaintext, nil
+}
+
+// ReconstructAndDecrypt recovers one chunk's plaintext from at least data verified
+// ciphertext shards: erasure-decode the ciphertext (plaintext size + crypto.Overhead),
+// decrypt it, and check the chunk against chunkMeta.Hash, which may be a plaintext
+// or ciphertext hash (see manifest.ChunkHashDomain).
+// Shards padded with UniformShardSize are detected by their length, whatever
+// chunk size the upload padded to. Chunks encrypted with a key commitment or associated data (manifest.ChunkCommitment,
+// manifest.ChunkAAD) aren't supported.
+func ReconstructAndDecrypt(shards []chunker.Shard, chunkMeta manifest.ChunkMeta, key []byte, data, parity int) ([]byte, error) {
+	opts := chunker.ReconstructOptions{DataShards: data, ParityShards: parity}
+
+	// Shards longer than the ciphertext needs were cut from padded data. The
+	// padding is a zero tail, so joining all the data shards and taking the
+	// ciphertext from the front decodes it whatever size it was padded to.
+	encryptedSize := chunkMeta.EncryptedSize
+	if encryptedSize == 0 {
+		encryptedSize = chunkMeta.Size + crypto.Overhead
+	}
+	if len(shards) > 0 && data > 0 && len(shards[0].Data) > chunker.ExpectedShardSize(encryptedSize, data) {
+		opts.PaddedSize = len(shards[0].Data) * data
+	}
+
+	return reconstructAndDecrypt(shards, chunkMeta, key, anyHashDomain, crypto.ChunkOptions{}, opts)
+}
+
+// withEncryptedSize fills in the encrypted size of a chunk from a manifest that
+// predates ChunkMeta.EncryptedSize (see manifest.ChunkEncryptedSize), and its
+// plaintext size if that wasn't recorded either
+func withEncryptedSize(m *manifest.Manifest, chunk manifest.ChunkMeta) (manifest.ChunkMeta, error) {
+	if chunk.EncryptedSize > 0 {
+		return chunk, nil
+	}
+	encryptedSize, err := m.ChunkEncryptedSize(chunk)
+	if err != nil {
+		return chunk, err
+	}
+	chunk.EncryptedSize = encryptedSize
+	if chunk.Size == 0 {
+		chunk.Size = encryptedSize - m.ChunkOverhead()
+	}
+	return chunk, nil
+}
+
+// anyHashDomain accepts a chunk hash matching either the ciphertext or the plaintext
+const anyHashDomain = "any"
+
+// reconstructAndDecrypt is ReconstructAndDecrypt with an explicit chunk hash domain,
+// chunk encryption options and reconstruction options
+func reconstructAndDecrypt(shards []chunker.Shard, chunk manifest.ChunkMeta, key []byte, hashDomain string, enc crypto.ChunkOptions, opts chunker.ReconstructOptions) ([]byte, error) {
+	// Shards encode the ciphertext. Manifests predating EncryptedSize imply it:
+	// plaintext size + nonce + tag (+ commitment)
+	encryptedSize := chunk.EncryptedSize
+	if encryptedSize == 0 {
+		encryptedSize = chunk.Size + enc.Overhead()
+	}
+	encrypted, err := chunker.ReconstructChunkWithOptions(shards, encryptedSize, opts)
+	if err != nil {
+		return nil, fmt.Errorf("chunk %d: %w", chunk.Index, err)
+	}
+
+	// A ciphertext hash is checked before spending time on decryption
+	ciphertextOK := hashDomain != manifest.ChunkHashPlaintext && hashDomain != "" && chunker.VerifyChunk(encrypted, chunk.Hash)
+	if hashDomain == manifest.ChunkHashCiphertext && !ciphertextOK {
+		return nil, fmt.Errorf("chunk %d: ciphertext hash mismatch", chunk.Index)
+	}
+
+	plaintext, err := crypto.DecryptChunkWithOptions(encrypted, key, enc)
+	if err != nil {
+		return nil, fmt.Errorf("chunk %d: %w", chunk.Index, err)
+	}
+	if len(plaintext) != chunk.Size {
+		return nil, fmt.Errorf("chunk %d: decrypted to %d bytes, manifest records %d", chunk.Index, len(plaintext), chunk.Size)
+	}
+
+	// Otherwise the manifest records the plaintext hash; confirm decryption produced the right bytes
+	if !ciphertextOK && !chunker.VerifyChunk(plaintext, chunk.Hash) {
+		return nil, fmt.Errorf("chunk %d: plaintext hash mismatch", chunk.Index)
+	}
+
+	return plaintext, nil
+}
@@ -0,0 +1,1505 @@
+package retriever
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/chunker"
+	"github.com/Abhinav-kodes/dbxn/pkg/crypto"
+	"github.com/Abhinav-kodes/dbxn/pkg/farmer"
+	"github.com/Abhinav-kodes/dbxn/pkg/manifest"
+	"github.com/Abhinav-kodes/dbxn/pkg/metrics"
+	"github.com/Abhinav-kodes/dbxn/pkg/scratch"
+	"github.com/Abhinav-kodes/dbxn/pkg/transport"
+)
+
+// ============================================================================
+// MOCK FARMER
+// ============================================================================
+
+// mockFarmer serves shards from memory over the farmer GET protocol
+type mockFarmer struct {
+	mu       sync.Mutex
+	shards   map[string][]byte // "blob/chunk/shard" → data
+	requests int               // GET requests served
+	down     bool              // simulate an unreachable farmer
+	delay    time.Duration     // simulate a slow farmer
+	failures int               // answer this many requests with 503 first
+	server   *httptest.Server
+}
+
+func newMockFarmer() *mockFarmer {
+	f := &mockFarmer{shards: make(map[string][]byte)}
+	f.server = httptest.NewServer(http.HandlerFunc(f.handle))
+	return f
+}
+
+func (f *mockFarmer) handle(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	delay := f.delay
+	f.mu.Unlock()
+
+	// Sleep outside the lock; give up early if the client cancels
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if strings.HasPrefix(r.URL.Path, farmer.BlobListPath+"/") {
+		farmer.BlobShardsHandler(f).ServeHTTP(w, r)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if r.Method != http.MethodGet || !strings.HasPrefix(r.URL.Path, "/shards/") {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	f.requests++
+	if f.failures > 0 {
+		f.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	data, ok := f.shards[strings.TrimPrefix(r.URL.Path, "/shards/")]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Write(data)
+}
+
+func (f *mockFarmer) put(blobID string, chunkIndex, shardIndex int, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.shards[manifest.ShardAddress(blobID, chunkIndex, shardIndex)] = data
+}
+
+// ListShards implements farmer.ShardLister over the stored shards
+func (f *mockFarmer) ListShards(blobID string) ([]farmer.StoredShard, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		return nil, fmt.Errorf("farmer down")
+	}
+
+	var shards []farmer.StoredShard
+	for addr, data := range f.shards {
+		id, chunkIndex, shardIndex, err := manifest.ParseShardAddress(addr)
+		if err != nil || id != blobID {
+			continue
+		}
+		sum := sha256.Sum256(data)
+		shards = append(shards, farmer.StoredShard{ChunkIndex: chunkIndex, ShardIndex: shardIndex, Hash: hex.EncodeToString(sum[:]), Size: len(data)})
+	}
+	return shards, nil
+}
+
+func (f *mockFarmer) setDown(down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.down = down
+}
+
+func (f *mockFarmer) setDelay(delay time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.delay = delay
+}
+
+func (f *mockFarmer) requestCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests
+}
+
+// totalRequests sums GET requests across a fleet
+func totalRequests(fleet []*mockFarmer) int {
+	total := 0
+	for _, f := range fleet {
+		total += f.requestCount()
+	}
+	return total
+}
+
+// newTestBlob chunks, encrypts and shards data onto a mock fleet of n farmers
+// (shard i of chunk c on farmer (c+i) % n) and returns the matching manifest
+func newTestBlob(t testing.TB, data []byte, n int) (*manifest.Manifest, []*mockFarmer) {
+	t.Helper()
+
+	fleet := make([]*mockFarmer, n)
+	farmers := make([]manifest.FarmerInfo, n)
+	for i := range fleet {
+		fleet[i] = newMockFarmer()
+		t.Cleanup(fleet[i].server.Close)
+		farmers[i] = manifest.FarmerInfo{Index: i, Endpoint: fleet[i].server.URL, Region: fmt.Sprintf("region-%d", i)}
+	}
+
+	testFile := fmt.Sprintf("test-blob-%d.bin", len(data))
+	if err := os.WriteFile(testFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testFile)
+
+	key, _ := crypto.GenerateKey()
+
+	var chunks []manifest.ChunkMeta
+	var metas []manifest.ShardMeta
+	var allShards []chunker.Shard
+	for result := range chunker.StreamChunkFile(testFile) {
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+		chunk := result.Chunk
+
+		encrypted, err := crypto.EncryptChunk(chunk.Data, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encChunk := chunk
+		encChunk.Size = len(encrypted)
+		shards, err := chunker.ShardChunk(encChunk, encrypted)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		chunks = append(chunks, manifest.ChunkMeta{Index: chunk.Index, Hash: chunk.Hash, Size: chunk.Size})
+		for _, s := range shards {
+			metas = append(metas, manifest.ShardMeta{
+				ChunkIndex:  s.ChunkIndex,
+				ShardIndex:  s.ShardIndex,
+				Hash:        s.Hash,
+				Size:        s.Size,
+				FarmerIndex: (s.ChunkIndex + s.ShardIndex) % n,
+			})
+		}
+		allShards = append(allShards, shards...)
+	}
+
+	fileHash, err := manifest.CalculateFileHash(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := manifest.New("blob.bin", int64(len(data)), fileHash, chunks, metas, farmers, key, "0xPub")
+
+	for _, s := range allShards {
+		fleet[(s.ChunkIndex+s.ShardIndex)%n].put(m.BlobID, s.ChunkIndex, s.ShardIndex, s.Data)
+	}
+
+	return m, fleet
+}
+
+// randomData returns n random bytes
+func randomData(n int) []byte {
+	data := make([]byte, n)
+	rand.Read(data)
+	return data
+}
+
+// ============================================================================
+// FETCH TESTS
+// ============================================================================
+
+func TestFetchChunk_ToleratesParityLoss(t *testing.T) {
+	data := randomData(chunker.ChunkSize + 500)
+	m, fleet := newTestBlob(t, data, 6)
+
+	// Two farmers down: every chunk still has 4 shards
+	fleet[0].setDown(true)
+	fleet[3].setDown(true)
+
+	key, _ := m.GetEncryptionKey()
+	for _, chunk := range m.Chunks {
+		plaintext, err := fetchChunk(m, chunk, key, DownloadConfig{}, nil)
+		if err != nil {
+			t.Fatalf("fetchChunk(%d) failed: %v", chunk.Index, err)
+		}
+		if !chunker.VerifyChunk(plaintext, chunk.Hash) {
+			t.Errorf("Chunk %d plaintext mismatch", chunk.Index)
+		}
+	}
+}
+
+func TestFetchChunk_ReportsMetrics(t *testing.T) {
+	m, fleet := newTestBlob(t, randomData(1000), 6)
+	fleet[0].setDown(true)
+
+	reg := metrics.NewRegistry()
+	key, _ := m.GetEncryptionKey()
+	if _, err := fetchChunk(m, m.Chunks[0], key, DownloadConfig{Metrics: reg}, nil); err != nil {
+		t.Fatalf("fetchChunk failed: %v", err)
+	}
+
+	if got := reg.Counter(metrics.ShardDownloadErrors, metrics.Labels{metrics.FarmerLabel: fleet[0].server.URL}); got != 1 {
+		t.Errorf("Expected 1 download error for the down farmer, got %v", got)
+	}
+	if got := reg.Counter(metrics.ShardsDownloaded, nil); got != float64(m.DataShards) {
+		t.Errorf("Expected %d shards downloaded, got %v", m.DataShards, got)
+	}
+	if got := reg.Counter(metrics.ChunksReconstructed, nil); got != 1 {
+		t.Errorf("Expected 1 chunk reconstructed, got %v", got)
+	}
+}
+
+func TestFetchChunk_TooManyFarmersDown(t *testing.T) {
+	data := randomData(1000)
+	m, fleet := newTestBlob(t, data, 6)
+
+	fleet[0].setDown(true)
+	fleet[1].setDown(true)
+	fleet[2].setDown(true)
+
+	key, _ := m.GetEncryptionKey()
+	if _, err := fetchChunk(m, m.Chunks[0], key, DownloadConfig{}, nil); err == nil {
+		t.Error("Expected error with only 3 shards reachable")
+	}
+}
+
+func TestFetchChunk_PlaintextHashMismatch(t *testing.T) {
+	data := randomData(1000)
+	m, _ := newTestBlob(t, data, 6)
+
+	// Shards are intact, but the manifest's plaintext hash is not
+	m.Chunks[0].Hash = strings.Repeat("0", 64)
+
+	key, _ := m.GetEncryptionKey()
+	_, err := fetchChunk(m, m.Chunks[0], key, DownloadConfig{}, nil)
+	if err == nil {
+		t.Fatal("Expected error for plaintext hash mismatch")
+	}
+	if !strings.Contains(err.Error(), "chunk 0") {
+		t.Errorf("Expected error to name the chunk, got %v", err)
+	}
+}
+
+func TestFetchChunk_OverfetchAvoidsSlowFarmer(t *testing.T) {
+	data := randomData(1000)
+	m, fleet := newTestBlob(t, data, 6)
+
+	// Farmer 0 holds data shard 0 of chunk 0 and is very slow
+	fleet[0].setDelay(5 * time.Second)
+
+	key, _ := m.GetEncryptionKey()
+	stats := &DownloadStats{}
+	start := time.Now()
+	plaintext, err := fetchChunk(m, m.Chunks[0], key, DownloadConfig{Overfetch: 1}, stats)
+	if err != nil {
+		t.Fatalf("fetchChunk failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected overfetch to skip the slow shard, took %s", elapsed)
+	}
+	if !chunker.VerifyChunk(plaintext, m.Chunks[0].Hash) {
+		t.Error("Plaintext mismatch")
+	}
+
+	if stats.OverfetchSaves != 1 {
+		t.Errorf("Expected 1 overfetch save, got %d", stats.OverfetchSaves)
+	}
+	if stats.ShardsFetched != chunker.DataShards || stats.ChunksFetched != 1 {
+		t.Errorf("Unexpected stats: %+v", *stats)
+	}
+}
+
+func TestFetchChunk_OverfetchCapped(t *testing.T) {
+	data := randomData(1000)
+	m, fleet := newTestBlob(t, data, 6)
+
+	key, _ := m.GetEncryptionKey()
+	stats := &DownloadStats{}
+	if _, err := fetchChunk(m, m.Chunks[0], key, DownloadConfig{Overfetch: 100}, stats); err != nil {
+		t.Fatalf("fetchChunk failed: %v", err)
+	}
+	if got := totalRequests(fleet); got > chunker.TotalShards {
+		t.Errorf("Expected at most %d requests, got %d", chunker.TotalShards, got)
+	}
+	if stats.ShardsFetched != chunker.DataShards {
+		t.Errorf("Expected %d shards used, got %d", chunker.DataShards, stats.ShardsFetched)
+	}
+}
+
+// ============================================================================
+// RECONSTRUCT AND DECRYPT TESTS
+// ============================================================================
+
+// encryptAndShard returns chunk metadata and shards for one plaintext chunk
+func encryptAndShard(t *testing.T, plaintext, key []byte, paddedSize int) (manifest.ChunkMeta, []chunker.Shard) {
+	t.Helper()
+
+	sum := sha256.Sum256(plaintext)
+	hash := hex.EncodeToString(sum[:])
+	encrypted, err := crypto.EncryptChunk(plaintext, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encChunk := chunker.Chunk{Index: 0, Data: encrypted, Size: len(encrypted)}
+
+	var shards []chunker.Shard
+	if paddedSize > 0 {
+		shards, err = chunker.ShardChunkPadded(encChunk, encrypted, paddedSize)
+	} else {
+		shards, err = chunker.ShardChunk(encChunk, encrypted)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return manifest.ChunkMeta{Index: 0, Hash: hash, Size: len(plaintext)}, shards
+}
+
+func TestReconstructAndDecrypt(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	plaintext := randomData(5000)
+
+	// Unpadded, padded to the default chunk size, and to a smaller UploadConfig.ChunkSize
+	for _, padded := range []int{0, chunker.ChunkSize + crypto.Overhead, 8*1024 + crypto.Overhead} {
+		meta, shards := encryptAndShard(t, plaintext, key, padded)
+
+		// Any DataShards shards will do
+		got, err := ReconstructAndDecrypt(shards[2:], meta, key, chunker.DataShards, chunker.ParityShards)
+		if err != nil {
+			t.Fatalf("padded=%d: ReconstructAndDecrypt failed: %v", padded, err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("padded=%d: plaintext mismatch", padded)
+		}
+	}
+}
+
+func TestReconstructAndDecrypt_EncryptedSize(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	plaintext := randomData(5000)
+	meta, shards := encryptAndShard(t, plaintext, key, 0)
+
+	// Shards are joined at EncryptedSize; Size checks the decrypted result
+	meta.EncryptedSize = len(plaintext) + crypto.Overhead
+	got, err := ReconstructAndDecrypt(shards, meta, key, chunker.DataShards, chunker.ParityShards)
+	if err != nil {
+		t.Fatalf("ReconstructAndDecrypt failed: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Error("Plaintext mismatch")
+	}
+
+	// Without a recorded size it's implied from Size
+	legacy := meta
+	legacy.EncryptedSize = 0
+	if _, err := ReconstructAndDecrypt(shards, legacy, key, chunker.DataShards, chunker.ParityShards); err != nil {
+		t.Errorf("Expected legacy chunk to reconstruct, got %v", err)
+	}
+
+	// A wrong encrypted size joins the wrong bytes
+	wrongJoin := meta
+	wrongJoin.EncryptedSize--
+	if _, err := ReconstructAndDecrypt(shards, wrongJoin, key, chunker.DataShards, chunker.ParityShards); err == nil {
+		t.Error("Expected error for wrong encrypted size")
+	}
+
+	// A plaintext size disagreeing with the decrypted length is rejected
+	wrongSize := meta
+	wrongSize.Size--
+	if _, err := ReconstructAndDecrypt(shards, wrongSize, key, chunker.DataShards, chunker.ParityShards); err == nil || !strings.Contains(err.Error(), "decrypted to") {
+		t.Errorf("Expected plaintext size mismatch, got %v", err)
+	}
+}
+
+func TestDownload_LegacyManifest(t *testing.T) {
+	data := randomData(2*chunker.ChunkSize + 777)
+	m, _ := newTestBlob(t, data, 6)
+
+	// Shaped like a manifest from before EncryptedSize: the size shards are
+	// joined at follows from the plaintext size and the shard sizes
+	path := "test-legacy-manifest.json"
+	defer os.Remove(path)
+	for i := range m.Chunks {
+		m.Chunks[i].EncryptedSize = 0
+	}
+	if err := m.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := manifest.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size, err := legacy.ChunkEncryptedSize(legacy.Chunks[2]); err != nil || size != 777+crypto.Overhead {
+		t.Errorf("Expected chunk 2 to infer %d bytes, got %d (%v)", 777+crypto.Overhead, size, err)
+	}
+
+	outputPath := "test-legacy-manifest.bin"
+	defer os.Remove(outputPath)
+	if err := Download(legacy, outputPath, DownloadConfig{}); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	got, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("Downloaded data doesn't match original")
+	}
+
+	// Shards that don't fit the implied size are reported, not joined wrongly
+	for i := range legacy.Shards {
+		if legacy.Shards[i].ChunkIndex == 1 {
+			legacy.Shards[i].Size++
+		}
+	}
+	if _, err := legacy.ChunkEncryptedSize(legacy.Chunks[1]); err == nil || !strings.Contains(err.Error(), "can't hold") {
+		t.Errorf("Expected shard size mismatch, got %v", err)
+	}
+}
+
+func TestReconstructAndDecrypt_Failures(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	meta, shards := encryptAndShard(t, randomData(5000), key, 0)
+
+	wrongKey, _ := crypto.GenerateKey()
+	if _, err := ReconstructAndDecrypt(shards, meta, wrongKey, chunker.DataShards, chunker.ParityShards); err == nil {
+		t.Error("Expected error with wrong key")
+	}
+
+	badMeta := meta
+	badMeta.Hash = strings.Repeat("0", 64)
+	if _, err := ReconstructAndDecrypt(shards, badMeta, key, chunker.DataShards, chunker.ParityShards); err == nil {
+		t.Error("Expected error on plaintext hash mismatch")
+	}
+
+	if _, err := ReconstructAndDecrypt(shards[:3], meta, key, chunker.DataShards, chunker.ParityShards); err == nil {
+		t.Error("Expected error with too few shards")
+	}
+}
+
+// ============================================================================
+// SHARD WRAP TESTS
+// ============================================================================
+
+func TestFetchChunk_UnwrapsShards(t *testing.T) {
+	data := randomData(chunker.ChunkSize + 10)
+	m, fleet := newTestBlob(t, data, 6)
+
+	// Re-store every shard under a storage-layer key, as a wrapping publisher would
+	wrapKey, _ := crypto.GenerateKey()
+	for i, meta := range m.Shards {
+		f := fleet[meta.FarmerIndex]
+		path := manifest.ShardAddress(m.BlobID, meta.ChunkIndex, meta.ShardIndex)
+		wrapped, err := crypto.EncryptChunk(f.shards[path], wrapKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.put(m.BlobID, meta.ChunkIndex, meta.ShardIndex, wrapped)
+		sum := sha256.Sum256(wrapped)
+		m.Shards[i].Hash = hex.EncodeToString(sum[:])
+		m.Shards[i].Size = len(wrapped)
+	}
+	m.ShardWrap = string(crypto.AlgXChaCha20Poly1305)
+
+	key, _ := m.GetEncryptionKey()
+	for _, chunk := range m.Chunks {
+		if _, err := fetchChunk(m, chunk, key, DownloadConfig{ShardWrapKey: wrapKey}, nil); err != nil {
+			t.Fatalf("fetchChunk(%d) with wrap key failed: %v", chunk.Index, err)
+		}
+	}
+
+	if _, err := fetchChunk(m, m.Chunks[0], key, DownloadConfig{}, nil); err == nil {
+		t.Error("Expected error without ShardWrapKey")
+	}
+	otherKey, _ := crypto.GenerateKey()
+	if _, err := fetchChunk(m, m.Chunks[0], key, DownloadConfig{ShardWrapKey: otherKey}, nil); err == nil {
+		t.Error("Expected error with wrong ShardWrapKey")
+	}
+}
+
+func TestFetchChunk_CommittedChunks(t *testing.T) {
+	m, fleet := newTestBlob(t, randomData(1000), 6)
+	key, _ := m.GetEncryptionKey()
+
+	// Re-encode the chunk with a key commitment, as a CommitChunks publisher would
+	chunk := m.Chunks[0]
+	plaintext, err := fetchChunk(m, chunk, key, DownloadConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := crypto.EncryptChunkCommitted(plaintext, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shards, err := chunker.ShardChunk(chunker.Chunk{Index: chunk.Index, Size: len(encrypted)}, encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range shards {
+		meta := &m.Shards[s.ShardIndex]
+		fleet[meta.FarmerIndex].put(m.BlobID, s.ChunkIndex, s.ShardIndex, s.Data)
+		meta.Hash, meta.Size = s.Hash, s.Size
+	}
+
+	if _, err := fetchChunk(m, chunk, key, DownloadConfig{}, nil); err == nil {
+		t.Error("Expected error for committed chunk in an uncommitted manifest")
+	}
+	m.ChunkCommitment = true
+	got, err := fetchChunk(m, chunk, key, DownloadConfig{}, nil)
+	if err != nil {
+		t.Fatalf("fetchChunk of committed chunk failed: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Error("Committed chunk plaintext mismatch")
+	}
+}
+
+func TestFetchChunk_AADBindsBlobID(t *testing.T) {
+	m, fleet := newTestBlob(t, randomData(1000), 6)
+	key, _ := m.GetEncryptionKey()
+
+	// Re-encode the chunk bound to its blob, as a BindChunkAAD publisher would
+	chunk := m.Chunks[0]
+	plaintext, err := fetchChunk(m, chunk, key, DownloadConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.ChunkAAD = true
+	encrypted, err := crypto.EncryptChunkWithOptions(plaintext, key, m.ChunkOptions(chunk.Index))
+	if err != nil {
+		t.Fatal(err)
+	}
+	shards, err := chunker.ShardChunk(chunker.Chunk{Index: chunk.Index, Size: len(encrypted)}, encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range shards {
+		meta := &m.Shards[s.ShardIndex]
+		fleet[meta.FarmerIndex].put(m.BlobID, s.ChunkIndex, s.ShardIndex, s.Data)
+		meta.Hash, meta.Size = s.Hash, s.Size
+	}
+
+	got, err := fetchChunk(m, chunk, key, DownloadConfig{}, nil)
+	if err != nil {
+		t.Fatalf("fetchChunk of bound chunk failed: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Error("Bound chunk plaintext mismatch")
+	}
+
+	// The same shards presented as another blob's chunk don't decrypt
+	rebound := *m
+	rebound.BlobID = "some-other-blob"
+	opts := chunker.ReconstructOptions{DataShards: m.DataShards, ParityShards: m.ParityShards, TotalShards: m.TotalShards}
+	if _, err := reconstructAndDecrypt(shards, chunk, key, m.ChunkHashDomain, rebound.ChunkOptions(chunk.Index), opts); err == nil {
+		t.Error("Expected error decrypting a chunk under another blob ID's associated data")
+	}
+	if _, err := reconstructAndDecrypt(shards, chunk, key, m.ChunkHashDomain, crypto.ChunkOptions{}, opts); err == nil {
+		t.Error("Expected error decrypting a bound chunk without associated data")
+	}
+}
+
+// ============================================================================
+// CHUNK CHAIN TESTS
+// ============================================================================
+
+// chainedManifest returns a manifest whose n chunks are linked through PrevHash
+func chainedManifest(n int) *manifest.Manifest {
+	chunks := make([]manifest.ChunkMeta, n)
+	prev := manifest.ChainGenesis
+	for i := range chunks {
+		sum := sha256.Sum256([]byte{byte(i)})
+		chunks[i] = manifest.ChunkMeta{Index: i, Hash: hex.EncodeToString(sum[:]), Size: 1, PrevHash: prev}
+		prev = manifest.ChainLink(prev, chunks[i].Hash)
+	}
+	return manifest.New("f.bin", int64(n), "h", chunks, nil, nil, make([]byte, 32), "0xPub")
+}
+
+func TestVerifyChunkChain(t *testing.T) {
+	if err := VerifyChunkChain(chainedManifest(4)); err != nil {
+		t.Fatalf("Valid chain rejected: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		tamper func(m *manifest.Manifest)
+	}{
+		{"reordered", func(m *manifest.Manifest) {
+			m.Chunks[1], m.Chunks[2] = m.Chunks[2], m.Chunks[1]
+			m.Chunks[1].Index, m.Chunks[2].Index = 1, 2
+		}},
+		{"dropped", func(m *manifest.Manifest) {
+			m.Chunks = append(m.Chunks[:1], m.Chunks[2:]...)
+			m.Chunks[1].Index = 1
+			m.ChunkCount--
+		}},
+		{"truncated", func(m *manifest.Manifest) { m.Chunks = m.Chunks[:3] }},
+		{"hash changed", func(m *manifest.Manifest) { m.Chunks[1].Hash = m.Chunks[0].Hash }},
+		{"unchained", func(m *manifest.Manifest) {
+			for i := range m.Chunks {
+				m.Chunks[i].PrevHash = ""
+			}
+		}},
+	}
+	for _, tt := range tests {
+		m := chainedManifest(4)
+		tt.tamper(m)
+		if err := VerifyChunkChain(m); err == nil {
+			t.Errorf("%s: expected chain verification to fail", tt.name)
+		}
+	}
+}
+
+// ============================================================================
+// DIRECTORY UNPACK TESTS
+// ============================================================================
+
+func TestUnpackDir_RejectsUnsafeEntries(t *testing.T) {
+	m, _ := newTestBlob(t, randomData(100), 6)
+
+	for _, entry := range []manifest.DirEntry{
+		{Path: "../escape.txt", Size: 10},
+		{Path: "/abs/path.txt", Size: 10},
+		{Path: "ok.txt", Offset: 50, Size: 51}, // runs past the blob
+	} {
+		m.Files = []manifest.DirEntry{entry}
+		dest := "test-unpack-unsafe"
+		if err := UnpackDir(context.Background(), m, dest, DownloadConfig{}); err == nil {
+			t.Errorf("Expected %+v to be rejected", entry)
+		}
+		if _, err := os.Stat(dest); !os.IsNotExist(err) {
+			t.Errorf("Nothing should be written for %+v", entry)
+			os.RemoveAll(dest)
+		}
+	}
+
+	m.Files = nil
+	if err := UnpackDir(context.Background(), m, "test-unpack-unsafe", DownloadConfig{}); err == nil {
+		t.Error("Expected error for single-file blob")
+	}
+}
+
+// ============================================================================
+// FARMER BLOB LIST TESTS
+// ============================================================================
+
+// blobList serves farmer.BlobLister from a sorted slice
+type blobList []string
+
+func (l blobList) ListBlobs(after string, limit int) ([]string, error) {
+	i := sort.SearchStrings(l, after)
+	if i < len(l) && l[i] == after {
+		i++
+	}
+	return l[i:min(i+limit, len(l))], nil
+}
+
+func TestListFarmerBlobs(t *testing.T) {
+	// More than two default pages
+	var ids blobList
+	for i := 0; i < 2*farmer.DefaultBlobListLimit+7; i++ {
+		ids = append(ids, fmt.Sprintf("0x%064x", i))
+	}
+	server := httptest.NewServer(farmer.RequireAuth("Bearer s3cret", farmer.BlobListHandler(ids)))
+	defer server.Close()
+
+	cfg := DownloadConfig{FarmerTokens: farmer.AuthTokens{server.URL: "Bearer s3cret"}}
+	got, err := ListFarmerBlobs(server.URL, cfg)
+	if err != nil {
+		t.Fatalf("ListFarmerBlobs failed: %v", err)
+	}
+	if len(got) != len(ids) {
+		t.Fatalf("Expected %d blob IDs, got %d", len(ids), len(got))
+	}
+	for i := range got {
+		if got[i] != ids[i] {
+			t.Fatalf("ID %d: expected %s, got %s", i, ids[i], got[i])
+		}
+	}
+
+	if _, err := ListFarmerBlobs(server.URL, DownloadConfig{}); err == nil {
+		t.Error("Expected error without credentials")
+	}
+
+	// A farmer repeating its cursor must not loop forever
+	stuck := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"blob_ids":["0xa"],"next":"0xa"}`)
+	}))
+	defer stuck.Close()
+	if _, err := ListFarmerBlobs(stuck.URL, DownloadConfig{}); err == nil {
+		t.Error("Expected error for a non-advancing cursor")
+	}
+}
+
+// ============================================================================
+// VERSIONED DOWNLOAD TESTS
+// ============================================================================
+
+func TestDownloadVersioned_Append(t *testing.T) {
+	data := randomData(2*chunker.ChunkSize + 500)
+	full, _ := newTestBlob(t, data, 6)
+
+	// v1 held the first two chunks; v2 appended the third and lists only it
+	v1 := *full
+	v1.Chunks = full.Chunks[:2]
+	v1.ChunkCount = 2
+	v1.FileSize = int64(2 * chunker.ChunkSize)
+	sum := sha256.Sum256(data[:2*chunker.ChunkSize])
+	v1.OriginalFileHash = hex.EncodeToString(sum[:])
+	v2 := *full
+	v2.Chunks = full.Chunks[2:]
+
+	outputPath := "test-versioned-output.bin"
+	defer os.Remove(outputPath)
+
+	if err := DownloadVersioned([]*manifest.Manifest{&v1, &v2}, outputPath, DownloadConfig{}); err != nil {
+		t.Fatalf("DownloadVersioned failed: %v", err)
+	}
+	got, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("Reconstructed data doesn't match")
+	}
+
+	// The old version alone is still a complete blob
+	if err := DownloadVersioned([]*manifest.Manifest{&v1}, outputPath, DownloadConfig{}); err != nil {
+		t.Fatalf("DownloadVersioned of v1 failed: %v", err)
+	}
+	if got, _ := os.ReadFile(outputPath); !bytes.Equal(got, data[:2*chunker.ChunkSize]) {
+		t.Error("v1 data doesn't match")
+	}
+
+	// v2 without its predecessor lacks chunks 0 and 1
+	os.Remove(outputPath)
+	if err := DownloadVersioned([]*manifest.Manifest{&v2}, outputPath, DownloadConfig{}); err == nil {
+		t.Error("Expected error for chain missing earlier chunks")
+	}
+	if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+		t.Error("Output should not be left after a failed download")
+	}
+
+	other := v1
+	other.BlobID = "0xother"
+	if err := DownloadVersioned([]*manifest.Manifest{&other, &v2}, outputPath, DownloadConfig{}); err == nil {
+		t.Error("Expected error for manifests of different blobs")
+	}
+}
+
+// ============================================================================
+// MANIFEST REBUILD TESTS
+// ============================================================================
+
+func TestRebuildManifest(t *testing.T) {
+	data := randomData(2*chunker.ChunkSize + 999)
+	original, fleet := newTestBlob(t, data, 6)
+	endpoints := make([]string, len(fleet))
+	for i, f := range fleet {
+		endpoints[i] = f.server.URL
+	}
+	key, _ := original.GetEncryptionKey()
+
+	// One farmer is gone: every chunk keeps five shards
+	fleet[5].setDown(true)
+
+	// Without the key: placement only
+	m, err := RebuildManifest(original.BlobID, endpoints, DownloadConfig{})
+	if err != nil {
+		t.Fatalf("RebuildManifest failed: %v", err)
+	}
+	if m.ChunkCount != 3 || len(m.Shards) != 3*(chunker.TotalShards-1) {
+		t.Errorf("Expected 3 chunks and %d shards, got %d and %d", 3*(chunker.TotalShards-1), m.ChunkCount, len(m.Shards))
+	}
+	if m.EncryptionKey != "" || m.KeyCommitment != "" || m.Chunks[0].Size != 0 {
+		t.Error("Rebuilt manifest without key should not claim key or chunk sizes")
+	}
+
+	// With the key re-supplied: a manifest that downloads
+	m, err = RebuildManifest(original.BlobID, endpoints, DownloadConfig{Key: key})
+	if err != nil {
+		t.Fatalf("RebuildManifest with key failed: %v", err)
+	}
+	if m.FileSize != original.FileSize || m.OriginalFileHash != original.OriginalFileHash {
+		t.Errorf("Expected size %d hash %s, got %d %s", original.FileSize, original.OriginalFileHash, m.FileSize, m.OriginalFileHash)
+	}
+	for i, chunk := range m.Chunks {
+		if chunk.Hash != original.Chunks[i].Hash || chunk.Size != original.Chunks[i].Size {
+			t.Errorf("Chunk %d metadata differs from the original", i)
+		}
+	}
+	r, err := Open(m, DownloadConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Download with rebuilt manifest failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("Downloaded data doesn't match")
+	}
+
+	// Chunk 1 loses two more shards: flagged, the rest still rebuilt
+	for _, f := range fleet[:2] {
+		f.mu.Lock()
+		for s := 0; s < chunker.TotalShards; s++ {
+			delete(f.shards, manifest.ShardAddress(original.BlobID, 1, s))
+		}
+		f.mu.Unlock()
+	}
+	m, err = RebuildManifest(original.BlobID, endpoints, DownloadConfig{Key: key})
+	var incomplete *IncompleteRebuildError
+	if !errors.As(err, &incomplete) || fmt.Sprint(incomplete.Chunks) != "[1]" {
+		t.Fatalf("Expected chunk 1 flagged, got %v", err)
+	}
+	if m == nil || m.Chunks[0].Size != chunker.ChunkSize || m.OriginalFileHash != "" {
+		t.Error("Expected a best-effort manifest without a file hash")
+	}
+
+	if _, err := RebuildManifest("0xunknown", endpoints, DownloadConfig{}); err == nil {
+		t.Error("Expected error for a blob no farmer holds")
+	}
+}
+
+// ============================================================================
+// SHARD FETCH RETRY TESTS
+// ============================================================================
+
+func TestFetchChunkShards_RetriesTransientFailures(t *testing.T) {
+	data := randomData(1000)
+	m, fleet := newTestBlob(t, data, 6)
+
+	// Farmer 0 holds data shard 0 of chunk 0 and fails twice before serving it
+	fleet[0].mu.Lock()
+	fleet[0].failures = 2
+	fleet[0].mu.Unlock()
+
+	stats := &DownloadStats{}
+	shards, err := fetchChunkShards(m, 0, DownloadConfig{MaxRetries: 2, AttemptTimeout: time.Second}, stats)
+	if err != nil {
+		t.Fatalf("fetchChunkShards failed: %v", err)
+	}
+	for _, s := range shards {
+		if s.ShardIndex >= chunker.DataShards {
+			t.Errorf("Expected data shards only after retrying, got shard %d", s.ShardIndex)
+		}
+	}
+	if stats.ShardRetries != 2 || stats.ShardAttempts != chunker.DataShards+2 || stats.ShardsFailed != 0 {
+		t.Errorf("Expected 2 retries in %d attempts and no failures, got %+v", chunker.DataShards+2, *stats)
+	}
+
+	// Without retries the failure falls through to a parity shard
+	fleet[0].mu.Lock()
+	fleet[0].failures = 1
+	fleet[0].mu.Unlock()
+	stats = &DownloadStats{}
+	if _, err := fetchChunkShards(m, 0, DownloadConfig{}, stats); err != nil {
+		t.Fatalf("fetchChunkShards failed: %v", err)
+	}
+	if stats.ShardRetries != 0 || stats.ShardsFailed != 1 {
+		t.Errorf("Expected one failure and no retries, got %+v", *stats)
+	}
+}
+
+func TestFetchChunkShards_NoRetryForMissingOrCorrupt(t *testing.T) {
+	data := randomData(1000)
+	m, fleet := newTestBlob(t, data, 6)
+
+	// Shard 0 is missing, shard 1 is corrupt
+	fleet[0].mu.Lock()
+	delete(fleet[0].shards, manifest.ShardAddress(m.BlobID, 0, 0))
+	fleet[0].mu.Unlock()
+	fleet[1].mu.Lock()
+	addr := manifest.ShardAddress(m.BlobID, 0, 1)
+	fleet[1].shards[addr] = append([]byte{0xFF}, fleet[1].shards[addr][1:]...)
+	fleet[1].mu.Unlock()
+
+	stats := &DownloadStats{}
+	if _, err := fetchChunkShards(m, 0, DownloadConfig{MaxRetries: 3}, stats); err != nil {
+		t.Fatalf("fetchChunkShards failed: %v", err)
+	}
+	if stats.ShardRetries != 0 {
+		t.Errorf("Expected no retries for missing or corrupt shards, got %d", stats.ShardRetries)
+	}
+	if fleet[0].requestCount() != 1 || fleet[1].requestCount() != 1 {
+		t.Errorf("Expected one request each, got %d and %d", fleet[0].requestCount(), fleet[1].requestCount())
+	}
+}
+
+// ============================================================================
+// READ REPAIR TESTS
+// ============================================================================
+
+// fleetSink stores repaired shards straight into a mock farmer
+type fleetSink struct {
+	f      *mockFarmer
+	refuse bool // fail every Put
+}
+
+func (s fleetSink) Put(ctx context.Context, addr string, data []byte, meta manifest.ShardMeta) error {
+	if s.refuse {
+		return errors.New("farmer refused shard")
+	}
+	blobID, chunkIndex, shardIndex, err := manifest.ParseShardAddress(addr)
+	if err != nil {
+		return err
+	}
+	s.f.put(blobID, chunkIndex, shardIndex, data)
+	return nil
+}
+
+// Has reports whether the mock farmer stores addr
+func (s fleetSink) Has(ctx context.Context, addr string) (bool, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	_, ok := s.f.shards[addr]
+	return ok, nil
+}
+
+func TestFetchChunk_ReadRepair(t *testing.T) {
+	data := randomData(1000)
+	m, fleet := newTestBlob(t, data, 7) // chunk 0 shard i on farmer i; farmer 6 holds nothing
+	key, _ := m.GetEncryptionKey()
+
+	// Shard 0 is missing; shard 1 is corrupt and its farmer refuses writes
+	fleet[0].mu.Lock()
+	delete(fleet[0].shards, manifest.ShardAddress(m.BlobID, 0, 0))
+	fleet[0].mu.Unlock()
+	fleet[1].mu.Lock()
+	addr := manifest.ShardAddress(m.BlobID, 0, 1)
+	fleet[1].shards[addr] = append([]byte{0xFF}, fleet[1].shards[addr][1:]...)
+	fleet[1].mu.Unlock()
+
+	byEndpoint := make(map[string]fleetSink)
+	for i, f := range fleet {
+		byEndpoint[f.server.URL] = fleetSink{f: f, refuse: i == 1}
+	}
+	cfg := DownloadConfig{
+		ReadRepair: true,
+		RepairSink: func(endpoint string) transport.ShardSink { return byEndpoint[endpoint] },
+	}
+
+	stats := &DownloadStats{}
+	got, err := fetchChunk(m, m.Chunks[0], key, cfg, stats)
+	if err != nil {
+		t.Fatalf("fetchChunk failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("Chunk data doesn't match original")
+	}
+	if stats.ShardsRepaired != 2 || stats.ShardsRelocated != 1 || stats.RepairsFailed != 0 {
+		t.Errorf("Expected 2 shards repaired, 1 relocated, got %+v", *stats)
+	}
+
+	for _, meta := range m.GetShardsForChunk(0) {
+		want := meta.ShardIndex
+		if meta.ShardIndex == 1 {
+			want = 6
+		}
+		if meta.FarmerIndex != want {
+			t.Errorf("Shard %d: expected farmer %d, got %d", meta.ShardIndex, want, meta.FarmerIndex)
+		}
+		f := fleet[meta.FarmerIndex]
+		f.mu.Lock()
+		stored := f.shards[manifest.ShardAddress(m.BlobID, 0, meta.ShardIndex)]
+		f.mu.Unlock()
+		if !chunker.VerifyShard(stored, meta.Hash) {
+			t.Errorf("Shard %d not healed on farmer %d", meta.ShardIndex, meta.FarmerIndex)
+		}
+	}
+
+	// Everything is healthy again: nothing left to repair
+	stats = &DownloadStats{}
+	if _, err := fetchChunk(m, m.Chunks[0], key, cfg, stats); err != nil {
+		t.Fatalf("fetchChunk after repair failed: %v", err)
+	}
+	if stats.ShardsFailed != 0 || stats.ShardsRepaired != 0 {
+		t.Errorf("Expected a clean read after repair, got %+v", *stats)
+	}
+}
+
+func TestFetchChunk_ShardFEC(t *testing.T) {
+	data := randomData(chunker.ChunkSize + 1000)
+	m, fleet := newTestBlob(t, data, 7) // chunk c shard i on farmer (c+i)%7
+
+	// Store every shard FEC-encoded, as an upload with ShardFEC would
+	m.ShardFEC = &chunker.ShardFEC{Blocks: 16, ParityBlocks: 1}
+	for i, meta := range m.Shards {
+		f := fleet[meta.FarmerIndex]
+		addr := manifest.ShardAddress(m.BlobID, meta.ChunkIndex, meta.ShardIndex)
+		encoded, err := m.ShardFEC.EncodeShard(chunker.Shard{Data: f.shards[addr]})
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.shards[addr] = encoded.Data
+		m.Shards[i].Hash, m.Shards[i].Size = encoded.Hash, encoded.Size
+	}
+
+	// Chunk 0: a flipped bit in shard 0 (correctable), two damaged blocks in shard 1 (not)
+	fleet[0].shards[manifest.ShardAddress(m.BlobID, 0, 0)][100] ^= 0x01
+	shard1 := fleet[1].shards[manifest.ShardAddress(m.BlobID, 0, 1)]
+	shard1[0] ^= 0x01
+	shard1[len(shard1)/2] ^= 0x01
+
+	byEndpoint := make(map[string]fleetSink)
+	for _, f := range fleet {
+		byEndpoint[f.server.URL] = fleetSink{f: f}
+	}
+	cfg := DownloadConfig{
+		ReadRepair: true,
+		RepairSink: func(endpoint string) transport.ShardSink { return byEndpoint[endpoint] },
+	}
+
+	r, err := Open(m, cfg)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("Data doesn't match original")
+	}
+	stats := r.Stats()
+	if stats.ShardsCorrected != 1 || stats.ShardsFailed != 1 {
+		t.Errorf("Expected 1 shard corrected and 1 failed, got %+v", stats)
+	}
+
+	// Read repair rewrote both: the corrected one and the lost one
+	report, err := CheckHealth(m, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if degraded := report.Degraded(); len(degraded) != 0 {
+		t.Errorf("Expected every chunk healthy after read repair, chunks %v degraded", degraded)
+	}
+}
+
+func TestRepairFromReport(t *testing.T) {
+	data := randomData(chunker.ChunkSize + 1000)
+	m, fleet := newTestBlob(t, data, 7) // chunk c shard i on farmer (c+i)%7
+
+	// Chunk 0: shard 2 is missing; shard 3 is corrupt and its farmer refuses writes
+	fleet[2].mu.Lock()
+	delete(fleet[2].shards, manifest.ShardAddress(m.BlobID, 0, 2))
+	fleet[2].mu.Unlock()
+	fleet[3].mu.Lock()
+	addr := manifest.ShardAddress(m.BlobID, 0, 3)
+	fleet[3].shards[addr] = append([]byte{0xFF}, fleet[3].shards[addr][1:]...)
+	fleet[3].mu.Unlock()
+
+	byEndpoint := make(map[string]fleetSink)
+	for i, f := range fleet {
+		byEndpoint[f.server.URL] = fleetSink{f: f, refuse: i == 3}
+	}
+	cfg := DownloadConfig{RepairSink: func(endpoint string) transport.ShardSink { return byEndpoint[endpoint] }}
+
+	report, err := CheckHealth(m, cfg)
+	if err != nil {
+		t.Fatalf("CheckHealth failed: %v", err)
+	}
+	if len(report.Chunks) != 2 || report.Chunks[0].State != ChunkDegraded || report.Chunks[1].State != ChunkHealthy {
+		t.Fatalf("Expected chunk 0 degraded and chunk 1 healthy, got %+v", report.Chunks)
+	}
+	if !slices.Equal(report.Chunks[0].Damaged, []int{2, 3}) {
+		t.Errorf("Expected shards 2 and 3 damaged, got %v", report.Chunks[0].Damaged)
+	}
+
+	repaired, err := RepairFromReport(m, report, cfg)
+	if err != nil {
+		t.Fatalf("RepairFromReport failed: %v", err)
+	}
+	if entry := report.Chunks[0]; entry.RepairErr != nil || !slices.Equal(entry.Repaired, []int{2, 3}) {
+		t.Errorf("Expected shards 2 and 3 repaired, got %v (%v)", entry.Repaired, entry.RepairErr)
+	}
+	if entry := report.Chunks[1]; entry.Repaired != nil || entry.RepairErr != nil {
+		t.Errorf("Healthy chunk 1 was touched: %+v", entry)
+	}
+
+	// Shard 3 moved off its refusing farmer, in the returned manifest only
+	for i, meta := range repaired.Shards {
+		if meta.ChunkIndex == 0 && meta.ShardIndex == 3 {
+			if meta.FarmerIndex == 3 {
+				t.Error("Expected shard 3 relocated off farmer 3")
+			}
+			if m.Shards[i].FarmerIndex != 3 {
+				t.Error("RepairFromReport modified the input manifest")
+			}
+		}
+	}
+
+	again, err := CheckHealth(repaired, cfg)
+	if err != nil {
+		t.Fatalf("CheckHealth after repair failed: %v", err)
+	}
+	if degraded := again.Degraded(); len(degraded) != 0 {
+		t.Errorf("Expected every chunk healthy after repair, chunks %v still degraded", degraded)
+	}
+
+	// A lost chunk is reported, not repaired
+	for _, f := range fleet[:4] {
+		f.setDown(true)
+	}
+	lost, err := CheckHealth(repaired, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lost.Chunks[0].State != ChunkLost {
+		t.Errorf("Expected chunk 0 lost with 4 farmers down, got %s", lost.Chunks[0].State)
+	}
+}
+
+// ============================================================================
+// PARALLEL DOWNLOAD TESTS
+// ============================================================================
+
+func TestDownload_ParallelKeepsOrder(t *testing.T) {
+	data := randomData(5*chunker.ChunkSize + 321)
+	m, fleet := newTestBlob(t, data, 6)
+	fleet[0].setDelay(20 * time.Millisecond) // chunks finish out of order
+
+	outputPath := "test-parallel-download.bin"
+	defer os.Remove(outputPath)
+	if err := Download(m, outputPath, DownloadConfig{Parallelism: 4, MaxBufferedChunks: 4}); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	got, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("Downloaded data doesn't match original")
+	}
+}
+
+func TestDownload_Stats(t *testing.T) {
+	data := randomData(2*chunker.ChunkSize + 321)
+	m, fleet := newTestBlob(t, data, 7) // chunk c shard i on farmer (c+i)%7
+	outputPath := "test-download-stats.bin"
+	defer os.Remove(outputPath)
+
+	// Worker counters are merged across parallel workers
+	var stats DownloadStats
+	if err := Download(m, outputPath, DownloadConfig{Parallelism: 3, Stats: &stats}); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if stats.ChunksFetched != 3 || stats.ShardsFetched != 3*chunker.DataShards {
+		t.Errorf("Expected 3 chunks from %d shards, got %+v", 3*chunker.DataShards, stats)
+	}
+
+	// Chunk 0 shard 0 is missing and its farmer refuses writes: read repair moves it
+	fleet[0].mu.Lock()
+	delete(fleet[0].shards, manifest.ShardAddress(m.BlobID, 0, 0))
+	fleet[0].mu.Unlock()
+	byEndpoint := make(map[string]fleetSink)
+	for i, f := range fleet {
+		byEndpoint[f.server.URL] = fleetSink{f: f, refuse: i == 0}
+	}
+	stats = DownloadStats{}
+	cfg := DownloadConfig{
+		ReadRepair: true,
+		RepairSink: func(endpoint string) transport.ShardSink { return byEndpoint[endpoint] },
+		Stats:      &stats,
+	}
+	if err := Download(m, outputPath, cfg); err != nil {
+		t.Fatalf("Download with read repair failed: %v", err)
+	}
+	if stats.ShardsFailed != 1 || stats.ShardsRepaired != 1 || stats.ShardsRelocated != 1 {
+		t.Errorf("Expected the relocation reported, got %+v", stats)
+	}
+}
+
+func TestDownload_SweepsStaleTempFiles(t *testing.T) {
+	data := randomData(3000)
+	m, _ := newTestBlob(t, data, 6)
+	outDir := t.TempDir()
+	tempDir := t.TempDir()
+
+	// Leftovers of crashed runs in TempDir and next to the output
+	old := time.Now().Add(-2 * scratch.StaleAge)
+	var stale []string
+	for _, d := range []string{tempDir, outDir} {
+		f, err := scratch.Create(d, "assembly-*")
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		os.Chtimes(f.Name(), old, old)
+		stale = append(stale, f.Name())
+	}
+
+	if err := Download(m, filepath.Join(outDir, "out.bin"), DownloadConfig{TempDir: tempDir}); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	for _, path := range stale {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected stale temp file %s removed at startup", path)
+		}
+	}
+}
+
+func TestVerifyFileHash(t *testing.T) {
+	data := randomData(3*chunker.ChunkSize + 99)
+	m, fleet := newTestBlob(t, data, 6)
+
+	// Chunks rebuilt from parity still add up to the recorded file
+	fleet[1].setDown(true)
+	if err := VerifyFileHash(m, DownloadConfig{}); err != nil {
+		t.Fatalf("VerifyFileHash failed: %v", err)
+	}
+
+	// Chunks that each verify but don't make up the recorded file are flagged
+	recorded := m.OriginalFileHash
+	m.OriginalFileHash = strings.Repeat("0", 64)
+	var hashErr *FileHashError
+	if err := VerifyFileHash(m, DownloadConfig{}); !errors.As(err, &hashErr) || hashErr.Got != recorded {
+		t.Errorf("Expected FileHashError with the real hash, got %v", err)
+	}
+	outputPath := "test-file-hash.bin"
+	defer os.Remove(outputPath)
+	if err := Download(m, outputPath, DownloadConfig{}); !errors.As(err, &hashErr) {
+		t.Errorf("Expected Download to fail with FileHashError, got %v", err)
+	}
+
+	// A sequential read to the end is checked too; random access isn't
+	r, err := Open(m, DownloadConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := r.ReadAt(make([]byte, 10), int64(len(data)-10)); err != nil {
+		t.Errorf("ReadAt failed: %v", err)
+	}
+	if _, err := io.ReadAll(r); !errors.As(err, &hashErr) {
+		t.Errorf("Expected ReadAll to fail with FileHashError, got %v", err)
+	}
+
+	m.OriginalFileHash = ""
+	if err := VerifyFileHash(m, DownloadConfig{}); err == nil {
+		t.Error("Expected error for a manifest without a file hash")
+	}
+}
+
+func TestFetchChunksOrdered_BoundsBufferedChunks(t *testing.T) {
+	const n, window = 20, 3
+	var mu sync.Mutex
+	outstanding, peak := 0, 0
+
+	fetch := func(i int, stats *DownloadStats) ([]byte, error) {
+		mu.Lock()
+		outstanding++
+		peak = max(peak, outstanding)
+		mu.Unlock()
+		if i%4 == 0 {
+			time.Sleep(5 * time.Millisecond) // hold back the chunk the writer waits for
+		}
+		return []byte{byte(i)}, nil
+	}
+	var order []int
+	emit := func(i int, data []byte) error {
+		mu.Lock()
+		outstanding--
+		mu.Unlock()
+		order = append(order, int(data[0]))
+		return nil
+	}
+
+	if err := fetchChunksOrdered(n, DownloadConfig{Parallelism: 3, MaxBufferedChunks: window}, fetch, emit); err != nil {
+		t.Fatal(err)
+	}
+	for i, got := range order {
+		if got != i {
+			t.Fatalf("Chunk %d emitted at position %d: %v", got, i, order)
+		}
+	}
+	if len(order) != n {
+		t.Errorf("Expected %d chunks, got %d", n, len(order))
+	}
+	if peak > window {
+		t.Errorf("Expected at most %d chunks buffered, saw %d", window, peak)
+	}
+
+	// The first error stops the download
+	failing := func(i int, stats *DownloadStats) ([]byte, error) {
+		if i == 7 {
+			return nil, errors.New("chunk 7 unavailable")
+		}
+		return []byte{byte(i)}, nil
+	}
+	emitted := 0
+	err := fetchChunksOrdered(n, DownloadConfig{Parallelism: 3}, failing, func(int, []byte) error { emitted++; return nil })
+	if err == nil || emitted > 7 {
+		t.Errorf("Expected failure before chunk 7 was written, got %v after %d chunks", err, emitted)
+	}
+}
+
+// benchmarkDownload downloads a 32-chunk blob from an in-process fleet. Compare
+// ns/op (and CPU time with -cpuprofile) across parallelism levels on a multi-core machine.
+func benchmarkDownload(b *testing.B, parallelism int) {
+	data := randomData(32 * chunker.ChunkSize)
+	m, _ := newTestBlob(b, data, 6)
+	outputPath := fmt.Sprintf("test-bench-download-%d.bin", parallelism)
+	defer os.Remove(outputPath)
+
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := Download(m, outputPath, DownloadConfig{Parallelism: parallelism}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDownload_Sequential(b *testing.B) {
+	benchmarkDownload(b, 1)
+}
+
+func BenchmarkDownload_Parallel4(b *testing.B) {
+	benchmarkDownload(b, 4)
+}
+
+// ============================================================================
+// RESHARD TESTS
+// ============================================================================
+
+func TestReshardBlob(t *testing.T) {
+	data := randomData(2*chunker.ChunkSize + 777)
+	m, fleet := newTestBlob(t, data, 7)
+
+	sinks := func(refuse bool) func(string) transport.ShardSink {
+		byEndpoint := make(map[string]transport.ShardSink)
+		for _, f := range fleet {
+			byEndpoint[f.server.URL] = fleetSink{f: f, refuse: refuse}
+		}
+		return func(endpoint string) transport.ShardSink { return byEndpoint[endpoint] }
+	}
+
+	resharded, err := ReshardBlob(m, 4, 3, DownloadConfig{RepairSink: sinks(false)})
+	if err != nil {
+		t.Fatalf("ReshardBlob failed: %v", err)
+	}
+	if resharded.DataShards != 4 || resharded.ParityShards != 3 || resharded.TotalShards != 7 {
+		t.Errorf("Expected 4+3 erasure config, got %d+%d (%d)", resharded.DataShards, resharded.ParityShards, resharded.TotalShards)
+	}
+	if len(resharded.Shards) != 7*len(m.Chunks) {
+		t.Errorf("Expected %d shards, got %d", 7*len(m.Chunks), len(resharded.Shards))
+	}
+	if m.ParityShards != 2 || len(m.Shards) != 6*len(m.Chunks) {
+		t.Error("ReshardBlob modified the original manifest")
+	}
+
+	// Both versions read back: the old shards weren't overwritten
+	for _, version := range []*manifest.Manifest{m, resharded} {
+		reader, err := Open(version, DownloadConfig{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatalf("Read of %d+%d blob failed: %v", version.DataShards, version.ParityShards, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("%d+%d blob doesn't match original", version.DataShards, version.ParityShards)
+		}
+	}
+
+	// The new config survives losing three farmers
+	for _, f := range fleet[:3] {
+		f.setDown(true)
+	}
+	reader, err := Open(resharded, DownloadConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := io.ReadAll(reader); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("Expected 4+3 blob to survive three lost farmers, got %v", err)
+	}
+	reader.Close()
+	for _, f := range fleet[:3] {
+		f.setDown(false)
+	}
+
+	// Rerunning finds every shard stored and writes nothing
+	again, err := ReshardBlob(m, 4, 3, DownloadConfig{RepairSink: sinks(true)})
+	if err != nil {
+		t.Fatalf("Resumed ReshardBlob failed: %v", err)
+	}
+	if !slices.Equal(again.Shards, resharded.Shards) {
+		t.Error("Resumed reshard produced different shards")
+	}
+
+	if _, err := ReshardBlob(m, 6, 3, DownloadConfig{RepairSink: sinks(false)}); err == nil {
+		t.Error("Expected error when the fleet is smaller than the new shard count")
+	}
+}
+
+func TestIncreaseRedundancy(t *testing.T) {
+	data := randomData(chunker.ChunkSize + 321)
+	m, fleet := newTestBlob(t, data, 6)
+
+	var pool []manifest.FarmerInfo
+	for i := 0; i < 2; i++ {
+		f := newMockFarmer()
+		t.Cleanup(f.server.Close)
+		fleet = append(fleet, f)
+		pool = append(pool, manifest.FarmerInfo{Endpoint: f.server.URL, Region: fmt.Sprintf("new-region-%d", i)})
+	}
+	sinks := make(map[string]transport.ShardSink)
+	for _, f := range fleet {
+		sinks[f.server.URL] = fleetSink{f: f}
+	}
+	cfg := DownloadConfig{RepairSink: func(endpoint string) transport.ShardSink { return sinks[endpoint] }}
+
+	plan, err := m.PlanRedundancyIncrease(4, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	grown, err := IncreaseRedundancy(m, plan, cfg)
+	if err != nil {
+		t.Fatalf("IncreaseRedundancy failed: %v", err)
+	}
+	if grown.ParityShards != 4 || len(grown.Farmers) != 8 {
+		t.Errorf("Expected 4 parity shards over 8 farmers, got %d over %d", grown.ParityShards, len(grown.Farmers))
+	}
+
+	// Four farmers down, the blob still reads
+	for _, f := range fleet[:4] {
+		f.setDown(true)
+	}
+	reader, err := Open(grown, DownloadConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if got, err := io.ReadAll(reader); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("Expected the 4+4 blob to survive four lost farmers, got %v", err)
+	}
+
+	// A plan made against another farmer list is refused
+	stale := plan
+	stale.NewFarmers = []manifest.FarmerInfo{{Index: 9, Endpoint: pool[0].Endpoint}}
+	if _, err := IncreaseRedundancy(m, stale, cfg); err == nil {
+		t.Error("Expected a stale plan to be refused")
+	}
+}
@@ -0,0 +1,169 @@
+package retriever
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/manifest"
+)
+
+// versionedChunk is a chunk resolved to the manifest version holding it
+type versionedChunk struct {
+	m    *manifest.Manifest
+	meta manifest.ChunkMeta
+}
+
+// DownloadVersioned reconstructs a blob described by a chain of manifest versions,
+// ordered oldest to newest, into outputPath. The newest manifest sets the blob's
+// size and chunk count; each chunk is fetched through the newest manifest that
+// lists it, so a version only needs to list the chunks it appended or replaced.
+// Every version must share the BlobID and chunk size. cfg.Key, if set, must open
+// every version; otherwise each version's own key is used.
+// On failure outputPath is removed.
+func DownloadVersioned(manifests []*manifest.Manifest, outputPath string, cfg DownloadConfig) error {
+	chunks, err := resolveVersions(manifests)
+	if err != nil {
+		return err
+	}
+	latest := manifests[len(manifests)-1]
+	sweepStaleTemp(latest, outputPath, cfg)
+
+	keys := make(map[*manifest.Manifest][]byte, len(manifests))
+	for i, m := range manifests {
+		if err := m.ValidateShardSizes(); err != nil {
+			return fmt.Errorf("manifest %d: %w", i, err)
+		}
+		if keys[m], err = resolveKey(m, cfg); err != nil {
+			return fmt.Errorf("manifest %d: %w", i, err)
+		}
+	}
+
+	fileHash, err := latest.DecryptedOriginalFileHash(keys[latest])
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", outputPath, err)
+	}
+	err = writeVersionedChunks(out, chunks, keys, fileHash, cfg)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(outputPath)
+		return err
+	}
+	return nil
+}
+
+// resolveVersions validates a manifest chain and picks, for every chunk of the
+// newest version, the newest manifest listing it
+func resolveVersions(manifests []*manifest.Manifest) ([]versionedChunk, error) {
+	if len(manifests) == 0 {
+		return nil, fmt.Errorf("at least one manifest is required")
+	}
+	for i, m := range manifests {
+		if m == nil {
+			return nil, fmt.Errorf("manifest %d is nil", i)
+		}
+		if m.IsSegmented() {
+			return nil, fmt.Errorf("manifest %d is segmented; read it with OpenSegmented", i)
+		}
+		if m.Draft {
+			return nil, fmt.Errorf("manifest %d is an upload draft; finish it with publisher.ResumeUpload", i)
+		}
+		if m.BlobID != manifests[0].BlobID {
+			return nil, fmt.Errorf("manifest %d belongs to blob %s, chain is for %s", i, m.BlobID, manifests[0].BlobID)
+		}
+		if m.ChunkSize != manifests[0].ChunkSize {
+			return nil, fmt.Errorf("manifest %d has chunk size %d, chain uses %d", i, m.ChunkSize, manifests[0].ChunkSize)
+		}
+	}
+	latest := manifests[len(manifests)-1]
+
+	byIndex := make(map[int]versionedChunk)
+	for _, m := range manifests {
+		for _, meta := range m.Chunks {
+			byIndex[meta.Index] = versionedChunk{m: m, meta: meta}
+		}
+	}
+
+	// Chunks must tile the blob exactly: full chunks, then the remainder
+	chunks := make([]versionedChunk, latest.ChunkCount)
+	var total int64
+	for i := range chunks {
+		c, ok := byIndex[i]
+		if !ok {
+			return nil, fmt.Errorf("chunk %d is not listed by any manifest version", i)
+		}
+		if i < len(chunks)-1 && c.meta.Size != latest.ChunkSize {
+			return nil, fmt.Errorf("chunk %d has size %d, only the last chunk may be shorter than %d", i, c.meta.Size, latest.ChunkSize)
+		}
+		chunks[i] = c
+		total += int64(c.meta.Size)
+	}
+	if total != latest.FileSize {
+		return nil, fmt.Errorf("chunks add up to %d bytes, newest manifest records %d", total, latest.FileSize)
+	}
+	return chunks, nil
+}
+
+// VerifyFileHash reconstructs every chunk of m, as Download would, and checks the
+// result against m.OriginalFileHash without writing it anywhere: an end-to-end
+// check that the blob is retrievable and intact. The hash is computed as chunks
+// are emitted in order, so memory stays bounded like a download's. Returns a
+// *FileHashError on mismatch.
+func VerifyFileHash(m *manifest.Manifest, cfg DownloadConfig) error {
+	if m != nil && m.OriginalFileHash == "" {
+		return fmt.Errorf("manifest %s records no file hash", m.BlobID)
+	}
+	manifests := []*manifest.Manifest{m}
+	chunks, err := resolveVersions(manifests)
+	if err != nil {
+		return err
+	}
+	if err := m.ValidateShardSizes(); err != nil {
+		return err
+	}
+	key, err := resolveKey(m, cfg)
+	if err != nil {
+		return err
+	}
+	fileHash, err := m.DecryptedOriginalFileHash(key)
+	if err != nil {
+		return err
+	}
+	return writeVersionedChunks(io.Discard, chunks, map[*manifest.Manifest][]byte{m: key}, fileHash, cfg)
+}
+
+// writeVersionedChunks fetches chunks into w in order, cfg.Parallelism at a time,
+// hashing them on the way so the whole-file hash, if recorded, is checked
+// without reading the output back
+func writeVersionedChunks(w io.Writer, chunks []versionedChunk, keys map[*manifest.Manifest][]byte, fileHash string, cfg DownloadConfig) error {
+	hasher := sha256.New()
+	out := io.MultiWriter(w, hasher)
+
+	fetch := func(i int, stats *DownloadStats) ([]byte, error) {
+		c := chunks[i]
+		return fetchChunk(c.m, c.meta, keys[c.m], cfg, stats)
+	}
+	err := fetchChunksOrdered(len(chunks), cfg, fetch, func(i int, data []byte) error {
+		if _, err := out.Write(data); err != nil {
+			return fmt.Errorf("failed to write chunk %d: %w", chunks[i].meta.Index, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if got := hex.EncodeToString(hasher.Sum(nil)); fileHash != "" && got != fileHash {
+		return &FileHashError{Expected: fileHash, Got: got}
+	}
+	return nil
+}
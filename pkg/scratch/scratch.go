@@ -0,0 +1,86 @@
+package scratch
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Prefix marks every temp file created through this package, so leftovers from
+// crashed runs can be found and removed later
+const Prefix = "dbxn-"
+
+// StaleAge is how old a leftover temp file must be before CleanupStale removes it.
+// Younger files may belong to an operation still running in another process.
+const StaleAge = time.Hour
+
+// Dir returns the directory for temp files: dir if set, otherwise os.TempDir()
+// (which respects $TMPDIR). Point dir at a dedicated scratch volume when the
+// default temp dir is too small.
+func Dir(dir string) string {
+	if dir != "" {
+		return dir
+	}
+	return os.TempDir()
+}
+
+// Create makes a new prefixed temp file in Dir(dir).
+// pattern follows os.CreateTemp ("*" is replaced by a random string).
+func Create(dir, pattern string) (*os.File, error) {
+	f, err := os.CreateTemp(Dir(dir), Prefix+pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	return f, nil
+}
+
+// CleanupStale removes prefixed temp files in Dir(dir) last modified more than
+// maxAge ago. Returns the number of files removed.
+func CleanupStale(dir string, maxAge time.Duration) (int, error) {
+	dir = Dir(dir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read temp dir: %w", err)
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	removed := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), Prefix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err == nil {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// CleanupStaleTree is CleanupStale for dir and every directory below it, for
+// stores that write their temp files beside files nested in subdirectories.
+// A missing dir removes nothing.
+func CleanupStaleTree(dir string, maxAge time.Duration) (int, error) {
+	removed := 0
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return filepath.SkipAll
+			}
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		n, err := CleanupStale(path, maxAge)
+		removed += n
+		return err
+	})
+	return removed, err
+}
@@ -0,0 +1,100 @@
+package scratch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// ============================================================================
+// TEMP DIR TESTS
+// ============================================================================
+
+func TestDir_RespectsTMPDIR(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	if got := Dir(""); got != tmp {
+		t.Errorf("Expected $TMPDIR %s, got %s", tmp, got)
+	}
+	if got := Dir("/mnt/scratch"); got != "/mnt/scratch" {
+		t.Errorf("Expected explicit dir to win, got %s", got)
+	}
+}
+
+func TestCleanupStale(t *testing.T) {
+	dir := t.TempDir()
+
+	stale, err := Create(dir, "old-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.Close()
+	old := time.Now().Add(-2 * StaleAge)
+	os.Chtimes(stale.Name(), old, old)
+
+	fresh, err := Create(dir, "new-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh.Close()
+
+	// Not ours, even though it's old
+	foreign := filepath.Join(dir, "other.tmp")
+	os.WriteFile(foreign, []byte("x"), 0644)
+	os.Chtimes(foreign, old, old)
+
+	removed, err := CleanupStale(dir, StaleAge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 {
+		t.Errorf("Expected 1 file removed, got %d", removed)
+	}
+	if _, err := os.Stat(stale.Name()); !os.IsNotExist(err) {
+		t.Error("Expected stale temp file removed")
+	}
+	if _, err := os.Stat(fresh.Name()); err != nil {
+		t.Error("Fresh temp file must be kept")
+	}
+	if _, err := os.Stat(foreign); err != nil {
+		t.Error("Unprefixed file must be kept")
+	}
+}
+
+func TestCleanupStaleTree(t *testing.T) {
+	dir := t.TempDir()
+	nested := filepath.Join(dir, "blob", "0")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * StaleAge)
+	var stale []string
+	for _, d := range []string{dir, nested} {
+		f, err := Create(d, "local-*")
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		os.Chtimes(f.Name(), old, old)
+		stale = append(stale, f.Name())
+	}
+
+	removed, err := CleanupStaleTree(dir, StaleAge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 {
+		t.Errorf("Expected 2 files removed, got %d", removed)
+	}
+	for _, path := range stale {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s removed", path)
+		}
+	}
+
+	if removed, err := CleanupStaleTree(filepath.Join(dir, "missing"), StaleAge); err != nil || removed != 0 {
+		t.Errorf("Expected a missing dir to remove nothing, got %d, %v", removed, err)
+	}
+}
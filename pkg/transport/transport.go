@@ -0,0 +1,188 @@
+// Package transport is the seam between the shard pipeline and where shards are
+// stored. The publisher writes every shard through a ShardSink and the retriever
+// reads it back through a ShardSource, one per farmer endpoint. HTTP farmers and
+// local directories (manifest.LocalEndpoint) are built in; any other transport
+// (gRPC, S3, in-memory for tests) is plugged in per endpoint through
+// UploadConfig.ShardSinks and DownloadConfig.ShardSources.
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/chunker"
+	"github.com/Abhinav-kodes/dbxn/pkg/manifest"
+	"github.com/Abhinav-kodes/dbxn/pkg/scratch"
+)
+
+// ShardSink stores shards on one farmer. addr is the shard's manifest.ShardAddress;
+// meta carries its indices, hash and size. Put must only return nil once the
+// exact bytes are stored: a sink that can't confirm that reports an error.
+// Sinks should report what they actually transmit with AddWireBytes; for those
+// that don't, a stored shard is assumed to have cost its own size.
+type ShardSink interface {
+	Put(ctx context.Context, addr string, data []byte, meta manifest.ShardMeta) error
+}
+
+// ShardSource reads shards back from one farmer by manifest.ShardAddress.
+// Callers verify the returned bytes against the manifest hash.
+type ShardSource interface {
+	Get(ctx context.Context, addr string) ([]byte, error)
+}
+
+// ShardChecker is implemented by sinks that can tell whether a shard is already
+// stored without transferring it, so resumed uploads skip it. Sinks without it
+// are assumed to hold nothing.
+type ShardChecker interface {
+	Has(ctx context.Context, addr string) (bool, error)
+}
+
+// RetryableError marks a failure worth retrying on the same farmer (timeouts,
+// dropped connections, server errors). Sources wrap transient failures in it;
+// anything else, such as a missing shard, moves the retriever on to another shard.
+type RetryableError struct {
+	Err error
+}
+
+func (e *RetryableError) Error() string { return e.Err.Error() }
+func (e *RetryableError) Unwrap() error { return e.Err }
+
+// IsRetryable reports whether err is or wraps a RetryableError
+func IsRetryable(err error) bool {
+	var retryable *RetryableError
+	return errors.As(err, &retryable)
+}
+
+// wireCounterKey is the context key of the counter AddWireBytes adds to
+type wireCounterKey struct{}
+
+// WithWireCounter returns ctx carrying counter, to which sinks add the bytes
+// they transmit while putting a shard under it
+func WithWireCounter(ctx context.Context, counter *atomic.Int64) context.Context {
+	return context.WithValue(ctx, wireCounterKey{}, counter)
+}
+
+// AddWireBytes records n bytes sent for the shard being put under ctx, after any
+// encoding (base64, JSON, compression) and for every request and retry. It does
+// nothing if ctx carries no counter.
+func AddWireBytes(ctx context.Context, n int) {
+	if counter, ok := ctx.Value(wireCounterKey{}).(*atomic.Int64); ok {
+		counter.Add(int64(n))
+	}
+}
+
+// LocalDir stores shards as files under a directory, at manifest.LocalShardPath.
+// It is both a sink and a source, and backs local endpoints.
+type LocalDir struct {
+	Dir     string
+	MaxRead int64 // largest shard Get will read (0 = no limit)
+}
+
+// Put writes the shard atomically (temp file + rename) after checking it
+// against meta.Hash, so a crash never leaves a partial shard at its path
+func (d LocalDir) Put(ctx context.Context, addr string, data []byte, meta manifest.ShardMeta) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !chunker.VerifyShard(data, meta.Hash) {
+		return fmt.Errorf("shard data does not match hash %s", meta.Hash)
+	}
+	path, err := d.path(addr)
+	if err != nil {
+		return err
+	}
+	if err := WriteFileAtomic(path, data); err != nil {
+		return err
+	}
+	AddWireBytes(ctx, len(data))
+	return nil
+}
+
+// Get reads a shard; a missing one is reported with an error wrapping os.ErrNotExist
+func (d LocalDir) Get(ctx context.Context, addr string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	path, err := d.path(addr)
+	if err != nil {
+		return nil, err
+	}
+	return ReadFileLimited(path, d.MaxRead)
+}
+
+// Has reports whether the shard's file exists
+func (d LocalDir) Has(ctx context.Context, addr string) (bool, error) {
+	path, err := d.path(addr)
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// path resolves a shard address to its file
+func (d LocalDir) path(addr string) (string, error) {
+	blobID, chunkIndex, shardIndex, err := manifest.ParseShardAddress(addr)
+	if err != nil {
+		return "", err
+	}
+	return manifest.LocalShardPath(d.Dir, blobID, chunkIndex, shardIndex)
+}
+
+// WriteFileAtomic writes data to path through a temp file in the same directory,
+// creating the directory if needed
+func WriteFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	tmp, err := scratch.Create(filepath.Dir(path), "local-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// ReadFileLimited reads a file, refusing more than limit bytes (0 = no limit).
+// Read failures after opening are retryable.
+func ReadFileLimited(path string, limit int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if limit > 0 {
+		r = io.LimitReader(f, limit+1)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, &RetryableError{fmt.Errorf("failed to read %s: %w", path, err)}
+	}
+	if limit > 0 && int64(len(data)) > limit {
+		return nil, fmt.Errorf("%s exceeds %d bytes", path, limit)
+	}
+	return data, nil
+}
@@ -0,0 +1,94 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/Abhinav-kodes/dbxn/pkg/manifest"
+)
+
+// ============================================================================
+// LOCAL DIRECTORY TRANSPORT TESTS
+// ============================================================================
+
+func hashOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestLocalDir_RoundTrip(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "test-transport-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	store := LocalDir{Dir: dir}
+	data := []byte("shard bytes")
+	meta := manifest.ShardMeta{ChunkIndex: 2, ShardIndex: 5, Hash: hashOf(data), Size: len(data)}
+	addr := manifest.ShardAddress("blob123", 2, 5)
+
+	if has, err := store.Has(ctx, addr); err != nil || has {
+		t.Errorf("Expected shard to be absent before Put, got %v, %v", has, err)
+	}
+	if _, err := store.Get(ctx, addr); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected ErrNotExist for missing shard, got %v", err)
+	}
+
+	if err := store.Put(ctx, addr, data, meta); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if has, err := store.Has(ctx, addr); err != nil || !has {
+		t.Errorf("Expected shard to be present after Put, got %v, %v", has, err)
+	}
+	got, err := store.Get(ctx, addr)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("Read shard doesn't match written shard")
+	}
+
+	if _, err := (LocalDir{Dir: dir, MaxRead: 4}).Get(ctx, addr); err == nil {
+		t.Error("Expected oversized shard to be refused")
+	}
+}
+
+func TestLocalDir_RejectsHashMismatch(t *testing.T) {
+	dir, err := os.MkdirTemp(".", "test-transport-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	store := LocalDir{Dir: dir}
+	addr := manifest.ShardAddress("blob123", 0, 0)
+	meta := manifest.ShardMeta{Hash: hashOf([]byte("expected")), Size: 8}
+
+	if err := store.Put(ctx, addr, []byte("tampered"), meta); err == nil {
+		t.Error("Expected Put to reject data not matching its hash")
+	}
+	if has, _ := store.Has(ctx, addr); has {
+		t.Error("Rejected shard was stored")
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	base := errors.New("connection reset")
+	if !IsRetryable(&RetryableError{base}) {
+		t.Error("Expected RetryableError to be retryable")
+	}
+	if !IsRetryable(errors.Join(errors.New("fetch"), &RetryableError{base})) {
+		t.Error("Expected wrapped RetryableError to be retryable")
+	}
+	if IsRetryable(base) {
+		t.Error("Expected plain error not to be retryable")
+	}
+}